@@ -0,0 +1,77 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type AccountIdCmd struct {
+	Target  string `kong:"arg,optional,help='Role alias, ARN or <AccountId>/<RoleName>',predictor='alias'"`
+	Arn     string `kong:"short='a',help='ARN of role',predictor='arn'"`
+	Alias   string `kong:"help='Role alias from the Aliases config',predictor='alias'"`
+	Profile string `kong:"short='p',help='Name of AWS Profile',predictor='profile'"`
+}
+
+// Run prints only the 12 digit AccountId of the role.  Returns an error if
+// the role is unknown.
+func (cc *AccountIdCmd) Run(ctx *RunContext) error {
+	var accountId int64
+	var role string
+	var err error
+	cache := ctx.Settings.Cache.GetSSO()
+
+	switch {
+	case ctx.Cli.AccountId.Profile != "":
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.AccountId.Profile, ctx.Settings)
+		if err != nil {
+			return err
+		}
+		accountId, role = rFlat.AccountId, rFlat.RoleName
+	case ctx.Cli.AccountId.Alias != "":
+		accountId, role, err = ctx.Settings.ResolveAlias(ctx.Cli.AccountId.Alias)
+	case ctx.Cli.AccountId.Arn != "":
+		accountId, role, err = utils.ParseRoleARN(ctx.Cli.AccountId.Arn)
+	case ctx.Cli.AccountId.Target != "":
+		if _, ok := ctx.Settings.Aliases[ctx.Cli.AccountId.Target]; ok {
+			accountId, role, err = ctx.Settings.ResolveAlias(ctx.Cli.AccountId.Target)
+		} else {
+			accountId, role, err = sso.ParseRoleTarget(ctx.Cli.AccountId.Target)
+		}
+	default:
+		return fmt.Errorf("Please specify --profile, --alias, --arn or a role")
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = cache.Roles.GetRole(accountId, role); err != nil {
+		return fmt.Errorf("Unable to resolve %s: %s", utils.MakeRoleARN(accountId, role), err.Error())
+	}
+
+	aId, err := utils.AccountIdToString(accountId)
+	if err != nil {
+		return err
+	}
+	fmt.Println(aId)
+	return nil
+}
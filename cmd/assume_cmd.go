@@ -0,0 +1,96 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type AssumeCmd struct {
+	Arn        string `kong:"short='a',required,help='ARN of role to assume',predictor='arn'"`
+	FromEnv    bool   `kong:"help='Assume the role using the AWS credentials in the environment instead of AWS SSO'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --arn before using it'"`
+	Output     string `kong:"short='o',enum='env,json,yaml',default='env',help='Output format [env|json|yaml]'"`
+}
+
+// Run prints the credentials for the role as shell export commands or JSON/YAML
+func (cc *AssumeCmd) Run(ctx *RunContext) error {
+	accountId, role, err := utils.ParseRoleARN(ctx.Cli.Assume.Arn)
+	if err != nil {
+		return err
+	}
+	region := ctx.Settings.GetDefaultRegion(accountId, role, false)
+
+	var creds *storage.RoleCredentials
+	if ctx.Cli.Assume.FromEnv {
+		if ctx.Settings.Offline() {
+			return fmt.Errorf("--from-env is not supported in --offline mode")
+		}
+
+		// honor ExternalId & SourceIdentity if the role is in our config
+		var configRole *sso.SSORole
+		partition := utils.PARTITION_AWS
+		if s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO); err == nil {
+			partition = s.Partition()
+			if r, err := s.GetRole(accountId, role); err == nil {
+				configRole = r
+			}
+		}
+
+		// talk to STS in the partition of our AWS SSO instance if the role has no DefaultRegion
+		stsRegion := region
+		if stsRegion == "" {
+			stsRegion = utils.PartitionRegion(partition)
+		}
+		c, err := sso.AssumeRoleFromEnv(ctx.Context, ctx.Settings.HTTPClient(), stsRegion,
+			ctx.Cli.Assume.Arn, configRole, 0)
+		if err != nil {
+			return err
+		}
+		creds = &c
+	} else {
+		if err := checkRoleAccess(ctx, accountId, role, ctx.Cli.Assume.NoValidate); err != nil {
+			return err
+		}
+		creds = GetRoleCredentials(ctx, doAuth(ctx), accountId, role)
+	}
+	log.Debugf("Assumed %s which expires at %s", ctx.Cli.Assume.Arn, creds.ExpireISO8601())
+
+	if ctx.Cli.Assume.Output != "env" {
+		out, err := marshalOutput(ctx, ctx.Cli.Assume.Output, NewCredsJSONOutput(creds, region))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", string(out))
+		return nil
+	}
+
+	fmt.Printf("export AWS_ACCESS_KEY_ID=\"%s\"\n", creds.AccessKeyId)
+	fmt.Printf("export AWS_SECRET_ACCESS_KEY=\"%s\"\n", creds.SecretAccessKey)
+	fmt.Printf("export AWS_SESSION_TOKEN=\"%s\"\n", creds.SessionToken)
+	if region != "" {
+		fmt.Printf("export AWS_DEFAULT_REGION=\"%s\"\n", region)
+	}
+	return nil
+}
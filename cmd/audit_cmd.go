@@ -0,0 +1,124 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/utils"
+	"github.com/synfinatic/gotable"
+)
+
+type AuditCmd struct {
+	Since  string `kong:"short='s',help='Report role usage and access changes since a duration (ex: 7d) or RFC3339 time'"`
+	Output string `kong:"short='o',enum='table,json',default='table',help='Output format for --since [table|json]'"`
+}
+
+// Run prints when each role was last used, most recent first
+func (cc *AuditCmd) Run(ctx *RunContext) error {
+	if ctx.Cli.Audit.Since != "" {
+		return auditSince(ctx)
+	}
+
+	roles := ctx.Settings.Cache.GetSSO().Roles.GetAllRoles()
+
+	sort.SliceStable(roles, func(i, j int) bool {
+		if roles[i].LastUsed != roles[j].LastUsed {
+			return roles[i].LastUsed > roles[j].LastUsed
+		}
+		return roles[i].Arn < roles[j].Arn
+	})
+
+	tr := []gotable.TableStruct{}
+	for _, roleFlat := range roles {
+		if used, err := utils.TimeSince(roleFlat.LastUsed, true); err == nil {
+			roleFlat.LastUsedStr = used
+		}
+		tr = append(tr, *roleFlat)
+	}
+
+	fields := []string{"AccountId", "AccountAlias", "RoleName", "LastUsedStr"}
+	fmt.Printf("Last use of AWS roles for SSO Instance: %s\n", ctx.Settings.DefaultSSO)
+	if err := gotable.GenerateTable(tr, fields); err != nil {
+		log.WithError(err).Fatalf("Unable to generate report")
+	}
+	fmt.Printf("\n")
+	return nil
+}
+
+// auditSince reports which roles were used and which roles we gained or lost
+// access to since the --since time
+func auditSince(ctx *RunContext) error {
+	since, err := utils.ParseSince(ctx.Cli.Audit.Since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	report := ctx.Settings.Cache.Audit(since)
+	if report.Baseline == 0 {
+		log.Warnf("No snapshot of your roles is available yet; run `aws-sso cache` to create one")
+	} else if report.Partial {
+		log.Warnf("Oldest snapshot of your roles is from %s; access changes before then are unknown",
+			time.Unix(report.Baseline, 0).Format(time.RFC3339))
+	}
+
+	if ctx.Cli.Audit.Output == "json" {
+		b, err := marshalJSON(ctx, report)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", string(b))
+		return nil
+	}
+
+	fmt.Printf("AWS role activity for SSO Instance %s since %s\n\n",
+		ctx.Settings.DefaultSSO, time.Unix(report.Since, 0).Format(time.RFC3339))
+
+	if len(report.Used) == 0 {
+		fmt.Printf("No roles used\n")
+	} else {
+		tr := []gotable.TableStruct{}
+		for _, usage := range report.Used {
+			if used, err := utils.TimeSince(usage.LastUsed, true); err == nil {
+				usage.LastUsedStr = used
+			}
+			tr = append(tr, usage)
+		}
+		fields := []string{"AccountId", "AccountAlias", "RoleName", "Count", "LastUsedStr"}
+		if err := gotable.GenerateTable(tr, fields); err != nil {
+			log.WithError(err).Fatalf("Unable to generate report")
+		}
+	}
+
+	if report.Baseline != 0 {
+		fmt.Printf("\nGained access: %d\n", len(report.Gained))
+		for _, arn := range report.Gained {
+			fmt.Printf("  + %s\n", arn)
+		}
+		fmt.Printf("Lost access: %d\n", len(report.Lost))
+		for _, arn := range report.Lost {
+			fmt.Printf("  - %s\n", arn)
+		}
+	}
+	fmt.Printf("\n")
+	return nil
+}
@@ -19,22 +19,37 @@ package main
  */
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
 )
 
-type CacheCmd struct{}
+type CacheCmd struct {
+	Prefetch bool `kong:"help='Also fetch STS credentials for roles matching PrefetchTags'"`
+}
 
 func (cc *CacheCmd) Run(ctx *RunContext) error {
-	log.Info("Refreshing local cache...")
-
-	awssso := doAuth(ctx)
 	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
 	if err != nil {
 		log.Fatalf("%s", err.Error())
 	}
 
+	// prefetch runs in the background and must never prompt for a login
+	if ctx.Cli.Cache.Prefetch && !sso.NewAWSSSO(s, &ctx.Store).ValidAuthToken() {
+		return fmt.Errorf("AWS SSO login required.  Please run `aws-sso reauth`")
+	}
+
+	log.Info("Refreshing local cache...")
+	awssso := doAuth(ctx)
+
 	ssoName, err := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
 	if err != nil {
 		log.Fatalf(err.Error())
@@ -51,5 +66,141 @@ func (cc *CacheCmd) Run(ctx *RunContext) error {
 	}
 
 	log.Info("Cache has been refreshed.")
+
+	if ctx.Cli.Cache.Prefetch {
+		prefetchRoleCredentials(ctx, awssso)
+	}
 	return nil
 }
+
+// prefetchRoleCredentials fetches STS credentials for each of the roles
+// matching PrefetchTags which do not have valid credentials cached
+func prefetchRoleCredentials(ctx *RunContext, awssso *sso.AWSSSO) {
+	if len(ctx.Settings.PrefetchTags) == 0 {
+		return
+	}
+
+	roles := []*sso.AWSRoleFlat{}
+	for _, role := range ctx.Settings.Cache.GetSSO().Roles.MatchingRoles(ctx.Settings.PrefetchTags) {
+		creds := storage.RoleCredentials{}
+		if role.IsValid() && storage.GetCachedRoleCredentials(ctx.Store, roleCredentialsKey(ctx, role.AccountId, role.RoleName), &creds) == nil {
+			continue
+		}
+		roles = append(roles, role)
+	}
+
+	for _, result := range fetchRoleCredentials(ctx, awssso, roles, ctx.Settings.MaxConcurrency, 0) {
+		if result.Err != nil {
+			log.WithError(result.Err).Warnf("Unable to prefetch role credentials for %s", result.Role.Arn)
+			continue
+		}
+		log.Infof("Prefetched role credentials for %s", result.Role.Arn)
+	}
+
+	if err := ctx.Settings.Cache.Save(false); err != nil {
+		log.WithError(err).Warnf("Unable to save cache")
+	}
+}
+
+// roleCredentialsResult is the result of fetching the STS credentials for a role
+type roleCredentialsResult struct {
+	Role     *sso.AWSRoleFlat
+	Creds    storage.RoleCredentials
+	Err      error
+	TimedOut bool
+}
+
+// fetchRoleCredentials fetches and caches new STS credentials for each of the
+// roles, making up to concurrency requests in parallel and backing off when
+// throttled.  A non-zero timeout aborts fetching any single role which takes
+// longer without affecting the others.  Results are in the same order as the roles.
+func fetchRoleCredentials(ctx *RunContext, awssso *sso.AWSSSO, roles []*sso.AWSRoleFlat, concurrency int, timeout time.Duration) []roleCredentialsResult {
+	results := make([]roleCredentialsResult, len(roles))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limiter := sso.NewAdaptiveLimiter(concurrency)
+
+	fetch := func(r *roleCredentialsResult) {
+		// the timeout starts once the limiter lets us call AWS
+		r.Err = limiter.CallWithTimeout(ctx.Context, timeout, func(rctx context.Context) error {
+			var err error
+			r.Creds, err = awssso.GetRoleCredentialsContext(rctx, r.Role.AccountId, r.Role.RoleName)
+			if err != nil && rctx.Err() != nil {
+				r.TimedOut = errors.Is(rctx.Err(), context.DeadlineExceeded)
+			}
+			return err
+		})
+		if errors.Is(r.Err, context.DeadlineExceeded) {
+			r.TimedOut = true
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, role := range roles {
+		results[i].Role = role
+		if role.Via != "" {
+			continue // role chaining is not safe to run in parallel
+		}
+		wg.Add(1)
+		go func(r *roleCredentialsResult) {
+			defer wg.Done()
+			fetch(r)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	for i := range results {
+		r := &results[i]
+		if r.Role.Via != "" {
+			fetch(r)
+		}
+		if r.Err == nil {
+			// the secure store is not safe for concurrent writes
+			saveRoleCredentials(ctx, roleCredentialsKey(ctx, r.Role.AccountId, r.Role.RoleName), &r.Creds)
+		}
+	}
+	return results
+}
+
+// startPrefetch refreshes the cache and prefetches role credentials in a
+// background process when PrefetchOnLogin is enabled
+func startPrefetch(ctx *RunContext) {
+	if !ctx.Settings.PrefetchOnLogin {
+		return
+	}
+	if ctx.Cli.ConfigFile == sso.STDIN_CONFIG {
+		// the background process can't read our config from stdin
+		log.Warnf("PrefetchOnLogin is not supported with --config -")
+		return
+	}
+
+	ssoName, err := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	if err != nil {
+		log.WithError(err).Warnf("Unable to prefetch")
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.WithError(err).Warnf("Unable to prefetch")
+		return
+	}
+
+	args := []string{"--config", ctx.Cli.ConfigFile, "--sso", ssoName}
+	if ctx.Cli.Env != "" {
+		args = append(args, "--env", ctx.Cli.Env)
+	}
+	args = append(args, "cache", "--prefetch")
+
+	// stdin/stdout/stderr are /dev/null and we don't wait for it to finish
+	cmd := exec.Command(exe, args...) // #nosec
+	if err = cmd.Start(); err != nil {
+		log.WithError(err).Warnf("Unable to prefetch")
+		return
+	}
+	log.Debugf("Started prefetch in the background: pid %d", cmd.Process.Pid)
+	if err = cmd.Process.Release(); err != nil {
+		log.WithError(err).Debugf("Unable to release prefetch process")
+	}
+}
@@ -37,6 +37,7 @@ type Predictor struct {
 	roles      []string
 	arns       []string
 	profiles   []string
+	aliases    []string
 }
 
 // AvailableAwsRegions lists all the AWS regions that AWS provides
@@ -75,6 +76,9 @@ func NewPredictor(cacheFile, configFile string) *Predictor {
 	p := Predictor{
 		configFile: configFile,
 	}
+	if configFile == sso.STDIN_CONFIG {
+		return &p // stdin is the shell, not our config
+	}
 	ssoName := os.Getenv("AWS_SSO")
 	if ssoName != "" {
 		override.DefaultSSO = ssoName
@@ -90,6 +94,8 @@ func NewPredictor(cacheFile, configFile string) *Predictor {
 		return &p
 	}
 
+	p.aliases = settings.AliasNames()
+
 	uniqueRoles := map[string]bool{}
 
 	cache := c.GetSSO()
@@ -98,6 +104,9 @@ func NewPredictor(cacheFile, configFile string) *Predictor {
 		p.accountids = append(p.accountids, id)
 
 		for roleName, rFlat := range cache.Roles.GetAccountRoles(aid) {
+			if rFlat.Disabled {
+				continue
+			}
 			p.arns = append(p.arns, rFlat.Arn)
 			uniqueRoles[roleName] = true
 			profile, err := rFlat.ProfileName(settings)
@@ -149,6 +158,11 @@ func (p *Predictor) ArnComplete() complete.Predictor {
 	return complete.PredictSet(arns...)
 }
 
+// AliasComplete returns a list of all the role aliases in the config
+func (p *Predictor) AliasComplete() complete.Predictor {
+	return complete.PredictSet(p.aliases...)
+}
+
 // RegionsComplete returns a list of all the valid AWS Regions
 func (p *Predictor) RegionComplete() complete.Predictor {
 	return complete.PredictSet(AvailableAwsRegions...)
@@ -24,12 +24,16 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"text/template"
 
+	goyaml "github.com/goccy/go-yaml"
 	"github.com/hexops/gotextdiff"
 	"github.com/hexops/gotextdiff/myers"
 	"github.com/hexops/gotextdiff/span"
 	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
 	"github.com/synfinatic/aws-sso-cli/utils"
 )
 
@@ -47,6 +51,9 @@ credential_process = {{ $profile.BinaryPath }} -u {{ $profile.Open }} -S "{{ $pr
 `
 )
 
+// keys in each profile which are always generated by us and never preserved
+var managedProfileKeys = []string{"credential_process", "region", "output"}
+
 type ProfileMap map[string]map[string]ProfileConfig
 
 type ProfileConfig struct {
@@ -59,23 +66,79 @@ type ProfileConfig struct {
 }
 
 type ConfigCmd struct {
-	Diff  bool   `kong:"help='Print a diff of changes to the config file instead of modifying it'"`
-	Open  string `kong:"help='Override how to open URLs: [open|clip]',required"`
+	Diff  bool   `kong:"help='Print a diff of changes to the config file instead of modifying it (dry run)'"`
+	Open  string `kong:"help='Override how to open URLs: [open|clip]'"`
 	Print bool   `kong:"help='Print profile entries instead of modifying config file',xor='action'"`
+	Prune bool   `kong:"help='Refresh the cache and remove profiles for roles which are no longer accessible (preview with --diff)'"`
+
+	Update ConfigUpdateCmd `kong:"cmd,hidden,default='1',help='Update ~/.aws/config with AWS SSO profiles from the cache'"`
+	Show   ConfigShowCmd   `kong:"cmd,help='Print the effective config with secrets redacted'"`
+	Edit   ConfigEditCmd   `kong:"cmd,help='Edit the config file in $EDITOR and validate it before saving'"`
+}
+
+type ConfigUpdateCmd struct{}
+
+type ConfigShowCmd struct {
+	Output string `kong:"short='o',help='Output format: [yaml|json]',default='yaml',enum='yaml,json'"`
 }
 
-func (cc *ConfigCmd) Run(ctx *RunContext) error {
+// Run prints our fully resolved settings (config file, environment and
+// CLI overrides) with any secrets redacted
+func (cc *ConfigShowCmd) Run(ctx *RunContext) error {
+	s, err := ctx.Settings.Redacted()
+	if err != nil {
+		return err
+	}
+
+	data, err := goyaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Cli.Config.Show.Output == "json" {
+		// round trip via a map so the JSON keys match our YAML config
+		config := map[string]interface{}{}
+		if err = goyaml.Unmarshal(data, &config); err != nil {
+			return err
+		}
+		if data, err = marshalJSON(ctx, config); err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Print(string(data))
+	return nil
+}
+
+func (cc *ConfigUpdateCmd) Run(ctx *RunContext) error {
+	if ctx.Cli.Config.Open == "" {
+		return fmt.Errorf("Missing required flag --open")
+	}
+
 	set := ctx.Settings
 	binaryPath, err := os.Executable()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Cli.Config.Prune {
+		if err = pruneRefreshCache(ctx); err != nil {
+			return err
+		}
+	}
+
+	_, existing := readManagedProfiles(awsConfigFile())
 	profiles := ProfileMap{}
 	profileUniqueCheck := map[string][]string{} // ProfileName() => Arn
 
 	// Find all the roles across all of the SSO instances
 	for ssoName, s := range set.Cache.SSO {
+		if _, ok := set.SSO[ssoName]; !ok && ctx.Cli.Config.Prune {
+			log.Infof("Pruning profiles for removed AWS SSO instance %s", ssoName)
+			continue
+		}
 		for _, role := range s.Roles.GetAllRoles() {
 			profile, err := role.ProfileName(ctx.Settings)
 			if err != nil {
@@ -95,7 +158,7 @@ func (cc *ConfigCmd) Run(ctx *RunContext) error {
 			profiles[ssoName][role.Arn] = ProfileConfig{
 				Arn:             role.Arn,
 				BinaryPath:      binaryPath,
-				ConfigVariables: ctx.Settings.ConfigVariables,
+				ConfigVariables: profileVariables(ctx, ssoName, role, existing[profile]),
 				Open:            ctx.Cli.Config.Open,
 				Profile:         profile,
 				Sso:             ssoName,
@@ -113,25 +176,173 @@ func (cc *ConfigCmd) Run(ctx *RunContext) error {
 			return err
 		}
 	}
-	return updateConfig(ctx, templ, profiles)
+
+	removed := []string{}
+	if ctx.Cli.Config.Prune {
+		removed = removedProfiles(awsConfigFile(), profileUniqueCheck)
+	}
+	if err = updateConfig(ctx, templ, profiles); err != nil {
+		return err
+	}
+
+	// with --diff the removed profiles are already shown in the diff
+	if !ctx.Cli.Config.Diff {
+		for _, profile := range removed {
+			fmt.Printf("Removed profile: %s\n", profile)
+		}
+	}
+	return nil
+}
+
+// pruneRefreshCache refreshes the roles for the selected AWS SSO instance so
+// roles we no longer have access to are removed
+func pruneRefreshCache(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	ssoName, err := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+
+	awssso := doAuth(ctx)
+	if err = ctx.Settings.Cache.Refresh(awssso, s, ssoName); err != nil {
+		return fmt.Errorf("Unable to refresh role cache: %s", err.Error())
+	}
+	if err = ctx.Settings.Cache.Save(true); err != nil {
+		log.WithError(err).Errorf("Unable to save cache")
+	}
+	return nil
+}
+
+// profileVariables returns the config variables for the profile of the given
+// role: keys the user added to the existing profile, our ConfigVariables and
+// the region & output format
+func profileVariables(ctx *RunContext, ssoName string, role *sso.AWSRoleFlat, existing map[string]string) map[string]interface{} {
+	vars := userProfileVariables(existing, ctx.Settings.ConfigVariables)
+
+	for key, value := range ctx.Settings.ConfigVariables {
+		vars[key] = value
+	}
+	if region := ctx.Settings.GetRoleRegion(ssoName, role.AccountId, role.RoleName); region != "" {
+		vars["region"] = region
+	}
+	if ctx.Settings.ProfileOutput != "" {
+		vars["output"] = ctx.Settings.ProfileOutput
+	}
+	return vars
+}
+
+// userProfileVariables returns the keys of the existing profile which were
+// added by the user, skipping our managedProfileKeys and ConfigVariables
+func userProfileVariables(existing map[string]string, configVariables map[string]interface{}) map[string]interface{} {
+	vars := map[string]interface{}{}
+	for key, value := range existing {
+		vars[key] = value
+	}
+	for _, key := range managedProfileKeys {
+		delete(vars, key)
+	}
+	for key := range configVariables {
+		delete(vars, key)
+	}
+	return vars
 }
 
-// updateConfig calculates the diff
+// readManagedProfiles returns the names, in order, and the key/values of
+// the profiles between our CONFIG_PREFIX and CONFIG_SUFFIX markers
+func readManagedProfiles(configFile string) ([]string, map[string]map[string]string) {
+	names := []string{}
+	profiles := map[string]map[string]string{}
+	input, err := os.Open(configFile)
+	if err != nil {
+		return names, profiles
+	}
+	defer input.Close()
+
+	managed := false
+	profile := ""
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == CONFIG_PREFIX:
+			managed = true
+		case line == CONFIG_SUFFIX:
+			managed = false
+			profile = ""
+		case managed && strings.HasPrefix(line, "[profile ") && strings.HasSuffix(line, "]"):
+			profile = strings.TrimSuffix(strings.TrimPrefix(line, "[profile "), "]")
+			names = append(names, profile)
+			profiles[profile] = map[string]string{}
+		case strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			// comment
+		case managed && profile != "" && strings.Contains(line, "="):
+			kv := strings.SplitN(line, "=", 2)
+			profiles[profile][strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return names, profiles
+}
+
+// removedProfiles returns the names of the profiles between our CONFIG_PREFIX
+// and CONFIG_SUFFIX markers which are not in the given list of profiles
+func removedProfiles(configFile string, profiles map[string][]string) []string {
+	removed := []string{}
+	names, _ := readManagedProfiles(configFile)
+	for _, profile := range names {
+		if _, ok := profiles[profile]; !ok {
+			removed = append(removed, profile)
+		}
+	}
+	return removed
+}
+
+// updateConfig updates the user's ~/.aws/config or prints the diff with --diff
 func updateConfig(ctx *RunContext, templ *template.Template, profiles ProfileMap) error {
-	// open our config file
 	configFile := awsConfigFile()
-	input, err := os.Open(configFile)
+	diff, err := updateConfigFile(configFile, templ, profiles, ctx.Cli.Config.Diff)
 	if err != nil {
 		return err
 	}
 
-	// open a temp file
-	output, err := os.CreateTemp("", "config.*")
+	if len(diff) == 0 {
+		// do nothing if there is no diff
+		log.Infof("No changes to made to %s", configFile)
+	} else if ctx.Cli.Config.Diff {
+		fmt.Printf("%s", diff)
+	}
+	return nil
+}
+
+// updateConfigFile replaces our profiles in the config file and returns the
+// diff of the changes.  If diffOnly is set, the config file is not modified.
+func updateConfigFile(configFile string, templ *template.Template, profiles ProfileMap, diffOnly bool) (string, error) {
+	input, err := os.Open(configFile)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer input.Close()
+
+	info, err := input.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// write to a temp file in the same directory so we can rename it into
+	// place, replacing the target of a symlinked config file
+	target := configFile
+	if path, err := filepath.EvalSymlinks(configFile); err == nil {
+		target = path
+	}
+	output, err := os.CreateTemp(filepath.Dir(target), ".config.*")
+	if err != nil {
+		return "", err
 	}
 	tempFileName := output.Name()
 	defer os.Remove(tempFileName)
+	defer output.Close()
 
 	w := bufio.NewWriter(output)
 
@@ -141,14 +352,14 @@ func updateConfig(ctx *RunContext, templ *template.Template, profiles ProfileMap
 	line, err := r.ReadString('\n')
 	for err == nil && line != fmt.Sprintf("%s\n", CONFIG_PREFIX) {
 		if _, err = w.WriteString(line); err != nil {
-			return err
+			return "", err
 		}
 		line, err = r.ReadString('\n')
 	}
 
 	endOfFile := false
 	if err != nil && err != io.EOF {
-		return err
+		return "", err
 	} else if err == io.EOF {
 		// Reached EOF before finding our CONFIG_PREFIX
 		endOfFile = true
@@ -156,7 +367,7 @@ func updateConfig(ctx *RunContext, templ *template.Template, profiles ProfileMap
 
 	// write our template out
 	if err = templ.Execute(w, profiles); err != nil {
-		return err
+		return "", err
 	}
 
 	if !endOfFile {
@@ -172,46 +383,35 @@ func updateConfig(ctx *RunContext, templ *template.Template, profiles ProfileMap
 			line, err = r.ReadString('\n')
 			for err == nil {
 				if _, err = w.WriteString(line); err != nil {
-					return err
+					return "", err
 				}
 				line, err = r.ReadString('\n')
 			}
 			if err != io.EOF {
-				return err
+				return "", err
 			}
 		}
 	}
-	w.Flush()
-	output.Close()
-	input.Close()
-
-	diff, err := generateDiff(configFile, tempFileName)
-	if err != nil {
-		return err
+	if err = w.Flush(); err != nil {
+		return "", err
 	}
-
-	if len(diff) == 0 {
-		// do nothing if there is no diff
-		log.Infof("No changes to made to %s", configFile)
-		return nil
+	if err = output.Close(); err != nil {
+		return "", err
 	}
 
-	if ctx.Cli.Config.Diff {
-		fmt.Printf("%s", diff)
-		return nil
+	diff, err := generateDiff(configFile, tempFileName)
+	if err != nil || len(diff) == 0 || diffOnly {
+		return diff, err
 	}
 
-	// copy file into place
-	input, err = os.Open(tempFileName) // output/tempFile is now the input!
-	if err != nil {
-		return err
+	// keep the permissions of the original file
+	if err = os.Chmod(tempFileName, info.Mode().Perm()); err != nil {
+		return "", err
 	}
-	output, err = os.OpenFile(configFile, os.O_RDWR|os.O_CREATE, 0600) // ~/.aws/config is now the output
-	if err != nil {
-		return err
+	if err = os.Rename(tempFileName, target); err != nil {
+		return "", fmt.Errorf("Unable to update %s: %s", configFile, err.Error())
 	}
-	_, err = io.Copy(output, input)
-	return err
+	return diff, nil
 }
 
 // awsConfigFile returns the path the the users ~/.aws/config
@@ -0,0 +1,179 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAwsConfig = `[default]
+region = us-east-1
+
+[profile manual]
+region = us-west-2
+
+# BEGIN_AWS_SSO_CLI
+
+[profile Data:Admin]
+credential_process = /usr/bin/aws-sso process --sso Default --arn arn:aws:iam::123456789012:role/Admin
+region = us-west-1
+output = json
+
+[profile Data:ReadOnly]
+credential_process = /usr/bin/aws-sso process --sso Default --arn arn:aws:iam::123456789012:role/ReadOnly
+
+# END_AWS_SSO_CLI
+
+[profile after]
+region = eu-west-1
+`
+
+func writeTestAwsConfig(t *testing.T) string {
+	configFile := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(configFile, []byte(testAwsConfig), 0600))
+	return configFile
+}
+
+func TestReadManagedProfiles(t *testing.T) {
+	names, profiles := readManagedProfiles(writeTestAwsConfig(t))
+	assert.Equal(t, []string{"Data:Admin", "Data:ReadOnly"}, names)
+	assert.Equal(t, map[string]string{
+		"credential_process": "/usr/bin/aws-sso process --sso Default --arn arn:aws:iam::123456789012:role/Admin",
+		"region":             "us-west-1",
+		"output":             "json",
+	}, profiles["Data:Admin"])
+	assert.NotContains(t, profiles, "manual")
+	assert.NotContains(t, profiles, "after")
+
+	names, profiles = readManagedProfiles(filepath.Join(t.TempDir(), "missing"))
+	assert.Empty(t, names)
+	assert.Empty(t, profiles)
+}
+
+func TestRemovedProfiles(t *testing.T) {
+	configFile := writeTestAwsConfig(t)
+
+	// only managed profiles no longer in the list are pruned
+	removed := removedProfiles(configFile, map[string][]string{
+		"Data:Admin": {"Default", "arn:aws:iam::123456789012:role/Admin"},
+		"New:Role":   {"Default", "arn:aws:iam::210987654321:role/Role"},
+	})
+	assert.Equal(t, []string{"Data:ReadOnly"}, removed)
+
+	removed = removedProfiles(configFile, map[string][]string{})
+	assert.Equal(t, []string{"Data:Admin", "Data:ReadOnly"}, removed)
+
+	removed = removedProfiles(configFile, map[string][]string{
+		"Data:Admin":    {},
+		"Data:ReadOnly": {},
+	})
+	assert.Empty(t, removed)
+}
+
+func TestUserProfileVariables(t *testing.T) {
+	existing := map[string]string{
+		"credential_process":     "/usr/bin/aws-sso process",
+		"region":                 "us-west-1",
+		"output":                 "json",
+		"sts_regional_endpoints": "regional",
+		"cli_pager":              "less",
+	}
+	vars := userProfileVariables(existing, map[string]interface{}{
+		"sts_regional_endpoints": "legacy",
+	})
+	assert.Equal(t, map[string]interface{}{
+		"cli_pager": "less",
+	}, vars)
+
+	assert.Empty(t, userProfileVariables(map[string]string{}, nil))
+}
+
+func TestUpdateConfigFilePrune(t *testing.T) {
+	configFile := writeTestAwsConfig(t)
+	templ, err := template.New("profile").Parse(CONFIG_TEMPLATE)
+	assert.NoError(t, err)
+
+	// Data:ReadOnly is no longer accessible
+	profiles := ProfileMap{
+		"Default": {
+			"arn:aws:iam::123456789012:role/Admin": ProfileConfig{
+				Arn:        "arn:aws:iam::123456789012:role/Admin",
+				BinaryPath: "/usr/bin/aws-sso",
+				ConfigVariables: map[string]interface{}{
+					"region": "us-west-1",
+					"output": "json",
+				},
+				Open:    "open",
+				Profile: "Data:Admin",
+				Sso:     "Default",
+			},
+		},
+	}
+	expected := `[default]
+region = us-east-1
+
+[profile manual]
+region = us-west-2
+
+# BEGIN_AWS_SSO_CLI
+
+[profile Data:Admin]
+credential_process = /usr/bin/aws-sso -u open -S "Default" process --arn arn:aws:iam::123456789012:role/Admin
+output = json
+region = us-west-1
+
+# END_AWS_SSO_CLI
+
+[profile after]
+region = eu-west-1
+`
+
+	// --diff leaves the file alone
+	diff, err := updateConfigFile(configFile, templ, profiles, true)
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "-[profile Data:ReadOnly]")
+	data, err := os.ReadFile(configFile)
+	assert.NoError(t, err)
+	assert.Equal(t, testAwsConfig, string(data))
+
+	// the shorter file must not keep any of the old contents
+	diff, err = updateConfigFile(configFile, templ, profiles, false)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, diff)
+	data, err = os.ReadFile(configFile)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(data))
+
+	info, err := os.Stat(configFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// no changes & no temp files left behind
+	diff, err = updateConfigFile(configFile, templ, profiles, false)
+	assert.NoError(t, err)
+	assert.Empty(t, diff)
+	files, err := os.ReadDir(filepath.Dir(configFile))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,131 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/synfinatic/aws-sso-cli/sso"
+)
+
+type ConfigEditCmd struct{}
+
+func (cc *ConfigEditCmd) Run(ctx *RunContext) error {
+	return editConfig(ctx)
+}
+
+// editConfig opens a copy of the config file in the users editor and only
+// replaces the config file once the changes are valid.  Does not require the
+// current config to be valid so it can be used to fix it.
+func editConfig(ctx *RunContext) error {
+	configFile := ctx.Cli.ConfigFile
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return err
+	}
+	orig, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	// keep the copy next to the config so relative paths resolve the same
+	tmp, err := ioutil.TempFile(filepath.Dir(configFile), "config-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(orig); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	var data []byte
+	for {
+		if err = runEditor(tmp.Name()); err != nil {
+			return err
+		}
+		if data, err = ioutil.ReadFile(tmp.Name()); err != nil {
+			return err
+		}
+		if bytes.Equal(data, orig) {
+			fmt.Fprintf(os.Stderr, "No changes made to %s\n", configFile)
+			return nil
+		}
+
+		_, err = sso.LoadSettings(tmp.Name(), "", DEFAULT_CONFIG, sso.OverrideSettings{})
+		if err == nil {
+			break
+		}
+		// report errors against the real config file, not our copy
+		fmt.Fprintf(os.Stderr, "Invalid config: %s\n", strings.ReplaceAll(err.Error(), tmp.Name(), configFile))
+
+		edit := promptui.Prompt{
+			Label:     "Edit again",
+			IsConfirm: true,
+			Default:   "y",
+		}
+		if _, err := edit.Run(); err != nil {
+			return fmt.Errorf("Discarded invalid changes to %s", configFile)
+		}
+	}
+
+	if err = ioutil.WriteFile(configFile, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved %s\n", configFile)
+	return nil
+}
+
+// editorCommand returns the users preferred editor from $VISUAL or $EDITOR
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// runEditor opens the file in the users editor and waits for it to exit
+func runEditor(file string) error {
+	editor := editorCommand()
+	args := append(editor[1:], file)
+	cmd := exec.Command(editor[0], args...) // #nosec
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Unable to run %s: %s", editor[0], err.Error())
+	}
+	return nil
+}
@@ -19,13 +19,13 @@ package main
  */
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os/user"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -38,18 +38,25 @@ import (
 	"github.com/synfinatic/aws-sso-cli/utils"
 )
 
-const AWS_FEDERATED_URL = "https://signin.aws.amazon.com/federation"
-
 type ConsoleCmd struct {
 	// Console actually should honor the --region flag
-	Region   string `kong:"help='AWS Region',env='AWS_DEFAULT_REGION',predictor='region'"`
-	Duration int32  `kong:"short='d',help='AWS Session duration in minutes (default 60)'"` // default stored in DEFAULT_CONFIG
+	Region   string `kong:"help='AWS Region (default: the role default region)',env='AWS_DEFAULT_REGION',predictor='region'"`
+	Service  string `kong:"help='Open the AWS Console for this service (ex: ec2) or path (ex: ec2/v2/home#Instances:)'"`
+	Duration string `kong:"short='d',help='AWS Session duration in minutes or as a duration like 8h (default 60)'"` // default stored in DEFAULT_CONFIG
+	Clamp    bool   `kong:"name='clamp-duration',help='Reduce the duration to the maximum allowed instead of failing'"`
 	Prompt   bool   `kong:"short='P',help='Force interactive prompt to select role'"`
+	Private  bool   `kong:"help='Open the AWS Console in a private/incognito browser window'"`
+
+	Arn        string `kong:"short='a',help='ARN of role to assume',env='AWS_SSO_ROLE_ARN',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',env='AWS_SSO_ACCOUNT_ID',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to assume',env='AWS_SSO_ROLE_NAME',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to assume',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
 
-	Arn       string `kong:"short='a',help='ARN of role to assume',env='AWS_SSO_ROLE_ARN',predictor='arn'"`
-	AccountId int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',env='AWS_SSO_ACCOUNT_ID',predictor='accountId'"`
-	Role      string `kong:"short='R',help='Name of AWS Role to assume',env='AWS_SSO_ROLE_NAME',predictor='role'"`
-	Profile   string `kong:"short='p',help='Name of AWS Profile to assume',predictor='profile'"`
+	All    bool     `kong:"help='Open the AWS Console for every role matching --filter'"`
+	Filter []string `kong:"help='Only open roles with the tag Key=Value (requires --all)'"`
+	Limit  int      `kong:"help='Maximum number of roles to open with --all',default=10"`
+	Yes    bool     `kong:"short='y',help='Do not ask for confirmation for roles matching ConfirmTags'"`
 
 	AccessKeyId     string `kong:"env='AWS_ACCESS_KEY_ID',hidden"`
 	SecretAccessKey string `kong:"env='AWS_SECRET_ACCESS_KEY',hidden"`
@@ -58,12 +65,16 @@ type ConsoleCmd struct {
 }
 
 func (cc *ConsoleCmd) Run(ctx *RunContext) error {
-	duration := ctx.Settings.ConsoleDuration
-	if ctx.Cli.Console.Duration > 0 {
-		duration = ctx.Cli.Console.Duration
+	duration, err := consoleDuration(ctx)
+	if err != nil {
+		return err
 	}
 
-	if ctx.Cli.Console.Prompt {
+	if ctx.Cli.Console.All {
+		return consoleAll(ctx)
+	} else if len(ctx.Cli.Console.Filter) > 0 {
+		return fmt.Errorf("--filter requires --all")
+	} else if ctx.Cli.Console.Prompt {
 		return consolePrompt(ctx)
 	} else if ctx.Cli.Console.Profile != "" {
 		awssso := doAuth(ctx)
@@ -81,9 +92,15 @@ func (cc *ConsoleCmd) Run(ctx *RunContext) error {
 		if err != nil {
 			return err
 		}
+		if err := checkRoleAccess(ctx, accountid, role, ctx.Cli.Console.NoValidate); err != nil {
+			return err
+		}
 
 		return openConsole(ctx, awssso, accountid, role)
 	} else if ctx.Cli.Console.AccountId > 0 && ctx.Cli.Console.Role != "" {
+		if err := checkAccountAccess(ctx, ctx.Cli.Console.AccountId, ctx.Cli.Console.NoValidate); err != nil {
+			return err
+		}
 		awssso := doAuth(ctx)
 		return openConsole(ctx, awssso, ctx.Cli.Console.AccountId, ctx.Cli.Console.Role)
 	} else if haveAWSEnvVars(ctx) {
@@ -96,6 +113,52 @@ func (cc *ConsoleCmd) Run(ctx *RunContext) error {
 	return consolePrompt(ctx)
 }
 
+// parseTagFilters converts a list of Key=Value --filter flags or ConfirmTags
+// Filter into a map
+func parseTagFilters(filters []string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, f := range filters {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return tags, fmt.Errorf("Invalid filter %s: must be Key=Value", f)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags, nil
+}
+
+// consoleAll opens the AWS Console for each role which matches our filter
+func consoleAll(ctx *RunContext) error {
+	tags, err := parseTagFilters(ctx.Cli.Console.Filter)
+	if err != nil {
+		return err
+	}
+
+	roles := ctx.Settings.Cache.GetSSO().Roles.MatchingRoles(tags)
+	if len(roles) == 0 {
+		return fmt.Errorf("No roles match the filter")
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Arn < roles[j].Arn })
+
+	if len(roles) > ctx.Cli.Console.Limit {
+		return fmt.Errorf("%d roles match, refusing to open more than %d.  Use a narrower --filter or increase --limit",
+			len(roles), ctx.Cli.Console.Limit)
+	}
+
+	awssso := doAuth(ctx)
+	failed := 0
+	for _, role := range roles {
+		if err := openConsole(ctx, awssso, role.AccountId, role.RoleName); err != nil {
+			log.WithError(err).Errorf("Unable to open console for %s", role.Arn)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Unable to open the console for %d of %d roles", failed, len(roles))
+	}
+	return nil
+}
+
 func stsSession(ctx *RunContext) (*sts.Client, error) {
 	cfgCreds := credentials.NewStaticCredentialsProvider(
 		ctx.Cli.Console.AccessKeyId,
@@ -104,9 +167,10 @@ func stsSession(ctx *RunContext) (*sts.Client, error) {
 	)
 
 	ssoRegion := ctx.Settings.SSO[ctx.Cli.SSO].SSORegion
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
+	cfg, err := config.LoadDefaultConfig(ctx.Context,
 		config.WithRegion(ssoRegion),
 		config.WithCredentialsProvider(cfgCreds),
+		config.WithHTTPClient(ctx.Settings.HTTPClient()),
 	)
 	if err != nil {
 		return &sts.Client{}, err
@@ -124,7 +188,7 @@ func consoleViaEnvVars(ctx *RunContext, duration int32) error {
 	}
 
 	input := sts.GetCallerIdentityInput{}
-	output, err := stsHandle.GetCallerIdentity(context.TODO(), &input)
+	output, err := stsHandle.GetCallerIdentity(ctx.Context, &input)
 	if err != nil {
 		return fmt.Errorf("Unable to call sts get-caller-identity: %s", err.Error())
 	}
@@ -135,12 +199,11 @@ func consoleViaEnvVars(ctx *RunContext, duration int32) error {
 	}
 
 	// now we know who we are, get our configured default region
-	region := ctx.Settings.GetDefaultRegion(accountid, role, false)
-	if ctx.Cli.Console.Region != "" {
-		region = ctx.Cli.Console.Region
-	}
+	region := consoleRegion(ctx, accountid, role)
 
 	creds := storage.RoleCredentials{
+		AccountId:       accountid,
+		RoleName:        role,
 		AccessKeyId:     ctx.Cli.Console.AccessKeyId,
 		SecretAccessKey: ctx.Cli.Console.SecretAccessKey,
 		SessionToken:    ctx.Cli.Console.SessionToken,
@@ -180,7 +243,17 @@ func consoleViaSDK(ctx *RunContext, duration int32) error {
 		DurationSeconds: aws.Int32(duration * 60),
 		Name:            aws.String(u.Username),
 	}
-	token, err := stsHandle.GetFederationToken(context.TODO(), &input)
+	token, err := stsHandle.GetFederationToken(ctx.Context, &input)
+	if err != nil && sso.IsDurationTooLargeError(err) {
+		max := sso.MaxDurationFromError(err)
+		if !ctx.Cli.Console.Clamp || max <= 0 || max >= duration*60 {
+			return sso.DurationTooLargeError(err, duration*60, max)
+		}
+		log.Warnf("Reducing session duration from %d to the maximum of %d minutes", duration, max/60)
+		duration = max / 60
+		input.DurationSeconds = aws.Int32(max)
+		token, err = stsHandle.GetFederationToken(ctx.Context, &input)
+	}
 	if err != nil {
 		return err
 	}
@@ -234,16 +307,44 @@ func haveAWSEnvVars(ctx *RunContext) bool {
 	return true
 }
 
+// consoleRegion returns the --region flag or the default region of the role so
+// the console (and any --service deep link) matches the role's STS region
+func consoleRegion(ctx *RunContext, accountid int64, role string) string {
+	if ctx.Cli.Console.Region != "" {
+		return ctx.Cli.Console.Region
+	}
+	if region := ctx.Settings.GetDefaultRegion(accountid, role, false); region != "" {
+		return region
+	}
+	return "us-east-1" // need a region for a valid url!
+}
+
+// consoleDuration returns the --duration or ConsoleDuration in minutes
+func consoleDuration(ctx *RunContext) (int32, error) {
+	seconds := ctx.Settings.ConsoleDuration * 60
+	if ctx.Cli.Console.Duration != "" {
+		var err error
+		if seconds, err = sso.ParseSessionDuration(ctx.Cli.Console.Duration); err != nil {
+			return 0, err
+		}
+	}
+	if err := sso.ValidateConsoleDuration(seconds); err != nil {
+		return 0, err
+	}
+	return seconds / 60, nil
+}
+
 // opens the AWS console or just prints the URL
 func openConsole(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string) error {
-	region := ctx.Settings.GetDefaultRegion(accountid, role, false)
-	if ctx.Cli.Console.Region != "" {
-		region = ctx.Cli.Console.Region
+	if err := confirmRole(ctx, accountid, role, ctx.Cli.Console.Yes); err != nil {
+		return err
 	}
 
-	duration := ctx.Settings.ConsoleDuration
-	if ctx.Cli.Console.Duration > 0 {
-		duration = ctx.Cli.Console.Duration
+	region := consoleRegion(ctx, accountid, role)
+
+	duration, err := consoleDuration(ctx)
+	if err != nil {
+		return err
 	}
 
 	ctx.Settings.Cache.AddHistory(utils.MakeRoleARN(accountid, role))
@@ -258,76 +359,78 @@ func openConsole(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role stri
 
 // openConsoleAccessKey opens the Frederated Console access URL
 func openConsoleAccessKey(ctx *RunContext, creds *storage.RoleCredentials, duration int32, region string) error {
-	signin := SigninTokenUrlParams{
-		SessionDuration: duration * 60,
-		Session: SessionUrlParams{
-			AccessKeyId:     creds.AccessKeyId,
-			SecretAccessKey: creds.SecretAccessKey,
-			SessionToken:    creds.SessionToken,
-		},
-	}
-
-	resp, err := http.Get(signin.GetUrl())
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
 	if err != nil {
-		return fmt.Errorf("Unable to login to AWS: %s", err.Error())
+		return err
 	}
+	partition := s.Partition()
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	signin := sso.NewSigninTokenUrlParams(s.GetFederationUrl(), creds, duration*60)
+
+	signinToken, err := getSigninToken(ctx, &signin)
 	if err != nil {
-		return err
+		// the federation endpoint doesn't tell us the limit, so ask IAM
+		max, merr := sso.RoleMaxSessionDuration(ctx.Context, creds, s.SSORegion, ctx.Settings.HTTPClient())
+		if merr != nil {
+			log.WithError(merr).Debugf("Unable to determine the maximum session duration")
+			return err
+		}
+		if max >= signin.SessionDuration {
+			return err
+		}
+		if !ctx.Cli.Console.Clamp {
+			return sso.DurationTooLargeError(err, signin.SessionDuration, max)
+		}
+		log.Warnf("Reducing session duration from %d to the maximum of %d minutes", duration, max/60)
+		signin.SessionDuration = max
+		signinToken, err = getSigninToken(ctx, &signin)
 	}
-
-	loginResponse := LoginResponse{}
-	err = json.Unmarshal(body, &loginResponse)
 	if err != nil {
-		return fmt.Errorf("Error parsing Login response: %s", err.Error())
+		return err
 	}
 
-	login := LoginUrlParams{
-		Issuer:      "https://github.com/synfinatic/aws-sso-cli",
-		Destination: fmt.Sprintf("https://console.aws.amazon.com/console/home?region=%s", region),
-		SigninToken: loginResponse.SigninToken,
+	login := sso.LoginUrlParams{
+		Issuer:        "https://github.com/synfinatic/aws-sso-cli",
+		FederationUrl: s.GetFederationUrl(),
+		Destination:   utils.ConsoleServiceUrl(partition, region, ctx.Cli.Console.Service),
+		SigninToken:   signinToken,
 	}
 	url := login.GetUrl()
 
-	return utils.HandleUrl(ctx.Settings.UrlAction, ctx.Settings.Browser, url,
+	browser := ctx.Settings.GetBrowser(creds.AccountId, creds.RoleName)
+	if ctx.Cli.Console.Private {
+		return utils.HandleUrlPrivate(ctx.Settings.UrlAction, browser, url,
+			"Please open the following URL in your browser:\n\n", "\n\n")
+	}
+	return utils.HandleUrl(ctx.Settings.UrlAction, browser, url,
 		"Please open the following URL in your browser:\n\n", "\n\n")
 }
 
-type LoginResponse struct {
-	SigninToken string `json:"SigninToken"`
-}
-
-type SigninTokenUrlParams struct {
-	SessionDuration int32
-	Session         SessionUrlParams // URL encoded SessionUrlParams
-}
-
-func (stup *SigninTokenUrlParams) GetUrl() string {
-	return fmt.Sprintf("%s?Action=getSigninToken&SessionDuration=%d&Session=%s",
-		AWS_FEDERATED_URL, stup.SessionDuration, stup.Session.Encode())
-}
-
-type SessionUrlParams struct {
-	AccessKeyId     string `json:"sessionId"`
-	SecretAccessKey string `json:"sessionKey"`
-	SessionToken    string `json:"sessionToken"`
-}
+// getSigninToken asks the AWS federation endpoint for a console SigninToken
+func getSigninToken(ctx *RunContext, signin *sso.SigninTokenUrlParams) (string, error) {
+	req, err := http.NewRequestWithContext(ctx.Context, http.MethodGet, signin.GetUrl(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ctx.Settings.HTTPClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Unable to login to AWS: %s", err.Error())
+	}
 
-func (sup *SessionUrlParams) Encode() string {
-	s, _ := json.Marshal(sup)
-	return url.QueryEscape(string(s))
-}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
 
-type LoginUrlParams struct {
-	Issuer      string
-	Destination string
-	SigninToken string
-}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unable to login to AWS: %s", resp.Status)
+	}
 
-func (lup *LoginUrlParams) GetUrl() string {
-	return fmt.Sprintf("%s?Action=login&Issuer=%s&Destination=%s&SigninToken=%s",
-		AWS_FEDERATED_URL, lup.Issuer, lup.Destination,
-		lup.SigninToken)
+	loginResponse := sso.LoginResponse{}
+	err = json.Unmarshal(body, &loginResponse)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing Login response: %s", err.Error())
+	}
+	return loginResponse.SigninToken, nil
 }
@@ -0,0 +1,156 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+type CredsCmd struct {
+	// AWS Params
+	Arn        string `kong:"short='a',help='ARN of role to assume',xor='arn-1',xor='arn-2',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',xor='arn-1',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to assume',xor='arn-2',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to assume',xor='arn-1',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+
+	Output         string `kong:"short='o',enum='json,yaml',default='json',help='Output format [json|yaml]'"`
+	File           string `kong:"short='f',help='Write credentials to this file (mode 0600) instead of stdout'"`
+	NonInteractive bool   `kong:"help='Fail instead of prompting for AWS SSO login (default when stdin is not a terminal)'"`
+}
+
+// CredsJSONOutput is the stable schema for `creds --output json|yaml`.  All values are
+// strings so it can be used directly by the Terraform external data source.
+type CredsJSONOutput struct {
+	AccessKeyId     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+	Expiration      string `json:"expiration"` // RFC3339
+	Region          string `json:"region"`
+	AccountId       string `json:"account_id"`
+	Role            string `json:"role"`
+}
+
+func NewCredsJSONOutput(creds *storage.RoleCredentials, region string) *CredsJSONOutput {
+	return &CredsJSONOutput{
+		AccessKeyId:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.ExpireISO8601(),
+		Region:          region,
+		AccountId:       creds.AccountIdStr(),
+		Role:            creds.RoleName,
+	}
+}
+
+func (cc *CredsCmd) Run(ctx *RunContext) error {
+	var err error
+
+	role := ctx.Cli.Creds.Role
+	account := ctx.Cli.Creds.AccountId
+
+	if ctx.Cli.Creds.Profile != "" {
+		cache := ctx.Settings.Cache.GetSSO()
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.Creds.Profile, ctx.Settings)
+		if err != nil {
+			return err
+		}
+
+		role = rFlat.RoleName
+		account = rFlat.AccountId
+	} else if ctx.Cli.Creds.Arn != "" {
+		account, role, err = utils.ParseRoleARN(ctx.Cli.Creds.Arn)
+		if err != nil {
+			return err
+		}
+		if err := checkRoleAccess(ctx, account, role, ctx.Cli.Creds.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if role == "" || account == 0 {
+		return fmt.Errorf("Please specify --arn, --profile or --account and --role")
+	}
+	if ctx.Cli.Creds.AccountId != 0 {
+		if err := checkAccountAccess(ctx, account, ctx.Cli.Creds.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if err = checkNonInteractiveLogin(ctx, ctx.Cli.Creds.NonInteractive); err != nil {
+		return err
+	}
+
+	awssso := doAuth(ctx)
+	creds := GetRoleCredentials(ctx, awssso, account, role)
+	region := ctx.Settings.GetDefaultRegion(account, role, false)
+
+	out, err := marshalOutput(ctx, ctx.Cli.Creds.Output, NewCredsJSONOutput(creds, region))
+	if err != nil {
+		return err
+	}
+
+	if ctx.Cli.Creds.File == "" {
+		fmt.Printf("%s\n", string(out))
+		return nil
+	}
+	return writeSecretFile(ctx.Cli.Creds.File, out)
+}
+
+// checkNonInteractiveLogin returns an error if an AWS SSO login is required
+// and we are non-interactive, so we never block waiting on a login nobody
+// will complete
+func checkNonInteractiveLogin(ctx *RunContext, nonInteractive bool) error {
+	if !nonInteractive && terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	if !sso.NewAWSSSO(s, &ctx.Store).ValidAuthToken() {
+		return fmt.Errorf("AWS SSO login required.  Please run `aws-sso reauth`")
+	}
+	return nil
+}
+
+// writeSecretFile writes the data to a file only readable by the user
+func writeSecretFile(path string, data []byte) error {
+	path = utils.GetHomePath(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open %s: %s", path, err.Error())
+	}
+	defer f.Close()
+
+	// make sure an existing file isn't readable by others
+	if err = f.Chmod(0600); err != nil {
+		return fmt.Errorf("Unable to set permissions on %s: %s", path, err.Error())
+	}
+	if _, err = f.Write(data); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", path, err.Error())
+	}
+	return nil
+}
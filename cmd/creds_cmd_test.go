@@ -0,0 +1,49 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSecretFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// ~ is expanded to our home directory
+	assert.NoError(t, writeSecretFile("~/creds.json", []byte("secret")))
+	data, err := os.ReadFile(filepath.Join(home, "creds.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(data))
+
+	// existing files are truncated and only readable by the user
+	path := filepath.Join(home, "existing")
+	assert.NoError(t, os.WriteFile(path, []byte("a much longer value"), 0644))
+	assert.NoError(t, writeSecretFile(path, []byte("secret")))
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
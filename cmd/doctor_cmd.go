@@ -0,0 +1,366 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+	"github.com/synfinatic/gotable"
+)
+
+// harmless URL opened when testing the browser
+const DOCTOR_TEST_URL = "https://github.com/synfinatic/aws-sso-cli"
+
+const (
+	DOCTOR_OK   = "OK"
+	DOCTOR_WARN = "WARN"
+	DOCTOR_FAIL = "FAIL"
+)
+
+type DoctorCmd struct {
+	Output      string `kong:"short='o',enum='table,json',default='table',help='Output format [table|json]'"`
+	SkipBrowser bool   `kong:"help='Do not test opening a URL in the browser'"`
+	Latency     bool   `kong:"help='Measure the TLS handshake latency to the AWS SSO and STS endpoints'"`
+	Samples     int    `kong:"default=3,help='Number of latency samples per endpoint'"`
+}
+
+// DoctorCheck is the result of a single diagnostic check
+type DoctorCheck struct {
+	Check   string `json:"check" header:"Check"`
+	Status  string `json:"status" header:"Status"`
+	Message string `json:"message" header:"Message"`
+	Hint    string `json:"hint,omitempty" header:"Hint"`
+}
+
+func (dc DoctorCheck) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(dc)
+	return gotable.GetHeaderTag(v, fieldName)
+}
+
+// DoctorLatency is the TLS handshake latency to a single AWS endpoint
+type DoctorLatency struct {
+	Endpoint string `json:"endpoint" header:"Endpoint"`
+	Host     string `json:"host" header:"Host"`
+	Samples  int    `json:"samples" header:"Samples"`
+	Min      string `json:"min" header:"Min"`
+	Avg      string `json:"avg" header:"Avg"`
+	Max      string `json:"max" header:"Max"`
+	Error    string `json:"error,omitempty" header:"Error"`
+}
+
+func (dl DoctorLatency) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(dl)
+	return gotable.GetHeaderTag(v, fieldName)
+}
+
+// Run runs our diagnostic checks and returns an error if any of them failed
+func (cc *DoctorCmd) Run(ctx *RunContext) error {
+	if ctx.Cli.Doctor.Latency {
+		return doctorLatency(ctx)
+	}
+
+	checks := []DoctorCheck{
+		doctorConfig(ctx),
+		doctorCache(ctx),
+		doctorSecureStore(ctx),
+		doctorSSOEndpoint(ctx),
+		doctorClipboard(ctx),
+	}
+	if !ctx.Cli.Doctor.SkipBrowser {
+		checks = append(checks, doctorBrowser(ctx))
+	}
+
+	switch ctx.Cli.Doctor.Output {
+	case "json":
+		b, err := marshalJSON(ctx, checks)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", string(b))
+	default:
+		ts := []gotable.TableStruct{}
+		for _, c := range checks {
+			ts = append(ts, c)
+		}
+		if err := gotable.GenerateTable(ts, []string{"Check", "Status", "Message", "Hint"}); err != nil {
+			return fmt.Errorf("Unable to generate report: %s", err.Error())
+		}
+		fmt.Printf("\n")
+	}
+
+	for _, c := range checks {
+		if c.Status == DOCTOR_FAIL {
+			return fmt.Errorf("One or more checks failed")
+		}
+	}
+	return nil
+}
+
+// doctorConfig validates each of the AWS SSO instances in our config
+func doctorConfig(ctx *RunContext) DoctorCheck {
+	check := DoctorCheck{Check: "Config"}
+
+	names := []string{}
+	for name := range ctx.Settings.SSO {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := ctx.Settings.SSO[name]
+		if _, err := sso.ValidateStartUrl(s.StartUrl); err != nil {
+			check.Status = DOCTOR_FAIL
+			check.Message = fmt.Sprintf("%s: %s", name, err.Error())
+			check.Hint = fmt.Sprintf("Fix the StartUrl in %s", ctx.Settings.ConfigFile())
+			return check
+		}
+		if s.SSORegion == "" {
+			check.Status = DOCTOR_FAIL
+			check.Message = fmt.Sprintf("%s: missing SSORegion", name)
+			check.Hint = fmt.Sprintf("Set the SSORegion in %s", ctx.Settings.ConfigFile())
+			return check
+		}
+	}
+
+	check.Status = DOCTOR_OK
+	check.Message = fmt.Sprintf("%s is valid", ctx.Settings.ConfigFile())
+	return check
+}
+
+// doctorCache verifies we can read the cache file and write to its directory
+func doctorCache(ctx *RunContext) DoctorCheck {
+	check := DoctorCheck{Check: "Cache"}
+	cacheFile := ctx.Settings.Cache.CacheFile()
+
+	if _, err := ioutil.ReadFile(cacheFile); err != nil {
+		if os.IsNotExist(err) {
+			check.Status = DOCTOR_WARN
+			check.Message = fmt.Sprintf("%s does not exist", cacheFile)
+			check.Hint = "Run `aws-sso cache`"
+		} else {
+			check.Status = DOCTOR_FAIL
+			check.Message = err.Error()
+			check.Hint = fmt.Sprintf("Check the permissions of %s", cacheFile)
+		}
+		return check
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(cacheFile), ".doctor-*")
+	if err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Message = fmt.Sprintf("Unable to write to %s: %s", filepath.Dir(cacheFile), err.Error())
+		check.Hint = fmt.Sprintf("Check the permissions of %s", filepath.Dir(cacheFile))
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	check.Status = DOCTOR_OK
+	check.Message = fmt.Sprintf("%s is readable and writable", cacheFile)
+	return check
+}
+
+// doctorSecureStore verifies we can read our AWS SSO token from the SecureStore
+func doctorSecureStore(ctx *RunContext) DoctorCheck {
+	check := DoctorCheck{Check: "SecureStore"}
+
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Message = err.Error()
+		return check
+	}
+
+	if !sso.NewAWSSSO(s, &ctx.Store).ValidAuthToken() {
+		check.Status = DOCTOR_WARN
+		check.Message = fmt.Sprintf("No valid AWS SSO token in %s SecureStore", ctx.Settings.SecureStore)
+		check.Hint = "Run `aws-sso reauth`.  If this persists, check access to your SecureStore"
+		return check
+	}
+
+	check.Status = DOCTOR_OK
+	check.Message = fmt.Sprintf("Found valid AWS SSO token in %s SecureStore", ctx.Settings.SecureStore)
+	return check
+}
+
+// doctorSSOEndpoint verifies we can talk to the AWS SSO portal
+func doctorSSOEndpoint(ctx *RunContext) DoctorCheck {
+	check := DoctorCheck{Check: "AWS SSO"}
+
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Message = err.Error()
+		return check
+	}
+
+	client := *ctx.Settings.HTTPClient()
+	client.Timeout = 10 * time.Second
+	req, err := http.NewRequestWithContext(ctx.Context, http.MethodGet, s.StartUrl, nil)
+	if err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Message = err.Error()
+		return check
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Message = err.Error()
+		check.Hint = "Check your network connection and the ProxyUrl and CABundle options"
+		return check
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		check.Status = DOCTOR_FAIL
+		check.Message = fmt.Sprintf("%s returned %s", s.StartUrl, resp.Status)
+		check.Hint = "Check the StartUrl in your config"
+		return check
+	}
+
+	check.Status = DOCTOR_OK
+	check.Message = fmt.Sprintf("Connected to %s", s.StartUrl)
+	return check
+}
+
+// doctorClipboard writes a test value to the clipboard and then restores
+// the original contents
+func doctorClipboard(ctx *RunContext) DoctorCheck {
+	check := DoctorCheck{Check: "Clipboard"}
+	status := DOCTOR_WARN
+	if utils.UrlActionIncludes(ctx.Settings.UrlAction, "clip") {
+		status = DOCTOR_FAIL
+	}
+
+	orig, _ := clipboard.ReadAll()
+	if err := utils.HandleUrl("clip", "", "aws-sso doctor", "", ""); err != nil {
+		check.Status = status
+		check.Message = err.Error()
+		check.Hint = "Install xclip, xsel or wl-clipboard or use a different UrlAction"
+		return check
+	}
+	_ = clipboard.WriteAll(orig)
+
+	check.Status = DOCTOR_OK
+	check.Message = "Able to copy to the clipboard"
+	return check
+}
+
+// doctorBrowser opens a harmless URL in the configured browser
+func doctorBrowser(ctx *RunContext) DoctorCheck {
+	check := DoctorCheck{Check: "Browser"}
+	status := DOCTOR_WARN
+	if utils.UrlActionIncludes(ctx.Settings.UrlAction, "open") {
+		status = DOCTOR_FAIL
+	}
+
+	browser := ctx.Settings.Browser
+	if browser == "" {
+		browser = "default browser"
+	}
+
+	if err := utils.HandleUrl("open", ctx.Settings.Browser, DOCTOR_TEST_URL, "", ""); err != nil {
+		check.Status = status
+		check.Message = err.Error()
+		check.Hint = "Check the Browser option in your config or use a different UrlAction"
+		return check
+	}
+
+	check.Status = DOCTOR_OK
+	check.Message = fmt.Sprintf("Opened %s in %s", DOCTOR_TEST_URL, browser)
+	return check
+}
+
+// doctorLatency times the TCP connect + TLS handshake to the AWS SSO portal,
+// OIDC and STS endpoints in the SSORegion.  No credentials are required.
+func doctorLatency(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+
+	// honor the CABundle & TLS policy.  Proxies are not used since we dial directly
+	config := ctx.Settings.TLSConfig()
+
+	partition := utils.RegionPartition(s.SSORegion)
+	endpoints := []struct {
+		Name    string
+		Service string
+	}{
+		{"AWS SSO", "portal.sso"},
+		{"OIDC", "oidc"},
+		{"STS", "sts"},
+	}
+
+	results := []DoctorLatency{}
+	failed := false
+	for _, e := range endpoints {
+		host := utils.ServiceHost(partition, e.Service, s.SSORegion)
+		r := DoctorLatency{
+			Endpoint: e.Name,
+			Host:     host,
+		}
+		stats, err := utils.MeasureLatency(ctx.Context, host+":443", ctx.Cli.Doctor.Samples, 10*time.Second, config)
+		if err != nil {
+			r.Error = err.Error()
+			failed = true
+		}
+		r.Samples = stats.Samples
+		if stats.Samples > 0 {
+			r.Min = stats.Min.Round(100 * time.Microsecond).String()
+			r.Avg = stats.Avg.Round(100 * time.Microsecond).String()
+			r.Max = stats.Max.Round(100 * time.Microsecond).String()
+		}
+		results = append(results, r)
+	}
+
+	switch ctx.Cli.Doctor.Output {
+	case "json":
+		b, err := marshalJSON(ctx, results)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", string(b))
+	default:
+		ts := []gotable.TableStruct{}
+		for _, r := range results {
+			ts = append(ts, r)
+		}
+		fields := []string{"Endpoint", "Host", "Samples", "Min", "Avg", "Max", "Error"}
+		if err := gotable.GenerateTable(ts, fields); err != nil {
+			return fmt.Errorf("Unable to generate report: %s", err.Error())
+		}
+		fmt.Printf("\n")
+	}
+
+	if failed {
+		return fmt.Errorf("Unable to connect to one or more endpoints")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type EcrLoginCmd struct {
+	// AWS Params
+	Arn        string `kong:"short='a',help='ARN of role to assume',xor='arn-1',xor='arn-2',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',xor='arn-1',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to assume',xor='arn-2',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to assume',xor='arn-1',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+	Region     string `kong:"help='AWS Region of the ECR registry (default: role DefaultRegion)',predictor='region'"`
+
+	Exec   bool   `kong:"name='run',help='Run docker login instead of printing the command'"`
+	Docker string `kong:"help='Path to the docker (or compatible) command',default='docker'"`
+}
+
+func (cc *EcrLoginCmd) Run(ctx *RunContext) error {
+	var err error
+
+	role := ctx.Cli.EcrLogin.Role
+	account := ctx.Cli.EcrLogin.AccountId
+
+	if ctx.Cli.EcrLogin.Profile != "" {
+		cache := ctx.Settings.Cache.GetSSO()
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.EcrLogin.Profile, ctx.Settings)
+		if err != nil {
+			return err
+		}
+
+		role = rFlat.RoleName
+		account = rFlat.AccountId
+	} else if ctx.Cli.EcrLogin.Arn != "" {
+		account, role, err = utils.ParseRoleARN(ctx.Cli.EcrLogin.Arn)
+		if err != nil {
+			return err
+		}
+		if err := checkRoleAccess(ctx, account, role, ctx.Cli.EcrLogin.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if role == "" || account == 0 {
+		return fmt.Errorf("Please specify --arn, --profile or --account and --role")
+	}
+	if ctx.Cli.EcrLogin.AccountId != 0 {
+		if err := checkAccountAccess(ctx, account, ctx.Cli.EcrLogin.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	region := ctx.Cli.EcrLogin.Region
+	if region == "" {
+		region = ctx.Settings.GetDefaultRegion(account, role, false)
+	}
+	if region == "" {
+		return fmt.Errorf("Please specify --region")
+	}
+
+	awssso := doAuth(ctx)
+	creds := GetRoleCredentials(ctx, awssso, account, role)
+
+	client, err := sso.NewECRClient(ctx.Context, creds, region, ctx.Settings.HTTPClient())
+	if err != nil {
+		return err
+	}
+	auth, err := sso.GetECRAuthorization(ctx.Context, client)
+	if err != nil {
+		return err
+	}
+	log.Debugf("ECR authorization token for %s expires at %d", auth.Endpoint, auth.ExpiresAt)
+
+	if !ctx.Cli.EcrLogin.Exec {
+		// the password is only passed via stdin so it never shows up in `ps`
+		fmt.Printf("echo '%s' | %s login --username %s --password-stdin %s\n",
+			auth.Password, ctx.Cli.EcrLogin.Docker, auth.Username, auth.Endpoint)
+		return nil
+	}
+
+	cmd := exec.Command(ctx.Cli.EcrLogin.Docker, "login", // #nosec
+		"--username", auth.Username, "--password-stdin", auth.Endpoint)
+	cmd.Stdin = strings.NewReader(auth.Password)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
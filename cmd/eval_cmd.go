@@ -19,10 +19,14 @@ package main
  */
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	// log "github.com/sirupsen/logrus"
 	"github.com/synfinatic/aws-sso-cli/utils"
@@ -30,15 +34,26 @@ import (
 
 type EvalCmd struct {
 	// AWS Params
-	Arn       string `kong:"short='a',help='ARN of role to assume',predictor='arn'"`
-	AccountId int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',predictor='accountId'"`
-	Role      string `kong:"short='R',help='Name of AWS Role to assume',predictor='role'"`
-	Profile   string `kong:"short='p',help='Name of AWS Profile to assume',predictor='profile'"`
-
-	Clear    bool   `kong:"short='c',help='Generate \"unset XXXX\" commands to clear environment'"`
-	NoRegion bool   `kong:"short='n',help='Do not set/clear AWS_DEFAULT_REGION from config.yaml'"`
-	Refresh  bool   `kong:"short='r',help='Refresh current IAM credentials'"`
-	EnvArn   string `kong:"hidden,env='AWS_SSO_ROLE_ARN'"` // used for refresh
+	Arn        string `kong:"short='a',help='ARN of role to assume',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to assume',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to assume',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+	Yes        bool   `kong:"short='y',help='Do not ask for confirmation for roles matching ConfirmTags'"`
+
+	PolicyArn  []string `kong:"help='ARN of IAM policy to scope down the session of a role using Via (repeatable)'"`
+	PolicyFile string   `kong:"help='Path to JSON IAM policy to scope down the session of a role using Via'"`
+
+	Clear    bool `kong:"short='c',help='Generate \"unset XXXX\" commands to clear environment'"`
+	NoRegion bool `kong:"short='n',help='Do not set/clear AWS_DEFAULT_REGION from config.yaml'"`
+	Refresh  bool `kong:"short='r',help='Refresh current IAM credentials'"`
+
+	RefreshIfExpiring time.Duration `kong:"help='Refresh cached credentials which expire within this time (ex: 15m)'"`
+	EnvArn            string        `kong:"hidden,env='AWS_SSO_ROLE_ARN'"` // used for refresh
+
+	Shell string `kong:"enum='bash,zsh,fish',default='bash',help='Shell syntax to generate [bash|zsh|fish]'"`
+	Write string `kong:"help='Write the script to the given file instead of stdout'"`
+	Force bool   `kong:"help='Overwrite the --write file if it exists'"`
 }
 
 func (cc *EvalCmd) Run(ctx *RunContext) error {
@@ -51,8 +66,18 @@ func (cc *EvalCmd) Run(ctx *RunContext) error {
 	var role string
 	var accountid int64
 
+	if ctx.Cli.Eval.Force && ctx.Cli.Eval.Write == "" {
+		return fmt.Errorf("--force requires --write")
+	}
+
+	if err = setMinRemaining(ctx, ctx.Cli.Eval.RefreshIfExpiring); err != nil {
+		return err
+	}
+
 	if ctx.Cli.Eval.Clear {
-		return unsetEnvVars(ctx)
+		return writeEvalScript(ctx, func(w io.Writer) {
+			unsetEnvVars(ctx, w, ctx.Cli.Eval.Shell)
+		})
 	}
 
 	// refreshing?
@@ -78,28 +103,100 @@ func (cc *EvalCmd) Run(ctx *RunContext) error {
 		if err != nil {
 			return err
 		}
+		if err := checkRoleAccess(ctx, accountid, role, ctx.Cli.Eval.NoValidate); err != nil {
+			return err
+		}
 	} else if ctx.Cli.Eval.Role != "" && ctx.Cli.Eval.AccountId > 0 {
 		// if CLI args are speecified, use that
 		role = ctx.Cli.Eval.Role
 		accountid = ctx.Cli.Eval.AccountId
+		if err := checkAccountAccess(ctx, accountid, ctx.Cli.Eval.NoValidate); err != nil {
+			return err
+		}
 	} else {
 		return fmt.Errorf("Please specify --refresh, --clear, --arn, or --account and --role")
 	}
+	if err = confirmRole(ctx, accountid, role, ctx.Cli.Eval.Yes); err != nil {
+		return err
+	}
 	region := ctx.Settings.GetDefaultRegion(accountid, role, ctx.Cli.Eval.NoRegion)
 
 	awssso := doAuth(ctx)
 
-	for k, v := range execShellEnvs(ctx, awssso, accountid, role, region) {
-		if len(v) == 0 {
-			fmt.Printf("unset %s\n", k)
-		} else {
-			fmt.Printf("export %s=\"%s\"\n", k, v)
+	envs := execShellEnvs(ctx, awssso, accountid, role, region)
+	return writeEvalScript(ctx, func(w io.Writer) {
+		for k, v := range envs {
+			if len(v) == 0 {
+				unsetEnvVar(w, ctx.Cli.Eval.Shell, k)
+			} else {
+				exportEnvVar(w, ctx.Cli.Eval.Shell, k, v)
+			}
 		}
+	})
+}
+
+// exportEnvVar writes the command to set the environment variable in the given shell
+func exportEnvVar(w io.Writer, shell, key, value string) {
+	switch shell {
+	case "fish":
+		fmt.Fprintf(w, "set -gx %s \"%s\"\n", key, value)
+	default:
+		fmt.Fprintf(w, "export %s=\"%s\"\n", key, value)
+	}
+}
+
+// unsetEnvVar writes the command to clear the environment variable in the given shell
+func unsetEnvVar(w io.Writer, shell, key string) {
+	switch shell {
+	case "fish":
+		fmt.Fprintf(w, "set -e %s\n", key)
+	default:
+		fmt.Fprintf(w, "unset %s\n", key)
 	}
+}
+
+// writeEvalScript writes the script to stdout or the --write file.  The file
+// contains credentials, so it is only readable by the user.
+func writeEvalScript(ctx *RunContext, script func(io.Writer)) error {
+	buf := bytes.Buffer{}
+	script(&buf)
+
+	path := ctx.Cli.Eval.Write
+	if path == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	path = utils.GetHomePath(path)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !ctx.Cli.Eval.Force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("Refusing to overwrite %s without --force", path)
+	} else if err != nil {
+		return fmt.Errorf("Unable to write %s: %s", path, err.Error())
+	}
+
+	// existing files may have been created with looser permissions
+	if err = f.Chmod(0600); err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to chmod %s: %s", path, err.Error())
+	}
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to write %s: %s", path, err.Error())
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", path, err.Error())
+	}
+	fmt.Printf("%s\n", path)
 	return nil
 }
 
-func unsetEnvVars(ctx *RunContext) error {
+func unsetEnvVars(ctx *RunContext, w io.Writer, shell string) {
 	envs := []string{
 		"AWS_ACCESS_KEY_ID",
 		"AWS_SECRET_ACCESS_KEY",
@@ -128,7 +225,6 @@ func unsetEnvVars(ctx *RunContext) error {
 	}
 
 	for _, e := range envs {
-		fmt.Printf("unset %s\n", e)
+		unsetEnvVar(w, shell, e)
 	}
-	return nil
 }
@@ -19,43 +19,87 @@ package main
  */
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"time"
 
 	"github.com/c-bata/go-prompt"
 	log "github.com/sirupsen/logrus"
 	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
 	"github.com/synfinatic/aws-sso-cli/utils"
 )
 
+// refresh the credentials served by --ecs-server at least this long before they expire
+const ECS_MIN_REMAINING = 15 * time.Minute
+
 type ExecCmd struct {
 	// AWS Params
-	Arn       string `kong:"short='a',help='ARN of role to assume',env='AWS_SSO_ROLE_ARN',predictor='arn'"`
-	AccountId int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',env='AWS_SSO_ACCOUNT_ID',predictor='accountId'"`
-	Role      string `kong:"short='R',help='Name of AWS Role to assume',env='AWS_SSO_ROLE_NAME',predictor='role'"`
-	Profile   string `kong:"short='p',help='Name of AWS Profile to assume',predictor='profile'"`
-	NoRegion  bool   `kong:"short='n',help='Do not set AWS_DEFAULT_REGION from config.yaml'"`
+	Arn        string `kong:"short='a',help='ARN of role to assume',env='AWS_SSO_ROLE_ARN',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',env='AWS_SSO_ACCOUNT_ID',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to assume',env='AWS_SSO_ROLE_NAME',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to assume',predictor='profile'"`
+	Alias      string `kong:"help='Role alias from the Aliases config to assume',predictor='alias'"`
+	NoRegion   bool   `kong:"short='n',help='Do not set AWS_DEFAULT_REGION from config.yaml'"`
+	SelectOnly bool   `kong:"help='Pick a role interactively and print the ARN without running a command'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+	Yes        bool   `kong:"short='y',help='Do not ask for confirmation for roles matching ConfirmTags'"`
+
+	NoDefaultExec bool `kong:"help='Start a shell instead of the DefaultExec command when no command is given'"`
+
+	RefreshIfExpiring time.Duration `kong:"help='Refresh cached credentials which expire within this time (ex: 15m)'"`
+	EcsServer         bool          `kong:"name='ecs-server',help='Provide credentials via a local ECS endpoint like aws-vault exec --ecs-server'"`
+
+	PermissionSet string `kong:"help='ARN of the AWS SSO permission set to assume (requires --account)'"`
+
+	PolicyArn  []string `kong:"help='ARN of IAM policy to scope down the session of a role using Via (repeatable)'"`
+	PolicyFile string   `kong:"help='Path to JSON IAM policy to scope down the session of a role using Via'"`
 
 	// Exec Params
-	Cmd  string   `kong:"arg,optional,name='command',help='Command to execute',env='SHELL'"`
+	Cmd  string   `kong:"arg,optional,name='command',help='Command to execute (default: DefaultExec or $SHELL)'"`
 	Args []string `kong:"arg,optional,passthrough,name='args',help='Associated arguments for the command'"`
 }
 
 func (cc *ExecCmd) Run(ctx *RunContext) error {
+	if ctx.Cli.Exec.SelectOnly {
+		return selectOnly(ctx)
+	}
+
 	err := checkAwsEnvironment()
 	if err != nil {
 		log.WithError(err).Fatalf("Unable to continue")
 	}
 
-	if runtime.GOOS == "windows" && ctx.Cli.Exec.Cmd == "" {
-		// Windows doesn't set $SHELL, so default to CommandPrompt
-		ctx.Cli.Exec.Cmd = "cmd.exe"
+	if err = setMinRemaining(ctx, ctx.Cli.Exec.RefreshIfExpiring); err != nil {
+		return err
+	}
+
+	if ctx.Cli.Exec.Cmd == "" {
+		switch {
+		case len(ctx.Settings.DefaultExec) > 0 && !ctx.Cli.Exec.NoDefaultExec:
+			ctx.Cli.Exec.Cmd = ctx.Settings.DefaultExec[0]
+			ctx.Cli.Exec.Args = ctx.Settings.DefaultExec[1:]
+		case runtime.GOOS == "windows":
+			// Windows doesn't set $SHELL, so default to CommandPrompt
+			ctx.Cli.Exec.Cmd = "cmd.exe"
+		default:
+			ctx.Cli.Exec.Cmd = os.Getenv("SHELL")
+		}
 	}
 
 	// Did user specify the ARN or account/role?
-	if ctx.Cli.Exec.Profile != "" {
+	if ctx.Cli.Exec.Alias != "" {
+		accountid, role, err := ctx.Settings.ResolveAlias(ctx.Cli.Exec.Alias)
+		if err != nil {
+			return err
+		}
+		awssso := doAuth(ctx)
+
+		return execCmd(ctx, awssso, accountid, role)
+	} else if ctx.Cli.Exec.Profile != "" {
 		awssso := doAuth(ctx)
 		cache := ctx.Settings.Cache.GetSSO()
 		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.Exec.Profile, ctx.Settings)
@@ -71,12 +115,36 @@ func (cc *ExecCmd) Run(ctx *RunContext) error {
 		if err != nil {
 			return err
 		}
+		if err := checkRoleAccess(ctx, accountid, role, ctx.Cli.Exec.NoValidate); err != nil {
+			return err
+		}
 
 		return execCmd(ctx, awssso, accountid, role)
+	} else if ctx.Cli.Exec.PermissionSet != "" {
+		if ctx.Cli.Exec.AccountId == 0 {
+			return fmt.Errorf("--permission-set requires --account")
+		}
+		if err := checkAccountAccess(ctx, ctx.Cli.Exec.AccountId, ctx.Cli.Exec.NoValidate); err != nil {
+			return err
+		}
+		if _, err := sso.ParsePermissionSetARN(ctx.Cli.Exec.PermissionSet); err != nil {
+			return err
+		}
+		awssso := doAuth(ctx)
+
+		role, err := resolvePermissionSet(ctx, awssso, ctx.Cli.Exec.AccountId, ctx.Cli.Exec.PermissionSet)
+		if err != nil {
+			return err
+		}
+		ctx.Cli.Exec.Role = role
+		return execCmd(ctx, awssso, ctx.Cli.Exec.AccountId, role)
 	} else if ctx.Cli.Exec.AccountId != 0 || ctx.Cli.Exec.Role != "" {
 		if ctx.Cli.Exec.AccountId == 0 || ctx.Cli.Exec.Role == "" {
 			return fmt.Errorf("Please specify both --account and --role")
 		}
+		if err := checkAccountAccess(ctx, ctx.Cli.Exec.AccountId, ctx.Cli.Exec.NoValidate); err != nil {
+			return err
+		}
 		awssso := doAuth(ctx)
 
 		return execCmd(ctx, awssso, ctx.Cli.Exec.AccountId, ctx.Cli.Exec.Role)
@@ -87,7 +155,7 @@ func (cc *ExecCmd) Run(ctx *RunContext) error {
 	if err != nil {
 		return err
 	}
-	if err = ctx.Settings.Cache.Expired(sso); err != nil {
+	if err = ctx.Settings.Cache.Expired(sso); err != nil && !ctx.Settings.Offline() {
 		log.Infof(err.Error())
 		c := &CacheCmd{}
 		if err = c.Run(ctx); err != nil {
@@ -113,6 +181,10 @@ func (cc *ExecCmd) Run(ctx *RunContext) error {
 
 // Executes Cmd+Args in the context of the AWS Role creds
 func execCmd(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string) error {
+	if err := confirmRole(ctx, accountid, role, ctx.Cli.Exec.Yes); err != nil {
+		return err
+	}
+
 	region := ctx.Settings.GetDefaultRegion(ctx.Cli.Exec.AccountId, ctx.Cli.Exec.Role, ctx.Cli.Exec.NoRegion)
 
 	ctx.Settings.Cache.AddHistory(utils.MakeRoleARN(accountid, role))
@@ -127,14 +199,53 @@ func execCmd(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string)
 	cmd.Stdin = os.Stdin
 	cmd.Env = os.Environ() // copy our current environment to the executor
 
+	envs := execShellEnvs(ctx, awssso, accountid, role, region)
+	if ctx.Cli.Exec.EcsServer {
+		server, err := startECSServer(ctx, awssso, accountid, role)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		ecsShellEnvs(envs, server, region)
+	}
+
 	// add the variables we need for AWS to the executor without polluting our
 	// own process
-	for k, v := range execShellEnvs(ctx, awssso, accountid, role, region) {
+	for k, v := range envs {
 		log.Debugf("Setting %s = %s", k, v)
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 	// just do it!
-	return cmd.Run()
+	err := cmd.Run()
+
+	// the hook only runs if our command did
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		runPostExecHook(ctx, accountid, role, cmd.Env, 0)
+	case errors.As(err, &exitErr):
+		runPostExecHook(ctx, accountid, role, cmd.Env, exitErr.ExitCode())
+	}
+	return err
+}
+
+// runPostExecHook runs the PostExecHook (if any) for the role with the same
+// environment as the command plus AWS_SSO_EXIT_CODE.  The hook is never passed
+// to a shell and failures only generate a warning.
+func runPostExecHook(ctx *RunContext, accountid int64, role string, env []string, exitCode int) {
+	hook := ctx.Settings.GetPostExecHook(accountid, role)
+	if len(hook) == 0 {
+		return
+	}
+
+	cmd := exec.Command(hook[0], hook[1:]...) // #nosec
+	cmd.Env = append(env, fmt.Sprintf("AWS_SSO_EXIT_CODE=%d", exitCode))
+	cmd.Stdout = os.Stderr // don't pollute our stdout
+	cmd.Stderr = os.Stderr
+	log.Debugf("Running PostExecHook: %v", hook)
+	if err := cmd.Run(); err != nil {
+		log.WithError(err).Warnf("PostExecHook failed")
+	}
 }
 
 func execShellEnvs(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role, region string) map[string]string {
@@ -183,6 +294,46 @@ func execShellEnvs(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role, r
 	return shellVars
 }
 
+// startECSServer serves the role credentials on a local ECS container
+// credentials endpoint, refreshing them before they expire
+func startECSServer(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string) (*sso.ECSServer, error) {
+	if _, ok := os.LookupEnv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); ok {
+		return nil, fmt.Errorf("Conflicting environment variable 'AWS_CONTAINER_CREDENTIALS_FULL_URI' is set")
+	}
+
+	// the AWS SDKs ask for new credentials shortly before they expire
+	if ctx.MinRemaining < ECS_MIN_REMAINING {
+		ctx.MinRemaining = ECS_MIN_REMAINING
+	}
+
+	server, err := sso.NewECSServer(func() (*storage.RoleCredentials, error) {
+		return roleCredentials(ctx, awssso, accountid, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := server.Serve(); err != nil {
+			log.WithError(err).Errorf("ECS server failed")
+		}
+	}()
+	log.Debugf("ECS server listening on %s", server.Url())
+	return server, nil
+}
+
+// ecsShellEnvs replaces the static credentials with the variables `aws-vault exec --ecs-server` sets
+func ecsShellEnvs(envs map[string]string, server *sso.ECSServer, region string) {
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_SSO_SESSION_EXPIRATION"} {
+		delete(envs, k)
+	}
+	envs["AWS_CONTAINER_CREDENTIALS_FULL_URI"] = server.Url()
+	envs["AWS_CONTAINER_AUTHORIZATION_TOKEN"] = server.AuthToken()
+	envs["AWS_VAULT"] = envs["AWS_SSO_PROFILE"]
+	if region != "" {
+		envs["AWS_REGION"] = region
+	}
+}
+
 // returns an error if we have existing AWS env vars
 func checkAwsEnvironment() error {
 	checkVars := []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_PROFILE"}
@@ -193,3 +344,27 @@ func checkAwsEnvironment() error {
 	}
 	return nil
 }
+
+// resolvePermissionSet returns the name of the role for the permission set ARN
+// in the given account using the PermissionSetRole to query AWS SSO
+func resolvePermissionSet(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, arn string) (string, error) {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return "", err
+	}
+	if s.PermissionSetRole == "" {
+		return "", fmt.Errorf("PermissionSetRole must be configured to use --permission-set")
+	}
+
+	aId, rName, err := utils.ParseRoleARN(s.PermissionSetRole)
+	if err != nil {
+		return "", fmt.Errorf("Invalid PermissionSetRole: %s", err.Error())
+	}
+	creds := GetRoleCredentials(ctx, awssso, aId, rName)
+
+	api, err := sso.NewSSOAdminClient(ctx.Context, creds, s.SSORegion, ctx.Settings.HTTPClient())
+	if err != nil {
+		return "", err
+	}
+	return sso.ResolvePermissionSet(ctx.Context, api, ctx.Settings.Cache.GetSSO().Roles, accountid, arn)
+}
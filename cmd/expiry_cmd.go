@@ -0,0 +1,78 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type ExpiryCmd struct {
+	Arn       string `kong:"short='a',help='ARN of role',env='AWS_SSO_ROLE_ARN',predictor='arn'"`
+	AccountId int64  `kong:"name='account',short='A',help='AWS AccountID of role',predictor='accountId'"`
+	Role      string `kong:"short='R',help='Name of AWS Role',predictor='role'"`
+	Profile   string `kong:"short='p',help='Name of AWS Profile',predictor='profile'"`
+	Format    string `kong:"short='f',enum='human,unix,rfc3339',default='human',help='Output format [human|unix|rfc3339]'"`
+}
+
+// Run prints when the cached STS credentials for the role expire.  Never refreshes
+// the cache and returns an error if the credentials are missing or expired.
+func (cc *ExpiryCmd) Run(ctx *RunContext) error {
+	var err error
+	var rFlat *sso.AWSRoleFlat
+	cache := ctx.Settings.Cache.GetSSO()
+
+	if ctx.Cli.Expiry.Profile != "" {
+		rFlat, err = cache.Roles.GetRoleByProfile(ctx.Cli.Expiry.Profile, ctx.Settings)
+	} else if ctx.Cli.Expiry.Arn != "" {
+		rFlat, err = ctx.Settings.Cache.GetRole(ctx.Cli.Expiry.Arn)
+	} else if ctx.Cli.Expiry.AccountId != 0 && ctx.Cli.Expiry.Role != "" {
+		rFlat, err = cache.Roles.GetRole(ctx.Cli.Expiry.AccountId, ctx.Cli.Expiry.Role)
+	} else {
+		return fmt.Errorf("Please specify --profile, --arn, or --account and --role")
+	}
+	if err != nil {
+		return err
+	}
+
+	if rFlat.Expires == 0 {
+		return fmt.Errorf("No cached STS credentials for %s", rFlat.Arn)
+	} else if rFlat.IsExpired() {
+		return fmt.Errorf("STS credentials for %s have expired", rFlat.Arn)
+	} else if !rFlat.IsValid() {
+		return fmt.Errorf("STS credentials for %s expire in less than %s", rFlat.Arn, utils.MinValidRemaining())
+	}
+
+	switch ctx.Cli.Expiry.Format {
+	case "unix":
+		fmt.Printf("%d", rFlat.Expires)
+	case "rfc3339":
+		fmt.Printf("%s", time.Unix(rFlat.Expires, 0).Format(time.RFC3339))
+	default:
+		exp, err := utils.TimeRemain(rFlat.Expires, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", exp)
+	}
+	return nil
+}
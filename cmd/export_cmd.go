@@ -0,0 +1,146 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// used to provide the export/import passphrase non-interactively
+const ENV_BUNDLE_PASSPHRASE = "AWS_SSO_BUNDLE_PASSPHRASE" // #nosec
+
+type ExportCmd struct {
+	Out            string `kong:"required,help='Write the encrypted bundle to this file'"`
+	IncludeSecrets bool   `kong:"help='Include the AWS SSO client registration and token from the SecureStore'"`
+	Force          bool   `kong:"help='Overwrite an existing file'"`
+
+	ClientRegistration bool `kong:"help='Only write the AWS SSO client registration as JSON for import --client-registration'"`
+}
+
+// Run writes our config and cache to a passphrase encrypted file which
+// can be restored on another machine via `aws-sso import`
+func (cc *ExportCmd) Run(ctx *RunContext) error {
+	if ctx.Cli.Export.ClientRegistration {
+		return exportClientRegistration(ctx)
+	}
+
+	b, err := sso.NewBundle(ctx.Settings, ctx.Store, ctx.Cli.Export.IncludeSecrets)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := bundlePassphrase(true)
+	if err != nil {
+		return err
+	}
+	data, err := b.Encrypt(passphrase)
+	if err != nil {
+		return fmt.Errorf("Unable to encrypt bundle: %s", err.Error())
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !ctx.Cli.Export.Force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(ctx.Cli.Export.Out, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to create %s: %s", ctx.Cli.Export.Out, err.Error())
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", ctx.Cli.Export.Out, err.Error())
+	}
+
+	fmt.Printf("Wrote %s\n", ctx.Cli.Export.Out)
+	return nil
+}
+
+// exportClientRegistration writes the client registration for the selected
+// AWS SSO instance so it can be shared with other hosts.  The file contains
+// the client secret, so it is only readable by the user.
+func exportClientRegistration(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	client, err := sso.NewAWSSSO(s, &ctx.Store).GetClientRegistration()
+	if err != nil {
+		return fmt.Errorf("No valid client registration to export: %s", err.Error())
+	}
+	data, err := json.MarshalIndent(client, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !ctx.Cli.Export.Force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(ctx.Cli.Export.Out, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to create %s: %s", ctx.Cli.Export.Out, err.Error())
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", ctx.Cli.Export.Out, err.Error())
+	}
+
+	fmt.Printf("Wrote %s which contains the client secret: keep it safe!\n", ctx.Cli.Export.Out)
+	return nil
+}
+
+// bundlePassphrase returns the passphrase from $AWS_SSO_BUNDLE_PASSPHRASE or
+// prompts for it, optionally twice to confirm a new passphrase
+func bundlePassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(ENV_BUNDLE_PASSPHRASE); passphrase != "" {
+		return passphrase, nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("Please set $%s when not running in a terminal", ENV_BUNDLE_PASSPHRASE)
+	}
+
+	fmt.Fprintf(os.Stderr, "Passphrase: ")
+	pass1, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintf(os.Stderr, "\n")
+	if err != nil {
+		return "", err
+	}
+	if len(pass1) == 0 {
+		return "", fmt.Errorf("Aborting with empty passphrase")
+	}
+	if !confirm {
+		return string(pass1), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Verify passphrase: ")
+	pass2, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintf(os.Stderr, "\n")
+	if err != nil {
+		return "", err
+	}
+	if string(pass1) != string(pass2) {
+		return "", fmt.Errorf("Passphrases do not match")
+	}
+	return string(pass1), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type FetchCmd struct {
+	// AWS Params
+	Arn        string `kong:"short='a',help='ARN of role to fetch credentials for',xor='arn-1',xor='arn-2',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to fetch credentials for',xor='arn-1',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to fetch credentials for',xor='arn-2',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to fetch credentials for',xor='arn-1',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+
+	RefreshIfExpiring time.Duration `kong:"help='Refresh cached credentials which expire within this time (ex: 15m)'"`
+	NonInteractive    bool          `kong:"help='Fail instead of prompting for AWS SSO login (default when stdin is not a terminal)'"`
+}
+
+// Run caches the STS credentials for the role in the SecureStore without
+// printing anything.  Cached credentials are reused unless they expire
+// within --refresh-if-expiring.
+func (cc *FetchCmd) Run(ctx *RunContext) error {
+	var err error
+
+	role := ctx.Cli.Fetch.Role
+	account := ctx.Cli.Fetch.AccountId
+
+	if ctx.Cli.Fetch.Profile != "" {
+		cache := ctx.Settings.Cache.GetSSO()
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.Fetch.Profile, ctx.Settings)
+		if err != nil {
+			return err
+		}
+
+		role = rFlat.RoleName
+		account = rFlat.AccountId
+	} else if ctx.Cli.Fetch.Arn != "" {
+		account, role, err = utils.ParseRoleARN(ctx.Cli.Fetch.Arn)
+		if err != nil {
+			return err
+		}
+		if err := checkRoleAccess(ctx, account, role, ctx.Cli.Fetch.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if role == "" || account == 0 {
+		return fmt.Errorf("Please specify --arn, --profile or --account and --role")
+	}
+	if ctx.Cli.Fetch.AccountId != 0 {
+		if err := checkAccountAccess(ctx, account, ctx.Cli.Fetch.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if err = setMinRemaining(ctx, ctx.Cli.Fetch.RefreshIfExpiring); err != nil {
+		return err
+	}
+	if err = checkNonInteractiveLogin(ctx, ctx.Cli.Fetch.NonInteractive); err != nil {
+		return err
+	}
+
+	awssso := doAuth(ctx)
+	creds := GetRoleCredentials(ctx, awssso, account, role)
+	log.Debugf("Credentials for %s expire at %s", creds.RoleArn(), creds.ExpireString())
+	return nil
+}
@@ -20,6 +20,7 @@ package main
 import (
 	log "github.com/sirupsen/logrus"
 	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
 )
 
 // FlushCmd defines the Kong args for the flush command
@@ -65,7 +66,7 @@ func flushSts(ctx *RunContext, awssso *sso.AWSSSO) {
 	cache := ctx.Settings.Cache.GetSSO()
 	for _, role := range cache.Roles.GetAllRoles() {
 		if !role.IsExpired() {
-			if err := ctx.Store.DeleteRoleCredentials(role.Arn); err != nil {
+			if err := storage.DeleteRoleCredentialsForArn(ctx.Store, role.Arn); err != nil {
 				log.WithError(err).Errorf("Unable to delete STS token for %s", role.Arn)
 			}
 		}
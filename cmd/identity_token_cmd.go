@@ -0,0 +1,122 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type IdentityTokenCmd struct {
+	Arn        string        `kong:"short='a',help='ARN of role to assert the identity of',xor='arn-1',xor='arn-2',predictor='arn',env='AWS_SSO_ROLE_ARN'"`
+	AccountId  int64         `kong:"name='account',short='A',help='AWS AccountID of role to assert the identity of',xor='arn-1',predictor='accountId'"`
+	Role       string        `kong:"short='R',help='Name of AWS Role to assert the identity of',xor='arn-2',predictor='role'"`
+	Profile    string        `kong:"short='p',help='Name of AWS Profile to assert the identity of',xor='arn-1',predictor='profile'"`
+	NoValidate bool          `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+	Audience   string        `kong:"help='Audience (aud claim) of the token'"`
+	TTL        time.Duration `kong:"name='ttl',default='5m',help='How long the token is valid for, limited by the STS credentials'"`
+}
+
+// Run verifies the identity of the role via sts:GetCallerIdentity and prints
+// a JWT signed with the IdentityTokenKeyFile asserting that identity.
+func (cc *IdentityTokenCmd) Run(ctx *RunContext) error {
+	var err error
+
+	if ctx.Settings.IdentityTokenKeyFile == "" {
+		return fmt.Errorf("IdentityTokenKeyFile is not configured")
+	}
+	if ctx.Cli.IdentityToken.TTL <= 0 {
+		return fmt.Errorf("Invalid --ttl: %s", ctx.Cli.IdentityToken.TTL)
+	}
+
+	key, err := sso.LoadSigningKey(utils.GetHomePath(ctx.Settings.IdentityTokenKeyFile))
+	if err != nil {
+		return err
+	}
+
+	role := ctx.Cli.IdentityToken.Role
+	account := ctx.Cli.IdentityToken.AccountId
+
+	if ctx.Cli.IdentityToken.Profile != "" {
+		cache := ctx.Settings.Cache.GetSSO()
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.IdentityToken.Profile, ctx.Settings)
+		if err != nil {
+			return err
+		}
+
+		role = rFlat.RoleName
+		account = rFlat.AccountId
+	} else if ctx.Cli.IdentityToken.Arn != "" {
+		account, role, err = utils.ParseRoleARN(ctx.Cli.IdentityToken.Arn)
+		if err != nil {
+			return err
+		}
+		if err := checkRoleAccess(ctx, account, role, ctx.Cli.IdentityToken.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if role == "" || account == 0 {
+		return fmt.Errorf("Please specify --profile, --arn, or --account and --role")
+	}
+
+	awssso := doAuth(ctx)
+	creds := GetRoleCredentials(ctx, awssso, account, role)
+
+	identity, err := sso.GetCallerIdentity(ctx.Context, creds, awssso.SsoRegion, ctx.Settings.HTTPClient())
+	if err != nil {
+		return fmt.Errorf("Unable to verify identity of %s: %s", creds.RoleArn(), err.Error())
+	}
+	if identity.Account != creds.AccountIdStr() {
+		return fmt.Errorf("Unable to verify identity of %s: credentials belong to account %s",
+			creds.RoleArn(), identity.Account)
+	}
+
+	ssoName, _ := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	now := utils.Now()
+	expires := now.Add(ctx.Cli.IdentityToken.TTL).Unix()
+	if creds.ExpireEpoch() < expires {
+		expires = creds.ExpireEpoch()
+	}
+
+	claims := sso.IdentityTokenClaims{
+		Issuer:         sso.IDENTITY_TOKEN_ISSUER,
+		Subject:        identity.Arn,
+		Audience:       ctx.Cli.IdentityToken.Audience,
+		IssuedAt:       now.Unix(),
+		NotBefore:      now.Unix(),
+		Expires:        expires,
+		AccountId:      identity.Account,
+		RoleName:       role,
+		RoleArn:        creds.RoleArn(),
+		UserId:         identity.UserId,
+		SSO:            ssoName,
+		CredsExpiresAt: creds.ExpireEpoch(),
+	}
+
+	token, err := sso.NewIdentityToken(claims, key)
+	if err != nil {
+		return fmt.Errorf("Unable to sign identity token: %s", err.Error())
+	}
+	fmt.Println(token)
+	return nil
+}
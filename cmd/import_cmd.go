@@ -0,0 +1,162 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	goyaml "github.com/goccy/go-yaml"
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type ImportCmd struct {
+	Bundle            string `kong:"arg,optional,help='Restore the config and cache from a file created by aws-sso export'"`
+	Force             bool   `kong:"help='Overwrite the existing config when restoring a bundle'"`
+	FromOrganizations bool   `kong:"help='Import account names and OU paths from AWS Organizations'"`
+	AccountId         int64  `kong:"name='account',short='A',help='AWS AccountID of role with AWS Organizations read access',predictor='accountId'"`
+	Role              string `kong:"short='R',help='Name of AWS Role with AWS Organizations read access',predictor='role'"`
+
+	ClientRegistration string `kong:"help='Pin the AWS SSO client registration from a file created by export --client-registration (- for stdin)'"`
+}
+
+// Run prints the Accounts config for the selected AWS SSO instance
+func (cc *ImportCmd) Run(ctx *RunContext) error {
+	if ctx.Cli.Import.ClientRegistration != "" {
+		return importClientRegistration(ctx)
+	}
+	if !ctx.Cli.Import.FromOrganizations {
+		return fmt.Errorf("Please specify a source to import from: --from-organizations or --client-registration")
+	}
+	if ctx.Cli.Import.AccountId == 0 || ctx.Cli.Import.Role == "" {
+		return fmt.Errorf("Please specify the --account and --role to query AWS Organizations with")
+	}
+
+	awssso := doAuth(ctx)
+	creds := GetRoleCredentials(ctx, awssso, ctx.Cli.Import.AccountId, ctx.Cli.Import.Role)
+
+	orgAccounts := []sso.OrgAccount{}
+	client, err := sso.NewOrganizationsClient(ctx.Context, creds, ctx.Settings.HTTPClient())
+	if err != nil {
+		return err
+	}
+	if orgAccounts, err = sso.ListOrgAccounts(ctx.Context, client); err != nil {
+		if !sso.IsOrganizationsAccessDenied(err) {
+			return fmt.Errorf("Unable to query AWS Organizations: %s", err.Error())
+		}
+		log.Warnf("Unable to query AWS Organizations, using AWS SSO account info only: %s", err.Error())
+		orgAccounts = []sso.OrgAccount{}
+	}
+
+	accounts := sso.ImportAccounts(ctx.Settings.Cache.GetSSO().Roles, orgAccounts)
+	out, err := goyaml.Marshal(map[string]interface{}{
+		"Accounts": accounts,
+	})
+	if err != nil {
+		return err
+	}
+
+	ssoName, _ := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	fmt.Printf("# Add to SSOConfig -> %s in your config.yaml\n%s", ssoName, string(out))
+	return nil
+}
+
+// importClientRegistration saves the client registration in the SecureStore
+// for the selected AWS SSO instance so we don't register a new client
+func importClientRegistration(ctx *RunContext) error {
+	var data []byte
+	var err error
+	if ctx.Cli.Import.ClientRegistration == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(ctx.Cli.Import.ClientRegistration)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read client registration: %s", err.Error())
+	}
+
+	client := storage.RegisterClientData{}
+	if err = json.Unmarshal(data, &client); err != nil {
+		return fmt.Errorf("Unable to parse client registration: %s", err.Error())
+	}
+
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	awssso := sso.NewAWSSSO(s, &ctx.Store)
+	if err = awssso.ImportClientRegistration(client); err != nil {
+		return err
+	}
+	fmt.Printf("Pinned client registration %s until %s\n", client.ClientId,
+		time.Unix(client.ClientSecretExpiresAt, 0).Format(time.RFC3339))
+	return nil
+}
+
+// importBundle validates and restores a bundle created by `aws-sso export`
+func importBundle(ctx *RunContext) error {
+	configFile := ctx.Cli.ConfigFile
+	if _, err := os.Stat(configFile); err == nil && !ctx.Cli.Import.Force {
+		return fmt.Errorf("%s already exists.  Use --force to replace it", configFile)
+	}
+
+	data, err := ioutil.ReadFile(ctx.Cli.Import.Bundle)
+	if err != nil {
+		return fmt.Errorf("Unable to read %s: %s", ctx.Cli.Import.Bundle, err.Error())
+	}
+	passphrase, err := bundlePassphrase(false)
+	if err != nil {
+		return err
+	}
+	b, err := sso.OpenBundle(data, passphrase)
+	if err != nil {
+		return err
+	}
+	if err = b.Validate(DEFAULT_CONFIG); err != nil {
+		return err
+	}
+
+	cacheFile := utils.GetHomePath(INSECURE_CACHE_FILE)
+	if err = b.Apply(configFile, cacheFile); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", configFile)
+
+	if len(b.Secrets) == 0 {
+		return nil
+	}
+
+	// the SecureStore to use is defined by the imported config
+	override := sso.OverrideSettings{}
+	if ctx.Settings, err = sso.LoadSettings(configFile, cacheFile, DEFAULT_CONFIG, override); err != nil {
+		return err
+	}
+	loadSecureStore(ctx)
+	if err = b.ApplySecrets(ctx.Store); err != nil {
+		return err
+	}
+	fmt.Printf("Restored AWS SSO secrets to the %s SecureStore\n", ctx.Settings.SecureStore)
+	return nil
+}
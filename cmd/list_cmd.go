@@ -20,14 +20,24 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"reflect"
 	"sort"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/Masterminds/sprig/v3"
+	"github.com/alecthomas/kong"
 	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
 	"github.com/synfinatic/aws-sso-cli/utils"
 	"github.com/synfinatic/gotable"
 )
 
+// longest Description to display in the table
+const MAX_DESCRIPTION_LEN = 40
+
 // keys match AWSRoleFlat header and value is the description
 var allListFields = map[string]string{
 	"Id":            "Column Index",
@@ -36,9 +46,15 @@ var allListFields = map[string]string{
 	"AccountName":   "Configured Account Name",
 	"AccountAlias":  "AWS Account Alias",
 	"DefaultRegion": "Default AWS Region",
+	"Description":   "Role description from config",
+	"Disabled":      "Role is disabled in the config",
 	"EmailAddress":  "Root Email for AWS account",
 	"ExpiresStr":    "Time until STS creds expire",
 	"Expires":       "Unix Epoch when STS creds expire",
+	"LastUsedStr":   "Time since role was last used",
+	"LastUsed":      "Unix Epoch when role was last used",
+	"RefreshedStr":  "Time since STS creds were refreshed",
+	"Refreshed":     "Unix Epoch when STS creds were refreshed",
 	"RoleName":      "AWS Role Name",
 	"SSO":           "AWS SSO Instance Name",
 	"Via":           "Role Chain Via",
@@ -46,8 +62,45 @@ var allListFields = map[string]string{
 }
 
 type ListCmd struct {
-	ListFields bool     `kong:"optional,short='f',help='List available fields',xor='fields'"`
-	Fields     []string `kong:"optional,arg,help='Fields to display',env='AWS_SSO_FIELDS',predictor='fieldList',xor='fields'"`
+	ListFields      bool       `kong:"optional,short='f',help='List available fields',xor='fields'"`
+	Format          string     `kong:"optional,help='Go template used to print each role (ex: {{.AccountId}} {{.RoleName}})',xor='fields'"`
+	MaskAccounts    bool       `kong:"optional,help='Mask all but the last 4 digits of AWS AccountIDs'"`
+	UsedSince       string     `kong:"optional,help='Only roles used since the duration (24h) or RFC3339 time'"`
+	RefreshedSince  string     `kong:"optional,help='Only roles refreshed since the duration (24h) or RFC3339 time'"`
+	Output          string     `kong:"optional,short='o',enum='table,json,yaml,tfvars',default='table',help='Output format [table|json|yaml|tfvars]'"`
+	Accounts        bool       `kong:"optional,help='Include a map of accounts keyed by AccountId with --output json|yaml'"`
+	WithExpiryEpoch bool       `kong:"optional,help='Include when the cached STS credentials expire as a Unix epoch'"`
+	GroupBy         string     `kong:"optional,short='g',help='Group roles by account or the value of the given tag'"`
+	OnlyCached      OnlyCached `kong:"optional,name='only-cached',help='Only roles with cached STS credentials, use =valid to exclude expired credentials'"`
+	Fields          []string   `kong:"optional,arg,help='Fields to display',env='AWS_SSO_FIELDS',predictor='fieldList',xor='fields'"`
+}
+
+// OnlyCached is the value of `list --only-cached` which may be given without a value
+type OnlyCached string
+
+const (
+	ONLY_CACHED_ALL   OnlyCached = "all"
+	ONLY_CACHED_VALID OnlyCached = "valid"
+)
+
+// Decode implements kong.MapperValue so the value is optional
+func (o *OnlyCached) Decode(ctx *kong.DecodeContext) error {
+	*o = ONLY_CACHED_ALL
+	if ctx.Scan.Peek().Type != kong.FlagValueToken {
+		return nil
+	}
+	v := OnlyCached(fmt.Sprintf("%v", ctx.Scan.Pop().Value))
+	switch v {
+	case ONLY_CACHED_ALL, ONLY_CACHED_VALID:
+		*o = v
+		return nil
+	}
+	return fmt.Errorf("must be one of all or valid, but got %q", v)
+}
+
+// IsBool implements kong.BoolMapper so no value is required
+func (o OnlyCached) IsBool() bool {
+	return true
 }
 
 // what should this actually do?
@@ -60,11 +113,31 @@ func (cc *ListCmd) Run(ctx *RunContext) error {
 		return nil
 	}
 
+	// fail before we possibly refresh the cache
+	if ctx.Cli.List.Output != "table" && (ctx.Cli.List.Format != "" || len(ctx.Cli.List.Fields) > 0) {
+		return fmt.Errorf("--output %s can not be combined with --format or fields", ctx.Cli.List.Output)
+	}
+	if ctx.Cli.List.GroupBy != "" && ctx.Cli.List.Format != "" {
+		return fmt.Errorf("--group-by can not be combined with --format")
+	}
+	if ctx.Cli.List.Accounts && ctx.Cli.List.Output != "json" && ctx.Cli.List.Output != "yaml" {
+		return fmt.Errorf("--accounts requires --output json or yaml")
+	}
+	if ctx.Cli.List.Output == "tfvars" && (ctx.Cli.List.GroupBy != "" || ctx.Cli.List.MaskAccounts) {
+		return fmt.Errorf("--output tfvars can not be combined with --group-by or --mask-accounts")
+	}
+	var templ *template.Template
+	if ctx.Cli.List.Format != "" {
+		if templ, err = parseListFormat(ctx.Cli.List.Format); err != nil {
+			return err
+		}
+	}
+
 	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
 	if err != nil {
 		return err
 	}
-	if err = ctx.Settings.Cache.Expired(s); err != nil {
+	if err = ctx.Settings.Cache.Expired(s); err != nil && !ctx.Settings.Offline() {
 		c := &CacheCmd{}
 		if err = c.Run(ctx); err != nil {
 			log.WithError(err).Errorf("Unable to refresh local cache")
@@ -75,8 +148,38 @@ func (cc *ListCmd) Run(ctx *RunContext) error {
 	if len(ctx.Cli.List.Fields) > 0 {
 		fields = ctx.Cli.List.Fields
 	}
+	fields = expiryEpochFields(fields, ctx.Cli.List.WithExpiryEpoch)
+
+	if ctx.Cli.List.MaskAccounts {
+		ctx.Settings.MaskAccounts = true
+	}
+
+	filter := roleFilter{
+		ShowDisabled: ctx.Settings.ShowDisabled(),
+		Cached:       ctx.Cli.List.OnlyCached,
+	}
+	if ctx.Cli.List.UsedSince != "" {
+		if filter.UsedSince, err = utils.ParseSince(ctx.Cli.List.UsedSince, time.Now()); err != nil {
+			return err
+		}
+	}
+	if ctx.Cli.List.RefreshedSince != "" {
+		if filter.RefreshedSince, err = utils.ParseSince(ctx.Cli.List.RefreshedSince, time.Now()); err != nil {
+			return err
+		}
+	}
 
-	printRoles(ctx, fields)
+	if templ != nil {
+		return printRolesTemplate(ctx, templ, filter)
+	}
+	if ctx.Cli.List.Output == "tfvars" {
+		return printAccountsTfvars(ctx, filter)
+	}
+	if ctx.Cli.List.Output != "table" {
+		return printRolesOutput(ctx, ctx.Cli.List.Output, filter, ctx.Cli.List.GroupBy,
+			ctx.Cli.List.Accounts, ctx.Cli.List.WithExpiryEpoch)
+	}
+	printRoles(ctx, fields, filter, ctx.Cli.List.GroupBy)
 
 	return nil
 }
@@ -92,22 +195,55 @@ func (cc *DefaultCmd) Run(ctx *RunContext) error {
 	}
 
 	// update cache?
-	if err = ctx.Settings.Cache.Expired(s); err != nil {
+	if err = ctx.Settings.Cache.Expired(s); err != nil && !ctx.Settings.Offline() {
 		c := &CacheCmd{}
 		if err = c.Run(ctx); err != nil {
 			log.WithError(err).Errorf("Unable to refresh local cache")
 		}
 	}
 
-	printRoles(ctx, ctx.Settings.ListFields)
+	printRoles(ctx, ctx.Settings.ListFields, roleFilter{ShowDisabled: ctx.Settings.ShowDisabled()}, "")
 	return nil
 }
 
-// Print all our roles
-func printRoles(ctx *RunContext, fields []string) {
+// roleFilter selects which roles to print.  Zero values match all enabled roles.
+type roleFilter struct {
+	UsedSince      int64      // Unix epoch
+	RefreshedSince int64      // Unix epoch
+	ShowDisabled   bool       // include roles disabled in the config
+	Cached         OnlyCached // only roles with cached STS creds
+}
+
+// Match returns true if the role passes all of the filters.  Roles without
+// the timestamp being filtered on never match.
+func (f roleFilter) Match(roleFlat *sso.AWSRoleFlat) bool {
+	if roleFlat.Disabled && !f.ShowDisabled {
+		return false
+	}
+	if f.UsedSince > 0 && (roleFlat.LastUsed == 0 || roleFlat.LastUsed < f.UsedSince) {
+		return false
+	}
+	if f.RefreshedSince > 0 && (roleFlat.Refreshed == 0 || roleFlat.Refreshed < f.RefreshedSince) {
+		return false
+	}
+	switch f.Cached {
+	case ONLY_CACHED_ALL:
+		if roleFlat.Expires == 0 {
+			return false
+		}
+	case ONLY_CACHED_VALID:
+		if !roleFlat.IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+// listRoles returns the roles matching the filter in AccountId and RoleName
+// order with the fields used for display populated
+func listRoles(ctx *RunContext, filter roleFilter) []*sso.AWSRoleFlat {
 	roles := ctx.Settings.Cache.GetSSO().Roles
-	tr := []gotable.TableStruct{}
-	idx := 0
+	ret := []*sso.AWSRoleFlat{}
 
 	// print in AccountId order
 	accounts := []int64{}
@@ -116,6 +252,7 @@ func printRoles(ctx *RunContext, fields []string) {
 	}
 	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
 
+	idx := 0
 	for _, account := range accounts {
 		// print roles in order
 		roleNames := []string{}
@@ -126,29 +263,467 @@ func printRoles(ctx *RunContext, fields []string) {
 
 		for _, roleName := range roleNames {
 			roleFlat, _ := roles.GetRole(account, roleName)
+			if !filter.Match(roleFlat) {
+				continue
+			}
 			if !roleFlat.IsExpired() {
 				if exp, err := utils.TimeRemain(roleFlat.Expires, true); err == nil {
 					roleFlat.ExpiresStr = exp
 				}
 			}
+			if used, err := utils.TimeSince(roleFlat.LastUsed, true); err == nil {
+				roleFlat.LastUsedStr = used
+			}
+			if refreshed, err := utils.TimeSince(roleFlat.Refreshed, true); err == nil {
+				roleFlat.RefreshedStr = refreshed
+			}
 			// update Profile
 			p, err := roleFlat.ProfileName(ctx.Settings)
 			if err == nil {
 				roleFlat.Profile = p
 			}
+			roleFlat.AccountIdStr, _ = utils.AccountIdToString(roleFlat.AccountId)
+			if ctx.Settings.MaskAccounts {
+				maskRoleFlat(roleFlat)
+			}
 			roleFlat.Id = idx
 			idx += 1
-			tr = append(tr, *roleFlat)
+			ret = append(ret, roleFlat)
+		}
+	}
+	return ret
+}
+
+// the group for roles without the tag used by --group-by
+const NO_GROUP = "(none)"
+
+// roleGroup is a set of roles printed together via --group-by
+type roleGroup struct {
+	Name   string // AccountId or tag value
+	Header string
+	Roles  []*sso.AWSRoleFlat
+}
+
+// groupRoles splits the roles by account or the value of the given tag,
+// preserving the order of the roles in each group.  Accounts are in the same
+// order as the roles and tag values are sorted with NO_GROUP last.
+func groupRoles(roles []*sso.AWSRoleFlat, groupBy string) []roleGroup {
+	groups := []roleGroup{}
+	index := map[string]int{}
+	for _, roleFlat := range roles {
+		var name, header string
+		if groupBy == "account" {
+			name = roleFlat.AccountIdStr
+			header = fmt.Sprintf("AccountId: %s", name)
+			if roleFlat.AccountName != "" {
+				header = fmt.Sprintf("%s (%s)", header, roleFlat.AccountName)
+			} else if roleFlat.AccountAlias != "" {
+				header = fmt.Sprintf("%s (%s)", header, roleFlat.AccountAlias)
+			}
+		} else {
+			var ok bool
+			if name, ok = roleFlat.Tags[groupBy]; !ok || name == "" {
+				name = NO_GROUP
+			}
+			header = fmt.Sprintf("%s: %s", groupBy, name)
+		}
+
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, roleGroup{Name: name, Header: header})
+		}
+		groups[i].Roles = append(groups[i].Roles, roleFlat)
+	}
+
+	if groupBy != "account" {
+		sort.SliceStable(groups, func(i, j int) bool {
+			if groups[i].Name == NO_GROUP || groups[j].Name == NO_GROUP {
+				return groups[j].Name == NO_GROUP && groups[i].Name != NO_GROUP
+			}
+			return groups[i].Name < groups[j].Name
+		})
+	}
+	return groups
+}
+
+// Print all our roles, optionally grouped by account or tag
+func printRoles(ctx *RunContext, fields []string, filter roleFilter, groupBy string) {
+
+	// AccountId is an int64, so use the string version when masking
+	if ctx.Settings.MaskAccounts {
+		maskedFields := []string{}
+		for _, f := range fields {
+			if f == "AccountId" {
+				f = "AccountIdStr"
+			}
+			maskedFields = append(maskedFields, f)
 		}
+		fields = maskedFields
 	}
 
+	roles := listRoles(ctx, filter)
 	fmt.Printf("List of AWS roles for SSO Instance: %s\n", ctx.Settings.DefaultSSO)
-	if err := gotable.GenerateTable(tr, fields); err != nil {
-		log.WithError(err).Fatalf("Unable to generate report")
+	if groupBy == "" {
+		printRolesTable(ctx, roles, fields, "")
+	} else {
+		for _, group := range groupRoles(roles, groupBy) {
+			fmt.Printf("\n%s\n", group.Header)
+			printRolesTable(ctx, group.Roles, fields, "  ")
+		}
 	}
 	fmt.Printf("\n")
 }
 
+// printRolesTable prints the roles as a table with each line prefixed by indent
+func printRolesTable(ctx *RunContext, roles []*sso.AWSRoleFlat, fields []string, indent string) {
+	tr := []gotable.TableStruct{}
+	colors := []string{} // color of the ExpiresStr column for each row
+	warn := time.Duration(ctx.Settings.ExpiryWarnMinutes) * time.Minute
+	critical := time.Duration(ctx.Settings.ExpiryCriticalMinutes) * time.Minute
+	color := useColor(ctx)
+
+	for _, roleFlat := range roles {
+		roleFlat.Description = utils.Truncate(roleFlat.Description, MAX_DESCRIPTION_LEN)
+		tr = append(tr, *roleFlat)
+		if color {
+			colors = append(colors, utils.ExpiryColor(roleFlat.Expires, warn, critical))
+		} else {
+			colors = append(colors, "")
+		}
+	}
+
+	var err error
+	if color || indent != "" {
+		err = generateColorTable(tr, fields, colors, indent)
+	} else {
+		err = gotable.GenerateTable(tr, fields)
+	}
+	if err != nil {
+		log.WithError(err).Fatalf("Unable to generate report")
+	}
+}
+
+// listTemplateRow is the data passed to the `list --format` template for each role
+type listTemplateRow struct {
+	sso.AWSRoleFlat
+	AccountId     string // zero padded (or masked) instead of an int64
+	TimeRemaining string // until the STS credentials expire
+	ExpiresEpoch  int64  // same as Expires
+}
+
+// listRoleExpiry is a role with the expiry of its cached STS credentials
+// for `list --with-expiry-epoch --output json|yaml`
+type listRoleExpiry struct {
+	*sso.AWSRoleFlat `yaml:",inline"`
+	ExpiresEpoch     int64  `json:"ExpiresEpoch"`  // 0 if there are no cached creds
+	TimeRemaining    string `json:"TimeRemaining"` // empty if there are no cached creds
+}
+
+// listRoleMasked is a role in `list --output json|yaml` with MaskAccounts,
+// where the AccountId is replaced by the masked AccountIdStr
+type listRoleMasked struct {
+	*sso.AWSRoleFlat `yaml:",inline"`
+	AccountId        string `json:"AccountId" yaml:"AccountId"`
+}
+
+// listRoleMaskedExpiry is listRoleExpiry with MaskAccounts
+type listRoleMaskedExpiry struct {
+	listRoleMasked `yaml:",inline"`
+	ExpiresEpoch   int64  `json:"ExpiresEpoch"`
+	TimeRemaining  string `json:"TimeRemaining"`
+}
+
+// outputRoles returns the roles to marshal for --output json|yaml
+func outputRoles(roles []*sso.AWSRoleFlat, expiryEpoch, mask bool) interface{} {
+	ret := []interface{}{}
+	for _, roleFlat := range roles {
+		timeRemaining := ""
+		if roleFlat.Expires > 0 {
+			timeRemaining = templateTimeRemain(roleFlat.Expires)
+		}
+		masked := listRoleMasked{AWSRoleFlat: roleFlat, AccountId: roleFlat.AccountIdStr}
+
+		switch {
+		case mask && expiryEpoch:
+			ret = append(ret, listRoleMaskedExpiry{masked, roleFlat.Expires, timeRemaining})
+		case mask:
+			ret = append(ret, masked)
+		case expiryEpoch:
+			ret = append(ret, listRoleExpiry{roleFlat, roleFlat.Expires, timeRemaining})
+		default:
+			ret = append(ret, roleFlat)
+		}
+	}
+	return ret
+}
+
+// expiryEpochFields maps the ExpiresEpoch field name to the Expires field and
+// adds it for --with-expiry-epoch if necessary
+func expiryEpochFields(fields []string, expiryEpoch bool) []string {
+	ret := []string{}
+	found := false
+	for _, f := range fields {
+		if f == "ExpiresEpoch" {
+			f = "Expires"
+		}
+		found = found || f == "Expires"
+		ret = append(ret, f)
+	}
+	if expiryEpoch && !found {
+		ret = append(ret, "Expires")
+	}
+	return ret
+}
+
+// listTemplateFuncs are the functions available to `list --format` in
+// addition to the sprig functions
+var listTemplateFuncs = template.FuncMap{
+	"padLeft": func(width int, v interface{}) string {
+		return fmt.Sprintf("%*v", width, v)
+	},
+	"padRight": func(width int, v interface{}) string {
+		return fmt.Sprintf("%-*v", width, v)
+	},
+	"timeRemain": templateTimeRemain,
+	"timeSince": func(epoch int64) string {
+		s, _ := utils.TimeSince(epoch, false)
+		return s
+	},
+	"formatTime": func(layout string, epoch int64) string {
+		if epoch == 0 {
+			return ""
+		}
+		return time.Unix(epoch, 0).Format(layout)
+	},
+}
+
+// templateTimeRemain returns how much time until the epoch or Expired.
+// Returns an empty string for 0.
+func templateTimeRemain(epoch int64) string {
+	if epoch == 0 {
+		return ""
+	}
+	s, _ := utils.TimeRemain(epoch, false)
+	return s
+}
+
+// parseListFormat parses the `list --format` template
+func parseListFormat(format string) (*template.Template, error) {
+	funcMap := sprig.TxtFuncMap()
+	for k, v := range listTemplateFuncs {
+		funcMap[k] = v
+	}
+	templ, err := template.New("format").Funcs(funcMap).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --format template: %s", err.Error())
+	}
+	return templ, nil
+}
+
+// printRolesTemplate prints each role using the template
+func printRolesTemplate(ctx *RunContext, templ *template.Template, filter roleFilter) error {
+	for _, roleFlat := range listRoles(ctx, filter) {
+		row := listTemplateRow{
+			AWSRoleFlat:   *roleFlat,
+			AccountId:     roleFlat.AccountIdStr,
+			TimeRemaining: templateTimeRemain(roleFlat.Expires),
+			ExpiresEpoch:  roleFlat.Expires,
+		}
+		row.Tags = ctx.Settings.VisibleTags(roleFlat.Tags)
+		if err := templ.Execute(os.Stdout, row); err != nil {
+			return fmt.Errorf("Unable to execute --format template: %s", err.Error())
+		}
+		fmt.Printf("\n")
+	}
+	return nil
+}
+
+// generateColorTable works like gotable.GenerateTable, but colors the
+// ExpiresStr column, pads each column based on the visible width and
+// prefixes each line with indent
+func generateColorTable(tr []gotable.TableStruct, fields []string, colors []string, indent string) error {
+	rows := []map[string]string{}
+	headers := map[string]string{}
+	for i, item := range tr {
+		row, h, err := gotable.TableRow(item)
+		if err != nil {
+			return err
+		}
+		row["ExpiresStr"] = utils.Colorize(row["ExpiresStr"], colors[i])
+		rows = append(rows, row)
+		headers = h
+	}
+
+	colWidth := make([]int, len(fields))
+	for i, field := range fields {
+		colWidth[i] = len(headers[field])
+		for _, row := range rows {
+			if l := utils.VisibleLen(row[field]); l > colWidth[i] {
+				colWidth[i] = l
+			}
+		}
+	}
+
+	printRow := func(row map[string]string) string {
+		cols := make([]string, len(fields))
+		for i, field := range fields {
+			pad := colWidth[i] - utils.VisibleLen(row[field])
+			cols[i] = row[field] + strings.Repeat(" ", pad)
+		}
+		return strings.Join(cols, " | ")
+	}
+
+	headerLine := printRow(headers)
+	fmt.Printf("%s%s\n%s%s\n", indent, headerLine, indent, strings.Repeat("=", len(headerLine)))
+	for _, row := range rows {
+		fmt.Printf("%s%s\n", indent, printRow(row))
+	}
+	return nil
+}
+
+// listAccount is an entry of the `list --accounts` map
+type listAccount struct {
+	AccountId    string   `json:"AccountId" yaml:"AccountId"`
+	AccountName  string   `json:"AccountName" yaml:"AccountName"`
+	AccountAlias string   `json:"AccountAlias" yaml:"AccountAlias"`
+	EmailAddress string   `json:"EmailAddress" yaml:"EmailAddress"`
+	Roles        []string `json:"Roles" yaml:"Roles"`
+}
+
+// listAccounts returns the accounts of the roles keyed by AccountId
+func listAccounts(roles []*sso.AWSRoleFlat, mask bool) map[string]*listAccount {
+	accounts := map[string]*listAccount{}
+	for _, roleFlat := range roles {
+		accountId, _ := utils.AccountIdToString(roleFlat.AccountId)
+		if mask {
+			accountId, _ = utils.AccountIdToMaskedString(roleFlat.AccountId)
+		}
+		account, ok := accounts[accountId]
+		if !ok {
+			account = &listAccount{
+				AccountId:    accountId,
+				AccountName:  roleFlat.AccountName,
+				AccountAlias: roleFlat.AccountAlias,
+				EmailAddress: roleFlat.EmailAddress,
+				Roles:        []string{},
+			}
+			accounts[accountId] = account
+		}
+		account.Roles = append(account.Roles, roleFlat.RoleName)
+	}
+	return accounts
+}
+
+// printRolesOutput prints the roles as json or yaml.  With groupBy, a map of
+// group name => roles is printed instead of a list.  With accounts, the roles
+// are printed along with a map of AccountId => account
+func printRolesOutput(ctx *RunContext, format string, filter roleFilter, groupBy string, accounts, expiryEpoch bool) error {
+	roles := listRoles(ctx, filter)
+	var accountMap map[string]*listAccount
+	if accounts {
+		accountMap = listAccounts(roles, ctx.Settings.MaskAccounts)
+	}
+	mask := ctx.Settings.MaskAccounts
+
+	var v interface{} = outputRoles(roles, expiryEpoch, mask)
+	if groupBy != "" {
+		groups := map[string]interface{}{}
+		for _, group := range groupRoles(roles, groupBy) {
+			groups[group.Name] = outputRoles(group.Roles, expiryEpoch, mask)
+		}
+		v = groups
+	}
+	for _, roleFlat := range roles {
+		roleFlat.Tags = ctx.Settings.VisibleTags(roleFlat.Tags)
+	}
+	if accounts {
+		v = map[string]interface{}{
+			"accounts": accountMap,
+			"roles":    v,
+		}
+	}
+
+	out, err := marshalOutput(ctx, format, v)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", string(out))
+	return nil
+}
+
+// printAccountsTfvars prints a Terraform .tfvars map of account name => AccountId.
+// Names are normalized to identifiers and suffixed with the AccountId if not unique
+func printAccountsTfvars(ctx *RunContext, filter roleFilter) error {
+	accounts := listAccounts(listRoles(ctx, filter), false)
+	ids := make([]string, 0, len(accounts))
+	for id := range accounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	names := map[string][]string{}
+	for _, id := range ids {
+		name := accounts[id].AccountAlias
+		if name == "" {
+			name = accounts[id].AccountName
+		}
+		if name == "" {
+			name = id
+		}
+		key := utils.NormalizeIdentifier(name)
+		names[key] = append(names[key], id)
+	}
+
+	keys := []string{}
+	values := map[string]string{}
+	width := 0
+	for name, nameIds := range names {
+		for _, id := range nameIds {
+			key := name
+			if len(nameIds) > 1 {
+				key = fmt.Sprintf("%s_%s", name, id)
+			}
+			keys = append(keys, key)
+			values[key] = id
+			if len(key) > width {
+				width = len(key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("accounts = {\n")
+	for _, key := range keys {
+		fmt.Printf("  %-*s = \"%s\"\n", width, key, values[key])
+	}
+	fmt.Printf("}\n")
+	return nil
+}
+
+// maskRoleFlat replaces the AccountId in all the displayed fields of the role
+func maskRoleFlat(roleFlat *sso.AWSRoleFlat) {
+	accountId, _ := utils.AccountIdToString(roleFlat.AccountId)
+	roleFlat.AccountIdStr, _ = utils.AccountIdToMaskedString(roleFlat.AccountId)
+	roleFlat.Arn = utils.MakeMaskedRoleARN(roleFlat.AccountId, roleFlat.RoleName)
+	roleFlat.Profile = strings.ReplaceAll(roleFlat.Profile, accountId, roleFlat.AccountIdStr)
+	if _, ok := roleFlat.Tags["AccountID"]; ok {
+		// don't modify the tags in our cache
+		tags := map[string]string{}
+		for k, v := range roleFlat.Tags {
+			tags[k] = v
+		}
+		tags["AccountID"] = roleFlat.AccountIdStr
+		roleFlat.Tags = tags
+	}
+	if roleFlat.Via != "" {
+		if aId, rName, err := utils.ParseRoleARN(roleFlat.Via); err == nil {
+			roleFlat.Via = utils.MakeMaskedRoleARN(aId, rName)
+		}
+	}
+}
+
 // Code to --list-fields
 type ConfigFieldNames struct {
 	Field       string `header:"Field"`
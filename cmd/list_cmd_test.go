@@ -0,0 +1,63 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"testing"
+
+	goyaml "github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/sso"
+)
+
+func TestOutputRolesMasked(t *testing.T) {
+	roleFlat := &sso.AWSRoleFlat{
+		AccountId: 123456789012,
+		RoleName:  "Admin",
+	}
+	maskRoleFlat(roleFlat)
+
+	for _, expiryEpoch := range []bool{false, true} {
+		v := outputRoles([]*sso.AWSRoleFlat{roleFlat}, expiryEpoch, true)
+
+		out, err := json.Marshal(v)
+		assert.NoError(t, err)
+		roles := []map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(out, &roles))
+		assert.Equal(t, roleFlat.AccountIdStr, roles[0]["AccountId"])
+		assert.Equal(t, roleFlat.Arn, roles[0]["Arn"])
+		assert.NotContains(t, string(out), "123456789012")
+
+		out, err = goyaml.Marshal(v)
+		assert.NoError(t, err)
+		roles = []map[string]interface{}{}
+		assert.NoError(t, goyaml.Unmarshal(out, &roles))
+		assert.Equal(t, roleFlat.AccountIdStr, roles[0]["AccountId"])
+		assert.NotContains(t, string(out), "123456789012")
+		if expiryEpoch {
+			assert.Contains(t, roles[0], "ExpiresEpoch")
+		}
+	}
+
+	// the real AccountId without MaskAccounts
+	out, err := json.Marshal(outputRoles([]*sso.AWSRoleFlat{{AccountId: 123456789012}}, false, false))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"AccountId":123456789012`)
+}
@@ -0,0 +1,56 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type LoginCmd struct {
+	Open  bool `kong:"help='Open the login URL in your browser',xor='action'"`
+	Print bool `kong:"help='Print the login URL instead of opening it',xor='action'"`
+	Clip  bool `kong:"help='Copy the login URL to the clipboard',xor='action'"`
+}
+
+// Run logs into AWS SSO unless we already have a valid AWS SSO token
+func (cc *LoginCmd) Run(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+
+	action := utils.UrlActionOverride(ctx.Cli.Login.Open, ctx.Cli.Login.Print,
+		ctx.Cli.Login.Clip, ctx.Settings.UrlAction)
+
+	awssso := sso.NewAWSSSO(s, &ctx.Store)
+	awssso.SetContext(ctx.Context)
+	if err = awssso.Authenticate(action, ctx.Settings.Browser); err != nil {
+		return fmt.Errorf("Unable to authenticate: %s", err.Error())
+	}
+	startPrefetch(ctx)
+
+	remain, _ := utils.TimeRemain(awssso.Token.ExpiresAt, false)
+	fmt.Printf("AWS SSO token expires at: %s (%s)\n",
+		time.Unix(awssso.Token.ExpiresAt, 0).Format("Mon Jan 2 15:04:05 -0700 MST 2006"), remain)
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+import (
+	"fmt"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+)
+
+// LogoutCmd defines the Kong args for the logout command
+type LogoutCmd struct{}
+
+// Run executes the logout command
+func (cc *LogoutCmd) Run(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	awssso := sso.NewAWSSSO(s, &ctx.Store)
+	awssso.SetContext(ctx.Context)
+
+	loggedOut, err := awssso.Logout()
+	if err != nil {
+		return fmt.Errorf("Deleted cached AWS SSO Token for %s, but %s", awssso.StoreKey(), err.Error())
+	}
+	if loggedOut {
+		fmt.Printf("Logged out of the AWS SSO session for %s\n", awssso.StoreKey())
+	} else {
+		fmt.Printf("No active AWS SSO session for %s, only the local cache was cleared\n", awssso.StoreKey())
+	}
+	return nil
+}
@@ -19,11 +19,17 @@ package main
  */
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
+	goyaml "github.com/goccy/go-yaml"
+	"github.com/manifoldco/promptui"
 	"github.com/posener/complete"
 	// "github.com/davecgh/go-spew/spew"
 	log "github.com/sirupsen/logrus"
@@ -31,6 +37,7 @@ import (
 	"github.com/synfinatic/aws-sso-cli/storage"
 	"github.com/synfinatic/aws-sso-cli/utils"
 	"github.com/willabides/kongplete"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // These variables are defined in the Makefile
@@ -45,6 +52,9 @@ type RunContext struct {
 	Cli      *CLI
 	Settings *sso.Settings // unified config & cache
 	Store    storage.SecureStorage
+	Context  context.Context // bounds all AWS API calls
+
+	MinRemaining time.Duration // refresh cached STS creds expiring sooner than this
 }
 
 const (
@@ -53,6 +63,7 @@ const (
 	JSON_STORE_FILE     = CONFIG_DIR + "/store.json"
 	INSECURE_CACHE_FILE = CONFIG_DIR + "/cache.json"
 	DEFAULT_STORE       = "file"
+	AGENT_SOCKET        = CONFIG_DIR + "/agent.sock"
 	COPYRIGHT_YEAR      = "2021-2022"
 )
 
@@ -81,31 +92,84 @@ var DEFAULT_CONFIG map[string]interface{} = map[string]interface{}{
 	"UrlAction":                                 "open",
 	"LogLevel":                                  "warn",
 	"DefaultSSO":                                "Default",
+	"NotifyAction":                              "print",
+	"NotifyMinutes":                             10,
+	"LoginTimeout":                              5,
+	"TokenExpiryBufferMinutes":                  2,
+	"MinValidRemainingMinutes":                  1,
+	"MaxConcurrency":                            10,
+	"ExpiryWarnMinutes":                         15,
+	"ExpiryCriticalMinutes":                     5,
+	"MaxOpenUrls":                               10,
+	"OpenUrlRetries":                            2,
+	"OpenUrlRetryMilliseconds":                  500,
+	"TLSMinVersion":                             "1.2",
 }
 
 type CLI struct {
 	// Common Arguments
-	Browser    string `kong:"short='b',help='Path to browser to open URLs with',env='AWS_SSO_BROWSER'"`
-	ConfigFile string `kong:"name='config',default='${CONFIG_FILE}',help='Config file',env='AWS_SSO_CONFIG'"`
-	Lines      bool   `kong:"help='Print line number in logs'"`
-	LogLevel   string `kong:"short='L',name='level',help='Logging level [error|warn|info|debug|trace] (default: warn)'"`
-	UrlAction  string `kong:"short='u',help='How to handle URLs [open|print|clip] (default: open)'"`
-	SSO        string `kong:"short='S',help='Override default AWS SSO Instance',env='AWS_SSO',predictor='sso'"`
-	STSRefresh bool   `kong:"help='Force refresh of STS Token Credentials'"`
+	AllAccounts     bool          `kong:"help='Ignore AccountsAllowlist when refreshing the cache'"`
+	Browser         string        `kong:"short='b',help='Path to browser to open URLs with',env='AWS_SSO_BROWSER'"`
+	CABundle        string        `kong:"name='ca-bundle',help='Path to PEM file of additional CA certificates to trust'"`
+	Color           string        `kong:"help='Colorize output [auto|always|never]',default='auto',enum='auto,always,never'"`
+	Compact         bool          `kong:"help='Print JSON on a single line (default when not a terminal)',xor='json'"`
+	ConfigFile      string        `kong:"name='config',default='${CONFIG_FILE}',help='Config file',env='AWS_SSO_CONFIG'"`
+	Deadline        time.Duration `kong:"help='Abort any AWS API calls which have not completed by this time (ex: 30s)'"`
+	Env             string        `kong:"help='Name of the config Environment to use',env='AWS_SSO_ENV'"`
+	IgnoreClockSkew bool          `kong:"help='Do not check the local clock against AWS'"`
+	JsonPretty      bool          `kong:"name='json-pretty',help='Pretty print JSON (default when a terminal)',xor='json'"`
+	Lines           bool          `kong:"help='Print line number in logs'"`
+	LogLevel        string        `kong:"short='L',name='level',help='Logging level [error|warn|info|debug|trace] (default: warn)'"`
+	LoginTimeout    int64         `kong:"help='Minutes to wait for AWS SSO login to complete (default: 5)'"`
+	NoFallback      bool          `kong:"help='Do not print the URL if it can not be opened in the browser'"`
+	Offline         bool          `kong:"help='Only use cached data and never make network calls'"`
+	Proxy           string        `kong:"help='URL of HTTP(S) proxy to use instead of $HTTPS_PROXY'"`
+	ReloadTags      bool          `kong:"help='Force re-reading the TagsFile'"`
+	UrlAction       string        `kong:"short='u',help='How to handle URLs [open|print|clip|remote-open] (default: open)'"`
+	SSO             string        `kong:"short='S',help='Override default AWS SSO Instance',env='AWS_SSO',predictor='sso'"`
+	SSORegion       string        `kong:"name='sso-region',help='Override the AWS region of the AWS SSO Instance'"`
+	STSRefresh      bool          `kong:"help='Force refresh of STS Token Credentials'"`
+	ShowDisabled    bool          `kong:"help='Include roles disabled in the config'"`
+	ShowHiddenTags  bool          `kong:"help='Include tags hidden by HiddenTags in the output'"`
+	Strict          bool          `kong:"help='Refuse to use roles disabled in the config'"`
+	Quiet           bool          `kong:"short='q',help='Suppress progress messages'"`
+	ValidateToken   bool          `kong:"help='Verify the cached AWS SSO token has not been revoked before using it'"`
 
 	// Commands
+	AccountId          AccountIdCmd                 `kong:"cmd,name='account-id',help='Print the AWS AccountID of a role'"`
+	Assume             AssumeCmd                    `kong:"cmd,help='Print credentials for a role, optionally assumed using the AWS credentials in the environment'"`
+	Audit              AuditCmd                     `kong:"cmd,help='Print when each AWS Role was last used'"`
 	Cache              CacheCmd                     `kong:"cmd,help='Force reload of cached AWS SSO role info and config.yaml'"`
-	Config             ConfigCmd                    `kong:"cmd,help='Update ~/.aws/config with AWS SSO profiles from the cache'"`
+	Config             ConfigCmd                    `kong:"cmd,help='Update ~/.aws/config with AWS SSO profiles or show the effective config'"`
 	Console            ConsoleCmd                   `kong:"cmd,help='Open AWS Console using specificed AWS Role/profile'"`
+	Creds              CredsCmd                     `kong:"cmd,help='Print AWS credentials as JSON for Terraform, Vault, etc'"`
 	Default            DefaultCmd                   `kong:"cmd,hidden,default='1'"` // list command without args
+	Doctor             DoctorCmd                    `kong:"cmd,help='Check for common configuration and environment problems'"`
+	EcrLogin           EcrLoginCmd                  `kong:"cmd,name='ecr-login',help='Print or run docker login for AWS ECR using specified IAM Role'"`
 	Eval               EvalCmd                      `kong:"cmd,help='Print AWS Environment vars for use with eval $(aws-sso eval ...)'"`
 	Exec               ExecCmd                      `kong:"cmd,help='Execute command using specified IAM Role'"`
+	Expiry             ExpiryCmd                    `kong:"cmd,help='Print when the cached STS credentials for a role expire'"`
+	Export             ExportCmd                    `kong:"cmd,help='Write config and cache to an encrypted file for migrating to another machine'"`
+	IdentityToken      IdentityTokenCmd             `kong:"cmd,name='identity-token',help='Print a signed token asserting the AWS identity of a role'"`
+	Import             ImportCmd                    `kong:"cmd,help='Generate Accounts config from AWS SSO and AWS Organizations or restore an exported bundle'"`
+	Fetch              FetchCmd                     `kong:"cmd,help='Fetch and cache STS credentials for a role without printing them'"`
 	Flush              FlushCmd                     `kong:"cmd,help='Flush AWS SSO/STS credentials from cache'"`
 	List               ListCmd                      `kong:"cmd,help='List all accounts / role (default command)'"`
+	Login              LoginCmd                     `kong:"cmd,help='Login to AWS SSO if the cached AWS SSO token is not valid'"`
+	Logout             LogoutCmd                    `kong:"cmd,help='Logout of the AWS SSO session and flush the cached AWS SSO token'"`
+	Paths              PathsCmd                     `kong:"cmd,help='Print the config, cache and SecureStore paths in use'"`
 	Process            ProcessCmd                   `kong:"cmd,help='Generate JSON for credential_process in ~/.aws/config'"`
+	Reauth             ReauthCmd                    `kong:"cmd,help='Force a new AWS SSO login without flushing cached STS credentials'"`
+	Refresh            RefreshCmd                   `kong:"cmd,help='Fetch new STS credentials for one or more roles'"`
+	Select             SelectCmd                    `kong:"cmd,help='Pick a role interactively and print the ARN'"`
+	Server             ServerCmd                    `kong:"cmd,help='Run aws-sso as a background service'"`
+	Status             StatusCmd                    `kong:"cmd,help='Print the state of the cached AWS SSO token as JSON'"`
 	Tags               TagsCmd                      `kong:"cmd,help='List tags'"`
+	Test               TestCmd                      `kong:"cmd,help='Verify an AWS Role can be assumed and print the resulting identity'"`
 	Time               TimeCmd                      `kong:"cmd,help='Print out much time before current STS Token expires'"`
 	Version            VersionCmd                   `kong:"cmd,help='Print version and exit'"`
+	Watch              WatchCmd                     `kong:"cmd,help='Notify before cached STS credentials expire'"`
+	Write              WriteCmd                     `kong:"cmd,help='Write STS credentials for one or more roles to ~/.aws/credentials'"`
 	InstallCompletions kongplete.InstallCompletions `kong:"cmd,help='Install shell completions'"`
 	Setup              SetupCmd                     `kong:"cmd,hidden"` // need this so variables are visisble.
 }
@@ -115,64 +179,138 @@ func main() {
 	ctx, override := parseArgs(&cli)
 	var err error
 
-	if err := urlActionValidate(cli.UrlAction); err != nil {
-		log.Fatalf("%s", err.Error())
-	}
-
 	if err := logLevelValidate(cli.LogLevel); err != nil {
 		log.Fatalf("%s", err.Error())
 	}
+	if cli.Offline && cli.STSRefresh {
+		log.Fatalf("--sts-refresh can not be used with --offline")
+	}
 
 	run_ctx := RunContext{
-		Kctx: ctx,
-		Cli:  &cli,
+		Kctx:    ctx,
+		Cli:     &cli,
+		Context: context.Background(),
+	}
+	if cli.Deadline > 0 {
+		var cancel context.CancelFunc
+		run_ctx.Context, cancel = context.WithTimeout(run_ctx.Context, cli.Deadline)
+		defer cancel()
 	}
 
 	// Load the config file
 	cli.ConfigFile = utils.GetHomePath(cli.ConfigFile)
+	if cli.ConfigFile == sso.STDIN_CONFIG {
+		switch ctx.Command() {
+		case "setup", "config edit", "import <bundle>", "server install":
+			log.Fatalf("%s requires a config file and can not be used with --config -", ctx.Command())
+		}
+	}
+
+	if ctx.Command() == "import <bundle>" {
+		// restoring a bundle doesn't require an existing config
+		if err = importBundle(&run_ctx); err != nil {
+			log.Fatalf("Error running command: %s", err.Error())
+		}
+		return
+	}
 
-	if _, err := os.Stat(cli.ConfigFile); errors.Is(err, os.ErrNotExist) {
+	if cli.ConfigFile == sso.STDIN_CONFIG {
+		log.Debugf("Reading config from stdin")
+	} else if _, err := os.Stat(cli.ConfigFile); errors.Is(err, os.ErrNotExist) {
 		log.Warnf("No config file found!  Will now prompt you for a basic config...")
 		if err = setupWizard(&run_ctx); err != nil {
 			log.Fatalf("%s", err.Error())
 		}
+		if ctx.Command() == "setup" {
+			return // nothing left to do
+		}
 	} else if err != nil {
 		log.WithError(err).Fatalf("Unable to open config file: %s", cli.ConfigFile)
 	}
 
+	if ctx.Command() == "config edit" {
+		// must work even when the current config is invalid
+		if err = editConfig(&run_ctx); err != nil {
+			log.Fatalf("%s", err.Error())
+		}
+		return
+	}
+
 	cacheFile := utils.GetHomePath(INSECURE_CACHE_FILE)
 
 	if run_ctx.Settings, err = sso.LoadSettings(cli.ConfigFile, cacheFile, DEFAULT_CONFIG, override); err != nil {
 		log.Fatalf("%s", err.Error())
 	}
 
-	// Load the secure store data
-	switch run_ctx.Settings.SecureStore {
-	case "json":
-		sfile := utils.GetHomePath(JSON_STORE_FILE)
-		if run_ctx.Settings.JsonStore != "" {
-			sfile = utils.GetHomePath(run_ctx.Settings.JsonStore)
+	loadSecureStore(&run_ctx)
+	utils.SetOpenUrlLimit(run_ctx.Settings.MaxOpenUrls, confirmOpenUrls)
+	utils.SetOpenUrlDelay(time.Duration(run_ctx.Settings.OpenUrlDelayMilliseconds) * time.Millisecond)
+	utils.SetOpenUrlRetries(run_ctx.Settings.OpenUrlRetries,
+		time.Duration(run_ctx.Settings.OpenUrlRetryMilliseconds)*time.Millisecond, !cli.NoFallback)
+	utils.SetUrlActions(run_ctx.Settings.UrlActions)
+	utils.SetRemoteOpenCommand(run_ctx.Settings.RemoteOpenCommand)
+	utils.SetMinValidRemaining(time.Duration(run_ctx.Settings.MinValidRemainingMinutes) * time.Minute)
+	utils.SetNotifyHTTPClient(run_ctx.Settings.HTTPClient())
+	if run_ctx.Settings.TimeFormat != nil {
+		utils.SetTimeRemainFormat(*run_ctx.Settings.TimeFormat)
+	}
+
+	// custom URL actions are only known after loading our config
+	if err := urlActionValidate(cli.UrlAction); err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+	if err := confirmTagsValidate(run_ctx.Settings.ConfirmTags); err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+
+	err = ctx.Run(&run_ctx)
+	if err != nil {
+		if errors.Is(run_ctx.Context.Err(), context.DeadlineExceeded) {
+			log.Fatalf("Aborted after --deadline %s: %s", cli.Deadline, err.Error())
 		}
-		run_ctx.Store, err = storage.OpenJsonStore(sfile)
+		log.Fatalf("Error running command: %s", err.Error())
+	}
+}
+
+// loadSecureStore opens the SecureStore selected by our settings
+func loadSecureStore(ctx *RunContext) {
+	var err error
+	agentSocket := os.Getenv(storage.AGENT_SOCKET_ENV)
+	switch {
+	case ctx.Kctx.Command() == "server daemon" && !ctx.Cli.Server.Daemon.SecureStore:
+		// the agent keeps everything in memory
+	case ctx.Kctx.Command() == "paths":
+		// only reports where the SecureStore is
+	case agentSocket != "" && ctx.Kctx.Command() != "server daemon":
+		ctx.Store, err = storage.OpenAgentStore(agentSocket)
+		if err != nil {
+			log.WithError(err).Fatalf("Unable to use agent via $%s", storage.AGENT_SOCKET_ENV)
+		}
+	case ctx.Settings.SecureStore == "json":
+		sfile := jsonStoreFile(ctx.Settings)
+		ctx.Store, err = storage.OpenJsonStore(sfile)
 		if err != nil {
 			log.WithError(err).Fatalf("Unable to open JsonStore %s", sfile)
 		}
 		log.Warnf("Using insecure json file for SecureStore: %s", sfile)
 	default:
-		cfg, err := storage.NewKeyringConfig(run_ctx.Settings.SecureStore, CONFIG_DIR)
+		cfg, err := storage.NewKeyringConfig(ctx.Settings.SecureStore, CONFIG_DIR)
 		if err != nil {
 			log.WithError(err).Fatalf("Unable to create SecureStore")
 		}
-		run_ctx.Store, err = storage.OpenKeyring(cfg)
+		ctx.Store, err = storage.OpenKeyring(cfg)
 		if err != nil {
-			log.WithError(err).Fatalf("Unable to open SecureStore %s", run_ctx.Settings.SecureStore)
+			log.WithError(err).Fatalf("Unable to open SecureStore %s", ctx.Settings.SecureStore)
 		}
 	}
+}
 
-	err = ctx.Run(&run_ctx)
-	if err != nil {
-		log.Fatalf("Error running command: %s", err.Error())
+// jsonStoreFile returns the path of the json SecureStore
+func jsonStoreFile(s *sso.Settings) string {
+	if s.JsonStore != "" {
+		return utils.GetHomePath(s.JsonStore)
 	}
+	return utils.GetHomePath(JSON_STORE_FILE)
 }
 
 // parseArgs parses our CLI arguments
@@ -183,6 +321,7 @@ func parseArgs(cli *CLI) (*kong.Context, sso.OverrideSettings) {
 		"CONFIG_FILE":     CONFIG_FILE,
 		"DEFAULT_STORE":   DEFAULT_STORE,
 		"JSON_STORE_FILE": JSON_STORE_FILE,
+		"AGENT_SOCKET":    AGENT_SOCKET,
 	}
 
 	parser := kong.Must(
@@ -199,6 +338,7 @@ func parseArgs(cli *CLI) (*kong.Context, sso.OverrideSettings) {
 		kongplete.WithPredictors(
 			map[string]complete.Predictor{
 				"accountId": p.AccountComplete(),
+				"alias":     p.AliasComplete(),
 				"arn":       p.ArnComplete(),
 				"fieldList": p.FieldListComplete(),
 				"profile":   p.ProfileComplete(),
@@ -213,11 +353,22 @@ func parseArgs(cli *CLI) (*kong.Context, sso.OverrideSettings) {
 	parser.FatalIfErrorf(err)
 
 	override := sso.OverrideSettings{
-		UrlAction:  cli.UrlAction,
-		Browser:    cli.Browser,
-		DefaultSSO: cli.SSO,
-		LogLevel:   cli.LogLevel,
-		LogLines:   cli.Lines,
+		AllAccounts:     cli.AllAccounts,
+		UrlAction:       cli.UrlAction,
+		Browser:         cli.Browser,
+		CABundle:        cli.CABundle,
+		LoginTimeout:    cli.LoginTimeout,
+		Offline:         cli.Offline,
+		ProxyUrl:        cli.Proxy,
+		ReloadTags:      cli.ReloadTags,
+		ShowDisabled:    cli.ShowDisabled,
+		ShowHiddenTags:  cli.ShowHiddenTags,
+		DefaultSSO:      cli.SSO,
+		SSORegion:       cli.SSORegion,
+		Env:             cli.Env,
+		IgnoreClockSkew: cli.IgnoreClockSkew,
+		LogLevel:        cli.LogLevel,
+		LogLines:        cli.Lines,
 	}
 
 	log.SetFormatter(&log.TextFormatter{
@@ -244,51 +395,249 @@ func (cc *VersionCmd) Run(ctx *RunContext) error {
 
 // Get our RoleCredentials from the secure store or from AWS SSO
 func GetRoleCredentials(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string) *storage.RoleCredentials {
+	creds, err := roleCredentials(ctx, awssso, accountid, role)
+	if err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+	return creds
+}
+
+// roleCredentials is GetRoleCredentials for callers which must not exit,
+// like the --ecs-server, and returns an error instead
+func roleCredentials(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string) (*storage.RoleCredentials, error) {
 	creds := storage.RoleCredentials{}
 
 	// First look for our creds in the secure store, if we're not forcing a refresh
 	arn := utils.MakeRoleARN(accountid, role)
+	policy, err := getSessionPolicy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load session policy: %s", err.Error())
+	}
+	key := roleCredentialsKey(ctx, accountid, role)
+	key.Duration = getSessionDuration(ctx)
+	key.Policy = policy.Hash()
 	log.Debugf("Getting role credentials for %s", arn)
-	if !ctx.Cli.STSRefresh {
-		if roleFlat, err := ctx.Settings.Cache.GetRole(arn); err == nil {
-			if !roleFlat.IsExpired() {
-				if err := ctx.Store.GetRoleCredentials(arn, &creds); err == nil {
-					if !creds.Expired() {
-						log.Debugf("Retrieved role credentials from the SecureStore")
-						return &creds
-					}
-				}
-			}
+
+	if ctx.Cli.Strict {
+		if roleFlat, err := ctx.Settings.Cache.GetRole(arn); err == nil && roleFlat.Disabled {
+			return nil, fmt.Errorf("Refusing to use %s which is disabled in the config", arn)
 		}
-	} else {
+	}
+
+	if err := ctx.Settings.Cache.SetRoleLastUsed(arn, time.Now().Unix()); err != nil {
+		log.WithError(err).Warnf("Unable to update cache")
+	}
+
+	if ctx.Cli.STSRefresh {
 		log.Infof("Forcing STS refresh for %s", arn)
+	} else if roleFlat, err := ctx.Settings.Cache.GetRole(arn); err == nil && roleFlat.IsValid() {
+		// offline we have to make do with whatever we have cached
+		if !ctx.Settings.Offline() && ctx.MinRemaining > 0 && roleFlat.ExpiresWithin(ctx.MinRemaining) {
+			log.Infof("Refreshing %s which expires in less than %s", arn, ctx.MinRemaining)
+		} else if err := storage.GetCachedRoleCredentials(ctx.Store, key, &creds); err != nil {
+			log.Debugf("%s", err.Error())
+		} else {
+			log.Debugf("Retrieved role credentials from the SecureStore")
+			return &creds, nil
+		}
+	}
+
+	if ctx.Settings.Offline() {
+		return nil, fmt.Errorf("No valid cached credentials for %s and unable to refresh them in --offline mode", arn)
 	}
 
 	log.Debugf("Fetching STS token from AWS SSO")
 
 	// If we didn't use our secure store ask AWS SSO
-	var err error
-	creds, err = awssso.GetRoleCredentials(accountid, role)
+	creds, err = awssso.GetRoleCredentialsWithPolicy(accountid, role, policy, key.Duration)
+	if err != nil && key.Duration > 0 && sso.IsDurationTooLargeError(err) {
+		max := sso.MaxDurationFromError(err)
+		if ctx.Cli.Process.Clamp && max > 0 && max < key.Duration {
+			log.Warnf("Reducing session duration from %d to the maximum of %d minutes", key.Duration/60, max/60)
+			key.Duration = max
+			creds, err = awssso.GetRoleCredentialsWithPolicy(accountid, role, policy, key.Duration)
+		} else {
+			err = sso.DurationTooLargeError(err, key.Duration, max)
+		}
+	}
 	if err != nil {
-		log.WithError(err).Fatalf("Unable to get role credentials for %s", arn)
+		return nil, fmt.Errorf("Unable to get role credentials for %s: %s", arn, err.Error())
 	}
 
 	log.Debugf("Retrieved role credentials from AWS SSO")
+	if ctx.MinRemaining > 0 && utils.Remaining(creds.ExpireEpoch()) < ctx.MinRemaining {
+		log.Warnf("New credentials for %s expire in less than %s", arn, ctx.MinRemaining)
+	}
 
-	// Cache our creds
-	if err := ctx.Store.SaveRoleCredentials(arn, creds); err != nil {
+	saveRoleCredentials(ctx, key, &creds)
+	return &creds, nil
+}
+
+// setMinRemaining sets how much time must remain on cached STS credentials
+// for them to be used.  The --refresh-if-expiring flag takes precedence over
+// the RefreshIfExpiringMinutes config option and zero disables the check.
+func setMinRemaining(ctx *RunContext, refreshIfExpiring time.Duration) error {
+	if refreshIfExpiring < 0 {
+		return fmt.Errorf("--refresh-if-expiring must not be negative")
+	}
+	ctx.MinRemaining = refreshIfExpiring
+	if ctx.MinRemaining == 0 {
+		ctx.MinRemaining = time.Duration(ctx.Settings.RefreshIfExpiringMinutes) * time.Minute
+	}
+	return nil
+}
+
+// checkAccountAccess verifies we have roles in the user provided --account
+// so we can fail early with a useful error
+func checkAccountAccess(ctx *RunContext, accountId int64, noValidate bool) error {
+	if noValidate {
+		return nil
+	}
+	return checkCachedAccess(ctx, func(r *sso.Roles) error {
+		return r.CheckAccountAccess(accountId)
+	})
+}
+
+// checkRoleAccess verifies the user provided --arn is available via the
+// selected AWS SSO instance
+func checkRoleAccess(ctx *RunContext, accountId int64, role string, noValidate bool) error {
+	if noValidate {
+		return nil
+	}
+	err := checkCachedAccess(ctx, func(r *sso.Roles) error {
+		return r.CheckRoleAccess(accountId, role)
+	})
+	if err != nil {
+		ssoName, _ := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+		return fmt.Errorf("%s via AWS SSO instance %s", err.Error(), ssoName)
+	}
+	return nil
+}
+
+// checkCachedAccess runs the check against our cached roles.  On failure, the
+// cache is refreshed once in case we were granted access since it was last
+// refreshed, and the check is run again.
+func checkCachedAccess(ctx *RunContext, check func(*sso.Roles) error) error {
+	err := check(ctx.Settings.Cache.GetSSO().Roles)
+	if err == nil || ctx.Settings.Offline() {
+		return err
+	}
+
+	log.Debugf("%s, refreshing the role cache", err.Error())
+	if rerr := refreshRoleCache(ctx); rerr != nil {
+		log.WithError(rerr).Warnf("Unable to refresh role cache")
+		return err
+	}
+	return check(ctx.Settings.Cache.GetSSO().Roles)
+}
+
+// refreshRoleCache refreshes and saves the cached roles of the selected AWS SSO instance
+func refreshRoleCache(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	ssoName, err := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+
+	awssso := doAuth(ctx)
+	if err = ctx.Settings.Cache.Refresh(awssso, s, ssoName); err != nil {
+		return err
+	}
+	return ctx.Settings.Cache.Save(true)
+}
+
+// roleCredentialsKey returns the key used to cache the role credentials
+// fetched with the default duration and no session policy
+func roleCredentialsKey(ctx *RunContext, accountId int64, role string) storage.RoleCredentialsKey {
+	ssoName, _ := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	key := storage.RoleCredentialsKey{
+		SSO:    ssoName,
+		Arn:    utils.MakeRoleARN(accountId, role),
+		Region: ctx.Settings.GetDefaultRegion(accountId, role, false),
+	}
+	if s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO); err == nil {
+		key.SessionName = s.RoleSessionName(accountId, role)
+	}
+	return key
+}
+
+// saveRoleCredentials caches the creds in the SecureStore and updates our cache
+func saveRoleCredentials(ctx *RunContext, key storage.RoleCredentialsKey, creds *storage.RoleCredentials) {
+	creds.CacheKey = key.String()
+	if err := ctx.Store.SaveRoleCredentials(key.String(), *creds); err != nil {
 		log.WithError(err).Warnf("Unable to cache role credentials in secure store")
 	}
 
-	// Update the cache
-	if err := ctx.Settings.Cache.SetRoleExpires(arn, creds.ExpireEpoch()); err != nil {
+	if err := ctx.Settings.Cache.SetRoleExpires(key.Arn, creds.ExpireEpoch()); err != nil {
 		log.WithError(err).Warnf("Unable to update cache")
 	}
-	return &creds
+}
+
+// getSessionPolicy returns the session policy flags for the selected command
+func getSessionPolicy(ctx *RunContext) (sso.SessionPolicy, error) {
+	var arns []string
+	var file string
+
+	switch strings.Fields(ctx.Kctx.Command())[0] {
+	case "eval":
+		arns, file = ctx.Cli.Eval.PolicyArn, ctx.Cli.Eval.PolicyFile
+	case "exec":
+		arns, file = ctx.Cli.Exec.PolicyArn, ctx.Cli.Exec.PolicyFile
+	case "process":
+		arns, file = ctx.Cli.Process.PolicyArn, ctx.Cli.Process.PolicyFile
+	}
+	return sso.LoadSessionPolicy(arns, file)
+}
+
+// getSessionDuration returns the requested session duration in seconds for
+// the selected command or 0 for the AWS default
+func getSessionDuration(ctx *RunContext) int32 {
+	var duration string
+	switch strings.Fields(ctx.Kctx.Command())[0] {
+	case "process":
+		duration = ctx.Cli.Process.Duration
+	case "test":
+		duration = ctx.Cli.Test.Duration
+	}
+	// already validated by validateSessionDuration
+	seconds, _ := sso.ParseSessionDuration(duration)
+	return seconds
+}
+
+// validateSessionDuration ensures the --duration flag, if any, is valid
+func validateSessionDuration(duration string) error {
+	seconds, err := sso.ParseSessionDuration(duration)
+	if err != nil || seconds == 0 {
+		return err
+	}
+	return sso.ValidateSessionDuration(seconds)
 }
 
 var AwsSSO *sso.AWSSSO // global
 
+// how often to report we are still waiting on throttled AWS API calls
+const RETRY_STATUS_INTERVAL = 2 * time.Second
+
+// newRetryStatus returns a sso.RetryStatusFunc which logs to stderr how many
+// throttled calls are waiting to be retried, at most every RETRY_STATUS_INTERVAL
+func newRetryStatus() sso.RetryStatusFunc {
+	var last time.Time
+	return func(waiting int, wait time.Duration) {
+		if waiting == 0 {
+			last = time.Time{}
+			return
+		}
+		if time.Since(last) < RETRY_STATUS_INTERVAL {
+			return
+		}
+		last = time.Now()
+		log.Warnf("Throttled by AWS: retrying %d account(s) in up to %s...", waiting, wait)
+	}
+}
+
 // Creates a singleton AWSSO object post authentication
 func doAuth(ctx *RunContext) *sso.AWSSSO {
 	if AwsSSO != nil {
@@ -299,10 +648,26 @@ func doAuth(ctx *RunContext) *sso.AWSSSO {
 		log.Fatalf("%s", err.Error())
 	}
 	AwsSSO = sso.NewAWSSSO(s, &ctx.Store)
+	AwsSSO.SetContext(ctx.Context)
+	if !ctx.Cli.Quiet && terminal.IsTerminal(int(os.Stderr.Fd())) {
+		AwsSSO.SetRetryStatus(newRetryStatus())
+	}
+	login := !AwsSSO.ValidAuthToken()
 	err = AwsSSO.Authenticate(ctx.Settings.UrlAction, ctx.Settings.Browser)
-	if err != nil {
+	if err != nil && ctx.Settings.Offline() {
+		log.Fatalf("No valid cached AWS SSO token and unable to login in --offline mode")
+	} else if err != nil {
 		log.WithError(err).Fatalf("Unable to authenticate")
 	}
+	if ctx.Settings.Offline() {
+		// use the cached token as-is and never refresh the role cache
+		return AwsSSO
+	}
+	if ctx.Cli.ValidateToken && !login {
+		if err = AwsSSO.ValidateToken(); err != nil {
+			log.WithError(err).Fatalf("Unable to authenticate")
+		}
+	}
 	if err = ctx.Settings.Cache.Expired(s); err != nil {
 		ssoName, err := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
 		if err != nil {
@@ -315,6 +680,9 @@ func doAuth(ctx *RunContext) *sso.AWSSSO {
 			log.WithError(err).Errorf("Unable to save cache")
 		}
 	}
+	if login {
+		startPrefetch(ctx)
+	}
 	return AwsSSO
 }
 
@@ -326,10 +694,138 @@ func logLevelValidate(level string) error {
 	return fmt.Errorf("Invalid value for --level: %s", level)
 }
 
-func urlActionValidate(action string) error {
+func notifyActionValidate(action string) error {
 	switch action {
-	case "open", "print", "clip", "":
+	case "print", "notify-send", "osascript", "webhook":
+		return nil
+	}
+	return fmt.Errorf("Invalid value for --action: '%s'", action)
+}
+
+func urlActionValidate(action string) error {
+	if action == "" {
 		return nil
 	}
-	return fmt.Errorf("Invalid value for --url-action: %s", action)
+	if _, err := utils.ParseUrlAction(action); err != nil {
+		return fmt.Errorf("Invalid value for --url-action: %s", action)
+	}
+	return nil
+}
+
+// confirmOpenUrls asks the user before opening more than MaxOpenUrls URLs
+// in the browser.  Always refuses when stdin is not a terminal.
+func confirmOpenUrls(count int) error {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("Refusing to open more than %d URLs in the browser.  See MaxOpenUrls in %s", count-1, CONFIG_FILE)
+	}
+	confirm := promptui.Prompt{
+		Label:     fmt.Sprintf("Open more than %d URLs in the browser", count-1),
+		IsConfirm: true,
+	}
+	if _, err := confirm.Run(); err != nil {
+		return fmt.Errorf("Aborted")
+	}
+	return nil
+}
+
+// confirmTagsValidate verifies each of the ConfirmTags has a valid Filter
+func confirmTagsValidate(confirmTags []sso.ConfirmTag) error {
+	for _, ct := range confirmTags {
+		if len(ct.Filter) == 0 {
+			return fmt.Errorf("Invalid ConfirmTags: missing Filter for %s", ct.Prompt)
+		}
+		if _, err := parseTagFilters(ct.Filter); err != nil {
+			return fmt.Errorf("Invalid ConfirmTags: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// confirmPrompt returns the prompt of the first ConfirmTags entry whose
+// Filter matches the role and true or false if none of them match
+func confirmPrompt(confirmTags []sso.ConfirmTag, role *sso.AWSRoleFlat) (string, bool) {
+	for _, ct := range confirmTags {
+		tags, err := parseTagFilters(ct.Filter)
+		if err != nil || len(tags) == 0 {
+			continue // validated by confirmTagsValidate
+		}
+		if role.MatchesTags(tags) {
+			return ct.Prompt, true
+		}
+	}
+	return "", false
+}
+
+// confirmRole asks the user to confirm using a role matching one of the
+// ConfirmTags filters.  Unless yes is set, we refuse when not in a terminal.
+func confirmRole(ctx *RunContext, accountId int64, role string, yes bool) error {
+	if yes {
+		return nil
+	}
+	rFlat, err := ctx.Settings.Cache.GetSSO().Roles.GetRole(accountId, role)
+	if err != nil {
+		return nil // roles we know nothing about have no tags to match
+	}
+	label, ok := confirmPrompt(ctx.Settings.ConfirmTags, rFlat)
+	if !ok {
+		return nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("Refusing to use %s which matches ConfirmTags without --yes", rFlat.Arn)
+	}
+	confirm := promptui.Prompt{
+		Label:     fmt.Sprintf("%s [%s]", label, rFlat.Arn),
+		IsConfirm: true,
+		Stdout:    &bellSkipper{},
+	}
+	if _, err := confirm.Run(); err != nil {
+		return fmt.Errorf("Aborted")
+	}
+	return nil
+}
+
+// useColor returns true if we should colorize our output
+func useColor(ctx *RunContext) bool {
+	switch ctx.Cli.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// marshalJSON pretty prints JSON for humans and uses a single line for
+// everything else, unless overridden by --compact or --json-pretty
+func marshalJSON(ctx *RunContext, v interface{}) ([]byte, error) {
+	pretty := terminal.IsTerminal(int(os.Stdout.Fd()))
+	if ctx.Cli.Compact {
+		pretty = false
+	} else if ctx.Cli.JsonPretty {
+		pretty = true
+	}
+
+	if pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// marshalOutput returns the data as json or yaml.  YAML uses the same json
+// struct tags so both formats always have the same keys.
+func marshalOutput(ctx *RunContext, format string, v interface{}) ([]byte, error) {
+	switch format {
+	case "json":
+		return marshalJSON(ctx, v)
+	case "yaml":
+		b, err := goyaml.Marshal(v)
+		if err != nil {
+			return b, err
+		}
+		return []byte(strings.TrimSuffix(string(b), "\n")), nil
+	}
+	return []byte{}, fmt.Errorf("Unsupported output format: %s", format)
 }
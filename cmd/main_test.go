@@ -0,0 +1,75 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/sso"
+)
+
+func TestConfirmPrompt(t *testing.T) {
+	confirmTags := []sso.ConfirmTag{
+		{
+			Filter: []string{"Env=prod"},
+			Prompt: "This is a production account, continue",
+		},
+		{
+			Filter: []string{"Env=dev", "app.kubernetes.io/part-of=payments"},
+			Prompt: "PCI scope, continue",
+		},
+	}
+	prod := &sso.AWSRoleFlat{Tags: map[string]string{"Env": "prod", "app.kubernetes.io/part-of": "payments"}}
+	pci := &sso.AWSRoleFlat{Tags: map[string]string{"Env": "dev", "app.kubernetes.io/part-of": "payments"}}
+	dev := &sso.AWSRoleFlat{Tags: map[string]string{"Env": "dev"}}
+
+	// the first matching entry wins
+	prompt, ok := confirmPrompt(confirmTags, prod)
+	assert.True(t, ok)
+	assert.Equal(t, "This is a production account, continue", prompt)
+
+	// all of the tags in the Filter must match
+	prompt, ok = confirmPrompt(confirmTags, pci)
+	assert.True(t, ok)
+	assert.Equal(t, "PCI scope, continue", prompt)
+
+	_, ok = confirmPrompt(confirmTags, dev)
+	assert.False(t, ok)
+
+	_, ok = confirmPrompt([]sso.ConfirmTag{}, prod)
+	assert.False(t, ok)
+}
+
+func TestConfirmTagsValidate(t *testing.T) {
+	assert.NoError(t, confirmTagsValidate([]sso.ConfirmTag{
+		{Filter: []string{"Env=prod", "Team=Payments"}, Prompt: "Continue"},
+	}))
+	assert.NoError(t, confirmTagsValidate(nil))
+
+	assert.Error(t, confirmTagsValidate([]sso.ConfirmTag{
+		{Filter: []string{"Env"}, Prompt: "Continue"},
+	}))
+	assert.Error(t, confirmTagsValidate([]sso.ConfirmTag{
+		{Filter: []string{"=prod"}, Prompt: "Continue"},
+	}))
+	assert.Error(t, confirmTagsValidate([]sso.ConfirmTag{
+		{Prompt: "Continue"},
+	}))
+}
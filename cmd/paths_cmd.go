@@ -0,0 +1,108 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type PathsCmd struct {
+	Output string `kong:"short='o',enum='human,json',default='human',help='Output format [human|json]'"`
+}
+
+// pathsResult are the files aws-sso is using after applying all overrides
+type pathsResult struct {
+	ConfigFile       string `json:"ConfigFile"`
+	CacheFile        string `json:"CacheFile"`
+	SecureStore      string `json:"SecureStore"`
+	SecureStorePath  string `json:"SecureStorePath,omitempty"`
+	AwsConfigFile    string `json:"AwsConfigFile"`
+	TagsFile         string `json:"TagsFile,omitempty"`
+	AccountNamesFile string `json:"AccountNamesFile,omitempty"`
+}
+
+func (cc *PathsCmd) Run(ctx *RunContext) error {
+	paths := pathsResult{
+		ConfigFile:    ctx.Settings.ConfigFile(),
+		CacheFile:     absPath(ctx.Settings.CacheFile()),
+		SecureStore:   ctx.Settings.SecureStore,
+		AwsConfigFile: absPath(awsConfigFile()),
+	}
+
+	if paths.ConfigFile != sso.STDIN_CONFIG {
+		paths.ConfigFile = absPath(paths.ConfigFile)
+	}
+
+	switch {
+	case os.Getenv(storage.AGENT_SOCKET_ENV) != "":
+		paths.SecureStore = "agent"
+		paths.SecureStorePath = absPath(os.Getenv(storage.AGENT_SOCKET_ENV))
+	case ctx.Settings.SecureStore == "json":
+		paths.SecureStorePath = absPath(jsonStoreFile(ctx.Settings))
+	case ctx.Settings.SecureStore == "file":
+		paths.SecureStorePath = absPath(storage.KeyringFileDir(CONFIG_DIR))
+	}
+
+	if ctx.Settings.TagsFile != "" {
+		paths.TagsFile = absPath(ctx.Settings.TagsFile)
+	}
+	if ctx.Settings.AccountNamesFile != "" {
+		paths.AccountNamesFile = absPath(ctx.Settings.AccountNamesFile)
+	}
+
+	if ctx.Cli.Paths.Output == "json" {
+		out, err := marshalJSON(ctx, paths)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	store := paths.SecureStore
+	if paths.SecureStorePath != "" {
+		store = fmt.Sprintf("%s (%s)", store, paths.SecureStorePath)
+	}
+	fmt.Printf("Config File:        %s\n", paths.ConfigFile)
+	fmt.Printf("Cache File:         %s\n", paths.CacheFile)
+	fmt.Printf("SecureStore:        %s\n", store)
+	fmt.Printf("AWS Config File:    %s\n", paths.AwsConfigFile)
+	if paths.TagsFile != "" {
+		fmt.Printf("Tags File:          %s\n", paths.TagsFile)
+	}
+	if paths.AccountNamesFile != "" {
+		fmt.Printf("Account Names File: %s\n", paths.AccountNamesFile)
+	}
+	return nil
+}
+
+// absPath expands ~ and returns the absolute path if possible
+func absPath(path string) string {
+	path = utils.GetHomePath(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
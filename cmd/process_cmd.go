@@ -30,25 +30,36 @@ import (
 
 type ProcessCmd struct {
 	// AWS Params
-	Arn       string `kong:"short='a',help='ARN of role to assume',xor='arn-1',xor='arn-2',predictor='arn'"`
-	AccountId int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',xor='arn-1',predictor='accountId'"`
-	Role      string `kong:"short='R',help='Name of AWS Role to assume',xor='arn-2',predictor='role'"`
-	Profile   string `kong:"short='p',help='Name of AWS Profile to assume',xor='arn-1',predictor='profile'"`
+	Arn        string `kong:"short='a',help='ARN of role to assume',xor='arn-1',xor='arn-2',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to assume',xor='arn-1',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to assume',xor='arn-2',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to assume',xor='arn-1',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+
+	PolicyArn  []string `kong:"help='ARN of IAM policy to scope down the session of a role using Via (repeatable)'"`
+	PolicyFile string   `kong:"help='Path to JSON IAM policy to scope down the session of a role using Via'"`
+
+	Duration string `kong:"short='d',help='Session duration in minutes or as a duration like 8h (default: the role session duration)'"`
+	Clamp    bool   `kong:"name='clamp-duration',help='Reduce the duration to the maximum allowed instead of failing'"`
 }
 
 func (cc *ProcessCmd) Run(ctx *RunContext) error {
 	var err error
 
-	if ctx.Settings.UrlAction == "print" {
+	if utils.UrlActionIncludes(ctx.Settings.UrlAction, "print") {
 		return fmt.Errorf("Unsupported --url-action=print option")
 	}
 
+	if err := validateSessionDuration(ctx.Cli.Process.Duration); err != nil {
+		return err
+	}
+
 	role := ctx.Cli.Process.Role
 	account := ctx.Cli.Process.AccountId
 
 	if ctx.Cli.Process.Profile != "" {
 		cache := ctx.Settings.Cache.GetSSO()
-		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.Eval.Profile, ctx.Settings)
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.Process.Profile, ctx.Settings)
 		if err != nil {
 			return err
 		}
@@ -60,11 +71,19 @@ func (cc *ProcessCmd) Run(ctx *RunContext) error {
 		if err != nil {
 			return err
 		}
+		if err := checkRoleAccess(ctx, account, role, ctx.Cli.Process.NoValidate); err != nil {
+			return err
+		}
 	}
 
 	if role == "" || account == 0 {
 		return fmt.Errorf("Please specify --arn or --account and --role")
 	}
+	if ctx.Cli.Process.AccountId != 0 {
+		if err := checkAccountAccess(ctx, account, ctx.Cli.Process.NoValidate); err != nil {
+			return err
+		}
+	}
 
 	awssso := doAuth(ctx)
 	return credentialProcess(ctx, awssso, account, role)
@@ -0,0 +1,50 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type ReauthCmd struct{}
+
+// Run forces a new AWS SSO login, leaving any cached STS credentials intact
+func (cc *ReauthCmd) Run(ctx *RunContext) error {
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+
+	awssso := sso.NewAWSSSO(s, &ctx.Store)
+	awssso.SetContext(ctx.Context)
+	if err = awssso.Reauthenticate(ctx.Settings.UrlAction, ctx.Settings.Browser); err != nil {
+		log.WithError(err).Fatalf("Unable to authenticate")
+	}
+	startPrefetch(ctx)
+
+	remain, _ := utils.TimeRemain(awssso.Token.ExpiresAt, false)
+	fmt.Printf("AWS SSO token expires at: %s (%s)\n",
+		time.Unix(awssso.Token.ExpiresAt, 0).Format("Mon Jan 2 15:04:05 -0700 MST 2006"), remain)
+	return nil
+}
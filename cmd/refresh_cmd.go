@@ -0,0 +1,191 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+type RefreshCmd struct {
+	Arn         []string      `kong:"short='a',help='ARN of role to refresh (repeatable)',predictor='arn'"`
+	Filter      []string      `kong:"help='Refresh roles with the tag Key=Value (repeatable)'"`
+	Concurrency int           `kong:"help='Maximum number of roles to refresh in parallel (default: MaxConcurrency)'"`
+	Timeout     time.Duration `kong:"default='30s',help='Give up on a role which takes longer than this to refresh (0 = never)'"`
+}
+
+// Run fetches new STS credentials for the selected roles
+func (cc *RefreshCmd) Run(ctx *RunContext) error {
+	var roles []*sso.AWSRoleFlat
+	var err error
+
+	if len(ctx.Cli.Refresh.Arn) > 0 || len(ctx.Cli.Refresh.Filter) > 0 {
+		if roles, err = refreshRoles(ctx); err != nil {
+			return err
+		}
+	} else if terminal.IsTerminal(int(os.Stdin.Fd())) && terminal.IsTerminal(int(os.Stdout.Fd())) {
+		if roles, err = selectRoles(ctx); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("Please specify --arn or --filter when not running in a terminal")
+	}
+
+	if len(roles) == 0 {
+		return fmt.Errorf("No roles selected")
+	}
+
+	if ctx.Cli.Refresh.Concurrency < 0 {
+		return fmt.Errorf("--concurrency must not be negative")
+	}
+	if ctx.Cli.Refresh.Timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+	concurrency := ctx.Cli.Refresh.Concurrency
+	if concurrency == 0 {
+		concurrency = ctx.Settings.MaxConcurrency
+	}
+
+	awssso := doAuth(ctx)
+	failed, denied, timedOut := 0, 0, 0
+	for _, result := range fetchRoleCredentials(ctx, awssso, roles, concurrency, ctx.Cli.Refresh.Timeout) {
+		switch {
+		case result.Err == nil:
+		case result.TimedOut:
+			fmt.Printf("%s: TIMED OUT after %s\n", result.Role.Arn, ctx.Cli.Refresh.Timeout)
+			timedOut++
+			continue
+		case sso.IsAccessDeniedError(result.Err):
+			fmt.Printf("%s: DENIED %s\n", result.Role.Arn, result.Err.Error())
+			denied++
+			continue
+		default:
+			fmt.Printf("%s: FAILED %s\n", result.Role.Arn, result.Err.Error())
+			failed++
+			continue
+		}
+		remain, _ := utils.TimeRemain(result.Creds.ExpireEpoch(), false)
+		fmt.Printf("%s: expires in %s\n", result.Role.Arn, remain)
+	}
+
+	if err = ctx.Settings.Cache.Save(false); err != nil {
+		log.WithError(err).Warnf("Unable to save cache")
+	}
+
+	if total := failed + denied + timedOut; total > 0 {
+		return fmt.Errorf("Unable to refresh %d of %d roles (%d denied, %d timed out, %d failed)",
+			total, len(roles), denied, timedOut, failed)
+	}
+	return nil
+}
+
+// refreshRoles returns the roles selected by --arn and --filter
+func refreshRoles(ctx *RunContext) ([]*sso.AWSRoleFlat, error) {
+	cache := ctx.Settings.Cache.GetSSO()
+	selected := map[string]*sso.AWSRoleFlat{}
+
+	for _, arn := range ctx.Cli.Refresh.Arn {
+		accountId, roleName, err := utils.ParseRoleARN(arn)
+		if err != nil {
+			return nil, err
+		}
+		role, err := cache.Roles.GetRole(accountId, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("Unknown role %s: %s", arn, err.Error())
+		}
+		selected[role.Arn] = role
+	}
+
+	if len(ctx.Cli.Refresh.Filter) > 0 {
+		tags, err := parseTagFilters(ctx.Cli.Refresh.Filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range cache.Roles.MatchingRoles(tags) {
+			selected[role.Arn] = role
+		}
+	}
+
+	roles := []*sso.AWSRoleFlat{}
+	for _, role := range selected {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Arn < roles[j].Arn })
+	return roles, nil
+}
+
+// selectRoles lets the user pick one or more roles from a checkbox list
+func selectRoles(ctx *RunContext) ([]*sso.AWSRoleFlat, error) {
+	roles := ctx.Settings.Cache.GetSSO().Roles.GetAllRoles()
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Arn < roles[j].Arn })
+
+	checked := make([]bool, len(roles))
+	cursor := 0
+	for {
+		count := 0
+		items := []string{}
+		for i, role := range roles {
+			box := "[ ]"
+			if checked[i] {
+				box = "[x]"
+				count++
+			}
+			items = append(items, fmt.Sprintf("%s %s %s", box, role.Arn, role.AccountAlias))
+		}
+		items = append([]string{fmt.Sprintf("Refresh %d selected roles", count)}, items...)
+
+		sel := promptui.Select{
+			Label:     "Select roles to refresh (Enter to toggle)",
+			Items:     items,
+			CursorPos: cursor,
+			Size:      15,
+			Stdout:    &bellSkipper{},
+			Searcher: func(input string, index int) bool {
+				return strings.Contains(strings.ToLower(items[index]), strings.ToLower(input))
+			},
+		}
+		i, _, err := sel.Run()
+		if err != nil {
+			return nil, fmt.Errorf("Aborted")
+		}
+
+		if i == 0 {
+			break
+		}
+		checked[i-1] = !checked[i-1]
+		cursor = i
+	}
+
+	selected := []*sso.AWSRoleFlat{}
+	for i, role := range roles {
+		if checked[i] {
+			selected = append(selected, role)
+		}
+	}
+	return selected, nil
+}
@@ -38,21 +38,44 @@ type TagsCompleter struct {
 	sso      *sso.SSOConfig
 	roleTags *sso.RoleTags
 	allTags  *sso.TagsList
+	primary  []string // AccountPrimaryTag without any hidden tags
 	suggest  []prompt.Suggest
 	exec     CompleterExec
+	skipAuth bool // exec does not need to talk to AWS
 }
 
 func NewTagsCompleter(ctx *RunContext, s *sso.SSOConfig, exec CompleterExec) *TagsCompleter {
 	set := ctx.Settings
 	roleTags := set.Cache.GetRoleTagsSelect()
 	allTags := set.Cache.GetAllTagsSelect()
+	for key := range *allTags {
+		// hidden tags can still be typed, but are never suggested
+		if set.TagHidden(key) {
+			delete(*allTags, key)
+		}
+	}
+	primary := []string{}
+	for _, tag := range set.AccountPrimaryTag {
+		if !set.TagHidden(tag) {
+			primary = append(primary, tag)
+		}
+	}
+
+	suggest := completeTags(roleTags, allTags, primary, []string{})
+	for _, alias := range set.AliasNames() {
+		suggest = append(suggest, prompt.Suggest{
+			Text:        alias,
+			Description: fmt.Sprintf("alias for %s", set.Aliases[alias]),
+		})
+	}
 
 	return &TagsCompleter{
 		ctx:      ctx,
 		sso:      s,
 		roleTags: roleTags,
 		allTags:  allTags,
-		suggest:  completeTags(roleTags, allTags, set.AccountPrimaryTag, []string{}),
+		primary:  primary,
+		suggest:  suggest,
 		exec:     exec,
 	}
 }
@@ -70,7 +93,7 @@ func (tc *TagsCompleter) Complete(d prompt.Document) []prompt.Suggest {
 	// remove any extra spaces
 	cleanArgs := CompleteSpaceReplace.ReplaceAllString(args, " ")
 	argsList := strings.Split(cleanArgs, " ")
-	suggest := completeTags(tc.roleTags, tc.allTags, tc.ctx.Settings.AccountPrimaryTag, argsList)
+	suggest := completeTags(tc.roleTags, tc.allTags, tc.primary, argsList)
 	return prompt.FilterHasPrefix(suggest, w, true)
 }
 
@@ -86,7 +109,14 @@ func (tc *TagsCompleter) Executor(args string) {
 
 	var roleArn string
 	argsList := strings.Split(args, " ")
-	if isRoleARN.MatchString(argsList[len(argsList)-1]) {
+	if _, ok := tc.ctx.Settings.Aliases[args]; ok {
+		// user picked a role alias
+		aId, rName, err := tc.ctx.Settings.ResolveAlias(args)
+		if err != nil {
+			log.Fatalf("%s", err.Error())
+		}
+		roleArn = utils.MakeRoleARN(aId, rName)
+	} else if isRoleARN.MatchString(argsList[len(argsList)-1]) {
 		// last word is our ARN, no need to filter
 		roleArn = argsList[len(argsList)-1]
 	} else {
@@ -106,7 +136,10 @@ func (tc *TagsCompleter) Executor(args string) {
 	if err != nil {
 		log.Fatalf("Unable to parse %s: %s", roleArn, err.Error())
 	}
-	awsSSO := doAuth(tc.ctx)
+	var awsSSO *sso.AWSSSO
+	if !tc.skipAuth {
+		awsSSO = doAuth(tc.ctx)
+	}
 	err = tc.exec(tc.ctx, awsSSO, aId, rName)
 	if err != nil {
 		log.Fatalf("Unable to exec: %s", err.Error())
@@ -164,6 +197,11 @@ func completeTags(roleTags *sso.RoleTags, allTags *sso.TagsList, accountPrimaryT
 							break
 						}
 					}
+					// help disambiguate similar roles
+					if val, ok := roleTags.GetRoleTags(role)["Description"]; ok && val != "" {
+						description = fmt.Sprintf("%s %s", description,
+							utils.Truncate(strings.ReplaceAll(val, "_", " "), MAX_DESCRIPTION_LEN))
+					}
 					suggestions = append(suggestions, prompt.Suggest{
 						Text:        role,
 						Description: description,
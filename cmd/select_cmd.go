@@ -0,0 +1,86 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/c-bata/go-prompt"
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+type SelectCmd struct{}
+
+// Run lets the user pick a role and prints the ARN
+func (cc *SelectCmd) Run(ctx *RunContext) error {
+	return selectOnly(ctx)
+}
+
+// selectOnly opens the interactive picker and prints the ARN of the selected
+// role to stdout without doing anything else.  The picker is drawn on stderr
+// so the output can be captured via $(aws-sso select)
+func selectOnly(ctx *RunContext) error {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("Unable to select a role: stdin is not a terminal")
+	}
+
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	if err = ctx.Settings.Cache.Expired(s); err != nil && !ctx.Settings.Offline() {
+		log.Infof(err.Error())
+		c := &CacheCmd{}
+		if err = c.Run(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.Refresh(ctx.Settings)
+	fmt.Fprintf(os.Stderr, "Please use `exit` or `Ctrl-D` to quit.\n")
+
+	selected := false
+	printArn := func(ctx *RunContext, awssso *sso.AWSSSO, accountid int64, role string) error {
+		fmt.Println(utils.MakeRoleARN(accountid, role))
+		selected = true
+		return nil
+	}
+
+	c := NewTagsCompleter(ctx, s, printArn)
+	c.skipAuth = true
+	opts := ctx.Settings.DefaultOptions(c.ExitChecker)
+	opts = append(opts, ctx.Settings.GetColorOptions()...)
+	opts = append(opts, prompt.OptionWriter(prompt.NewStderrWriter()))
+
+	p := prompt.New(
+		c.Executor,
+		c.Complete,
+		opts...,
+	)
+	p.Run()
+
+	if !selected {
+		return fmt.Errorf("No role selected")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type ServerCmd struct {
+	Daemon  ServerDaemonCmd  `kong:"cmd,help='Hold all AWS SSO and STS credentials in memory instead of the SecureStore'"`
+	Install ServerInstallCmd `kong:"cmd,help='Generate a systemd or launchd service file for the server daemon'"`
+}
+
+type ServerDaemonCmd struct {
+	Socket      string        `kong:"help='Path of the unix socket to listen on',default='${AGENT_SOCKET}'"`
+	IdleTimeout time.Duration `kong:"help='Forget credentials which have not been requested for this long (ex: 15m)'"`
+	MaxLifetime time.Duration `kong:"help='Forget credentials this long after they were stored (ex: 8h)'"`
+	SecureStore bool          `kong:"help='Also save credentials in the SecureStore and reload forgotten credentials from it'"`
+}
+
+func (cc *ServerDaemonCmd) Run(ctx *RunContext) error {
+	socket := utils.GetHomePath(ctx.Cli.Server.Daemon.Socket)
+	agent, err := storage.NewAgent(socket)
+	if err != nil {
+		return err
+	}
+	agent.SetExpiry(ctx.Cli.Server.Daemon.IdleTimeout, ctx.Cli.Server.Daemon.MaxLifetime)
+	if ctx.Cli.Server.Daemon.SecureStore {
+		agent.SetSecureStore(ctx.Store)
+	}
+
+	// zero our secrets on the way out
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan error, 1)
+	go func() {
+		sig := <-sigs
+		log.Infof("Received %s, shutting down", sig)
+		done <- agent.Close()
+	}()
+
+	fmt.Printf("export %s=%s\n", storage.AGENT_SOCKET_ENV, socket)
+	log.Infof("Listening on %s", socket)
+	if err = agent.Serve(); err != nil {
+		agent.Close()
+		return err
+	}
+	return <-done
+}
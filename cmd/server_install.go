@@ -0,0 +1,236 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+const (
+	SERVICE_NAME   = "aws-sso"
+	LAUNCHD_LABEL  = "com.github.synfinatic.aws-sso"
+	SERVICE_DOCURL = "https://github.com/synfinatic/aws-sso-cli"
+)
+
+type ServerInstallCmd struct {
+	Type   string `kong:"required,enum='systemd,launchd',help='Type of service file to generate [systemd|launchd]'"`
+	User   bool   `kong:"help='Generate a per-user service instead of a system service'"`
+	Output string `kong:"short='o',help='Write the service file to this path instead of STDOUT'"`
+	Force  bool   `kong:"help='Overwrite an existing --output file'"`
+	Socket string `kong:"help='Path of the unix socket for the server to listen on',default='${AGENT_SOCKET}'"`
+}
+
+// serviceConfig is passed to our service file templates
+type serviceConfig struct {
+	Label      string
+	DocUrl     string
+	Args       []string // binary + arguments
+	Env        map[string]string
+	User       string // only set for system services
+	UserScope  bool
+	LogFile    string
+	WorkingDir string
+}
+
+const SYSTEMD_TEMPLATE = `[Unit]
+Description=AWS SSO CLI credential server
+Documentation={{ .DocUrl }}
+
+[Service]
+Type=simple
+{{- if .User }}
+User={{ .User }}
+{{- end }}
+{{- range $k, $v := .Env }}
+Environment={{ quote (printf "%s=%s" $k $v) }}
+{{- end }}
+ExecStart={{ range $i, $arg := .Args }}{{ if $i }} {{ end }}{{ quote $arg }}{{ end }}
+Restart=on-failure
+
+[Install]
+WantedBy={{ if .UserScope }}default.target{{ else }}multi-user.target{{ end }}
+`
+
+const LAUNCHD_TEMPLATE = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{ .Label }}</string>
+{{- if .User }}
+	<key>UserName</key>
+	<string>{{ xml .User }}</string>
+{{- end }}
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args }}
+		<string>{{ xml . }}</string>
+{{- end }}
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+{{- range $k, $v := .Env }}
+		<key>{{ xml $k }}</key>
+		<string>{{ xml $v }}</string>
+{{- end }}
+	</dict>
+	<key>WorkingDirectory</key>
+	<string>{{ xml .WorkingDir }}</string>
+	<key>StandardErrorPath</key>
+	<string>{{ xml .LogFile }}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+</dict>
+</plist>
+`
+
+var serviceTemplateFuncs = template.FuncMap{
+	"quote": systemdQuote,
+	"xml":   xmlEscape,
+}
+
+func (cc *ServerInstallCmd) Run(ctx *RunContext) error {
+	config, err := newServiceConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tmpl, path string
+	switch ctx.Cli.Server.Install.Type {
+	case "systemd":
+		tmpl = SYSTEMD_TEMPLATE
+		path = fmt.Sprintf("/etc/systemd/system/%s.service", SERVICE_NAME)
+		if config.UserScope {
+			path = utils.GetHomePath(fmt.Sprintf("~/.config/systemd/user/%s.service", SERVICE_NAME))
+		}
+	case "launchd":
+		tmpl = LAUNCHD_TEMPLATE
+		path = fmt.Sprintf("/Library/LaunchDaemons/%s.plist", LAUNCHD_LABEL)
+		if config.UserScope {
+			path = utils.GetHomePath(fmt.Sprintf("~/Library/LaunchAgents/%s.plist", LAUNCHD_LABEL))
+		}
+	}
+
+	t, err := template.New("service").Funcs(serviceTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	buf := bytes.Buffer{}
+	if err = t.Execute(&buf, config); err != nil {
+		return fmt.Errorf("Unable to generate %s service: %s", ctx.Cli.Server.Install.Type, err.Error())
+	}
+
+	out := ctx.Cli.Server.Install.Output
+	if out == "" {
+		fmt.Print(buf.String())
+		fmt.Fprintf(os.Stderr, "# Save the above to %s\n", path)
+		return nil
+	}
+
+	out = utils.GetHomePath(out)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !ctx.Cli.Server.Install.Force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(out, flags, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("Refusing to overwrite %s without --force", out)
+	} else if err != nil {
+		return fmt.Errorf("Unable to write %s: %s", out, err.Error())
+	}
+	defer f.Close()
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", out, err.Error())
+	}
+	fmt.Printf("%s\n", out)
+	return nil
+}
+
+// newServiceConfig builds the serviceConfig for running our current binary
+// with the current config file
+func newServiceConfig(ctx *RunContext) (*serviceConfig, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to determine path to aws-sso: %s", err.Error())
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return nil, fmt.Errorf("Unable to determine path to aws-sso: %s", err.Error())
+	}
+
+	// services do not expand ~, so every path must be absolute
+	configFile, err := filepath.Abs(utils.GetHomePath(ctx.Cli.ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	socket, err := filepath.Abs(utils.GetHomePath(ctx.Cli.Server.Install.Socket))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &serviceConfig{
+		Label:  LAUNCHD_LABEL,
+		DocUrl: SERVICE_DOCURL,
+		Args:   []string{exe, "server", "daemon", "--socket", socket},
+		Env: map[string]string{
+			"AWS_SSO_CONFIG": configFile,
+		},
+		UserScope:  ctx.Cli.Server.Install.User,
+		WorkingDir: utils.GetHomePath("~"),
+		LogFile:    utils.GetHomePath(CONFIG_DIR + "/server.log"),
+	}
+
+	// system services must still run as the user who owns the config & SecureStore
+	if !config.UserScope {
+		u, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to determine current user: %s", err.Error())
+		}
+		config.User = u.Username
+	}
+	return config, nil
+}
+
+// systemdQuote quotes a value for ExecStart= or Environment= if necessary
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// xmlEscape escapes a value for use in a plist
+func xmlEscape(s string) string {
+	buf := bytes.Buffer{}
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
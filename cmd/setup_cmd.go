@@ -20,6 +20,7 @@ package main
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -29,25 +30,7 @@ import (
 	"github.com/synfinatic/aws-sso-cli/sso"
 )
 
-// https://docs.aws.amazon.com/general/latest/gr/sso.html
-var AvailableAwsSSORegions []string = []string{
-	"us-east-1",
-	"us-east-2",
-	"us-west-2",
-	"ap-south-1",
-	"ap-northeast-2",
-	"ap-southeast-1",
-	"ap-southeast-2",
-	"ap-northeast-1",
-	"ca-central-1",
-	"eu-central-1",
-	"eu-west-1",
-	"eu-west-2",
-	"eu-west-3",
-	"eu-north-1",
-	"sa-east-1",
-	"us-gov-west-1",
-}
+var AvailableAwsSSORegions []string = sso.SSORegions
 
 const (
 	START_URL_FORMAT  = "https://%s.awsapps.com/start"
@@ -59,11 +42,11 @@ type SetupCmd struct {
 	DefaultRegion    string `kong:"help='Default AWS region for running commands (or \"None\")'"`
 	UrlAction        string `kong:"name='default-url-action',help='How to handle URLs [open|print|clip]'"`
 	SSOStartHostname string `kong:"help='AWS SSO User Portal Hostname'"`
-	SSORegion        string `kong:"help='AWS SSO Instance Region'"`
 	HistoryLimit     int64  `kong:"help='Number of items to keep in History',default=-1"`
 	HistoryMinutes   int64  `kong:"help='Number of minutes to keep items in History',default=-1"`
 	DefaultLevel     string `kong:"help='Logging level [error|warn|info|debug|trace]'"`
 	Force            bool   `kong:"help='Force override of existing config file'"`
+	FromUrl          string `kong:"help='Create a minimal config using the AWS SSO start URL'"`
 }
 
 // Run executes the setup command
@@ -72,6 +55,10 @@ func (cc *SetupCmd) Run(ctx *RunContext) error {
 }
 
 func setupWizard(ctx *RunContext) error {
+	if ctx.Cli.Setup.FromUrl != "" {
+		return setupFromUrl(ctx)
+	}
+
 	var err error
 	var instanceName, startHostname, ssoRegion, awsRegion, urlAction string
 	var historyLimit, historyMinutes, logLevel string
@@ -123,19 +110,23 @@ func setupWizard(ctx *RunContext) error {
 		}
 	}
 
-	// Pick our AWS SSO region
+	// Pick our AWS SSO region unless given via --sso-region
+	ssoRegion = ctx.Cli.SSORegion
 	label := "AWS SSO Region (SSORegion)"
-	sel := promptui.Select{
-		Label:        label,
-		Items:        AvailableAwsSSORegions,
-		HideSelected: false,
-		Stdout:       &bellSkipper{},
-		Templates: &promptui.SelectTemplates{
-			Selected: fmt.Sprintf(`%s: {{ . | faint }}`, label),
-		},
-	}
-	if _, ssoRegion, err = sel.Run(); err != nil {
-		return err
+	var sel promptui.Select
+	if ssoRegion == "" {
+		sel = promptui.Select{
+			Label:        label,
+			Items:        AvailableAwsSSORegions,
+			HideSelected: false,
+			Stdout:       &bellSkipper{},
+			Templates: &promptui.SelectTemplates{
+				Selected: fmt.Sprintf(`%s: {{ . | faint }}`, label),
+			},
+		}
+		if _, ssoRegion, err = sel.Run(); err != nil {
+			return err
+		}
 	}
 
 	// Pick the default AWS region to use
@@ -268,6 +259,62 @@ func setupWizard(ctx *RunContext) error {
 	return s.Save(ctx.Cli.ConfigFile, false)
 }
 
+// setupFromUrl writes a minimal config file after discovering the AWS SSO
+// region from the user provided start URL
+func setupFromUrl(ctx *RunContext) error {
+	startUrl, err := sso.ValidateStartUrl(ctx.Cli.Setup.FromUrl)
+	if err != nil {
+		return err
+	}
+
+	ssoRegion := ctx.Cli.SSORegion
+	if ssoRegion == "" {
+		log.Infof("Discovering AWS SSO region for %s", startUrl)
+		client, err := setupHTTPClient(ctx)
+		if err != nil {
+			return err
+		}
+		if ssoRegion, err = sso.DiscoverSSORegion(ctx.Context, client, startUrl, AvailableAwsSSORegions); err != nil {
+			return err
+		}
+		log.Infof("Found AWS SSO in %s", ssoRegion)
+	}
+
+	instanceName := ctx.Cli.SSO
+	if instanceName == "" {
+		instanceName = "Default"
+	}
+	if err = validateSSOName(instanceName); err != nil {
+		return err
+	}
+
+	s := sso.Settings{
+		DefaultSSO: instanceName,
+		SSO:        map[string]*sso.SSOConfig{},
+	}
+	s.SSO[instanceName] = &sso.SSOConfig{
+		SSORegion: ssoRegion,
+		StartUrl:  startUrl,
+	}
+	return s.Save(ctx.Cli.ConfigFile, ctx.Cli.Setup.Force)
+}
+
+// setupHTTPClient returns the http.Client of our config or, since we usually
+// don't have one yet, a client using the --proxy and --ca-bundle flags
+func setupHTTPClient(ctx *RunContext) (*http.Client, error) {
+	if ctx.Settings != nil {
+		return ctx.Settings.HTTPClient(), nil
+	}
+	if ctx.Cli.Offline {
+		return sso.NewOfflineHTTPClient(), nil
+	}
+	tlsConfig, err := sso.NewTLSConfig("", []string{}, ctx.Cli.CABundle)
+	if err != nil {
+		return nil, err
+	}
+	return sso.NewHTTPClient(ctx.Cli.Proxy, tlsConfig)
+}
+
 var ssoHostnameRegexp *regexp.Regexp
 
 // validateSSOHostname verifies our SSO Start url is in the format of http://xxxxx.awsapps.com/start
@@ -0,0 +1,103 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+const (
+	TOKEN_VALID    = "valid"
+	TOKEN_EXPIRING = "expiring"
+	TOKEN_EXPIRED  = "expired"
+	TOKEN_MISSING  = "missing"
+)
+
+type StatusCmd struct {
+	ExpiringWithin time.Duration `kong:"help='Report the token as expiring when less than this time remains',default='15m'"`
+}
+
+// TokenStatus is the state of the AWS SSO token.  The JSON keys are stable
+// so they can be consumed by monitoring tools.
+type TokenStatus struct {
+	SSO              string `json:"sso"`
+	StartUrl         string `json:"start_url"`
+	SSORegion        string `json:"sso_region"`
+	Status           string `json:"status"`
+	ExpiresAt        int64  `json:"expires_at"`        // Unix epoch seconds, 0 if missing
+	Expires          string `json:"expires"`           // RFC3339, empty if missing
+	RemainingSeconds int64  `json:"remaining_seconds"` // negative once expired
+}
+
+// Run prints the state of our cached AWS SSO token as JSON.  Never logs in
+// and returns an error if the token is missing or expired.
+func (cc *StatusCmd) Run(ctx *RunContext) error {
+	ssoName, err := ctx.Settings.GetSelectedSSOName(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+	s, err := ctx.Settings.GetSelectedSSO(ctx.Cli.SSO)
+	if err != nil {
+		return err
+	}
+
+	status := TokenStatus{
+		SSO:       ssoName,
+		StartUrl:  s.StartUrl,
+		SSORegion: s.SSORegion,
+		Status:    TOKEN_MISSING,
+	}
+
+	token := storage.CreateTokenResponse{}
+	key := sso.NewAWSSSO(s, &ctx.Store).StoreKey()
+	if err := ctx.Store.GetCreateTokenResponse(key, &token); err == nil && token.AccessToken != "" {
+		remaining := utils.Remaining(token.ExpiresAt)
+		status.ExpiresAt = token.ExpiresAt
+		status.Expires = time.Unix(token.ExpiresAt, 0).Format(time.RFC3339)
+		status.RemainingSeconds = int64(remaining / time.Second)
+
+		switch {
+		case remaining <= 0:
+			status.Status = TOKEN_EXPIRED
+		case remaining < ctx.Cli.Status.ExpiringWithin:
+			status.Status = TOKEN_EXPIRING
+		default:
+			status.Status = TOKEN_VALID
+		}
+	}
+
+	b, err := marshalJSON(ctx, status)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", string(b))
+
+	switch status.Status {
+	case TOKEN_MISSING:
+		return fmt.Errorf("No AWS SSO token for %s", ssoName)
+	case TOKEN_EXPIRED:
+		return fmt.Errorf("AWS SSO token for %s has expired", ssoName)
+	}
+	return nil
+}
@@ -24,20 +24,97 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/gotable"
 )
 
 type TagsCmd struct {
 	AccountId   int64  `kong:"name='account',short='A',help='Filter results based on AWS AccountID'"`
 	Role        string `kong:"short='R',help='Filter results based on AWS Role Name'"`
 	ForceUpdate bool   `kong:"help='Force account/role cache update'"`
+
+	Roles  TagsRolesCmd  `kong:"cmd,hidden,default='1',help='List tags for each role'"`
+	Keys   TagsKeysCmd   `kong:"cmd,help='List all tag keys and the number of roles with each key'"`
+	Values TagsValuesCmd `kong:"cmd,help='List all values of a tag key and the number of roles with each value'"`
 }
 
-func (cc *TagsCmd) Run(ctx *RunContext) error {
-	set := ctx.Settings
+type TagsRolesCmd struct{}
+
+type TagsKeysCmd struct {
+	Output string `kong:"short='o',enum='table,json',default='table',help='Output format [table|json]'"`
+	Sort   string `kong:"enum='count,name',default='count',help='Sort results by [count|name]'"`
+}
+
+type TagsValuesCmd struct {
+	Key    string `kong:"arg,required,help='Tag key to list values for'"`
+	Output string `kong:"short='o',enum='table,json',default='table',help='Output format [table|json]'"`
+	Sort   string `kong:"enum='count,name',default='count',help='Sort results by [count|name]'"`
+}
+
+func (cc *TagsKeysCmd) Run(ctx *RunContext) error {
+	if err := updateTagsCache(ctx); err != nil {
+		return err
+	}
+	cache := ctx.Settings.Cache.GetSSO()
+	counts := []sso.TagCount{}
+	for _, c := range cache.Roles.GetTagKeyCounts() {
+		if !ctx.Settings.TagHidden(c.Name) {
+			counts = append(counts, c)
+		}
+	}
+	return printTagCounts(ctx, counts, ctx.Cli.Tags.Keys.Output, ctx.Cli.Tags.Keys.Sort)
+}
+
+func (cc *TagsValuesCmd) Run(ctx *RunContext) error {
+	if err := updateTagsCache(ctx); err != nil {
+		return err
+	}
+	if ctx.Settings.TagHidden(ctx.Cli.Tags.Values.Key) {
+		return fmt.Errorf("The tag key %s is hidden by HiddenTags.  Use --show-hidden-tags to list it",
+			ctx.Cli.Tags.Values.Key)
+	}
 	cache := ctx.Settings.Cache.GetSSO()
+	counts := cache.Roles.GetTagValueCounts(ctx.Cli.Tags.Values.Key)
+	if len(counts) == 0 {
+		return fmt.Errorf("No roles have the tag key: %s", ctx.Cli.Tags.Values.Key)
+	}
+	return printTagCounts(ctx, counts, ctx.Cli.Tags.Values.Output, ctx.Cli.Tags.Values.Sort)
+}
+
+// printTagCounts prints the tag key or value counts as a table or json
+func printTagCounts(ctx *RunContext, counts []sso.TagCount, output, sortBy string) error {
+	if sortBy == "name" {
+		sort.SliceStable(counts, func(i, j int) bool {
+			return counts[i].Name < counts[j].Name
+		})
+	}
+
+	switch output {
+	case "json":
+		b, err := marshalJSON(ctx, counts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", string(b))
+	default:
+		ts := []gotable.TableStruct{}
+		for _, c := range counts {
+			ts = append(ts, c)
+		}
+		if err := gotable.GenerateTable(ts, []string{"Name", "Count"}); err != nil {
+			return fmt.Errorf("Unable to generate report: %s", err.Error())
+		}
+		fmt.Printf("\n")
+	}
+	return nil
+}
+
+// updateTagsCache refreshes the role cache if requested or expired
+func updateTagsCache(ctx *RunContext) error {
+	set := ctx.Settings
 	if ctx.Cli.Tags.ForceUpdate {
 		s := set.SSO[ctx.Cli.SSO]
 		awssso := sso.NewAWSSSO(s, &ctx.Store)
+		awssso.SetContext(ctx.Context)
 		err := awssso.Authenticate(ctx.Settings.UrlAction, ctx.Settings.Browser)
 		if err != nil {
 			log.WithError(err).Fatalf("Unable to authenticate")
@@ -62,7 +139,7 @@ func (cc *TagsCmd) Run(ctx *RunContext) error {
 			return err
 		}
 
-		if err := set.Cache.Expired(s); err != nil {
+		if err := set.Cache.Expired(s); err != nil && !set.Offline() {
 			log.Warn(err.Error())
 			c := &CacheCmd{}
 			if err = c.Run(ctx); err != nil {
@@ -70,6 +147,14 @@ func (cc *TagsCmd) Run(ctx *RunContext) error {
 			}
 		}
 	}
+	return nil
+}
+
+func (cc *TagsRolesCmd) Run(ctx *RunContext) error {
+	if err := updateTagsCache(ctx); err != nil {
+		return err
+	}
+	cache := ctx.Settings.Cache.GetSSO()
 	roles := []*sso.AWSRoleFlat{}
 
 	// If user has specified an account (or account + role) then limit
@@ -95,13 +180,14 @@ func (cc *TagsCmd) Run(ctx *RunContext) error {
 
 	for _, fRole := range roles {
 		fmt.Printf("%s\n", fRole.Arn)
-		keys := make([]string, 0, len(fRole.Tags))
-		for k := range fRole.Tags {
+		tags := ctx.Settings.VisibleTags(fRole.Tags)
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
 			keys = append(keys, k)
 		}
 		sort.Strings(keys)
 		for _, k := range keys {
-			fmt.Printf("  %s: %s\n", k, fRole.Tags[k])
+			fmt.Printf("  %s: %s\n", k, tags[k])
 		}
 		fmt.Printf("\n")
 	}
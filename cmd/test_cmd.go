@@ -0,0 +1,150 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type TestCmd struct {
+	Arn        string `kong:"short='a',help='ARN of role to test',xor='arn-1',xor='arn-2',predictor='arn'"`
+	AccountId  int64  `kong:"name='account',short='A',help='AWS AccountID of role to test',xor='arn-1',predictor='accountId'"`
+	Role       string `kong:"short='R',help='Name of AWS Role to test',xor='arn-2',predictor='role'"`
+	Profile    string `kong:"short='p',help='Name of AWS Profile to test',xor='arn-1',predictor='profile'"`
+	NoValidate bool   `kong:"help='Do not verify you have access to the --account or --arn before using it'"`
+	Duration   string `kong:"short='d',help='Session duration to request in minutes or as a duration like 8h (default: the role session duration)'"`
+	Keep       bool   `kong:"help='Cache the credentials in the secure store if the test succeeds'"`
+	Output     string `kong:"short='o',enum='human,json',default='human',help='Output format [human|json]'"`
+}
+
+// testResult is the outcome of testing a role
+type testResult struct {
+	Arn      string              `json:"Arn"`
+	Success  bool                `json:"Success"`
+	Identity *sso.CallerIdentity `json:"Identity,omitempty"`
+	Expires  string              `json:"Expires,omitempty"`
+	Step     string              `json:"Step,omitempty"`
+	Reason   string              `json:"Reason,omitempty"`
+	Error    string              `json:"Error,omitempty"`
+}
+
+// Run fetches brand new STS credentials for the role and calls
+// sts:GetCallerIdentity with them.  Returns an error if either step fails.
+func (cc *TestCmd) Run(ctx *RunContext) error {
+	var err error
+
+	if err := validateSessionDuration(ctx.Cli.Test.Duration); err != nil {
+		return err
+	}
+
+	role := ctx.Cli.Test.Role
+	account := ctx.Cli.Test.AccountId
+
+	if ctx.Cli.Test.Profile != "" {
+		cache := ctx.Settings.Cache.GetSSO()
+		rFlat, err := cache.Roles.GetRoleByProfile(ctx.Cli.Test.Profile, ctx.Settings)
+		if err != nil {
+			return err
+		}
+
+		role = rFlat.RoleName
+		account = rFlat.AccountId
+	} else if ctx.Cli.Test.Arn != "" {
+		account, role, err = utils.ParseRoleARN(ctx.Cli.Test.Arn)
+		if err != nil {
+			return err
+		}
+		if err := checkRoleAccess(ctx, account, role, ctx.Cli.Test.NoValidate); err != nil {
+			return err
+		}
+	}
+
+	if role == "" || account == 0 {
+		return fmt.Errorf("Please specify --profile, --arn, or --account and --role")
+	}
+
+	awssso := doAuth(ctx)
+	result := testRole(ctx, awssso, account, role)
+
+	if ctx.Cli.Test.Output == "json" {
+		out, err := marshalJSON(ctx, result)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else if result.Success {
+		fmt.Printf("Successfully assumed %s\n", result.Arn)
+		fmt.Printf("  Account: %s\n", result.Identity.Account)
+		fmt.Printf("  Arn:     %s\n", result.Identity.Arn)
+		fmt.Printf("  UserId:  %s\n", result.Identity.UserId)
+		fmt.Printf("  Expires: %s\n", result.Expires)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("Unable to %s for %s: %s: %s", result.Step, result.Arn, result.Reason, result.Error)
+	}
+	return nil
+}
+
+// testRole assumes the role and verifies the credentials work, bypassing the
+// secure store so we know the role can be assumed right now
+func testRole(ctx *RunContext, awssso *sso.AWSSSO, account int64, role string) testResult {
+	arn := utils.MakeRoleARN(account, role)
+	result := testResult{
+		Arn: arn,
+	}
+
+	policy := sso.SessionPolicy{}
+	duration := getSessionDuration(ctx)
+
+	creds, err := awssso.GetRoleCredentialsWithPolicy(account, role, policy, duration)
+	if err != nil {
+		result.Step = "assume role"
+		result.Reason = sso.RoleErrorReason(err)
+		if sso.IsDurationTooLargeError(err) {
+			err = sso.DurationTooLargeError(err, duration, sso.MaxDurationFromError(err))
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	identity, err := sso.GetCallerIdentity(ctx.Context, &creds, awssso.SsoRegion, ctx.Settings.HTTPClient())
+	if err != nil {
+		result.Step = "get caller identity"
+		result.Reason = sso.RoleErrorReason(err)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Success = true
+	result.Identity = &identity
+	result.Expires = time.UnixMilli(creds.Expiration).Format(time.RFC3339)
+
+	if ctx.Cli.Test.Keep {
+		key := roleCredentialsKey(ctx, account, role)
+		key.Duration = duration
+		key.Policy = policy.Hash()
+		saveRoleCredentials(ctx, key, &creds)
+	}
+	return result
+}
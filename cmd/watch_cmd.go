@@ -0,0 +1,95 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+type WatchCmd struct {
+	Action   string `kong:"short='a',help='How to notify [print|notify-send|osascript|webhook]'"`
+	Interval int64  `kong:"short='i',default=60,help='Number of seconds between checks'"`
+	Minutes  int64  `kong:"short='m',help='Notify when credentials expire within this many minutes (default 10)'"`
+}
+
+func (cc *WatchCmd) Run(ctx *RunContext) error {
+	action := ctx.Settings.NotifyAction
+	if ctx.Cli.Watch.Action != "" {
+		action = ctx.Cli.Watch.Action
+	}
+	if err := notifyActionValidate(action); err != nil {
+		return err
+	}
+
+	minutes := ctx.Settings.NotifyMinutes
+	if ctx.Cli.Watch.Minutes > 0 {
+		minutes = ctx.Cli.Watch.Minutes
+	}
+
+	if ctx.Cli.Watch.Interval <= 0 {
+		return fmt.Errorf("--interval must be greater than zero")
+	}
+
+	threshold := time.Duration(minutes) * time.Minute
+	interval := time.Duration(ctx.Cli.Watch.Interval) * time.Second
+	notified := map[string]int64{} // ARN => Expires we have already notified for
+
+	log.Infof("Watching for STS credentials which expire within %d minutes", minutes)
+	for {
+		checkExpiry(ctx, action, threshold, notified)
+		time.Sleep(interval)
+	}
+}
+
+// checkExpiry re-reads the cache file and sends a notification for every role
+// which is about to expire, but only once per set of credentials
+func checkExpiry(ctx *RunContext, action string, threshold time.Duration, notified map[string]int64) {
+	cache, err := sso.OpenCache(ctx.Settings.Cache.CacheFile(), ctx.Settings)
+	if err != nil {
+		log.WithError(err).Errorf("Unable to open cache")
+		return
+	}
+
+	for _, role := range cache.GetSSO().Roles.GetAllRoles() {
+		if role.IsExpired() || time.Until(time.Unix(role.Expires, 0)) > threshold {
+			continue
+		}
+
+		if notified[role.Arn] == role.Expires {
+			continue
+		}
+
+		remain, err := utils.TimeRemain(role.Expires, false)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to determine time remaining for %s", role.Arn)
+			continue
+		}
+
+		msg := fmt.Sprintf("STS credentials for %s expire in %s", role.Arn, remain)
+		if err = utils.Notify(action, ctx.Settings.NotifyWebhook, "AWS SSO CLI", msg); err != nil {
+			log.WithError(err).Errorf("Unable to send notification")
+		}
+		notified[role.Arn] = role.Expires
+	}
+}
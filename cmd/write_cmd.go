@@ -0,0 +1,177 @@
+package main
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/sso"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+const AWS_CREDENTIALS_FILE = "~/.aws/credentials"
+
+type WriteCmd struct {
+	Arn         []string      `kong:"short='a',help='ARN of role to write (repeatable)',predictor='arn'"`
+	All         bool          `kong:"help='Write every role, or every role matching --filter'"`
+	Filter      []string      `kong:"help='With --all, only write roles with the tag Key=Value (repeatable)'"`
+	File        string        `kong:"short='f',help='Credentials file to update (default: $AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials)'"`
+	Concurrency int           `kong:"help='Maximum number of roles to fetch in parallel (default: MaxConcurrency)'"`
+	Timeout     time.Duration `kong:"default='30s',help='Give up on a role which takes longer than this to fetch (0 = never)'"`
+}
+
+// Run writes the STS credentials for the selected roles into the AWS shared
+// credentials file, one section per role named after its profile
+func (cc *WriteCmd) Run(ctx *RunContext) error {
+	roles, err := writeRoles(ctx)
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("No roles selected")
+	}
+
+	if ctx.Cli.Write.Concurrency < 0 {
+		return fmt.Errorf("--concurrency must not be negative")
+	}
+	if ctx.Cli.Write.Timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+	concurrency := ctx.Cli.Write.Concurrency
+	if concurrency == 0 {
+		concurrency = ctx.Settings.MaxConcurrency
+	}
+
+	file := awsCredentialsFile()
+	if ctx.Cli.Write.File != "" {
+		file = utils.GetHomePath(ctx.Cli.Write.File)
+	}
+
+	log.Warnf("Writing long lived plaintext AWS secrets for %d roles to %s.  "+
+		"Anyone who can read this file can use these roles until the credentials expire!", len(roles), file)
+
+	awssso := doAuth(ctx)
+	profiles := []utils.CredentialsProfile{}
+	failed := 0
+	for _, result := range fetchRoleCredentials(ctx, awssso, roles, concurrency, ctx.Cli.Write.Timeout) {
+		if result.Err != nil {
+			log.WithError(result.Err).Errorf("Unable to fetch credentials for %s", result.Role.Arn)
+			failed++
+			continue
+		}
+		profile, err := result.Role.ProfileName(ctx.Settings)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to generate profile name for %s", result.Role.Arn)
+			failed++
+			continue
+		}
+		profiles = append(profiles, utils.CredentialsProfile{
+			Name:            profile,
+			AccessKeyId:     result.Creds.AccessKeyId,
+			SecretAccessKey: result.Creds.SecretAccessKey,
+			SessionToken:    result.Creds.SessionToken,
+			Expires:         time.Unix(result.Creds.ExpireEpoch(), 0),
+		})
+	}
+
+	if err = ctx.Settings.Cache.Save(false); err != nil {
+		log.WithError(err).Warnf("Unable to save cache")
+	}
+
+	if len(profiles) > 0 {
+		existing, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Unable to read %s: %s", file, err.Error())
+		}
+		data := utils.UpdateCredentialsFile(string(existing), profiles)
+		if err = writeSecretFile(file, []byte(data)); err != nil {
+			return err
+		}
+		for _, p := range profiles {
+			fmt.Printf("Wrote [%s] which expires at %s\n", p.Name, p.Expires.Format(time.RFC3339))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Unable to write %d of %d roles", failed, len(roles))
+	}
+	return nil
+}
+
+// writeRoles returns the roles selected by --arn and --all/--filter
+func writeRoles(ctx *RunContext) ([]*sso.AWSRoleFlat, error) {
+	cache := ctx.Settings.Cache.GetSSO()
+	selected := map[string]*sso.AWSRoleFlat{}
+
+	if len(ctx.Cli.Write.Filter) > 0 && !ctx.Cli.Write.All {
+		return nil, fmt.Errorf("--filter requires --all")
+	}
+	if len(ctx.Cli.Write.Arn) == 0 && !ctx.Cli.Write.All {
+		return nil, fmt.Errorf("Please specify --arn or --all")
+	}
+
+	for _, arn := range ctx.Cli.Write.Arn {
+		accountId, roleName, err := utils.ParseRoleARN(arn)
+		if err != nil {
+			return nil, err
+		}
+		role, err := cache.Roles.GetRole(accountId, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("Unknown role %s: %s", arn, err.Error())
+		}
+		selected[role.Arn] = role
+	}
+
+	if ctx.Cli.Write.All {
+		matches := cache.Roles.GetAllRoles()
+		if len(ctx.Cli.Write.Filter) > 0 {
+			tags, err := parseTagFilters(ctx.Cli.Write.Filter)
+			if err != nil {
+				return nil, err
+			}
+			matches = cache.Roles.MatchingRoles(tags)
+		}
+		for _, role := range matches {
+			if role.Disabled && !ctx.Settings.ShowDisabled() {
+				continue
+			}
+			selected[role.Arn] = role
+		}
+	}
+
+	roles := []*sso.AWSRoleFlat{}
+	for _, role := range selected {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Arn < roles[j].Arn })
+	return roles, nil
+}
+
+// awsCredentialsFile returns the path to the users ~/.aws/credentials
+func awsCredentialsFile() string {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		path = utils.GetHomePath(AWS_CREDENTIALS_FILE)
+	}
+	return path
+}
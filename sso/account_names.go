@@ -0,0 +1,81 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	goyaml "github.com/goccy/go-yaml"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// loadAccountNames merges the AccountNamesFile (if any) with the inline
+// AccountNames, which take precedence, and validates every AccountId
+func (s *Settings) loadAccountNames() error {
+	names := map[string]string{}
+	if s.AccountNamesFile != "" {
+		data, err := ioutil.ReadFile(utils.GetHomePath(s.AccountNamesFile))
+		if err != nil {
+			return fmt.Errorf("Unable to read AccountNamesFile: %s", err.Error())
+		}
+		// AccountIds are often not quoted, so they are parsed as integers
+		fileNames := map[interface{}]string{}
+		if err = goyaml.Unmarshal(data, &fileNames); err != nil {
+			return fmt.Errorf("Unable to parse AccountNamesFile %s: %s", s.AccountNamesFile, err.Error())
+		}
+		for id, name := range fileNames {
+			names[fmt.Sprintf("%v", id)] = name
+		}
+	}
+	for id, name := range s.AccountNames {
+		names[id] = name
+	}
+
+	s.accountNames = map[int64]string{}
+	for id, name := range names {
+		accountId, err := utils.AccountIdToInt64(id)
+		if err != nil {
+			return fmt.Errorf("Invalid AccountId %s in AccountNames: %s", id, err.Error())
+		}
+		s.accountNames[accountId] = name
+	}
+	return nil
+}
+
+// AccountName returns the friendly name for the account from AccountNames
+// or AccountNamesFile, if any
+func (s *Settings) AccountName(accountId int64) (string, bool) {
+	name, ok := s.accountNames[accountId]
+	return name, ok
+}
+
+// accountNamesModified returns the Unix epoch seconds the AccountNamesFile was
+// last modified or 0 if there is no file
+func (s *Settings) accountNamesModified() int64 {
+	if s.AccountNamesFile == "" {
+		return 0
+	}
+	info, err := os.Stat(utils.GetHomePath(s.AccountNamesFile))
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().Unix()
+}
@@ -0,0 +1,55 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAccountNames(t *testing.T) {
+	s := &Settings{
+		AccountNamesFile: "./testdata/account_names.yaml",
+		AccountNames: map[string]string{
+			"000012345678": "Inline",
+			"111111111111": "Other",
+		},
+	}
+	assert.NoError(t, s.loadAccountNames())
+
+	name, ok := s.AccountName(258234615182)
+	assert.True(t, ok)
+	assert.Equal(t, "Playground", name)
+
+	// inline names take precedence over the file
+	name, ok = s.AccountName(12345678)
+	assert.True(t, ok)
+	assert.Equal(t, "Inline", name)
+
+	_, ok = s.AccountName(222222222222)
+	assert.False(t, ok)
+
+	s.AccountNames = map[string]string{"not-an-account": "Bad"}
+	assert.Error(t, s.loadAccountNames())
+
+	s.AccountNames = map[string]string{}
+	s.AccountNamesFile = "./testdata/does-not-exist.yaml"
+	assert.Error(t, s.loadAccountNames())
+}
@@ -0,0 +1,114 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	goyaml "github.com/goccy/go-yaml"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// ResolveAlias returns the AccountId and RoleName for the given role alias
+func (s *Settings) ResolveAlias(alias string) (int64, string, error) {
+	target, ok := s.Aliases[alias]
+	if !ok {
+		return 0, "", fmt.Errorf("Unknown alias: %s", alias)
+	}
+	return ParseRoleTarget(target)
+}
+
+// AliasNames returns the sorted list of configured role aliases
+func (s *Settings) AliasNames() []string {
+	names := []string{}
+	for alias := range s.Aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseRoleTarget parses either a role ARN or an <AccountId>/<RoleName>
+func ParseRoleTarget(target string) (int64, string, error) {
+	if strings.HasPrefix(target, "arn:") {
+		return utils.ParseRoleARN(target)
+	}
+
+	s := strings.SplitN(target, "/", 2)
+	if len(s) != 2 || s[1] == "" {
+		return 0, "", fmt.Errorf("Invalid alias target %s: must be a role ARN or <AccountId>/<RoleName>", target)
+	}
+	accountId, err := utils.AccountIdToInt64(s[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid alias target %s: %s", target, err.Error())
+	}
+	return accountId, s[1], nil
+}
+
+// validateAliases ensures every alias is unique and points at a valid role.
+// Aliases are compared case insensitive to avoid confusion.
+func (s *Settings) validateAliases(configFile string) error {
+	names := map[string]string{}
+	for _, alias := range s.configAliasNames(configFile) {
+		lower := strings.ToLower(alias)
+		if dup, ok := names[lower]; ok {
+			return fmt.Errorf("Duplicate alias: %s and %s", dup, alias)
+		}
+		names[lower] = alias
+	}
+
+	for alias, target := range s.Aliases {
+		if _, _, err := ParseRoleTarget(target); err != nil {
+			return fmt.Errorf("Invalid alias %s: %s", alias, err.Error())
+		}
+	}
+	return nil
+}
+
+// configAliasNames returns all of the alias names in the config file,
+// including duplicates which are silently dropped when parsing into a map
+func (s *Settings) configAliasNames(configFile string) []string {
+	config := struct {
+		Aliases goyaml.MapSlice `yaml:"Aliases"`
+	}{}
+
+	names := []string{}
+	var data []byte
+	var err error
+	if configFile == STDIN_CONFIG {
+		data = s.stdinConfig
+	} else {
+		data, err = ioutil.ReadFile(configFile)
+	}
+	if err == nil {
+		err = goyaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		// fall back to the names we already know about
+		return s.AliasNames()
+	}
+
+	for _, item := range config.Aliases {
+		names = append(names, fmt.Sprintf("%v", item.Key))
+	}
+	return names
+}
@@ -0,0 +1,104 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveAlias(t *testing.T) {
+	s := &Settings{
+		Aliases: map[string]string{
+			"prod-admin": "arn:aws:iam::123456789012:role/AdministratorAccess",
+			"dev-ro":     "000012345678/ReadOnly",
+		},
+	}
+
+	aId, role, err := s.ResolveAlias("prod-admin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123456789012), aId)
+	assert.Equal(t, "AdministratorAccess", role)
+
+	aId, role, err = s.ResolveAlias("dev-ro")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12345678), aId)
+	assert.Equal(t, "ReadOnly", role)
+
+	_, _, err = s.ResolveAlias("missing")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown alias")
+
+	assert.Equal(t, []string{"dev-ro", "prod-admin"}, s.AliasNames())
+}
+
+func TestParseAliasTarget(t *testing.T) {
+	_, _, err := ParseRoleTarget("123456789012")
+	assert.Error(t, err)
+
+	_, _, err = ParseRoleTarget("123456789012/")
+	assert.Error(t, err)
+
+	_, _, err = ParseRoleTarget("foobar/Role")
+	assert.Error(t, err)
+
+	_, _, err = ParseRoleTarget("arn:aws:iam::foo:role/Role")
+	assert.Error(t, err)
+}
+
+func TestValidateAliases(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*config.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(tfile.Name())
+
+	s := &Settings{
+		Aliases: map[string]string{
+			"prod-admin": "arn:aws:iam::123456789012:role/AdministratorAccess",
+		},
+	}
+	assert.NoError(t, s.validateAliases(tfile.Name()))
+
+	s.Aliases["bad"] = "not-a-role"
+	err = s.validateAliases(tfile.Name())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid alias bad")
+	delete(s.Aliases, "bad")
+
+	// duplicates are lost when parsed into a map, so check the config file
+	err = ioutil.WriteFile(tfile.Name(), []byte(`Aliases:
+  prod-admin: arn:aws:iam::123456789012:role/AdministratorAccess
+  prod-admin: arn:aws:iam::123456789012:role/ReadOnly
+`), 0600)
+	assert.NoError(t, err)
+	err = s.validateAliases(tfile.Name())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Duplicate alias")
+
+	err = ioutil.WriteFile(tfile.Name(), []byte(`Aliases:
+  prod-admin: arn:aws:iam::123456789012:role/AdministratorAccess
+  Prod-Admin: arn:aws:iam::123456789012:role/ReadOnly
+`), 0600)
+	assert.NoError(t, err)
+	err = s.validateAliases(tfile.Name())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Duplicate alias: prod-admin and Prod-Admin")
+}
@@ -0,0 +1,80 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// session name used when assuming a role with the ambient AWS credentials
+const FROM_ENV_SESSION_NAME = "aws-sso-cli"
+
+// AssumeRoleFromEnv calls sts:AssumeRole for the role ARN using the ambient AWS
+// credentials (environment variables, ~/.aws/credentials, EC2 instance profile, etc)
+// instead of AWS SSO.  configRole may be nil, otherwise its ExternalId and
+// SourceIdentity are used.
+func AssumeRoleFromEnv(ctx context.Context, client *http.Client, region, roleArn string, configRole *SSORole, duration int32) (storage.RoleCredentials, error) {
+	accountId, role, err := utils.ParseRoleARN(roleArn)
+	if err != nil {
+		return storage.RoleCredentials{}, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+		config.WithHTTPClient(client),
+	)
+	if err != nil {
+		return storage.RoleCredentials{}, err
+	}
+
+	// make sure we have something to assume the role with before calling AWS
+	if cfg.Credentials == nil {
+		return storage.RoleCredentials{}, fmt.Errorf("No AWS credentials found in the environment")
+	}
+	source, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return storage.RoleCredentials{}, fmt.Errorf("No AWS credentials found in the environment: %s", err.Error())
+	}
+	log.Debugf("Assuming %s using AWS credentials from %s", roleArn, source.Source)
+
+	if configRole == nil {
+		configRole = &SSORole{}
+	}
+	output, err := assumeRole(ctx, cfg, roleArn, FROM_ENV_SESSION_NAME, configRole, SessionPolicy{}, duration)
+	if err != nil {
+		return storage.RoleCredentials{}, fmt.Errorf("Unable to assume %s: %w", roleArn, err)
+	}
+
+	return storage.RoleCredentials{
+		AccountId:       accountId,
+		RoleName:        role,
+		AccessKeyId:     aws.ToString(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(output.Credentials.SessionToken),
+		Expiration:      aws.ToTime(output.Credentials.Expiration).UnixMilli(),
+	}, nil
+}
@@ -0,0 +1,80 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssumeRoleFromEnv(t *testing.T) {
+	env := map[string]string{
+		"AWS_CONFIG_FILE":             "/dev/null",
+		"AWS_SHARED_CREDENTIALS_FILE": "/dev/null",
+		"AWS_EC2_METADATA_DISABLED":   "true",
+		"AWS_ACCESS_KEY_ID":           "AKID-Env",
+		"AWS_SECRET_ACCESS_KEY":       "secret-Env",
+		"AWS_SESSION_TOKEN":           "",
+		"AWS_PROFILE":                 "",
+		"AWS_CA_BUNDLE":               "",
+	}
+	for k, v := range env {
+		if old, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+
+	const roleArn = "arn:aws:iam::000000000003:role/Final"
+	transport := &mockStsTransport{signer: map[string]string{}}
+	client := &http.Client{Transport: transport}
+
+	creds, err := AssumeRoleFromEnv(context.TODO(), client, "us-east-1", roleArn, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID-Final", creds.AccessKeyId)
+	assert.Equal(t, "secret-Final", creds.SecretAccessKey)
+	assert.Equal(t, int64(3), creds.AccountId)
+	assert.Equal(t, "Final", creds.RoleName)
+	assert.Equal(t, "AKID-Env", transport.signer[roleArn])
+
+	_, err = AssumeRoleFromEnv(context.TODO(), client, "us-east-1", "not-an-arn", nil, 0)
+	assert.Error(t, err)
+
+	transport.denied = roleArn
+	_, err = AssumeRoleFromEnv(context.TODO(), client, "us-east-1", roleArn, nil, 0)
+	assert.Error(t, err)
+	assert.True(t, IsAccessDeniedError(err))
+
+	// no ambient credentials
+	os.Unsetenv("AWS_ACCESS_KEY_ID")
+	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	_, err = AssumeRoleFromEnv(context.TODO(), client, "us-east-1", roleArn, nil, 0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No AWS credentials found in the environment")
+}
@@ -0,0 +1,214 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/utils"
+	"github.com/synfinatic/gotable"
+)
+
+// How long role snapshots and usage history are kept in the cache
+const AUDIT_RETENTION = 90 * 24 * 60 * 60 // 90 days in seconds
+
+// RoleSnapshot records the roles we had access to at a given time
+type RoleSnapshot struct {
+	Time  int64    `json:"Time"`
+	Roles []string `json:"Roles"` // sorted list of role ARNs
+}
+
+// RoleUsage is how often a role was used during the audit window
+type RoleUsage struct {
+	Arn          string `json:"arn" header:"ARN"`
+	AccountId    int64  `json:"account_id" header:"AccountId"`
+	AccountAlias string `json:"account_alias" header:"AccountAlias"`
+	RoleName     string `json:"role_name" header:"Role"`
+	Count        int    `json:"count" header:"Count"`
+	LastUsed     int64  `json:"last_used" header:"LastUsedEpoch"`
+	LastUsedStr  string `json:"-" header:"LastUsed"`
+}
+
+func (ru RoleUsage) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(ru)
+	return gotable.GetHeaderTag(v, fieldName)
+}
+
+// AuditReport summarizes role usage & access changes since a point in time.
+// Baseline is the time of the snapshot the current roles were compared to,
+// or zero if there was no snapshot.  Partial is true when the oldest snapshot
+// is newer than Since.
+type AuditReport struct {
+	Since    int64       `json:"since"`
+	Until    int64       `json:"until"`
+	Baseline int64       `json:"baseline"`
+	Partial  bool        `json:"partial"`
+	Used     []RoleUsage `json:"used"`
+	Gained   []string    `json:"gained"`
+	Lost     []string    `json:"lost"`
+}
+
+// roleArns returns the sorted list of role ARNs for the current SSO instance
+func (c *Cache) roleArns() []string {
+	arns := []string{}
+	for _, role := range c.GetSSO().Roles.GetAllRoles() {
+		arns = append(arns, role.Arn)
+	}
+	sort.Strings(arns)
+	return arns
+}
+
+// addSnapshot records the current roles if they have changed since the
+// last snapshot and expires old snapshots
+func (c *Cache) addSnapshot(now int64) {
+	cache := c.GetSSO()
+	arns := c.roleArns()
+
+	if l := len(cache.Snapshots); l == 0 || !equalStrings(cache.Snapshots[l-1].Roles, arns) {
+		cache.Snapshots = append(cache.Snapshots, RoleSnapshot{
+			Time:  now,
+			Roles: arns,
+		})
+	}
+
+	// keep the newest snapshot older than the cutoff since it describes
+	// the roles we had at the start of the retention window
+	cutoff := now - AUDIT_RETENTION
+	for len(cache.Snapshots) > 1 && cache.Snapshots[1].Time <= cutoff {
+		cache.Snapshots = cache.Snapshots[1:]
+	}
+}
+
+// addUsage records that the role was used and expires old usage records
+func (c *Cache) addUsage(arn string, used int64) {
+	cache := c.GetSSO()
+	if cache.Usage == nil {
+		cache.Usage = map[string][]int64{}
+	}
+
+	cache.Usage[arn] = append(cache.Usage[arn], used)
+
+	cutoff := time.Now().Unix() - AUDIT_RETENTION
+	for a, times := range cache.Usage {
+		keep := []int64{}
+		for _, t := range times {
+			if t > cutoff {
+				keep = append(keep, t)
+			}
+		}
+		if len(keep) == 0 {
+			delete(cache.Usage, a)
+		} else {
+			cache.Usage[a] = keep
+		}
+	}
+}
+
+// Audit reports which roles were used and which roles we gained or lost
+// access to since the given Unix epoch
+func (c *Cache) Audit(since int64) *AuditReport {
+	cache := c.GetSSO()
+	report := AuditReport{
+		Since:  since,
+		Until:  time.Now().Unix(),
+		Used:   []RoleUsage{},
+		Gained: []string{},
+		Lost:   []string{},
+	}
+
+	for arn, times := range cache.Usage {
+		usage := RoleUsage{Arn: arn}
+		for _, t := range times {
+			if t < since {
+				continue
+			}
+			usage.Count++
+			if t > usage.LastUsed {
+				usage.LastUsed = t
+			}
+		}
+		if usage.Count == 0 {
+			continue
+		}
+		if role, err := c.GetRole(arn); err == nil {
+			usage.AccountId = role.AccountId
+			usage.AccountAlias = role.AccountAlias
+			usage.RoleName = role.RoleName
+		} else if aId, roleName, err := utils.ParseRoleARN(arn); err == nil {
+			usage.AccountId = aId
+			usage.RoleName = roleName
+		}
+		report.Used = append(report.Used, usage)
+	}
+	sort.SliceStable(report.Used, func(i, j int) bool {
+		if report.Used[i].Count != report.Used[j].Count {
+			return report.Used[i].Count > report.Used[j].Count
+		}
+		return report.Used[i].Arn < report.Used[j].Arn
+	})
+
+	// compare against the roles we had at the start of the window, or the
+	// oldest snapshot we have if it is newer
+	var baseline *RoleSnapshot
+	for i := range cache.Snapshots {
+		if cache.Snapshots[i].Time <= since || baseline == nil {
+			baseline = &cache.Snapshots[i]
+		}
+		if cache.Snapshots[i].Time > since {
+			break
+		}
+	}
+	if baseline == nil {
+		return &report
+	}
+	report.Baseline = baseline.Time
+	report.Partial = baseline.Time > since
+
+	before := map[string]bool{}
+	for _, arn := range baseline.Roles {
+		before[arn] = true
+	}
+	now := map[string]bool{}
+	for _, arn := range c.roleArns() {
+		now[arn] = true
+		if !before[arn] {
+			report.Gained = append(report.Gained, arn)
+		}
+	}
+	for _, arn := range baseline.Roles {
+		if !now[arn] {
+			report.Lost = append(report.Lost, arn)
+		}
+	}
+	return &report
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
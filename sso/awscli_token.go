@@ -0,0 +1,105 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// where `aws sso login` caches the AWS SSO token.  Variable for testing
+var awsCliSSOCacheDir = "~/.aws/sso/cache"
+
+// awsCliToken is the format of the token cache files written by the AWS CLI v2
+type awsCliToken struct {
+	StartUrl    string `json:"startUrl"`
+	Region      string `json:"region"`
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+// AWS CLI v2 has used both of these formats for expiresAt
+var awsCliTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05UTC",
+}
+
+// expires returns the Unix epoch when the token expires
+func (t awsCliToken) expires() (int64, error) {
+	for _, format := range awsCliTimeFormats {
+		if e, err := time.Parse(format, t.ExpiresAt); err == nil {
+			return e.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("Unable to parse expiresAt: %s", t.ExpiresAt)
+}
+
+// loadAwsCliToken returns an unexpired AWS SSO token cached by `aws sso login`
+// for our StartUrl and SSORegion
+func (as *AWSSSO) loadAwsCliToken() (storage.CreateTokenResponse, error) {
+	ret := storage.CreateTokenResponse{}
+	files, err := filepath.Glob(filepath.Join(utils.GetHomePath(awsCliSSOCacheDir), "*.json"))
+	if err != nil {
+		return ret, err
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Debugf("Unable to read %s: %s", file, err.Error())
+			continue
+		}
+		t := awsCliToken{}
+		if err = json.Unmarshal(data, &t); err != nil || t.AccessToken == "" {
+			continue // not a token, ie: the botocore client registration
+		}
+
+		if strings.TrimSuffix(t.StartUrl, "/") != strings.TrimSuffix(as.StartUrl, "/") || t.Region != as.SsoRegion {
+			continue
+		}
+
+		expires, err := t.expires()
+		if err != nil {
+			log.Debugf("%s: %s", file, err.Error())
+			continue
+		}
+
+		token := storage.CreateTokenResponse{
+			AccessToken: t.AccessToken,
+			ExpiresAt:   expires,
+			TokenType:   "Bearer",
+		}
+		if !as.tokenExpired(&token) && token.ExpiresAt > ret.ExpiresAt {
+			ret = token
+		}
+	}
+
+	if ret.AccessToken == "" {
+		return ret, fmt.Errorf("No valid AWS CLI token for %s", as.StartUrl)
+	}
+	return ret, nil
+}
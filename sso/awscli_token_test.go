@@ -0,0 +1,105 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+func TestLoadAwsCliToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aws-cli-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	oldDir := awsCliSSOCacheDir
+	awsCliSSOCacheDir = dir
+	defer func() { awsCliSSOCacheDir = oldDir }()
+
+	as := &AWSSSO{
+		SsoRegion: "us-east-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+	}
+
+	// no files
+	_, err = as.loadAwsCliToken()
+	assert.Error(t, err)
+
+	write := func(name, startUrl, region, token string, expires string) {
+		data := fmt.Sprintf(`{"startUrl": "%s", "region": "%s", "accessToken": "%s", "expiresAt": "%s"}`,
+			startUrl, region, token, expires)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+	future := time.Now().Add(time.Hour).UTC()
+
+	write("expired.json", as.StartUrl, as.SsoRegion, "expired", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	write("other-url.json", "https://other.awsapps.com/start", as.SsoRegion, "other-url", future.Format(time.RFC3339))
+	write("other-region.json", as.StartUrl, "us-west-2", "other-region", future.Format(time.RFC3339))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "botocore-client-id.json"), []byte(`{"clientId": "foo"}`), 0600))
+
+	_, err = as.loadAwsCliToken()
+	assert.Error(t, err)
+
+	// older AWS CLI time format & trailing slash
+	write("valid.json", as.StartUrl+"/", as.SsoRegion, "valid", future.Format("2006-01-02T15:04:05UTC"))
+	token, err := as.loadAwsCliToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "valid", token.AccessToken)
+	assert.Equal(t, future.Unix(), token.ExpiresAt)
+}
+
+func TestAuthenticateAwsCliToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aws-cli-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	oldDir := awsCliSSOCacheDir
+	awsCliSSOCacheDir = dir
+	defer func() { awsCliSSOCacheDir = oldDir }()
+
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+	defer os.Remove(tfile.Name())
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	settings := &Settings{UseAwsCliToken: true}
+	as := &AWSSSO{
+		SsoRegion: "us-east-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+		SSOConfig: &SSOConfig{settings: settings},
+	}
+
+	data := fmt.Sprintf(`{"startUrl": "%s", "region": "%s", "accessToken": "cli-token", "expiresAt": "%s"}`,
+		as.StartUrl, as.SsoRegion, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token.json"), []byte(data), 0600))
+
+	assert.NoError(t, as.Authenticate("print", ""))
+	assert.Equal(t, "cli-token", as.Token.AccessToken)
+	assert.True(t, as.ValidAuthToken())
+}
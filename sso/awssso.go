@@ -21,8 +21,11 @@ package sso
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -49,6 +52,7 @@ type SsoApi interface {
 	ListAccountRoles(context.Context, *sso.ListAccountRolesInput, ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error)
 	ListAccounts(context.Context, *sso.ListAccountsInput, ...func(*sso.Options)) (*sso.ListAccountsOutput, error)
 	GetRoleCredentials(context.Context, *sso.GetRoleCredentialsInput, ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error)
+	Logout(context.Context, *sso.LogoutInput, ...func(*sso.Options)) (*sso.LogoutOutput, error)
 }
 
 type AWSSSO struct {
@@ -67,31 +71,73 @@ type AWSSSO struct {
 	SSOConfig  *SSOConfig                  `json:"SSOConfig"`
 	urlAction  string                      // cache for future calls
 	browser    string                      // cache for future calls
+	// LoginTimeout is how long to wait for the user to complete the login.  0 = forever
+	LoginTimeout time.Duration `json:"-"`
+	// TokenExpiryBuffer treats the AWS SSO token as expired this long before
+	// it really expires.  0 = at the exact expiry
+	TokenExpiryBuffer time.Duration `json:"-"`
+	rolesLock         sync.Mutex    // protects Roles when enumerating in parallel
+	authLock          sync.Mutex    // protects Token when enumerating in parallel
+	ctx               context.Context
+	retryStatus       RetryStatusFunc // reports throttling backoff progress
 }
 
 func NewAWSSSO(s *SSOConfig, store *storage.SecureStorage) *AWSSSO {
 	oidcSession := ssooidc.New(ssooidc.Options{
-		Region: s.SSORegion,
+		Region:     s.SSORegion,
+		HTTPClient: s.HTTPClient(),
 	})
 
 	ssoSession := sso.New(sso.Options{
-		Region: s.SSORegion,
+		Region:     s.SSORegion,
+		HTTPClient: s.HTTPClient(),
 	})
 
 	as := AWSSSO{
-		sso:        ssoSession,
-		ssooidc:    oidcSession,
-		store:      *store,
-		ClientName: awsSSOClientName,
-		ClientType: awsSSOClientType,
-		SsoRegion:  s.SSORegion,
-		StartUrl:   s.StartUrl,
-		Roles:      map[string][]RoleInfo{},
-		SSOConfig:  s,
+		sso:               ssoSession,
+		ssooidc:           oidcSession,
+		store:             *store,
+		ClientName:        awsSSOClientName,
+		ClientType:        awsSSOClientType,
+		SsoRegion:         s.SSORegion,
+		StartUrl:          s.StartUrl,
+		Roles:             map[string][]RoleInfo{},
+		SSOConfig:         s,
+		LoginTimeout:      s.LoginTimeout(),
+		TokenExpiryBuffer: s.TokenExpiryBuffer(),
 	}
 	return &as
 }
 
+// SetContext sets the context used for all AWS API calls so the caller can
+// cancel them or impose a deadline
+func (as *AWSSSO) SetContext(ctx context.Context) {
+	as.ctx = ctx
+}
+
+// SetRetryStatus registers a callback to report progress while throttled
+// API calls are waiting to be retried.  nil disables reporting
+func (as *AWSSSO) SetRetryStatus(f RetryStatusFunc) {
+	as.retryStatus = f
+}
+
+// getContext returns the context to use for AWS API calls
+func (as *AWSSSO) getContext() context.Context {
+	if as.ctx == nil {
+		return context.Background()
+	}
+	return as.ctx
+}
+
+// httpClient returns the http.Client to use for requests which are not made
+// via the AWS SDK clients
+func (as *AWSSSO) httpClient() *http.Client {
+	if as.SSOConfig == nil {
+		return http.DefaultClient
+	}
+	return as.SSOConfig.HTTPClient()
+}
+
 type RoleInfo struct {
 	Id           int    `yaml:"Id" json:"Id" header:"Id"`
 	Arn          string `yaml:"-" json:"-" header:"Arn"`
@@ -118,68 +164,82 @@ func (ri RoleInfo) RoleArn() string {
 }
 
 func (as *AWSSSO) GetRoles(account AccountInfo) ([]RoleInfo, error) {
+	as.rolesLock.Lock()
 	roles, ok := as.Roles[account.AccountId]
+	as.rolesLock.Unlock()
 	if ok && len(roles) > 0 {
 		return roles, nil
 	}
-	as.Roles[account.AccountId] = []RoleInfo{}
+	roles = []RoleInfo{}
 
+	token := as.accessToken()
 	input := sso.ListAccountRolesInput{
-		AccessToken: aws.String(as.Token.AccessToken),
+		AccessToken: aws.String(token),
 		AccountId:   aws.String(account.AccountId),
 		MaxResults:  aws.Int32(1000),
 	}
-	output, err := as.sso.ListAccountRoles(context.TODO(), &input)
+	output, err := as.sso.ListAccountRoles(as.getContext(), &input)
 	if err != nil {
+		if IsThrottlingError(err) || as.getContext().Err() != nil {
+			return roles, err
+		}
 		// sometimes our AccessToken is invalid even though it has not expired
 		// so retry once
 		log.Debugf("Unexpected AccessToken failure.  Refreshing...")
-		if err = as.reauthenticate(); err != nil {
+		if err = as.refreshToken(token); err != nil {
 			// failed again... return our cache?
-			return as.Roles[account.AccountId], err
+			return roles, err
 		}
-		input.AccessToken = aws.String(as.Token.AccessToken)
-		if output, err = as.sso.ListAccountRoles(context.TODO(), &input); err != nil {
-			return as.Roles[account.AccountId], err
+		input.AccessToken = aws.String(as.accessToken())
+		if output, err = as.sso.ListAccountRoles(as.getContext(), &input); err != nil {
+			return roles, err
 		}
 	}
 	for i, r := range output.RoleList {
-		if err := as.makeRoleInfo(account, i, r); err != nil {
-			return as.Roles[account.AccountId], err
+		rInfo, err := as.makeRoleInfo(account, i, r)
+		if err != nil {
+			return roles, err
 		}
+		roles = append(roles, rInfo)
 	}
 
 	for aws.ToString(output.NextToken) != "" {
 		input.NextToken = output.NextToken
-		output, err = as.sso.ListAccountRoles(context.TODO(), &input)
+		output, err = as.sso.ListAccountRoles(as.getContext(), &input)
 		if err != nil {
-			return as.Roles[account.AccountId], err
+			return roles, err
 		}
-		roleCount := len(as.Roles[account.AccountId])
+		roleCount := len(roles)
 		for i, r := range output.RoleList {
 			x := roleCount + i
-			if err := as.makeRoleInfo(account, x, r); err != nil {
-				return as.Roles[account.AccountId], err
+			rInfo, err := as.makeRoleInfo(account, x, r)
+			if err != nil {
+				return roles, err
 			}
+			roles = append(roles, rInfo)
 		}
 	}
-	return as.Roles[account.AccountId], nil
+
+	as.rolesLock.Lock()
+	as.Roles[account.AccountId] = roles
+	as.rolesLock.Unlock()
+	return roles, nil
 }
 
-// makeRoleInfo takes the sso.types.RoleInfo and adds it onto our as.Roles[accountId] list
-func (as *AWSSSO) makeRoleInfo(account AccountInfo, i int, r types.RoleInfo) error {
+// makeRoleInfo converts the sso.types.RoleInfo into our RoleInfo
+func (as *AWSSSO) makeRoleInfo(account AccountInfo, i int, r types.RoleInfo) (RoleInfo, error) {
 	var via string
 
 	aId, err := strconv.ParseInt(account.AccountId, 10, 64)
 	if err != nil {
-		return fmt.Errorf("Unable to parse accountid %s: %s",
+		return RoleInfo{}, fmt.Errorf("Unable to parse accountid %s: %s",
 			account.AccountId, err.Error())
 	}
 	ssoRole, err := as.SSOConfig.GetRole(aId, aws.ToString(r.RoleName))
 	if err != nil && len(ssoRole.Via) > 0 {
 		via = ssoRole.Via
 	}
-	as.Roles[account.AccountId] = append(as.Roles[account.AccountId], RoleInfo{
+	return RoleInfo{
 		Id:           i,
 		AccountId:    aws.ToString(r.AccountId),
 		Arn:          utils.MakeRoleARN(aId, aws.ToString(r.RoleName)),
@@ -189,8 +249,7 @@ func (as *AWSSSO) makeRoleInfo(account AccountInfo, i int, r types.RoleInfo) err
 		SSORegion:    as.SsoRegion,
 		StartUrl:     as.StartUrl,
 		Via:          via,
-	})
-	return nil
+	}, nil
 }
 
 type AccountInfo struct {
@@ -218,19 +277,22 @@ func (as *AWSSSO) GetAccounts() ([]AccountInfo, error) {
 		return as.Accounts, nil
 	}
 
+	token := as.accessToken()
 	input := sso.ListAccountsInput{
-		AccessToken: aws.String(as.Token.AccessToken),
+		AccessToken: aws.String(token),
 		MaxResults:  aws.Int32(1000),
 	}
-	output, err := as.sso.ListAccounts(context.TODO(), &input)
-	if err != nil {
+	output, err := as.sso.ListAccounts(as.getContext(), &input)
+	if err != nil && as.getContext().Err() != nil {
+		return as.Accounts, err
+	} else if err != nil {
 		// sometimes our AccessToken is invalid so try a new one once?
 		log.Debugf("Unexpected AccessToken failure.  Refreshing...")
-		if err = as.reauthenticate(); err != nil {
+		if err = as.refreshToken(token); err != nil {
 			return as.Accounts, err
 		}
-		input.AccessToken = aws.String(as.Token.AccessToken)
-		if output, err = as.sso.ListAccounts(context.TODO(), &input); err != nil {
+		input.AccessToken = aws.String(as.accessToken())
+		if output, err = as.sso.ListAccounts(as.getContext(), &input); err != nil {
 			return as.Accounts, err
 		}
 	}
@@ -244,7 +306,7 @@ func (as *AWSSSO) GetAccounts() ([]AccountInfo, error) {
 	}
 	for aws.ToString(output.NextToken) != "" {
 		input.NextToken = output.NextToken
-		output, err = as.sso.ListAccounts(context.TODO(), &input)
+		output, err = as.sso.ListAccounts(as.getContext(), &input)
 		if err != nil {
 			return as.Accounts, err
 		}
@@ -262,26 +324,57 @@ func (as *AWSSSO) GetAccounts() ([]AccountInfo, error) {
 	return as.Accounts, nil
 }
 
-var roleChainMap map[string]bool = map[string]bool{} // track our roles
-
 // GetRoleCredentials recursively does any sts:AssumeRole calls as necessary for role-chaining
 // through `Via` and returns the final set of RoleCredentials for the requested role
 func (as *AWSSSO) GetRoleCredentials(accountId int64, role string) (storage.RoleCredentials, error) {
+	return as.GetRoleCredentialsWithPolicy(accountId, role, SessionPolicy{}, 0)
+}
+
+// GetRoleCredentialsWithPolicy is the same as GetRoleCredentials, but scopes down the
+// final set of RoleCredentials with the session policy.  Only supported for roles using `Via`.
+// A non-zero duration (in seconds) limits how long the credentials are valid for.  AWS SSO
+// does not support shorter sessions, so for roles without `Via` only the Expiration is reduced.
+func (as *AWSSSO) GetRoleCredentialsWithPolicy(accountId int64, role string, policy SessionPolicy, duration int32) (storage.RoleCredentials, error) {
+	return as.getRoleCredentials(as.getContext(), accountId, role, policy, duration, map[string]bool{})
+}
+
+// GetRoleCredentialsContext is the same as GetRoleCredentials, but uses the given
+// context instead of the one from SetContext so each role can have its own deadline
+func (as *AWSSSO) GetRoleCredentialsContext(ctx context.Context, accountId int64, role string) (storage.RoleCredentials, error) {
+	return as.getRoleCredentials(ctx, accountId, role, SessionPolicy{}, 0, map[string]bool{})
+}
+
+// getRoleCredentials does the work for the public methods.  chain tracks the role ARNs
+// already visited for this request so we can detect loops in the `Via` config.
+func (as *AWSSSO) getRoleCredentials(ctx context.Context, accountId int64, role string, policy SessionPolicy, duration int32, chain map[string]bool) (storage.RoleCredentials, error) {
 	aId, err := utils.AccountIdToString(accountId)
 	if err != nil {
 		return storage.RoleCredentials{}, err
 	}
 
 	configRole, err := as.SSOConfig.GetRole(accountId, role)
-	if err != nil && configRole.Via == "" {
+	if err != nil || configRole.Via == "" {
+		if !policy.IsEmpty() {
+			return storage.RoleCredentials{}, fmt.Errorf("Session policies are only supported for roles using Via and %s:%s has no Via", aId, role)
+		}
 		log.Debugf("Getting %s:%s directly", aId, role)
 		// This are the actual role creds requested through AWS SSO
+		token := as.accessToken()
 		input := sso.GetRoleCredentialsInput{
-			AccessToken: aws.String(as.Token.AccessToken),
+			AccessToken: aws.String(token),
 			AccountId:   aws.String(aId),
 			RoleName:    aws.String(role),
 		}
-		output, err := as.sso.GetRoleCredentials(context.TODO(), &input)
+		output, err := as.sso.GetRoleCredentials(ctx, &input)
+		if err != nil && IsUnauthorizedError(err) {
+			// our AccessToken was revoked before it expired, so login again
+			log.Warnf("Cached AWS SSO token was rejected by AWS SSO.  Reauthenticating...")
+			if err = as.refreshToken(token); err != nil {
+				return storage.RoleCredentials{}, err
+			}
+			input.AccessToken = aws.String(as.accessToken())
+			output, err = as.sso.GetRoleCredentials(ctx, &input)
+		}
 		if err != nil {
 			return storage.RoleCredentials{}, err
 		}
@@ -294,17 +387,20 @@ func (as *AWSSSO) GetRoleCredentials(accountId int64, role string) (storage.Role
 			SessionToken:    aws.ToString(output.RoleCredentials.SessionToken),
 			Expiration:      output.RoleCredentials.Expiration,
 		}
+		if duration > 0 {
+			expires := time.Now().Add(time.Duration(duration) * time.Second).UnixMilli()
+			if expires < ret.Expiration {
+				ret.Expiration = expires
+			}
+		}
 
 		return ret, nil
 	}
 
 	// Detect loops
-	roleChainMap[configRole.ARN] = true
-	for k := range roleChainMap {
-		if k == configRole.Via {
-			log.Fatalf("Detected role chain loop!  Getting %s via %s", configRole.ARN, configRole.Via)
-		}
-		roleChainMap[k] = true
+	chain[configRole.ARN] = true
+	if chain[configRole.Via] {
+		return storage.RoleCredentials{}, fmt.Errorf("Detected role chain loop!  Getting %s via %s", configRole.ARN, configRole.Via)
 	}
 
 	// Need to recursively call sts:AssumeRole in order to retrieve the STS creds for
@@ -317,7 +413,7 @@ func (as *AWSSSO) GetRoleCredentials(accountId int64, role string) (storage.Role
 	}
 
 	// recurse
-	creds, err := as.GetRoleCredentials(viaAccountId, viaRole)
+	creds, err := as.getRoleCredentials(ctx, viaAccountId, viaRole, SessionPolicy{}, 0, chain)
 	if err != nil {
 		return storage.RoleCredentials{}, err
 	}
@@ -328,34 +424,22 @@ func (as *AWSSSO) GetRoleCredentials(accountId int64, role string) (storage.Role
 		creds.SessionToken,
 	)
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
+	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(as.SsoRegion),
 		config.WithCredentialsProvider(cfgCreds),
+		config.WithHTTPClient(as.SSOConfig.HTTPClient()),
 	)
 	if err != nil {
 		return storage.RoleCredentials{}, err
 	}
-	stsSession := sts.NewFromConfig(cfg)
 
-	previousAccount, _ := utils.AccountIdToString(creds.AccountId)
-	previousRole := fmt.Sprintf("%s@%s", creds.RoleName, previousAccount)
-
-	input := sts.AssumeRoleInput{
-		//		DurationSeconds: aws.Int64(900),
-		RoleArn:         aws.String(utils.MakeRoleARN(accountId, role)),
-		RoleSessionName: aws.String(previousRole),
-	}
-	if configRole.ExternalId != "" {
-		// Optional vlaue: https://docs.aws.amazon.com/sdk-for-go/api/service/sts/#AssumeRoleInput
-		input.ExternalId = aws.String(configRole.ExternalId)
-	}
-	if configRole.SourceIdentity != "" {
-		input.SourceIdentity = aws.String(configRole.SourceIdentity)
-	}
-
-	output, err := stsSession.AssumeRole(context.TODO(), &input)
+	roleArn := utils.MakeRoleARNPartition(as.SSOConfig.Partition(), accountId, role)
+	sessionName := roleSessionName(creds.AccountId, creds.RoleName)
+	output, err := assumeRole(ctx, cfg, roleArn, sessionName, configRole, policy, duration)
 	if err != nil {
-		return storage.RoleCredentials{}, err
+		// wrap so callers know which hop in the chain failed
+		return storage.RoleCredentials{}, fmt.Errorf("Unable to assume %s via %s: %w",
+			roleArn, configRole.Via, err)
 	}
 	log.Debugf("%s", spew.Sdump(output))
 	ret := storage.RoleCredentials{
@@ -368,3 +452,31 @@ func (as *AWSSSO) GetRoleCredentials(accountId int64, role string) (storage.Role
 	}
 	return ret, nil
 }
+
+// roleSessionName returns the session name for credentials assumed via the given role
+func roleSessionName(viaAccountId int64, viaRole string) string {
+	viaAccount, _ := utils.AccountIdToString(viaAccountId)
+	return fmt.Sprintf("%s@%s", viaRole, viaAccount)
+}
+
+// assumeRole calls sts:AssumeRole using the credentials in cfg, applying the
+// ExternalId and SourceIdentity of the configRole
+func assumeRole(ctx context.Context, cfg aws.Config, roleArn, sessionName string, configRole *SSORole, policy SessionPolicy, duration int32) (*sts.AssumeRoleOutput, error) {
+	input := sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleArn),
+		RoleSessionName: aws.String(sessionName),
+	}
+	if configRole.ExternalId != "" {
+		// Optional vlaue: https://docs.aws.amazon.com/sdk-for-go/api/service/sts/#AssumeRoleInput
+		input.ExternalId = aws.String(configRole.ExternalId)
+	}
+	if configRole.SourceIdentity != "" {
+		input.SourceIdentity = aws.String(configRole.SourceIdentity)
+	}
+	if duration > 0 {
+		input.DurationSeconds = aws.Int32(duration)
+	}
+	policy.apply(&input)
+
+	return sts.NewFromConfig(cfg).AssumeRole(ctx, &input)
+}
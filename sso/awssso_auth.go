@@ -22,9 +22,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
 	oidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
 	log "github.com/sirupsen/logrus"
@@ -48,10 +53,40 @@ func (as *AWSSSO) Authenticate(urlAction, browser string) error {
 	// check our cache
 	token := storage.CreateTokenResponse{}
 	err := as.store.GetCreateTokenResponse(as.StoreKey(), &token)
-	if err == nil && !token.Expired() {
+	if err == nil && !as.tokenExpired(&token) {
 		as.Token = token
 		return nil
-	} else if err != nil {
+	}
+
+	// extend our session without the user logging in again
+	if err == nil && token.RefreshToken != "" {
+		rerr := as.refreshAccessToken(&token)
+		if rerr == nil {
+			log.Infof("Refreshed AWS SSO token")
+			return nil
+		}
+		log.Infof("Unable to refresh AWS SSO token: %s", rerr.Error())
+		// another aws-sso process may have refreshed it first
+		if as.reloadToken() {
+			return nil
+		}
+	}
+
+	// fall back to the token cached by `aws sso login`
+	if as.SSOConfig != nil && as.SSOConfig.UseAwsCliToken() {
+		cliToken, cliErr := as.loadAwsCliToken()
+		if cliErr == nil {
+			log.Infof("Using AWS SSO token from the AWS CLI cache")
+			as.Token = cliToken
+			if err := as.store.SaveCreateTokenResponse(as.StoreKey(), cliToken); err != nil {
+				log.WithError(err).Warnf("Unable to cache AWS SSO token")
+			}
+			return nil
+		}
+		log.Debugf("%s", cliErr.Error())
+	}
+
+	if err != nil {
 		log.Debugf(err.Error())
 	} else {
 		if as.Token.ExpiresAt != 0 {
@@ -63,6 +98,21 @@ func (as *AWSSSO) Authenticate(urlAction, browser string) error {
 		}
 	}
 
+	return as.loginOnce()
+}
+
+// Reauthenticate always talks to AWS SSO to generate a new AWS SSO AccessToken
+// even if the cached token has not expired.  Cached role credentials are not modified.
+func (as *AWSSSO) Reauthenticate(urlAction, browser string) error {
+	log.Tracef("Reauthenticate(%s, %s)", urlAction, browser)
+	if urlAction != "" {
+		as.urlAction = urlAction
+	}
+
+	if browser != "" {
+		as.browser = browser
+	}
+
 	return as.reauthenticate()
 }
 
@@ -71,6 +121,107 @@ func (as *AWSSSO) StoreKey() string {
 	return fmt.Sprintf("%s|%s", as.SsoRegion, as.StartUrl)
 }
 
+// accessToken returns our current AWS SSO AccessToken
+func (as *AWSSSO) accessToken() string {
+	as.authLock.Lock()
+	defer as.authLock.Unlock()
+	return as.Token.AccessToken
+}
+
+// refreshToken reauthenticates unless another goroutine has already replaced
+// the given AccessToken.  The rejected AccessToken is removed from the cache
+// so it is never used again, even if the login fails.
+func (as *AWSSSO) refreshToken(oldToken string) error {
+	as.authLock.Lock()
+	defer as.authLock.Unlock()
+	if as.Token.AccessToken != oldToken {
+		return nil
+	}
+	if err := as.store.DeleteCreateTokenResponse(as.StoreKey()); err != nil {
+		log.WithError(err).Debugf("Unable to delete cached AWS SSO token")
+	}
+	as.Token = storage.CreateTokenResponse{}
+	return as.loginOnce()
+}
+
+// Logout invalidates our AWS SSO token server-side and then removes it from
+// the cache.  Returns true if AWS SSO accepted the logout.  Tokens which have
+// already expired are only removed from the cache.
+func (as *AWSSSO) Logout() (bool, error) {
+	token := storage.CreateTokenResponse{}
+	if err := as.store.GetCreateTokenResponse(as.StoreKey(), &token); err != nil {
+		log.Debugf("No cached AWS SSO token: %s", err.Error())
+		return false, nil
+	}
+
+	var err error
+	loggedOut := false
+	if !token.ExpiresWithin(0) {
+		input := sso.LogoutInput{
+			AccessToken: aws.String(token.AccessToken),
+		}
+		if _, err = as.sso.Logout(as.getContext(), &input); err == nil {
+			loggedOut = true
+		} else if IsUnauthorizedError(err) {
+			// already invalid server-side
+			err = nil
+		} else {
+			err = fmt.Errorf("Unable to logout of AWS SSO: %s", err.Error())
+		}
+	}
+
+	as.authLock.Lock()
+	as.Token = storage.CreateTokenResponse{}
+	as.authLock.Unlock()
+	if derr := as.store.DeleteCreateTokenResponse(as.StoreKey()); derr != nil {
+		return loggedOut, fmt.Errorf("Unable to delete cached AWS SSO token: %s", derr.Error())
+	}
+	return loggedOut, err
+}
+
+// IsUnauthorizedError returns true if AWS SSO rejected our AccessToken, which
+// happens when the token has been revoked before it expired
+func IsUnauthorizedError(err error) bool {
+	var ue *ssotypes.UnauthorizedException
+	return errors.As(err, &ue)
+}
+
+// ValidateToken verifies that AWS SSO still accepts our AccessToken by making
+// a lightweight API call.  Revoked tokens are removed from the cache and the
+// user is asked to login again.
+func (as *AWSSSO) ValidateToken() error {
+	token := as.accessToken()
+	input := sso.ListAccountsInput{
+		AccessToken: aws.String(token),
+		MaxResults:  aws.Int32(1),
+	}
+	_, err := as.sso.ListAccounts(as.getContext(), &input)
+	if err == nil {
+		return nil
+	} else if !IsUnauthorizedError(err) {
+		return fmt.Errorf("Unable to validate AWS SSO token: %s", err.Error())
+	}
+
+	log.Warnf("Cached AWS SSO token was rejected by AWS SSO.  Reauthenticating...")
+	return as.refreshToken(token)
+}
+
+// ValidAuthToken returns true if we have a cached AWS SSO token which has not
+// expired, meaning we can talk to AWS SSO without the user logging in
+func (as *AWSSSO) ValidAuthToken() bool {
+	token := storage.CreateTokenResponse{}
+	if err := as.store.GetCreateTokenResponse(as.StoreKey(), &token); err != nil {
+		return false
+	}
+	return !as.tokenExpired(&token)
+}
+
+// tokenExpired returns true if the AWS SSO token has expired or will within
+// our TokenExpiryBuffer
+func (as *AWSSSO) tokenExpired(token *storage.CreateTokenResponse) bool {
+	return token.ExpiresWithin(as.TokenExpiryBuffer)
+}
+
 // reauthenticate talks to AWS SSO to generate a new AWS SSO AccessToken
 func (as *AWSSSO) reauthenticate() error {
 	log.Tracef("reauthenticate()")
@@ -87,6 +238,7 @@ func (as *AWSSSO) reauthenticate() error {
 			return fmt.Errorf("Unable to register client with AWS SSO: %s", err.Error())
 		}
 		if err = as.startDeviceAuthorization(); err != nil {
+			err = RegionMismatchError(as.getContext(), as.httpClient(), err, as.StartUrl, as.SsoRegion)
 			return fmt.Errorf("Unable to start device authorization with AWS SSO: %s", err.Error())
 		}
 	}
@@ -109,19 +261,87 @@ func (as *AWSSSO) reauthenticate() error {
 		return fmt.Errorf("Unable to create new AWS SSO token: %s", err.Error())
 	}
 
+	if err = as.runPostLoginHook(); err != nil {
+		log.WithError(err).Warnf("PostLoginHook failed")
+	}
+
 	return nil
 }
 
+// runs a command with the given environment.  Variable to make testing easier
+var hookRunner = func(env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...) // #nosec
+	cmd.Env = env
+	cmd.Stdout = os.Stderr // don't pollute our stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runPostLoginHook runs the user defined PostLoginHook (if any) after a
+// successful login.  The command is never passed to a shell.
+func (as *AWSSSO) runPostLoginHook() error {
+	if as.SSOConfig == nil {
+		return nil
+	}
+	hook := as.SSOConfig.PostLoginHook()
+	if len(hook) == 0 {
+		return nil
+	}
+
+	env := append(os.Environ(),
+		fmt.Sprintf("AWS_SSO=%s", as.SSOConfig.Name()),
+		fmt.Sprintf("AWS_SSO_START_URL=%s", as.StartUrl),
+		fmt.Sprintf("AWS_SSO_REGION=%s", as.SsoRegion),
+		fmt.Sprintf("AWS_SSO_TOKEN_EXPIRATION=%s",
+			time.Unix(as.Token.ExpiresAt, 0).Format(AWS_SSO_SESSION_EXPIRATION_FORMAT)),
+	)
+	log.Debugf("Running PostLoginHook: %v", hook)
+	return hookRunner(env, hook[0], hook[1:]...)
+}
+
 const (
-	awsSSOClientName = "aws-sso-cli"
-	awsSSOClientType = "public"
-	awsSSOGrantType  = "urn:ietf:params:oauth:grant-type:device_code"
+	awsSSOClientName       = "aws-sso-cli"
+	awsSSOClientType       = "public"
+	awsSSOGrantType        = "urn:ietf:params:oauth:grant-type:device_code"
+	awsSSORefreshGrantType = "refresh_token"
+	// AWS SSO only issues refresh tokens to clients registered with this scope
+	awsSSOScope = "sso:account:access"
 	// The default values for ODIC defined in:
 	// https://tools.ietf.org/html/draft-ietf-oauth-device-flow-15#section-3.5
 	SLOW_DOWN_SEC  = 5
 	RETRY_INTERVAL = 5
 )
 
+// ImportClientRegistration saves the given client registration, typically
+// shared with other hosts, so we use it until it expires instead of
+// registering a new client
+func (as *AWSSSO) ImportClientRegistration(client storage.RegisterClientData) error {
+	if client.ClientId == "" || client.ClientSecret == "" {
+		return fmt.Errorf("Client registration is missing the clientId or clientSecret")
+	}
+	if client.Expired() {
+		return fmt.Errorf("Client registration %s expired or expires within the hour", client.ClientId)
+	}
+
+	as.ClientData = client
+	if err := as.store.SaveRegisterClientData(as.StoreKey(), client); err != nil {
+		return fmt.Errorf("Unable to save client registration: %s", err.Error())
+	}
+	return nil
+}
+
+// GetClientRegistration returns our cached client registration
+func (as *AWSSSO) GetClientRegistration() (storage.RegisterClientData, error) {
+	client := storage.RegisterClientData{}
+	if err := as.store.GetRegisterClientData(as.StoreKey(), &client); err != nil {
+		return client, err
+	}
+	if client.Expired() {
+		return client, fmt.Errorf("Client registration %s has expired", client.ClientId)
+	}
+	return client, nil
+}
+
 // registerClient does the needful to talk to AWS or read our cache to get the
 // RegisterClientData for later steps and saves it to our secret store
 func (as *AWSSSO) registerClient(force bool) error {
@@ -131,15 +351,17 @@ func (as *AWSSSO) registerClient(force bool) error {
 		if err == nil && !as.ClientData.Expired() {
 			log.Debug("Using RegisterClient cache")
 			return nil
+		} else if err == nil {
+			log.Infof("AWS SSO client registration %s has expired, registering a new client", as.ClientData.ClientId)
 		}
 	}
 
 	input := ssooidc.RegisterClientInput{
 		ClientName: aws.String(as.ClientName),
 		ClientType: aws.String(as.ClientType),
-		Scopes:     nil,
+		Scopes:     []string{awsSSOScope},
 	}
-	resp, err := as.ssooidc.RegisterClient(context.TODO(), &input)
+	resp, err := as.ssooidc.RegisterClient(as.getContext(), &input)
 	if err != nil {
 		return err
 	}
@@ -168,7 +390,7 @@ func (as *AWSSSO) startDeviceAuthorization() error {
 		ClientId:     aws.String(as.ClientData.ClientId),
 		ClientSecret: aws.String(as.ClientData.ClientSecret),
 	}
-	resp, err := as.ssooidc.StartDeviceAuthorization(context.TODO(), &input)
+	resp, err := as.ssooidc.StartDeviceAuthorization(as.getContext(), &input)
 	if err != nil {
 		return err
 	}
@@ -217,7 +439,6 @@ func (as *AWSSSO) createToken() error {
 		ClientSecret: aws.String(as.ClientData.ClientSecret),
 		DeviceCode:   aws.String(as.DeviceAuth.DeviceCode),
 		GrantType:    aws.String(awsSSOGrantType),
-		// RefreshToken is not supported by AWS
 	}
 
 	// figure out our timings
@@ -227,11 +448,20 @@ func (as *AWSSSO) createToken() error {
 		retryInterval = time.Duration(as.DeviceAuth.Interval) * time.Second
 	}
 
+	// stop polling on Ctrl-C or once we hit our LoginTimeout
+	ctx, stop := signal.NotifyContext(as.getContext(), os.Interrupt)
+	defer stop()
+	if as.LoginTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, as.LoginTimeout)
+		defer cancel()
+	}
+
 	var err error
 	var resp *ssooidc.CreateTokenOutput
 
 	for {
-		resp, err = as.ssooidc.CreateToken(context.TODO(), &input)
+		resp, err = as.ssooidc.CreateToken(ctx, &input)
 		if err == nil {
 			break
 		}
@@ -242,12 +472,73 @@ func (as *AWSSSO) createToken() error {
 		if errors.As(err, &sde) {
 			log.Debugf("Slowing down CreateToken()")
 			retryInterval += slowDown
-			time.Sleep(retryInterval)
-		} else if errors.As(err, &ape) {
-			time.Sleep(retryInterval)
-		} else {
+		} else if !errors.As(err, &ape) && ctx.Err() == nil {
 			return err
 		}
+
+		select {
+		case <-ctx.Done():
+			if err := as.getContext().Err(); err != nil {
+				return err // the caller's deadline, not our LoginTimeout
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("AWS SSO login timed out after %s", as.LoginTimeout)
+			}
+			return fmt.Errorf("AWS SSO login cancelled")
+		case <-time.After(retryInterval):
+		}
+	}
+
+	as.saveToken(resp, "")
+	return nil
+}
+
+// refreshAccessToken uses the RefreshToken of the expired token to get a new
+// AccessToken without the user logging in.  Refresh tokens are only valid
+// for the client they were issued to and are removed from the cache once
+// AWS SSO rejects them, unless another process has already replaced them.
+func (as *AWSSSO) refreshAccessToken(token *storage.CreateTokenResponse) error {
+	log.Tracef("refreshAccessToken()")
+	client := storage.RegisterClientData{}
+	if err := as.store.GetRegisterClientData(as.StoreKey(), &client); err != nil {
+		return err
+	}
+	if client.Expired() {
+		return fmt.Errorf("Client registration %s has expired", client.ClientId)
+	}
+
+	input := ssooidc.CreateTokenInput{
+		ClientId:     aws.String(client.ClientId),
+		ClientSecret: aws.String(client.ClientSecret),
+		GrantType:    aws.String(awsSSORefreshGrantType),
+		RefreshToken: aws.String(token.RefreshToken),
+	}
+	resp, err := as.ssooidc.CreateToken(as.getContext(), &input)
+	if err != nil {
+		var ige *oidctypes.InvalidGrantException
+		var ete *oidctypes.ExpiredTokenException
+		cached := storage.CreateTokenResponse{}
+		if (errors.As(err, &ige) || errors.As(err, &ete)) &&
+			as.store.GetCreateTokenResponse(as.StoreKey(), &cached) == nil &&
+			cached.RefreshToken == token.RefreshToken {
+			if derr := as.store.DeleteCreateTokenResponse(as.StoreKey()); derr != nil {
+				log.WithError(derr).Debugf("Unable to delete cached AWS SSO token")
+			}
+		}
+		return err
+	}
+
+	as.ClientData = client
+	as.saveToken(resp, token.RefreshToken)
+	return nil
+}
+
+// saveToken updates our AWS SSO token from the CreateToken response and saves
+// it to our secret store.  AWS SSO may not return a new RefreshToken when
+// refreshing, in which case we keep using the current one.
+func (as *AWSSSO) saveToken(resp *ssooidc.CreateTokenOutput, refreshToken string) {
+	if resp.RefreshToken != nil {
+		refreshToken = aws.ToString(resp.RefreshToken)
 	}
 
 	secs, _ := time.ParseDuration(fmt.Sprintf("%ds", resp.ExpiresIn)) // seconds
@@ -255,14 +546,11 @@ func (as *AWSSSO) createToken() error {
 		AccessToken:  aws.ToString(resp.AccessToken),
 		ExpiresIn:    resp.ExpiresIn,
 		ExpiresAt:    time.Now().Add(secs).Unix(),
-		IdToken:      aws.ToString(resp.IdToken),      // per AWS docs, this may be undefined
-		RefreshToken: aws.ToString(resp.RefreshToken), // per AWS docs, not currently implemented
+		IdToken:      aws.ToString(resp.IdToken), // per AWS docs, this may be undefined
+		RefreshToken: refreshToken,               // only if the instance issues them
 		TokenType:    aws.ToString(resp.TokenType),
 	}
-	err = as.store.SaveCreateTokenResponse(as.StoreKey(), as.Token)
-	if err != nil {
+	if err := as.store.SaveCreateTokenResponse(as.StoreKey(), as.Token); err != nil {
 		log.WithError(err).Errorf("Unable to save CreateTokenResponse")
 	}
-
-	return nil
 }
@@ -27,7 +27,10 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	oidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/stretchr/testify/assert"
 	"github.com/synfinatic/aws-sso-cli/storage"
@@ -35,7 +38,8 @@ import (
 
 // mock ssooidc
 type mockSsoOidcApi struct {
-	Results []mockSsoOidcApiResults
+	Results         []mockSsoOidcApiResults
+	LastCreateToken *ssooidc.CreateTokenInput
 }
 
 type mockSsoOidcApiResults struct {
@@ -77,6 +81,7 @@ func (m *mockSsoOidcApi) StartDeviceAuthorization(ctx context.Context, params *s
 
 func (m *mockSsoOidcApi) CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
 	var x mockSsoOidcApiResults
+	m.LastCreateToken = params
 	switch {
 	case len(m.Results) == 0:
 		return &ssooidc.CreateTokenOutput{}, fmt.Errorf("calling mocked CreateToken too many times")
@@ -248,6 +253,206 @@ func TestAuthenticate(t *testing.T) {
 	assert.Equal(t, "token-type", as.Token.TokenType)
 }
 
+func TestReauthenticate(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	defer os.Remove(tfile.Name())
+
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+	}
+
+	expires := time.Now().Add(time.Hour * 8).Unix()
+
+	// a valid token & role credentials are already cached
+	err = jstore.SaveCreateTokenResponse(as.StoreKey(), storage.CreateTokenResponse{
+		AccessToken: "old-access-token",
+		ExpiresAt:   expires,
+	})
+	assert.NoError(t, err)
+
+	creds := storage.RoleCredentials{
+		AccountId:   123456789012,
+		RoleName:    "Foobar",
+		AccessKeyId: "access-key-id",
+		Expiration:  expires * 1000,
+	}
+	err = jstore.SaveRoleCredentials(creds.RoleArn(), creds)
+	assert.NoError(t, err)
+
+	as.ssooidc = &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				RegisterClient: &ssooidc.RegisterClientOutput{
+					ClientId:              aws.String("this-is-my-client-id"),
+					ClientSecret:          aws.String("this-is-my-client-secret"),
+					ClientIdIssuedAt:      time.Now().Unix(),
+					ClientSecretExpiresAt: expires,
+				},
+			},
+			{
+				StartDeviceAuthorization: &ssooidc.StartDeviceAuthorizationOutput{
+					DeviceCode:              aws.String("device-code"),
+					UserCode:                aws.String("user-code"),
+					VerificationUri:         aws.String("verification-uri"),
+					VerificationUriComplete: aws.String("verification-uri-complete"),
+					ExpiresIn:               60,
+					Interval:                5,
+				},
+			},
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{
+					AccessToken: aws.String("new-access-token"),
+					ExpiresIn:   28800,
+				},
+			},
+		},
+	}
+
+	err = as.Reauthenticate("print", "fake-browser")
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access-token", as.Token.AccessToken)
+
+	token := storage.CreateTokenResponse{}
+	err = jstore.GetCreateTokenResponse(as.StoreKey(), &token)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access-token", token.AccessToken)
+
+	// role credentials are untouched
+	cachedCreds := storage.RoleCredentials{}
+	err = jstore.GetRoleCredentials(creds.RoleArn(), &cachedCreds)
+	assert.NoError(t, err)
+	assert.Equal(t, creds, cachedCreds)
+}
+
+func TestAuthenticateRefreshToken(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	defer os.Remove(tfile.Name())
+
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+	}
+
+	// expired token issued to our registered client
+	expires := time.Now().Add(time.Hour * 8).Unix()
+	err = jstore.SaveRegisterClientData(as.StoreKey(), storage.RegisterClientData{
+		ClientId:              "this-is-my-client-id",
+		ClientSecret:          "this-is-my-client-secret",
+		ClientSecretExpiresAt: expires,
+	})
+	assert.NoError(t, err)
+	err = jstore.SaveCreateTokenResponse(as.StoreKey(), storage.CreateTokenResponse{
+		AccessToken:  "old-access-token",
+		ExpiresAt:    time.Now().Add(-time.Minute).Unix(),
+		RefreshToken: "refresh-token",
+	})
+	assert.NoError(t, err)
+
+	// refresh without logging in and keep the RefreshToken if AWS doesn't rotate it
+	mock := &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{
+					AccessToken: aws.String("new-access-token"),
+					ExpiresIn:   28800,
+				},
+			},
+		},
+	}
+	as.ssooidc = mock
+	assert.NoError(t, as.Authenticate("print", "fake-browser"))
+	assert.Equal(t, "new-access-token", as.Token.AccessToken)
+	assert.Equal(t, "refresh-token", as.Token.RefreshToken)
+	assert.Equal(t, "refresh_token", aws.ToString(mock.LastCreateToken.GrantType))
+	assert.Equal(t, "refresh-token", aws.ToString(mock.LastCreateToken.RefreshToken))
+	assert.Equal(t, "this-is-my-client-id", aws.ToString(mock.LastCreateToken.ClientId))
+
+	token := storage.CreateTokenResponse{}
+	assert.NoError(t, jstore.GetCreateTokenResponse(as.StoreKey(), &token))
+	assert.Equal(t, "new-access-token", token.AccessToken)
+	assert.Empty(t, mock.Results)
+
+	// rejected refresh tokens fall back to logging in
+	token.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	assert.NoError(t, jstore.SaveCreateTokenResponse(as.StoreKey(), token))
+	mock = &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{},
+				Error:       &oidctypes.InvalidGrantException{},
+			},
+			{
+				StartDeviceAuthorization: &ssooidc.StartDeviceAuthorizationOutput{
+					DeviceCode:              aws.String("device-code"),
+					UserCode:                aws.String("user-code"),
+					VerificationUri:         aws.String("verification-uri"),
+					VerificationUriComplete: aws.String("verification-uri-complete"),
+					ExpiresIn:               60,
+					Interval:                5,
+				},
+			},
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{
+					AccessToken:  aws.String("login-access-token"),
+					ExpiresIn:    28800,
+					RefreshToken: aws.String("new-refresh-token"),
+				},
+			},
+		},
+	}
+	as.ssooidc = mock
+	assert.NoError(t, as.Authenticate("print", "fake-browser"))
+	assert.Equal(t, "login-access-token", as.Token.AccessToken)
+	assert.Equal(t, "new-refresh-token", as.Token.RefreshToken)
+	assert.Equal(t, awsSSOGrantType, aws.ToString(mock.LastCreateToken.GrantType))
+	assert.Empty(t, mock.Results)
+
+	// instances which don't issue refresh tokens always login
+	token = storage.CreateTokenResponse{
+		AccessToken: "login-access-token",
+		ExpiresAt:   time.Now().Add(-time.Minute).Unix(),
+	}
+	assert.NoError(t, jstore.SaveCreateTokenResponse(as.StoreKey(), token))
+	mock = &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				StartDeviceAuthorization: &ssooidc.StartDeviceAuthorizationOutput{
+					DeviceCode:              aws.String("device-code"),
+					UserCode:                aws.String("user-code"),
+					VerificationUri:         aws.String("verification-uri"),
+					VerificationUriComplete: aws.String("verification-uri-complete"),
+					ExpiresIn:               60,
+					Interval:                5,
+				},
+			},
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{
+					AccessToken: aws.String("another-access-token"),
+					ExpiresIn:   28800,
+				},
+			},
+		},
+	}
+	as.ssooidc = mock
+	assert.NoError(t, as.Authenticate("print", "fake-browser"))
+	assert.Equal(t, "another-access-token", as.Token.AccessToken)
+	assert.Equal(t, "", as.Token.RefreshToken)
+	assert.Empty(t, mock.Results)
+}
+
 func TestAuthenticateFailure(t *testing.T) {
 	tfile, err := ioutil.TempFile("", "*storage.json")
 	assert.NoError(t, err)
@@ -343,3 +548,363 @@ func TestAuthenticateFailure(t *testing.T) {
 	err = as.Authenticate("print", "fake-browser")
 	assert.Contains(t, err.Error(), "some error")
 }
+
+func TestCreateTokenTimeout(t *testing.T) {
+	as := &AWSSSO{
+		SsoRegion:    "us-west-1",
+		StartUrl:     "https://testing.awsapps.com/start",
+		LoginTimeout: 100 * time.Millisecond,
+		DeviceAuth: storage.StartDeviceAuthData{
+			DeviceCode: "device-code",
+			Interval:   1,
+		},
+	}
+
+	as.ssooidc = &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{},
+				Error:       &oidctypes.AuthorizationPendingException{},
+			},
+		},
+	}
+
+	start := time.Now()
+	err := as.createToken()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.Less(t, time.Since(start), 1*time.Second)
+}
+
+func TestCreateTokenDeadline(t *testing.T) {
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		DeviceAuth: storage.StartDeviceAuthData{
+			DeviceCode: "device-code",
+			Interval:   1,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	as.SetContext(ctx)
+
+	as.ssooidc = &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{},
+				Error:       &oidctypes.AuthorizationPendingException{},
+			},
+		},
+	}
+
+	start := time.Now()
+	err := as.createToken()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 1*time.Second)
+}
+
+func TestImportClientRegistration(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	defer os.Remove(tfile.Name())
+
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+		ssooidc: &mockSsoOidcApi{
+			Results: []mockSsoOidcApiResults{
+				{
+					RegisterClient: &ssooidc.RegisterClientOutput{
+						ClientId:              aws.String("new-client-id"),
+						ClientSecret:          aws.String("new-client-secret"),
+						ClientIdIssuedAt:      time.Now().Unix(),
+						ClientSecretExpiresAt: time.Now().Add(90 * 24 * time.Hour).Unix(),
+					},
+				},
+			},
+		},
+	}
+
+	_, err = as.GetClientRegistration()
+	assert.Error(t, err)
+
+	assert.Error(t, as.ImportClientRegistration(storage.RegisterClientData{
+		ClientSecretExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+	}))
+	assert.Error(t, as.ImportClientRegistration(storage.RegisterClientData{
+		ClientId:              "pinned-client-id",
+		ClientSecret:          "pinned-client-secret",
+		ClientSecretExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}))
+
+	pinned := storage.RegisterClientData{
+		ClientId:              "pinned-client-id",
+		ClientSecret:          "pinned-client-secret",
+		ClientSecretExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+	}
+	assert.NoError(t, as.ImportClientRegistration(pinned))
+
+	// pinned registration is used instead of registering a new client
+	assert.NoError(t, as.registerClient(false))
+	assert.Equal(t, "pinned-client-id", as.ClientData.ClientId)
+	client, err := as.GetClientRegistration()
+	assert.NoError(t, err)
+	assert.Equal(t, pinned, client)
+
+	// expired registrations are replaced
+	pinned.ClientSecretExpiresAt = time.Now().Unix()
+	assert.NoError(t, jstore.SaveRegisterClientData(as.StoreKey(), pinned))
+	_, err = as.GetClientRegistration()
+	assert.Error(t, err)
+	assert.NoError(t, as.registerClient(false))
+	assert.Equal(t, "new-client-id", as.ClientData.ClientId)
+}
+
+func TestValidAuthToken(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	defer os.Remove(tfile.Name())
+
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+	}
+	assert.False(t, as.ValidAuthToken())
+
+	err = jstore.SaveCreateTokenResponse(as.StoreKey(), storage.CreateTokenResponse{
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(-time.Minute).Unix(),
+	})
+	assert.NoError(t, err)
+	assert.False(t, as.ValidAuthToken())
+
+	err = jstore.SaveCreateTokenResponse(as.StoreKey(), storage.CreateTokenResponse{
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+	assert.True(t, as.ValidAuthToken())
+
+	// no buffer means the exact expiry
+	err = jstore.SaveCreateTokenResponse(as.StoreKey(), storage.CreateTokenResponse{
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(90 * time.Second).Unix(),
+	})
+	assert.NoError(t, err)
+	assert.True(t, as.ValidAuthToken())
+
+	as.TokenExpiryBuffer = 2 * time.Minute
+	assert.False(t, as.ValidAuthToken())
+}
+
+func TestRunPostLoginHook(t *testing.T) {
+	defer func(f func([]string, string, ...string) error) { hookRunner = f }(hookRunner)
+
+	var gotEnv []string
+	var gotName string
+	var gotArgs []string
+	hookRunner = func(env []string, name string, args ...string) error {
+		gotEnv = env
+		gotName = name
+		gotArgs = args
+		return nil
+	}
+
+	settings := &Settings{
+		PostLoginHook: []string{"/usr/bin/notify", "--login", "$(rm -rf /)"},
+	}
+	c := &SSOConfig{
+		SSORegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+	}
+	settings.SSO = map[string]*SSOConfig{"Testing": c}
+	c.Refresh(settings)
+
+	as := &AWSSSO{
+		SsoRegion: c.SSORegion,
+		StartUrl:  c.StartUrl,
+		SSOConfig: c,
+		Token: storage.CreateTokenResponse{
+			ExpiresAt: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC).Unix(),
+		},
+	}
+	assert.NoError(t, as.runPostLoginHook())
+	assert.Equal(t, "/usr/bin/notify", gotName)
+	assert.Equal(t, []string{"--login", "$(rm -rf /)"}, gotArgs)
+	assert.Contains(t, gotEnv, "AWS_SSO=Testing")
+	assert.Contains(t, gotEnv, "AWS_SSO_START_URL=https://testing.awsapps.com/start")
+	assert.Contains(t, gotEnv, "AWS_SSO_REGION=us-west-1")
+	assert.Contains(t, gotEnv, fmt.Sprintf("AWS_SSO_TOKEN_EXPIRATION=%s",
+		time.Unix(as.Token.ExpiresAt, 0).Format(AWS_SSO_SESSION_EXPIRATION_FORMAT)))
+
+	// errors are returned to the caller
+	hookRunner = func(env []string, name string, args ...string) error {
+		return fmt.Errorf("exit status 1")
+	}
+	assert.Error(t, as.runPostLoginHook())
+
+	// no hook
+	settings.PostLoginHook = []string{}
+	gotName = ""
+	assert.NoError(t, as.runPostLoginHook())
+	assert.Equal(t, "", gotName)
+}
+
+func TestValidateToken(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	defer os.Remove(tfile.Name())
+
+	expires := time.Now().Add(time.Hour * 8).Unix()
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+		urlAction: "print",
+		Token: storage.CreateTokenResponse{
+			AccessToken: "revoked-access-token",
+			ExpiresAt:   expires,
+		},
+	}
+	err = jstore.SaveCreateTokenResponse(as.StoreKey(), as.Token)
+	assert.NoError(t, err)
+
+	// token is still accepted
+	as.sso = &mockSsoApi{
+		Results: []mockSsoApiResults{
+			{ListAccounts: &sso.ListAccountsOutput{}},
+		},
+	}
+	assert.NoError(t, as.ValidateToken())
+	assert.Equal(t, "revoked-access-token", as.Token.AccessToken)
+
+	// other errors are returned as is
+	as.sso = &mockSsoApi{
+		Results: []mockSsoApiResults{
+			{Error: fmt.Errorf("network is down")},
+		},
+	}
+	err = as.ValidateToken()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "network is down")
+
+	// revoked token is removed from the cache and we login again
+	as.sso = &mockSsoApi{
+		Results: []mockSsoApiResults{
+			{Error: &ssotypes.UnauthorizedException{Message: aws.String("Session token not found or invalid")}},
+		},
+	}
+	as.ssooidc = &mockSsoOidcApi{
+		Results: []mockSsoOidcApiResults{
+			{
+				RegisterClient: &ssooidc.RegisterClientOutput{
+					ClientId:              aws.String("this-is-my-client-id"),
+					ClientSecret:          aws.String("this-is-my-client-secret"),
+					ClientIdIssuedAt:      time.Now().Unix(),
+					ClientSecretExpiresAt: expires,
+				},
+			},
+			{
+				StartDeviceAuthorization: &ssooidc.StartDeviceAuthorizationOutput{
+					DeviceCode:              aws.String("device-code"),
+					UserCode:                aws.String("user-code"),
+					VerificationUri:         aws.String("verification-uri"),
+					VerificationUriComplete: aws.String("verification-uri-complete"),
+					ExpiresIn:               60,
+					Interval:                5,
+				},
+			},
+			{
+				CreateToken: &ssooidc.CreateTokenOutput{
+					AccessToken: aws.String("new-access-token"),
+					ExpiresIn:   28800,
+				},
+			},
+		},
+	}
+	assert.NoError(t, as.ValidateToken())
+	assert.Equal(t, "new-access-token", as.Token.AccessToken)
+
+	token := storage.CreateTokenResponse{}
+	err = jstore.GetCreateTokenResponse(as.StoreKey(), &token)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access-token", token.AccessToken)
+
+	assert.True(t, IsUnauthorizedError(&ssotypes.UnauthorizedException{}))
+	assert.False(t, IsUnauthorizedError(fmt.Errorf("foo")))
+}
+
+func TestLogout(t *testing.T) {
+	tfile, err := ioutil.TempFile("", "*storage.json")
+	assert.NoError(t, err)
+
+	jstore, err := storage.OpenJsonStore(tfile.Name())
+	assert.NoError(t, err)
+
+	defer os.Remove(tfile.Name())
+
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     jstore,
+	}
+	token := storage.CreateTokenResponse{
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(time.Hour * 8).Unix(),
+	}
+
+	// no cached token
+	as.sso = &mockSsoApi{}
+	loggedOut, err := as.Logout()
+	assert.NoError(t, err)
+	assert.False(t, loggedOut)
+
+	// server-side logout
+	assert.NoError(t, jstore.SaveCreateTokenResponse(as.StoreKey(), token))
+	as.sso = &mockSsoApi{
+		Results: []mockSsoApiResults{
+			{Logout: &sso.LogoutOutput{}},
+		},
+	}
+	loggedOut, err = as.Logout()
+	assert.NoError(t, err)
+	assert.True(t, loggedOut)
+	assert.Error(t, jstore.GetCreateTokenResponse(as.StoreKey(), &storage.CreateTokenResponse{}))
+
+	// server-side failure still clears the cache
+	assert.NoError(t, jstore.SaveCreateTokenResponse(as.StoreKey(), token))
+	as.sso = &mockSsoApi{
+		Results: []mockSsoApiResults{
+			{Error: fmt.Errorf("network is down")},
+		},
+	}
+	loggedOut, err = as.Logout()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "network is down")
+	assert.False(t, loggedOut)
+	assert.Error(t, jstore.GetCreateTokenResponse(as.StoreKey(), &storage.CreateTokenResponse{}))
+
+	// expired tokens are only cleared locally
+	token.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	assert.NoError(t, jstore.SaveCreateTokenResponse(as.StoreKey(), token))
+	as.sso = &mockSsoApi{}
+	loggedOut, err = as.Logout()
+	assert.NoError(t, err)
+	assert.False(t, loggedOut)
+	assert.Error(t, jstore.GetCreateTokenResponse(as.StoreKey(), &storage.CreateTokenResponse{}))
+}
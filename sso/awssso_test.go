@@ -20,9 +20,14 @@ package sso
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,6 +36,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
 )
 
 // mock sso
@@ -42,6 +48,7 @@ type mockSsoApiResults struct {
 	ListAccountRoles   *sso.ListAccountRolesOutput
 	ListAccounts       *sso.ListAccountsOutput
 	GetRoleCredentials *sso.GetRoleCredentialsOutput
+	Logout             *sso.LogoutOutput
 	Error              error
 }
 
@@ -72,6 +79,15 @@ func (m *mockSsoApi) GetRoleCredentials(ctx context.Context, params *sso.GetRole
 	return x.GetRoleCredentials, x.Error
 }
 
+func (m *mockSsoApi) Logout(ctx context.Context, params *sso.LogoutInput, optFns ...func(*sso.Options)) (*sso.LogoutOutput, error) {
+	var x mockSsoApiResults
+	if len(m.Results) == 0 {
+		return &sso.LogoutOutput{}, fmt.Errorf("calling mocked Logout too many times")
+	}
+	x, m.Results = m.Results[0], m.Results[1:]
+	return x.Logout, x.Error
+}
+
 func TestGetRoles(t *testing.T) {
 	tfile, err := ioutil.TempFile("", "*storage.json")
 	assert.NoError(t, err)
@@ -369,3 +385,167 @@ func TestGetRoleCredentials(t *testing.T) {
 	_, err = as.GetRoleCredentials(int64(000001111111), "FooBar")
 	assert.Error(t, err)
 }
+
+// mockStsTransport answers sts:AssumeRole with credentials named after the
+// role and remembers which AccessKeyId signed each request
+type mockStsTransport struct {
+	lock   sync.Mutex
+	signer map[string]string // RoleArn => AccessKeyId used to assume it
+	denied string            // RoleArn to refuse
+}
+
+func (m *mockStsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	roleArn := form.Get("RoleArn")
+	_, roleName, _ := utils.ParseRoleARN(roleArn)
+
+	auth := req.Header.Get("Authorization")
+	keyId := auth[strings.Index(auth, "Credential=")+len("Credential="):]
+	keyId = keyId[:strings.Index(keyId, "/")]
+	m.lock.Lock()
+	m.signer[roleArn] = keyId
+	m.lock.Unlock()
+
+	status := http.StatusOK
+	resp := fmt.Sprintf(`<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>AKID-%s</AccessKeyId>
+      <SecretAccessKey>secret-%s</SecretAccessKey>
+      <SessionToken>token-%s</SessionToken>
+      <Expiration>2040-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>request-id</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`, roleName, roleName, roleName)
+	if roleArn == m.denied {
+		status = http.StatusForbidden
+		resp = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error>
+  <RequestId>request-id</RequestId>
+</ErrorResponse>`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       ioutil.NopCloser(strings.NewReader(resp)),
+		Request:    req,
+	}, nil
+}
+
+func TestGetRoleCredentialsChain(t *testing.T) {
+	os.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	defer os.Unsetenv("AWS_CONFIG_FILE")
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+	if bundle, ok := os.LookupEnv("AWS_CA_BUNDLE"); ok {
+		// our mock transport can't load a custom CA bundle
+		os.Unsetenv("AWS_CA_BUNDLE")
+		defer os.Setenv("AWS_CA_BUNDLE", bundle)
+	}
+
+	const (
+		baseArn  = "arn:aws:iam::000000000001:role/Base"
+		hopArn   = "arn:aws:iam::000000000002:role/Hop"
+		finalArn = "arn:aws:iam::000000000003:role/Final"
+	)
+	transport := &mockStsTransport{signer: map[string]string{}}
+	settings := &Settings{httpClient: &http.Client{Transport: transport}}
+	ssoConfig := &SSOConfig{
+		settings:  settings,
+		SSORegion: "us-east-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		Accounts: map[string]*SSOAccount{
+			"000000000001": {Roles: map[string]*SSORole{
+				"Base": {ARN: baseArn, Tags: map[string]string{"Foo": "Bar"}},
+			}},
+			"000000000002": {Roles: map[string]*SSORole{
+				"Hop": {ARN: hopArn, Via: baseArn},
+			}},
+			"000000000003": {Roles: map[string]*SSORole{
+				"Final": {ARN: finalArn, Via: hopArn},
+			}},
+		},
+	}
+
+	as := &AWSSSO{
+		SsoRegion: "us-east-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		SSOConfig: ssoConfig,
+		Token: storage.CreateTokenResponse{
+			AccessToken: "access-token",
+			ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+		},
+		sso: &mockSsoApi{
+			Results: []mockSsoApiResults{
+				{
+					GetRoleCredentials: &sso.GetRoleCredentialsOutput{
+						RoleCredentials: &types.RoleCredentials{
+							AccessKeyId:     aws.String("AKID-Base"),
+							SecretAccessKey: aws.String("secret-Base"),
+							SessionToken:    aws.String("token-Base"),
+							Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	creds, err := as.GetRoleCredentials(3, "Final")
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID-Final", creds.AccessKeyId)
+	assert.Equal(t, int64(3), creds.AccountId)
+	assert.Equal(t, "Final", creds.RoleName)
+	// each hop uses the credentials of the previous hop
+	assert.Equal(t, "AKID-Base", transport.signer[hopArn])
+	assert.Equal(t, "AKID-Hop", transport.signer[finalArn])
+
+	// console URL is built from the terminal credentials of the chain
+	signin := NewSigninTokenUrlParams("https://signin.aws.amazon.com/federation", &creds, 3600)
+	u, err := url.Parse(signin.GetUrl())
+	assert.NoError(t, err)
+	session := SessionUrlParams{}
+	assert.NoError(t, json.Unmarshal([]byte(u.Query().Get("Session")), &session))
+	assert.Equal(t, SessionUrlParams{
+		AccessKeyId:     "AKID-Final",
+		SecretAccessKey: "secret-Final",
+		SessionToken:    "token-Final",
+	}, session)
+	assert.Equal(t, "3600", u.Query().Get("SessionDuration"))
+
+	// a failed hop is reported instead of returning partial credentials
+	transport.denied = hopArn
+	as.sso = &mockSsoApi{
+		Results: []mockSsoApiResults{
+			{
+				GetRoleCredentials: &sso.GetRoleCredentialsOutput{
+					RoleCredentials: &types.RoleCredentials{
+						AccessKeyId:     aws.String("AKID-Base"),
+						SecretAccessKey: aws.String("secret-Base"),
+						SessionToken:    aws.String("token-Base"),
+						Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+					},
+				},
+			},
+		},
+	}
+	creds, err = as.GetRoleCredentials(3, "Final")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), hopArn)
+	assert.True(t, IsAccessDeniedError(err))
+	assert.Empty(t, creds.AccessKeyId)
+
+	// loops are detected
+	ssoConfig.Accounts["000000000001"].Roles["Base"].Via = finalArn
+	_, err = as.GetRoleCredentials(3, "Final")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "loop")
+}
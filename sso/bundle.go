@@ -0,0 +1,207 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+const BUNDLE_VERSION = 1
+
+// Bundle is the contents of an `aws-sso export` file used to migrate our
+// config and cache to another machine
+type Bundle struct {
+	Version int                      `json:"Version"`
+	Created int64                    `json:"Created"`
+	Config  string                   `json:"Config"`
+	Cache   string                   `json:"Cache,omitempty"`
+	Secrets map[string]BundleSecrets `json:"Secrets,omitempty"` // key is the SecureStore key
+}
+
+// BundleSecrets are the long lived AWS SSO secrets for an SSO instance.
+// STS credentials are short lived and not exported.
+type BundleSecrets struct {
+	ClientData *storage.RegisterClientData  `json:"ClientData,omitempty"`
+	Token      *storage.CreateTokenResponse `json:"Token,omitempty"`
+}
+
+// NewBundle creates a Bundle from our config and cache files and optionally
+// the AWS SSO secrets in the SecureStore
+func NewBundle(s *Settings, store storage.SecureStorage, includeSecrets bool) (*Bundle, error) {
+	b := Bundle{
+		Version: BUNDLE_VERSION,
+		Created: time.Now().Unix(),
+		Secrets: map[string]BundleSecrets{},
+	}
+
+	config, err := s.ReadConfig()
+	if err != nil {
+		return &b, fmt.Errorf("Unable to read %s: %s", s.ConfigFile(), err.Error())
+	}
+	b.Config = string(config)
+
+	// the cache is optional since it can be rebuilt
+	if cache, err := ioutil.ReadFile(s.cacheFile); err == nil {
+		b.Cache = string(cache)
+	}
+
+	if !includeSecrets {
+		return &b, nil
+	}
+
+	names := []string{}
+	for name := range s.SSO {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := NewAWSSSO(s.SSO[name], &store).StoreKey()
+		secrets := BundleSecrets{}
+
+		client := storage.RegisterClientData{}
+		if err := store.GetRegisterClientData(key, &client); err == nil {
+			secrets.ClientData = &client
+		}
+		token := storage.CreateTokenResponse{}
+		if err := store.GetCreateTokenResponse(key, &token); err == nil {
+			secrets.Token = &token
+		}
+		if secrets.ClientData != nil || secrets.Token != nil {
+			b.Secrets[key] = secrets
+		}
+	}
+	return &b, nil
+}
+
+// Encrypt returns the Bundle encrypted with the passphrase
+func (b *Bundle) Encrypt(passphrase string) ([]byte, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return []byte{}, err
+	}
+	return utils.EncryptWithPassphrase(data, passphrase)
+}
+
+// OpenBundle decrypts a Bundle created by Encrypt
+func OpenBundle(data []byte, passphrase string) (*Bundle, error) {
+	b := Bundle{}
+	plain, err := utils.DecryptWithPassphrase(data, passphrase)
+	if err != nil {
+		return &b, err
+	}
+	if err = json.Unmarshal(plain, &b); err != nil {
+		return &b, fmt.Errorf("Invalid bundle: %s", err.Error())
+	}
+	if b.Version > BUNDLE_VERSION {
+		return &b, fmt.Errorf("Bundle was created by a newer version of aws-sso")
+	}
+	return &b, nil
+}
+
+// Validate loads the config and cache in the Bundle the same way we load
+// our own and checks each AWS SSO instance
+func (b *Bundle) Validate(defaults map[string]interface{}) error {
+	dir, err := ioutil.TempDir("", "aws-sso-import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(configFile, []byte(b.Config), 0600); err != nil {
+		return err
+	}
+	cacheFile := ""
+	if b.Cache != "" {
+		cacheFile = filepath.Join(dir, "cache.json")
+		if err = ioutil.WriteFile(cacheFile, []byte(b.Cache), 0600); err != nil {
+			return err
+		}
+	}
+
+	s, err := LoadSettings(configFile, cacheFile, defaults, OverrideSettings{})
+	if err != nil {
+		return fmt.Errorf("Invalid config: %s", err.Error())
+	}
+
+	for name, sso := range s.SSO {
+		if _, err := ValidateStartUrl(sso.StartUrl); err != nil {
+			return fmt.Errorf("Invalid config for %s: %s", name, err.Error())
+		}
+		if sso.SSORegion == "" {
+			return fmt.Errorf("Invalid config for %s: missing SSORegion", name)
+		}
+	}
+	return nil
+}
+
+// Apply writes the config and cache in the Bundle to the given files.  Any
+// existing config file is first saved with a .bak extension.
+func (b *Bundle) Apply(configFile, cacheFile string) error {
+	if err := utils.EnsureDirExists(configFile); err != nil {
+		return fmt.Errorf("Unable to create directory for %s: %s", configFile, err.Error())
+	}
+
+	if _, err := os.Stat(configFile); err == nil {
+		if err = os.Rename(configFile, configFile+".bak"); err != nil {
+			return fmt.Errorf("Unable to backup %s: %s", configFile, err.Error())
+		}
+	}
+	if err := ioutil.WriteFile(configFile, []byte(b.Config), 0600); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", configFile, err.Error())
+	}
+
+	if b.Cache == "" {
+		return nil
+	}
+	if err := utils.EnsureDirExists(cacheFile); err != nil {
+		return fmt.Errorf("Unable to create directory for %s: %s", cacheFile, err.Error())
+	}
+	if err := ioutil.WriteFile(cacheFile, []byte(b.Cache), 0600); err != nil {
+		return fmt.Errorf("Unable to write %s: %s", cacheFile, err.Error())
+	}
+	return nil
+}
+
+// ApplySecrets saves the AWS SSO secrets in the Bundle to the SecureStore
+func (b *Bundle) ApplySecrets(store storage.SecureStorage) error {
+	for key, secrets := range b.Secrets {
+		if secrets.ClientData != nil {
+			if err := store.SaveRegisterClientData(key, *secrets.ClientData); err != nil {
+				return fmt.Errorf("Unable to save client data: %s", err.Error())
+			}
+		}
+		if secrets.Token != nil {
+			if err := store.SaveCreateTokenResponse(key, *secrets.Token); err != nil {
+				return fmt.Errorf("Unable to save token: %s", err.Error())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+func TestBundle(t *testing.T) {
+	s, err := LoadSettings(TEST_SETTINGS_FILE, TEST_CACHE_FILE, map[string]interface{}{}, OverrideSettings{})
+	assert.NoError(t, err)
+
+	var store storage.SecureStorage = storage.NewMemoryStore()
+	key := NewAWSSSO(s.SSO["Default"], &store).StoreKey()
+	assert.NoError(t, store.SaveCreateTokenResponse(key, storage.CreateTokenResponse{AccessToken: "secret"}))
+
+	// secrets are only included when requested
+	b, err := NewBundle(s, store, false)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b.Config)
+	assert.NotEmpty(t, b.Cache)
+	assert.Empty(t, b.Secrets)
+
+	b, err = NewBundle(s, store, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", b.Secrets[key].Token.AccessToken)
+	assert.Nil(t, b.Secrets[key].ClientData)
+
+	enc, err := b.Encrypt("passphrase")
+	assert.NoError(t, err)
+	_, err = OpenBundle(enc, "wrong")
+	assert.Error(t, err)
+	b2, err := OpenBundle(enc, "passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, b, b2)
+	assert.NoError(t, b2.Validate(map[string]interface{}{}))
+
+	dir, err := ioutil.TempDir("", "bundle-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yaml")
+	cacheFile := filepath.Join(dir, "cache.json")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte("old"), 0600))
+
+	assert.NoError(t, b2.Apply(configFile, cacheFile))
+	config, _ := ioutil.ReadFile(configFile)
+	assert.Equal(t, b.Config, string(config))
+	backup, _ := ioutil.ReadFile(configFile + ".bak")
+	assert.Equal(t, "old", string(backup))
+	cache, _ := ioutil.ReadFile(cacheFile)
+	assert.Equal(t, b.Cache, string(cache))
+
+	newStore := storage.NewMemoryStore()
+	assert.NoError(t, b2.ApplySecrets(newStore))
+	token := storage.CreateTokenResponse{}
+	assert.NoError(t, newStore.GetCreateTokenResponse(key, &token))
+	assert.Equal(t, "secret", token.AccessToken)
+
+	// invalid configs are rejected
+	b2.Config = "SSOConfig:\n  Default:\n    StartUrl: https://example.com\n"
+	assert.Error(t, b2.Validate(map[string]interface{}{}))
+	b2.Config = "not: [valid"
+	assert.Error(t, b2.Validate(map[string]interface{}{}))
+}
@@ -20,6 +20,7 @@ package sso
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"strconv"
@@ -33,11 +34,102 @@ import (
 
 const CACHE_VERSION = 3
 
+// how often (in seconds) we record a role being used.  credential_process can
+// ask for credentials many times a minute and we don't want to rewrite the
+// cache file every time.
+const LAST_USED_INTERVAL = 60
+
+// ErrCacheTooNew is returned when the cache file was written by a newer
+// version of aws-sso which we do not know how to read
+var ErrCacheTooNew = errors.New("cache file was created by a newer version of aws-sso")
+
+// cacheMigration upgrades the raw cache file from the given version to the next
+type cacheMigration func(raw map[string]interface{}, s *Settings) error
+
+var cacheMigrations = map[int64]cacheMigration{
+	1: migrateCacheV1, // cache files without a version
+	2: migrateCacheV2,
+}
+
+// migrateCacheV1 is a no-op since cache files without a version use the same
+// format as version 2
+func migrateCacheV1(raw map[string]interface{}, s *Settings) error {
+	return nil
+}
+
+// migrateCacheV2 moves the data for our single AWS SSO instance into the
+// per-SSO map used by version 3.  The roles will be refreshed on next use.
+func migrateCacheV2(raw map[string]interface{}, s *Settings) error {
+	ssoCache := map[string]interface{}{}
+	for _, key := range []string{"History", "Roles"} {
+		if v, ok := raw[key]; ok {
+			ssoCache[key] = v
+			delete(raw, key)
+		}
+	}
+	delete(raw, "LastUpdate")
+	ssoCache["LastUpdate"] = 0 // force a refresh
+
+	ssoMap, ok := raw["SSO"].(map[string]interface{})
+	if !ok {
+		ssoMap = map[string]interface{}{}
+	}
+	if _, ok := ssoMap[s.DefaultSSO]; !ok {
+		ssoMap[s.DefaultSSO] = ssoCache
+	}
+	raw["SSO"] = ssoMap
+	return nil
+}
+
+// migrateCache upgrades the cache file contents to CACHE_VERSION, saving a
+// backup of the original file first.  Refuses to downgrade the cache.
+func migrateCache(f string, cacheBytes []byte, s *Settings) ([]byte, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(cacheBytes, &raw); err != nil {
+		return cacheBytes, nil // let the caller report the error
+	}
+
+	version := int64(1) // cache files without a version
+	if v, ok := raw["Version"].(float64); ok {
+		version = int64(v)
+	}
+
+	if version == CACHE_VERSION {
+		return cacheBytes, nil
+	} else if version > CACHE_VERSION {
+		return cacheBytes, fmt.Errorf("%w: %s is version %d, but we only support version %d.  "+
+			"Please upgrade aws-sso or delete the cache file", ErrCacheTooNew, f, version, CACHE_VERSION)
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", f, version)
+	if err := ioutil.WriteFile(backup, cacheBytes, 0600); err != nil {
+		return cacheBytes, fmt.Errorf("Unable to backup cache file before migrating: %s", err.Error())
+	}
+
+	for v := version; v < CACHE_VERSION; v++ {
+		migrate, ok := cacheMigrations[v]
+		if !ok {
+			return cacheBytes, fmt.Errorf("Unable to migrate cache file %s from version %d", f, v)
+		}
+		if err := migrate(raw, s); err != nil {
+			return cacheBytes, fmt.Errorf("Unable to migrate cache file %s from version %d: %s", f, v, err.Error())
+		}
+	}
+	raw["Version"] = CACHE_VERSION
+
+	log.Infof("Migrated cache file %s from version %d to %d.  Backup saved as %s",
+		f, version, CACHE_VERSION, backup)
+	return json.Marshal(raw)
+}
+
 type SSOCache struct {
-	LastUpdate int64    `json:"LastUpdate,omitempty"` // when these records for this SSO were updated
-	History    []string `json:"History,omitempty"`
-	Roles      *Roles   `json:"Roles,omitempty"`
-	name       string   // name of this SSO Instance
+	LastUpdate     int64              `json:"LastUpdate,omitempty"`     // when these records for this SSO were updated
+	TagsFileUpdate int64              `json:"TagsFileUpdate,omitempty"` // modification time of the TagsFile last applied
+	History        []string           `json:"History,omitempty"`
+	Roles          *Roles             `json:"Roles,omitempty"`
+	Snapshots      []RoleSnapshot     `json:"Snapshots,omitempty"` // roles we had access to over time
+	Usage          map[string][]int64 `json:"Usage,omitempty"`     // when each role ARN was used
+	name           string             // name of this SSO Instance
 }
 
 // Our Cachefile.  Sub-structs defined in sso/cache.go
@@ -65,6 +157,9 @@ func OpenCache(f string, s *Settings) (*Cache, error) {
 		if err != nil {
 			return &cache, err // return empty struct
 		}
+		if cacheBytes, err = migrateCache(f, cacheBytes, s); err != nil {
+			return &cache, err // return empty struct
+		}
 		err = json.Unmarshal(cacheBytes, &cache)
 	}
 
@@ -234,14 +329,22 @@ func (c *Cache) deleteOldHistory() {
 // Refresh updates our cached Roles based on AWS SSO & our Config
 // but does not save this data!
 func (c *Cache) Refresh(sso *AWSSSO, config *SSOConfig, ssoName string) error {
-	// save role creds expires time
+	// save role creds expires & last used time
 	expires := map[string]int64{}
+	lastUsed := map[string]int64{}
+	refreshed := map[string]int64{}
 	cache := c.GetSSO()
 	for _, account := range cache.Roles.Accounts {
 		for _, role := range account.Roles {
 			if role.Expires > 0 {
 				expires[role.Arn] = role.Expires
 			}
+			if role.LastUsed > 0 {
+				lastUsed[role.Arn] = role.LastUsed
+			}
+			if role.Refreshed > 0 {
+				refreshed[role.Arn] = role.Refreshed
+			}
 		}
 	}
 
@@ -267,7 +370,7 @@ func (c *Cache) Refresh(sso *AWSSSO, config *SSOConfig, ssoName string) error {
 	}
 	c.SSO[ssoName].Roles = r
 
-	// restore our history tags & expires
+	// restore our history tags, expires & last used
 	for _, account := range c.SSO[ssoName].Roles.Accounts {
 		for _, role := range account.Roles {
 			if value, ok := historyTags[role.Arn]; ok {
@@ -276,13 +379,24 @@ func (c *Cache) Refresh(sso *AWSSSO, config *SSOConfig, ssoName string) error {
 			if value, ok := expires[role.Arn]; ok {
 				role.Expires = value
 			}
+			if value, ok := lastUsed[role.Arn]; ok {
+				role.LastUsed = value
+			}
+			if value, ok := refreshed[role.Arn]; ok {
+				role.Refreshed = value
+			}
 		}
 	}
 	c.ConfigCreatedAt = config.CreatedAt()
-	return nil
+	c.addSnapshot(time.Now().Unix())
+
+	// external tags are merged last so they always apply to the new roles
+	_, err = c.ApplyTagsFile(true)
+	return err
 }
 
-// Update the Expires time in the cache.  expires is Unix epoch time in sec
+// Update the Expires time in the cache.  expires is Unix epoch time in sec.
+// Non-zero values also record the current time as when the role was refreshed.
 func (c *Cache) SetRoleExpires(arn string, expires int64) error {
 	flat, err := c.GetRole(arn)
 	if err != nil {
@@ -290,7 +404,29 @@ func (c *Cache) SetRoleExpires(arn string, expires int64) error {
 	}
 
 	cache := c.GetSSO()
-	cache.Roles.Accounts[flat.AccountId].Roles[flat.RoleName].Expires = expires
+	role := cache.Roles.Accounts[flat.AccountId].Roles[flat.RoleName]
+	role.Expires = expires
+	if expires > 0 {
+		role.Refreshed = time.Now().Unix()
+	}
+	return c.Save(false)
+}
+
+// Update the LastUsed time in the cache.  lastUsed is Unix epoch time in sec.
+// Uses within LAST_USED_INTERVAL of the previous one are not saved.
+func (c *Cache) SetRoleLastUsed(arn string, lastUsed int64) error {
+	flat, err := c.GetRole(arn)
+	if err != nil {
+		return err
+	}
+
+	if lastUsed >= flat.LastUsed && lastUsed-flat.LastUsed < LAST_USED_INTERVAL {
+		return nil
+	}
+
+	cache := c.GetSSO()
+	cache.Roles.Accounts[flat.AccountId].Roles[flat.RoleName].LastUsed = lastUsed
+	c.addUsage(arn, lastUsed)
 	return c.Save(false)
 }
 
@@ -306,12 +442,10 @@ func (c *Cache) MarkRolesExpired() error {
 
 // returns all tags, but with with spaces replaced with underscores
 func (c *Cache) GetAllTagsSelect() *TagsList {
-	cache := c.GetSSO()
-	tags := cache.Roles.GetAllTags()
 	fixedTags := NewTagsList()
-	for k, values := range *tags {
-		key := strings.ReplaceAll(k, " ", "_")
-		for _, v := range values {
+	for _, role := range c.selectRoles() {
+		for k, v := range role.Tags {
+			key := strings.ReplaceAll(k, " ", "_")
 			if key == "History" {
 				v = reformatHistory(v)
 			}
@@ -325,9 +459,7 @@ func (c *Cache) GetAllTagsSelect() *TagsList {
 // replaced with underscores
 func (c *Cache) GetRoleTagsSelect() *RoleTags {
 	ret := RoleTags{}
-	cache := c.GetSSO()
-	fList := cache.Roles.GetAllRoles()
-	for _, role := range fList {
+	for _, role := range c.selectRoles() {
 		ret[role.Arn] = map[string]string{}
 		for k, v := range role.Tags {
 			key := strings.ReplaceAll(k, " ", "_")
@@ -341,6 +473,19 @@ func (c *Cache) GetRoleTagsSelect() *RoleTags {
 	return &ret
 }
 
+// selectRoles returns the roles available for interactive selection which
+// excludes roles disabled in the config unless --show-disabled
+func (c *Cache) selectRoles() []*AWSRoleFlat {
+	roles := []*AWSRoleFlat{}
+	for _, role := range c.GetSSO().Roles.GetAllRoles() {
+		if role.Disabled && (c.settings == nil || !c.settings.ShowDisabled()) {
+			continue
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
+
 // GetRole returns the AWSRoleFlat for the given role ARN
 func (c *Cache) GetRole(arn string) (*AWSRoleFlat, error) {
 	accountId, roleName, err := utils.ParseRoleARN(arn)
@@ -386,7 +531,21 @@ func (c *Cache) addSSORoles(r *Roles, as *AWSSSO) error {
 		return fmt.Errorf("Unable to get AWS SSO accounts: %s", err.Error())
 	}
 
+	allowed := []AccountInfo{}
 	for _, aInfo := range accounts {
+		if !c.settings.AccountAllowed(aInfo.GetAccountId64(), aInfo.AccountName) {
+			log.Debugf("Skipping AWS Account %s: not in AccountsAllowlist", aInfo.AccountId)
+			continue
+		}
+		allowed = append(allowed, aInfo)
+	}
+
+	allRoles, err := as.GetAllRoles(allowed, c.settings.MaxConcurrency)
+	if err != nil {
+		return fmt.Errorf("Unable to get AWS SSO roles: %s", err.Error())
+	}
+
+	for _, aInfo := range allowed {
 		accountId := aInfo.GetAccountId64()
 		r.Accounts[accountId] = &AWSAccount{
 			Alias:        aInfo.AccountName, // AWS SSO calls it `AccountName`
@@ -395,11 +554,7 @@ func (c *Cache) addSSORoles(r *Roles, as *AWSSSO) error {
 			Roles:        map[string]*AWSRole{},
 		}
 
-		roles, err := as.GetRoles(aInfo)
-		if err != nil {
-			return fmt.Errorf("Unable to get AWS SSO roles: %s", err.Error())
-		}
-		for _, role := range roles {
+		for _, role := range allRoles[aInfo.AccountId] {
 			r.Accounts[accountId].Roles[role.RoleName] = &AWSRole{
 				Arn: utils.MakeRoleARN(accountId, role.RoleName),
 				Tags: map[string]string{
@@ -427,6 +582,14 @@ func (c *Cache) addSSORoles(r *Roles, as *AWSSSO) error {
 
 // addConfigRoles decorates the provided Roles with the contents of our config
 func (c *Cache) addConfigRoles(r *Roles, config *SSOConfig) error {
+	// friendly account names from AccountNames/AccountNamesFile.  The name
+	// from AWS SSO is still available as the AccountAlias
+	for id, account := range r.Accounts {
+		if name, ok := c.settings.AccountName(id); ok {
+			account.Name = name
+		}
+	}
+
 	// The load all the Config file stuff.  Normally this is just adding markup, but
 	// for accounts &roles that are not in SSO, we may be creating them as well!
 	for accountId, account := range config.Accounts {
@@ -441,7 +604,9 @@ func (c *Cache) addConfigRoles(r *Roles, config *SSOConfig) error {
 			}
 		}
 		r.Accounts[id].DefaultRegion = account.DefaultRegion
-		r.Accounts[id].Name = account.Name
+		if account.Name != "" {
+			r.Accounts[id].Name = account.Name
+		}
 
 		// set our account tags
 		for k, v := range config.Accounts[accountId].Tags {
@@ -461,6 +626,9 @@ func (c *Cache) addConfigRoles(r *Roles, config *SSOConfig) error {
 			if r.Accounts[id].Roles[roleName].DefaultRegion != "" {
 				r.Accounts[id].Roles[roleName].Tags["DefaultRegion"] = r.Accounts[id].Roles[roleName].DefaultRegion
 			}
+			if enabled := config.Accounts[accountId].Enabled; enabled != nil {
+				r.Accounts[id].Roles[roleName].Disabled = !*enabled
+			}
 		}
 
 		// set the tags from the config file
@@ -474,9 +642,20 @@ func (c *Cache) addConfigRoles(r *Roles, config *SSOConfig) error {
 			r.Accounts[id].Roles[roleName].Profile = role.Profile
 			r.Accounts[id].Roles[roleName].DefaultRegion = r.Accounts[id].DefaultRegion
 			r.Accounts[id].Roles[roleName].Via = role.Via
+			r.Accounts[id].Roles[roleName].Description = role.Description
+			if role.Description != "" {
+				// make the description searchable in the interactive prompt
+				r.Accounts[id].Roles[roleName].Tags["Description"] = role.Description
+			}
 			if role.DefaultRegion != "" {
 				r.Accounts[id].Roles[roleName].DefaultRegion = role.DefaultRegion
 			}
+			// roles inherit the account setting unless set explicitly
+			if role.Enabled != nil {
+				r.Accounts[id].Roles[roleName].Disabled = !*role.Enabled
+			} else if enabled := config.Accounts[accountId].Enabled; enabled != nil {
+				r.Accounts[id].Roles[roleName].Disabled = !*enabled
+			}
 			// Copy the account tags to the role
 			for k, v := range config.Accounts[accountId].Tags {
 				r.Accounts[id].Roles[roleName].Tags[k] = v
@@ -23,10 +23,12 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	goyaml "github.com/goccy/go-yaml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"github.com/synfinatic/aws-sso-cli/utils"
 )
 
 const (
@@ -212,6 +214,19 @@ func (suite *CacheTestSuite) TestIsExpired() {
 
 	r, _ := suite.cache.GetRole(TEST_ROLE_ARN)
 	assert.True(t, r.IsExpired())
+	assert.False(t, r.IsValid())
+
+	// expiring within MinValidRemaining is not expired, but not valid either
+	defer utils.SetMinValidRemaining(utils.MinValidRemaining())
+	utils.SetMinValidRemaining(5 * time.Minute)
+	r2 := *r
+	r2.Expires = time.Now().Add(2 * time.Minute).Unix()
+	assert.False(t, r2.IsExpired())
+	assert.False(t, r2.IsValid())
+
+	r2.Expires = time.Now().Add(time.Hour).Unix()
+	assert.False(t, r2.IsExpired())
+	assert.True(t, r2.IsValid())
 }
 
 func (suite *CacheTestSuite) BadRole() {
@@ -274,11 +289,155 @@ func (suite *CacheTestSuite) TestSetRoleExpires() {
 	flat, err := suite.cache.GetRole(TEST_ROLE_ARN)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(12344553243), flat.Expires)
+	assert.InDelta(t, time.Now().Unix(), flat.Refreshed, 5)
 
 	err = suite.cache.SetRoleExpires(INVALID_ROLE_ARN, 12344553243)
 	assert.Error(t, err)
 }
 
+func (suite *CacheTestSuite) TestSetRoleLastUsed() {
+	t := suite.T()
+	err := suite.cache.SetRoleLastUsed(TEST_ROLE_ARN, 12344553243)
+	assert.NoError(t, err)
+
+	flat, err := suite.cache.GetRole(TEST_ROLE_ARN)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12344553243), flat.LastUsed)
+
+	// persists across invocations
+	c, err := OpenCache(suite.cache.CacheFile(), suite.cache.settings)
+	assert.NoError(t, err)
+	flat, err = c.GetRole(TEST_ROLE_ARN)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12344553243), flat.LastUsed)
+
+	// uses within LAST_USED_INTERVAL are not recorded
+	err = suite.cache.SetRoleLastUsed(TEST_ROLE_ARN, 12344553243+LAST_USED_INTERVAL-1)
+	assert.NoError(t, err)
+	flat, err = suite.cache.GetRole(TEST_ROLE_ARN)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12344553243), flat.LastUsed)
+
+	err = suite.cache.SetRoleLastUsed(TEST_ROLE_ARN, 12344553243+LAST_USED_INTERVAL)
+	assert.NoError(t, err)
+	flat, err = suite.cache.GetRole(TEST_ROLE_ARN)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12344553243+LAST_USED_INTERVAL), flat.LastUsed)
+
+	err = suite.cache.SetRoleLastUsed(INVALID_ROLE_ARN, 12344553243)
+	assert.Error(t, err)
+}
+
+func (suite *CacheTestSuite) TestAudit() {
+	t := suite.T()
+	cache := suite.cache.GetSSO()
+	snapshots, usage := cache.Snapshots, cache.Usage
+	defer func() {
+		cache.Snapshots, cache.Usage = snapshots, usage
+	}()
+
+	now := time.Now().Unix()
+	cache.Snapshots = nil
+	cache.Usage = map[string][]int64{}
+
+	// no snapshots yet
+	report := suite.cache.Audit(now - 3600)
+	assert.Equal(t, int64(0), report.Baseline)
+	assert.Empty(t, report.Gained)
+	assert.Empty(t, report.Lost)
+
+	suite.cache.addUsage(TEST_ROLE_ARN, now-7200)
+	suite.cache.addUsage(TEST_ROLE_ARN, now-60)
+	suite.cache.addUsage(TEST_ROLE_ARN, now-30)
+	suite.cache.addUsage(TEST_ROLE_ARN, now-(AUDIT_RETENTION+60)) // expired immediately
+	assert.Len(t, cache.Usage[TEST_ROLE_ARN], 3)
+
+	arns := suite.cache.roleArns()
+	lost := "arn:aws:iam::123456789012:role/Removed"
+	cache.Snapshots = []RoleSnapshot{
+		{Time: now - 86400, Roles: append([]string{lost}, arns[1:]...)},
+	}
+	suite.cache.addSnapshot(now - 1800)
+	assert.Len(t, cache.Snapshots, 2)
+	suite.cache.addSnapshot(now - 900) // unchanged
+	assert.Len(t, cache.Snapshots, 2)
+
+	report = suite.cache.Audit(now - 3600)
+	assert.Equal(t, now-86400, report.Baseline)
+	assert.False(t, report.Partial)
+	assert.Equal(t, []string{arns[0]}, report.Gained)
+	assert.Equal(t, []string{lost}, report.Lost)
+	assert.Len(t, report.Used, 1)
+	assert.Equal(t, 2, report.Used[0].Count)
+	assert.Equal(t, now-30, report.Used[0].LastUsed)
+	assert.Equal(t, "AWSAdministratorAccess", report.Used[0].RoleName)
+
+	// window starts before our oldest snapshot
+	report = suite.cache.Audit(now - 2*86400)
+	assert.Equal(t, now-86400, report.Baseline)
+	assert.True(t, report.Partial)
+	assert.Equal(t, 3, report.Used[0].Count)
+
+	// old snapshots are expired, but the one covering the window start is kept
+	suite.cache.addSnapshot(now + AUDIT_RETENTION)
+	assert.Len(t, cache.Snapshots, 1)
+	assert.Equal(t, now-1800, cache.Snapshots[0].Time)
+}
+
+func (suite *CacheTestSuite) TestAddConfigRolesDescription() {
+	t := suite.T()
+	settings, err := LoadSettings(TEST_SETTINGS_FILE, TEST_CACHE_FILE, map[string]interface{}{}, OverrideSettings{})
+	assert.NoError(t, err)
+
+	r := &Roles{
+		Accounts: map[int64]*AWSAccount{},
+	}
+	err = suite.cache.addConfigRoles(r, settings.SSO["Default"])
+	assert.NoError(t, err)
+
+	role := r.Accounts[258234615182].Roles["LimitedAccess"]
+	assert.Equal(t, "Read only access to the playground", role.Description)
+	assert.Equal(t, "Read only access to the playground", role.Tags["Description"])
+
+	role = r.Accounts[258234615182].Roles["AWSAdministratorAccess"]
+	assert.Equal(t, "", role.Description)
+	assert.NotContains(t, role.Tags, "Description")
+}
+
+func (suite *CacheTestSuite) TestAddConfigRolesEnabled() {
+	t := suite.T()
+	settings, err := LoadSettings(TEST_SETTINGS_FILE, TEST_CACHE_FILE, map[string]interface{}{}, OverrideSettings{})
+	assert.NoError(t, err)
+
+	disabled, enabled := false, true
+	account := settings.SSO["Default"].Accounts["258234615182"]
+	account.Enabled = &disabled
+	account.Roles["LimitedAccess"].Enabled = &enabled
+
+	r := &Roles{
+		Accounts: map[int64]*AWSAccount{},
+	}
+	err = suite.cache.addConfigRoles(r, settings.SSO["Default"])
+	assert.NoError(t, err)
+
+	assert.False(t, r.Accounts[258234615182].Roles["LimitedAccess"].Disabled)
+	assert.True(t, r.Accounts[258234615182].Roles["AWSAdministratorAccess"].Disabled)
+
+	// disabled roles are hidden from the interactive selector
+	cache := suite.cache.GetSSO()
+	roles := cache.Roles
+	defer func() { cache.Roles = roles }()
+	cache.Roles = r
+
+	arn := "arn:aws:iam::258234615182:role/AWSAdministratorAccess"
+	assert.NotContains(t, *suite.cache.GetRoleTagsSelect(), arn)
+	assert.Contains(t, *suite.cache.GetRoleTagsSelect(), "arn:aws:iam::258234615182:role/LimitedAccess")
+
+	suite.cache.settings.showDisabled = true
+	defer func() { suite.cache.settings.showDisabled = false }()
+	assert.Contains(t, *suite.cache.GetRoleTagsSelect(), arn)
+}
+
 func (suite *CacheTestSuite) TestCheckProfiles() {
 	t := suite.T()
 	tests := ProfileTests{}
@@ -313,3 +472,54 @@ func (suite *CacheTestSuite) TestCheckProfiles() {
 	err = r.checkProfiles(&badSettings)
 	assert.Error(t, err)
 }
+
+func TestMigrateCache(t *testing.T) {
+	f, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".v2.bak")
+
+	settings := &Settings{
+		HistoryLimit: 1,
+		DefaultSSO:   "Default",
+		cacheFile:    f.Name(),
+	}
+
+	v2 := []byte(`{
+  "Version": 2,
+  "ConfigCreatedAt": 1234,
+  "LastUpdate": 5678,
+  "History": ["arn:aws:iam::707513610766:role/AWSAdministratorAccess"],
+  "Roles": {"Accounts": {}}
+}`)
+	err = ioutil.WriteFile(f.Name(), v2, 0600)
+	assert.NoError(t, err)
+
+	c, err := OpenCache(f.Name(), settings)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(CACHE_VERSION), c.Version)
+	assert.Equal(t, int64(1234), c.ConfigCreatedAt)
+	assert.Equal(t, []string{"arn:aws:iam::707513610766:role/AWSAdministratorAccess"}, c.GetSSO().History)
+	assert.Equal(t, int64(0), c.GetSSO().LastUpdate)
+
+	// original file is backed up
+	backup, err := ioutil.ReadFile(f.Name() + ".v2.bak")
+	assert.NoError(t, err)
+	assert.Equal(t, v2, backup)
+
+	// refuse to downgrade
+	err = ioutil.WriteFile(f.Name(), []byte(`{"Version": 99}`), 0600)
+	assert.NoError(t, err)
+	_, err = OpenCache(f.Name(), settings)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrCacheTooNew)
+	assert.Contains(t, err.Error(), "version 99")
+
+	// current version is untouched
+	input, err := ioutil.ReadFile(TEST_CACHE_FILE)
+	assert.NoError(t, err)
+	out, err := migrateCache(f.Name(), input, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, input, out)
+}
@@ -0,0 +1,92 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// how far off the local clock can be before we complain
+const CLOCK_SKEW_THRESHOLD = 5 * time.Minute
+
+// clockSkewTransport compares the local clock against the Date header of
+// every AWS response and warns the user about gross clock skew.
+type clockSkewTransport struct {
+	transport http.RoundTripper
+	correct   bool // use AWS time for expiry calculations
+	warned    sync.Once
+}
+
+// WithClockSkewDetection wraps the client to detect local clock skew.  If
+// correct is true, utils.Now() uses the time reported by AWS
+func WithClockSkewDetection(client *http.Client, correct bool) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &clockSkewTransport{
+		transport: transport,
+		correct:   correct,
+	}
+	return client
+}
+
+func (t *clockSkewTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err == nil {
+		t.checkSkew(resp.Header.Get("Date"), time.Now())
+	}
+	return resp, err
+}
+
+// checkSkew compares the HTTP Date header against the local time
+func (t *clockSkewTransport) checkSkew(date string, local time.Time) {
+	if date == "" {
+		return
+	}
+	server, err := http.ParseTime(date)
+	if err != nil {
+		log.Debugf("Unable to parse Date header %s: %s", date, err.Error())
+		return
+	}
+
+	skew := server.Sub(local)
+	if skew < CLOCK_SKEW_THRESHOLD && skew > -CLOCK_SKEW_THRESHOLD {
+		return
+	}
+
+	t.warned.Do(func() {
+		if t.correct {
+			log.Warnf("Local clock is off by %s compared to AWS.  Using AWS time for expiration times.",
+				skew.Round(time.Second))
+		} else {
+			log.Warnf("Local clock is off by %s compared to AWS.  Expiration times will be wrong!  "+
+				"Please fix your clock, set `UseServerTime` in the config or use --ignore-clock-skew",
+				skew.Round(time.Second))
+		}
+	})
+	if t.correct {
+		utils.SetClockSkew(skew)
+	}
+}
@@ -0,0 +1,72 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+func TestClockSkewTransport(t *testing.T) {
+	defer utils.SetClockSkew(0)
+
+	serverTime := time.Now().Add(2 * time.Hour)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+	}))
+	defer ts.Close()
+
+	// detect, but don't correct
+	c := WithClockSkewDetection(&http.Client{}, false)
+	resp, err := c.Get(ts.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, time.Duration(0), utils.ClockSkew())
+
+	// use AWS time
+	c = WithClockSkewDetection(&http.Client{}, true)
+	resp, err = c.Get(ts.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.InDelta(t, (2 * time.Hour).Seconds(), utils.ClockSkew().Seconds(), 5)
+}
+
+func TestCheckSkew(t *testing.T) {
+	defer utils.SetClockSkew(0)
+	tr := &clockSkewTransport{correct: true}
+	now := time.Now()
+
+	// small differences are ignored
+	tr.checkSkew(now.Add(time.Minute).UTC().Format(http.TimeFormat), now)
+	assert.Equal(t, time.Duration(0), utils.ClockSkew())
+
+	// invalid or missing headers are ignored
+	tr.checkSkew("", now)
+	tr.checkSkew("yesterday", now)
+	assert.Equal(t, time.Duration(0), utils.ClockSkew())
+
+	// local clock is fast
+	tr.checkSkew(now.Add(-time.Hour).UTC().Format(http.TimeFormat), now)
+	assert.InDelta(t, (-time.Hour).Seconds(), utils.ClockSkew().Seconds(), 2)
+}
@@ -0,0 +1,272 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	START_CONCURRENCY = 2  // initial number of parallel API calls
+	MAX_CONCURRENCY   = 25 // never exceed this many parallel API calls
+	THROTTLE_RETRIES  = 5  // attempts per account before giving up
+)
+
+// how long to wait before retrying a throttled call.  Multiplied by the attempt
+var throttleBackoff = 1 * time.Second
+
+// RetryStatusFunc is called whenever the number of throttled calls waiting to
+// be retried changes.  waiting is the number of calls currently backing off and
+// wait is the longest backoff of those calls.  waiting == 0 means the backoff
+// has completed.  Calls are serialized so implementations need not be thread safe.
+type RetryStatusFunc func(waiting int, wait time.Duration)
+
+// AdaptiveLimiter limits the number of concurrent API calls using AIMD:
+// the limit increases by one after a full window of successful calls and
+// is cut in half anytime we are throttled
+type AdaptiveLimiter struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
+	limit     int
+	max       int
+	inFlight  int
+	successes int
+}
+
+// NewAdaptiveLimiter returns an AdaptiveLimiter which will never allow more
+// than max (capped at MAX_CONCURRENCY) concurrent calls
+func NewAdaptiveLimiter(max int) *AdaptiveLimiter {
+	if max < 1 || max > MAX_CONCURRENCY {
+		max = MAX_CONCURRENCY
+	}
+	limit := START_CONCURRENCY
+	if limit > max {
+		limit = max
+	}
+	l := &AdaptiveLimiter{
+		limit: limit,
+		max:   max,
+	}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+// Acquire blocks until another call is allowed
+func (l *AdaptiveLimiter) Acquire() {
+	_ = l.AcquireContext(context.Background())
+}
+
+// AcquireContext blocks until another call is allowed or the context is done
+func (l *AdaptiveLimiter) AcquireContext(ctx context.Context) error {
+	// wake up our Wait() when the context is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.lock.Lock()
+			l.cond.Broadcast()
+			l.lock.Unlock()
+		case <-stop:
+		}
+	}()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if l.inFlight < l.limit {
+			break
+		}
+		l.cond.Wait()
+	}
+	l.inFlight++
+	return nil
+}
+
+// Release marks a call as complete and adjusts the limit based on if it was throttled
+func (l *AdaptiveLimiter) Release(throttled bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.inFlight--
+	if throttled {
+		l.successes = 0
+		if l.limit > 1 {
+			l.limit /= 2
+		}
+		log.Debugf("Throttled by AWS: reducing concurrency to %d", l.limit)
+	} else {
+		l.successes++
+		if l.successes >= l.limit && l.limit < l.max {
+			l.successes = 0
+			l.limit++
+		}
+	}
+	l.cond.Broadcast()
+}
+
+// Limit returns the current concurrency limit
+func (l *AdaptiveLimiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.limit
+}
+
+// Call runs f once the limiter allows another call, retrying with a backoff
+// when AWS throttles us, up to THROTTLE_RETRIES attempts.  Gives up early if
+// the context is done while waiting to run or retry.
+func (l *AdaptiveLimiter) Call(ctx context.Context, f func() error) error {
+	return l.CallWithTimeout(ctx, 0, func(context.Context) error {
+		return f()
+	})
+}
+
+// CallWithTimeout is like Call, but a non-zero timeout limits how long f and
+// its retries may take.  The timeout starts once the limiter first allows
+// the call, so time spent waiting behind other calls does not count.
+func (l *AdaptiveLimiter) CallWithTimeout(ctx context.Context, timeout time.Duration, f func(context.Context) error) error {
+	var err error
+	cctx := ctx
+	for attempt := 1; attempt <= THROTTLE_RETRIES; attempt++ {
+		if err = l.AcquireContext(cctx); err != nil {
+			return err
+		}
+		if attempt == 1 && timeout > 0 {
+			var cancel context.CancelFunc
+			cctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		err = f(cctx)
+		throttled := IsThrottlingError(err)
+		l.Release(throttled)
+		if !throttled || attempt == THROTTLE_RETRIES {
+			return err
+		}
+		select {
+		case <-cctx.Done():
+			return cctx.Err()
+		case <-time.After(throttleBackoff * time.Duration(attempt)):
+		}
+	}
+	return err
+}
+
+// IsThrottlingError returns true if AWS rejected our call due to rate limiting
+func IsThrottlingError(err error) bool {
+	var ae smithy.APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	switch ae.ErrorCode() {
+	case "ThrottlingException", "TooManyRequestsException", "Throttling":
+		return true
+	}
+	return false
+}
+
+// IsAccessDeniedError returns true if AWS refused to give us credentials for the role
+func IsAccessDeniedError(err error) bool {
+	var ae smithy.APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	switch ae.ErrorCode() {
+	case "ForbiddenException", "AccessDenied", "AccessDeniedException":
+		return true
+	}
+	return false
+}
+
+// GetAllRoles calls GetRoles for each of the accounts in parallel, adapting the
+// concurrency to avoid AWS throttling.  Returns the roles for each AccountId.
+func (as *AWSSSO) GetAllRoles(accounts []AccountInfo, maxConcurrency int) (map[string][]RoleInfo, error) {
+	limiter := NewAdaptiveLimiter(maxConcurrency)
+	results := make([][]RoleInfo, len(accounts))
+	errs := make([]error, len(accounts))
+
+	var statusLock sync.Mutex
+	waiting := 0
+	var maxWait time.Duration
+	status := func(delta int, wait time.Duration) {
+		if as.retryStatus == nil {
+			return
+		}
+		statusLock.Lock()
+		defer statusLock.Unlock()
+		waiting += delta
+		if wait > maxWait {
+			maxWait = wait
+		}
+		if waiting == 0 {
+			maxWait = 0
+		}
+		as.retryStatus(waiting, maxWait)
+	}
+
+	var wg sync.WaitGroup
+	for i, aInfo := range accounts {
+		wg.Add(1)
+		go func(i int, aInfo AccountInfo) {
+			defer wg.Done()
+			for attempt := 1; attempt <= THROTTLE_RETRIES; attempt++ {
+				// don't start any more calls once the --deadline has passed
+				if err := limiter.AcquireContext(as.getContext()); err != nil {
+					errs[i] = err
+					return
+				}
+				results[i], errs[i] = as.GetRoles(aInfo)
+				throttled := IsThrottlingError(errs[i])
+				limiter.Release(throttled)
+				if !throttled || attempt == THROTTLE_RETRIES {
+					return
+				}
+				wait := throttleBackoff * time.Duration(attempt)
+				status(1, wait)
+				select {
+				case <-as.getContext().Done():
+					errs[i] = as.getContext().Err()
+					status(-1, 0)
+					return
+				case <-time.After(wait):
+				}
+				status(-1, 0)
+			}
+		}(i, aInfo)
+	}
+	wg.Wait()
+	log.Debugf("Effective concurrency for AWS SSO role enumeration: %d", limiter.Limit())
+
+	roles := map[string][]RoleInfo{}
+	for i, aInfo := range accounts {
+		if errs[i] != nil {
+			return roles, fmt.Errorf("%s: %s", aInfo.AccountId, errs[i].Error())
+		}
+		roles[aInfo.AccountId] = results[i]
+	}
+	return roles, nil
+}
@@ -0,0 +1,287 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := NewAdaptiveLimiter(4)
+	assert.Equal(t, START_CONCURRENCY, l.Limit())
+
+	// additive increase after a full window of successes
+	for i := 0; i < START_CONCURRENCY; i++ {
+		l.Acquire()
+		l.Release(false)
+	}
+	assert.Equal(t, 3, l.Limit())
+
+	// never exceed our max
+	for i := 0; i < 20; i++ {
+		l.Acquire()
+		l.Release(false)
+	}
+	assert.Equal(t, 4, l.Limit())
+
+	// multiplicative decrease
+	l.Acquire()
+	l.Release(true)
+	assert.Equal(t, 2, l.Limit())
+	l.Acquire()
+	l.Release(true)
+	l.Acquire()
+	l.Release(true)
+	assert.Equal(t, 1, l.Limit())
+
+	assert.Equal(t, MAX_CONCURRENCY, NewAdaptiveLimiter(0).max)
+	assert.Equal(t, MAX_CONCURRENCY, NewAdaptiveLimiter(1000).max)
+	assert.Equal(t, 1, NewAdaptiveLimiter(1).Limit())
+}
+
+func TestIsThrottlingError(t *testing.T) {
+	assert.True(t, IsThrottlingError(&types.TooManyRequestsException{}))
+	assert.True(t, IsThrottlingError(fmt.Errorf("wrapped: %w", &types.TooManyRequestsException{})))
+	assert.False(t, IsThrottlingError(&types.UnauthorizedException{}))
+	assert.False(t, IsThrottlingError(fmt.Errorf("some error")))
+	assert.False(t, IsThrottlingError(nil))
+}
+
+func TestIsAccessDeniedError(t *testing.T) {
+	assert.True(t, IsAccessDeniedError(&smithy.GenericAPIError{Code: "ForbiddenException"}))
+	assert.True(t, IsAccessDeniedError(fmt.Errorf("wrapped: %w", &smithy.GenericAPIError{Code: "AccessDenied"})))
+	assert.False(t, IsAccessDeniedError(&types.TooManyRequestsException{}))
+	assert.False(t, IsAccessDeniedError(fmt.Errorf("some error")))
+	assert.False(t, IsAccessDeniedError(nil))
+}
+
+func TestAdaptiveLimiterCall(t *testing.T) {
+	defer func(d time.Duration) { throttleBackoff = d }(throttleBackoff)
+	throttleBackoff = time.Millisecond
+
+	l := NewAdaptiveLimiter(4)
+
+	// retried after being throttled
+	calls := 0
+	err := l.Call(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return &types.TooManyRequestsException{}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// other errors are not retried
+	calls = 0
+	err = l.Call(context.Background(), func() error {
+		calls++
+		return fmt.Errorf("some error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// give up after THROTTLE_RETRIES
+	calls = 0
+	err = l.Call(context.Background(), func() error {
+		calls++
+		return &types.TooManyRequestsException{}
+	})
+	assert.True(t, IsThrottlingError(err))
+	assert.Equal(t, THROTTLE_RETRIES, calls)
+
+	// stop retrying once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = l.Call(ctx, func() error {
+		return &types.TooManyRequestsException{}
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAdaptiveLimiterAcquireContext(t *testing.T) {
+	l := NewAdaptiveLimiter(1)
+	assert.NoError(t, l.AcquireContext(context.Background()))
+
+	// gives up waiting once the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.AcquireContext(ctx), context.DeadlineExceeded)
+
+	l.Release(false)
+	assert.NoError(t, l.AcquireContext(context.Background()))
+	l.Release(false)
+}
+
+func TestAdaptiveLimiterCallWithTimeout(t *testing.T) {
+	l := NewAdaptiveLimiter(1)
+
+	// time spent waiting for the limiter does not count against the timeout
+	l.Acquire()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.Release(false)
+	}()
+	err := l.CallWithTimeout(context.Background(), 30*time.Millisecond, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+	assert.NoError(t, err)
+
+	// but the call itself is limited
+	err = l.CallWithTimeout(context.Background(), 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// mock sso which throttles the first call for each account
+type mockThrottlingSsoApi struct {
+	mockSsoApi
+	lock      sync.Mutex
+	throttled map[string]bool
+}
+
+func (m *mockThrottlingSsoApi) ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	accountId := aws.ToString(params.AccountId)
+	if !m.throttled[accountId] {
+		m.throttled[accountId] = true
+		return nil, &types.TooManyRequestsException{}
+	}
+	return &sso.ListAccountRolesOutput{
+		RoleList: []types.RoleInfo{
+			{
+				AccountId: params.AccountId,
+				RoleName:  aws.String("AdminAccess"),
+			},
+		},
+	}, nil
+}
+
+func TestGetAllRoles(t *testing.T) {
+	defer func(d time.Duration) { throttleBackoff = d }(throttleBackoff)
+	throttleBackoff = time.Millisecond
+
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		ssooidc:   &mockSsoOidcApi{},
+		Roles:     map[string][]RoleInfo{},
+		SSOConfig: &SSOConfig{
+			Accounts: map[string]*SSOAccount{},
+		},
+		sso: &mockThrottlingSsoApi{
+			throttled: map[string]bool{},
+		},
+	}
+
+	accounts := []AccountInfo{}
+	for i := 1; i <= 10; i++ {
+		accounts = append(accounts, AccountInfo{
+			AccountId:   fmt.Sprintf("%012d", i),
+			AccountName: fmt.Sprintf("Account%d", i),
+		})
+	}
+
+	maxWaiting := 0
+	lastWaiting := -1
+	as.SetRetryStatus(func(waiting int, wait time.Duration) {
+		if waiting > maxWaiting {
+			maxWaiting = waiting
+		}
+		if waiting > 0 {
+			assert.GreaterOrEqual(t, int64(wait), int64(throttleBackoff))
+		}
+		lastWaiting = waiting
+	})
+
+	roles, err := as.GetAllRoles(accounts, 5)
+	assert.NoError(t, err)
+	assert.Greater(t, maxWaiting, 0)
+	assert.Equal(t, 0, lastWaiting)
+	assert.Len(t, roles, 10)
+	for _, aInfo := range accounts {
+		assert.Len(t, roles[aInfo.AccountId], 1)
+		assert.Equal(t, "AdminAccess", roles[aInfo.AccountId][0].RoleName)
+		assert.Equal(t, aInfo.AccountName, roles[aInfo.AccountId][0].AccountName)
+	}
+}
+
+// mock sso which counts the calls and always throttles
+type mockAlwaysThrottlingSsoApi struct {
+	mockSsoApi
+	lock  sync.Mutex
+	calls int
+}
+
+func (m *mockAlwaysThrottlingSsoApi) ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls++
+	return nil, &types.TooManyRequestsException{}
+}
+
+func TestGetAllRolesContext(t *testing.T) {
+	defer func(d time.Duration) { throttleBackoff = d }(throttleBackoff)
+	throttleBackoff = time.Millisecond
+
+	api := &mockAlwaysThrottlingSsoApi{}
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		ssooidc:   &mockSsoOidcApi{},
+		Roles:     map[string][]RoleInfo{},
+		SSOConfig: &SSOConfig{
+			Accounts: map[string]*SSOAccount{},
+		},
+		sso: api,
+	}
+	accounts := []AccountInfo{}
+	for i := 1; i <= 10; i++ {
+		accounts = append(accounts, AccountInfo{AccountId: fmt.Sprintf("%012d", i)})
+	}
+
+	// no calls are made once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	as.SetContext(ctx)
+	_, err := as.GetAllRoles(accounts, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.Equal(t, 0, api.calls)
+
+	// give up after THROTTLE_RETRIES
+	as.SetContext(context.Background())
+	_, err = as.GetAllRoles(accounts[:1], 5)
+	assert.Error(t, err)
+	assert.Equal(t, THROTTLE_RETRIES, api.calls)
+}
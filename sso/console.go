@@ -0,0 +1,82 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// Types for building the AWS Console federation URLs
+
+type LoginResponse struct {
+	SigninToken string `json:"SigninToken"`
+}
+
+type SigninTokenUrlParams struct {
+	FederationUrl   string // ex: https://signin.aws.amazon.com/federation
+	SessionDuration int32
+	Session         SessionUrlParams // URL encoded SessionUrlParams
+}
+
+// NewSigninTokenUrlParams returns the getSigninToken params for the given credentials.
+// For roles using `Via` the creds must be the ones for the final role in the chain.
+func NewSigninTokenUrlParams(federationUrl string, creds *storage.RoleCredentials, duration int32) SigninTokenUrlParams {
+	return SigninTokenUrlParams{
+		FederationUrl:   federationUrl,
+		SessionDuration: duration,
+		Session: SessionUrlParams{
+			AccessKeyId:     creds.AccessKeyId,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+		},
+	}
+}
+
+func (stup *SigninTokenUrlParams) GetUrl() string {
+	return fmt.Sprintf("%s?Action=getSigninToken&SessionDuration=%d&Session=%s",
+		stup.FederationUrl, stup.SessionDuration, stup.Session.Encode())
+}
+
+type SessionUrlParams struct {
+	AccessKeyId     string `json:"sessionId"`
+	SecretAccessKey string `json:"sessionKey"`
+	SessionToken    string `json:"sessionToken"`
+}
+
+func (sup *SessionUrlParams) Encode() string {
+	s, _ := json.Marshal(sup)
+	return url.QueryEscape(string(s))
+}
+
+type LoginUrlParams struct {
+	FederationUrl string // ex: https://signin.aws.amazon.com/federation
+	Issuer        string
+	Destination   string
+	SigninToken   string
+}
+
+func (lup *LoginUrlParams) GetUrl() string {
+	return fmt.Sprintf("%s?Action=login&Issuer=%s&Destination=%s&SigninToken=%s",
+		lup.FederationUrl, lup.Issuer, url.QueryEscape(lup.Destination),
+		lup.SigninToken)
+}
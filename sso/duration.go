@@ -0,0 +1,181 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// The shortest & longest session durations in seconds supported by STS
+const (
+	MIN_SESSION_DURATION = 15 * 60
+	MAX_SESSION_DURATION = 12 * 60 * 60
+)
+
+// ValidateSessionDuration ensures the session duration in seconds is
+// within the bounds supported by STS
+func ValidateSessionDuration(duration int32) error {
+	if duration < MIN_SESSION_DURATION || duration > MAX_SESSION_DURATION {
+		return fmt.Errorf("Invalid session duration %s: must be between %s and %s",
+			secondsString(duration), secondsString(MIN_SESSION_DURATION), secondsString(MAX_SESSION_DURATION))
+	}
+	return nil
+}
+
+// ValidateConsoleDuration ensures the console session duration in seconds is
+// a whole number of minutes within the bounds supported by the federation endpoint
+func ValidateConsoleDuration(duration int32) error {
+	if duration%60 != 0 {
+		return fmt.Errorf("Invalid session duration %s: must be a whole number of minutes",
+			secondsString(duration))
+	}
+	return ValidateSessionDuration(duration)
+}
+
+// ParseSessionDuration parses a session duration as either a number of
+// minutes (ex: 90) or a Go duration (ex: 1h30m) and returns it in seconds.
+// An empty string returns 0.
+func ParseSessionDuration(duration string) (int32, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	if minutes, err := strconv.ParseInt(duration, 10, 32); err == nil {
+		if minutes <= 0 || minutes > math.MaxInt32/60 {
+			return 0, fmt.Errorf("Invalid session duration: %s", duration)
+		}
+		return int32(minutes * 60), nil
+	}
+
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid session duration %s: must be minutes (ex: 90) or a duration (ex: 1h30m)", duration)
+	}
+	if d <= 0 || d.Seconds() > math.MaxInt32 {
+		return 0, fmt.Errorf("Invalid session duration: %s", duration)
+	}
+	return int32(d.Seconds()), nil
+}
+
+// DurationTooLargeError explains that the requested session duration in
+// seconds is longer than the role allows.  max is the maximum in seconds or
+// 0 if unknown.
+func DurationTooLargeError(err error, duration, max int32) error {
+	if max > 0 {
+		return fmt.Errorf("Requested session duration of %s exceeds the maximum of %s for this role.  "+
+			"Use a shorter --duration or --clamp-duration: %s", secondsString(duration), secondsString(max), err.Error())
+	}
+	return fmt.Errorf("Requested session duration of %s exceeds the maximum for this role "+
+		"(1h when using Via).  Use a shorter --duration: %s", secondsString(duration), err.Error())
+}
+
+// secondsString returns the number of seconds as a human readable string (ex: 1h30m)
+func secondsString(seconds int32) string {
+	s := (time.Duration(seconds) * time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// AWS reports the allowed maximum in the validation error for some APIs
+var maxDurationRe = regexp.MustCompile(`less than or equal to (\d+)`)
+
+// IsDurationTooLargeError returns true if AWS rejected the call because
+// the requested session duration is longer than allowed
+func IsDurationTooLargeError(err error) bool {
+	var ae smithy.APIError
+	if !errors.As(err, &ae) || ae.ErrorCode() != "ValidationError" {
+		return false
+	}
+	msg := strings.ToLower(ae.ErrorMessage())
+	return strings.Contains(msg, "durationseconds") || strings.Contains(msg, "maxsessionduration")
+}
+
+// MaxDurationFromError returns the maximum session duration in seconds as
+// reported by the error or 0 if it is not known
+func MaxDurationFromError(err error) int32 {
+	match := maxDurationRe.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	max, err := strconv.ParseInt(match[1], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(max)
+}
+
+// RoleMaxSessionDuration uses the given credentials to look up the
+// MaxSessionDuration in seconds of the IAM Role they belong to.  Requires
+// the role to have iam:GetRole on itself.
+func RoleMaxSessionDuration(ctx context.Context, creds *storage.RoleCredentials, region string, httpClient *http.Client) (int32, error) {
+	cfg, err := credentialsConfig(ctx, creds, region, httpClient)
+	if err != nil {
+		return 0, err
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return 0, fmt.Errorf("Unable to call sts get-caller-identity: %s", err.Error())
+	}
+
+	roleName, err := assumedRoleName(aws.ToString(identity.Arn))
+	if err != nil {
+		return 0, err
+	}
+
+	output, err := iam.NewFromConfig(cfg).GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Unable to call iam get-role: %s", err.Error())
+	}
+	return aws.ToInt32(output.Role.MaxSessionDuration), nil
+}
+
+// assumedRoleName returns the IAM Role name of an STS assumed-role ARN:
+// arn:aws:sts::<account>:assumed-role/<role>/<session>
+func assumedRoleName(arn string) (string, error) {
+	s := strings.Split(arn, ":")
+	if len(s) != 6 {
+		return "", fmt.Errorf("Invalid assumed role ARN: %s", arn)
+	}
+	parts := strings.Split(s[5], "/")
+	if len(parts) != 3 || parts[0] != "assumed-role" {
+		return "", fmt.Errorf("Invalid assumed role ARN: %s", arn)
+	}
+	return parts[1], nil
+}
@@ -0,0 +1,132 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDurationTooLargeError(t *testing.T) {
+	err := &smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role.",
+	}
+	assert.True(t, IsDurationTooLargeError(err))
+	assert.True(t, IsDurationTooLargeError(fmt.Errorf("wrapped: %w", err)))
+
+	err = &smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: "1 validation error detected: Value '200000' at 'durationSeconds' failed to satisfy constraint: Member must have value less than or equal to 129600",
+	}
+	assert.True(t, IsDurationTooLargeError(err))
+
+	assert.False(t, IsDurationTooLargeError(&smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: "Invalid RoleArn",
+	}))
+	assert.False(t, IsDurationTooLargeError(&smithy.GenericAPIError{Code: "AccessDenied"}))
+	assert.False(t, IsDurationTooLargeError(fmt.Errorf("some error")))
+	assert.False(t, IsDurationTooLargeError(nil))
+}
+
+func TestMaxDurationFromError(t *testing.T) {
+	err := &smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: "1 validation error detected: Value '200000' at 'durationSeconds' failed to satisfy constraint: Member must have value less than or equal to 129600",
+	}
+	assert.Equal(t, int32(129600), MaxDurationFromError(err))
+
+	err = &smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role.",
+	}
+	assert.Equal(t, int32(0), MaxDurationFromError(err))
+}
+
+func TestValidateSessionDuration(t *testing.T) {
+	assert.NoError(t, ValidateSessionDuration(15*60))
+	assert.NoError(t, ValidateSessionDuration(12*60*60))
+	assert.Error(t, ValidateSessionDuration(14*60))
+	assert.Error(t, ValidateSessionDuration(12*60*60+60))
+}
+
+func TestValidateConsoleDuration(t *testing.T) {
+	assert.NoError(t, ValidateConsoleDuration(15*60))
+	assert.NoError(t, ValidateConsoleDuration(12*60*60))
+	assert.Error(t, ValidateConsoleDuration(90))
+	assert.Error(t, ValidateConsoleDuration(30))
+	assert.Error(t, ValidateConsoleDuration(0))
+	assert.Error(t, ValidateConsoleDuration(14*60))
+	assert.Error(t, ValidateConsoleDuration(12*60*60+60))
+}
+
+func TestParseSessionDuration(t *testing.T) {
+	d, err := ParseSessionDuration("")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), d)
+
+	d, err = ParseSessionDuration("90")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(90*60), d)
+
+	d, err = ParseSessionDuration("8h")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(8*60*60), d)
+
+	d, err = ParseSessionDuration("1h30m")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(90*60), d)
+
+	for _, bad := range []string{"0", "-5", "-1h", "8 hours", "1d"} {
+		_, err = ParseSessionDuration(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDurationTooLargeError(t *testing.T) {
+	apiErr := fmt.Errorf("ValidationError: DurationSeconds exceeds the MaxSessionDuration")
+
+	err := DurationTooLargeError(apiErr, 8*60*60, 60*60)
+	assert.Contains(t, err.Error(), "session duration of 8h exceeds the maximum of 1h")
+	assert.Contains(t, err.Error(), "--clamp-duration")
+	assert.Contains(t, err.Error(), apiErr.Error())
+
+	err = DurationTooLargeError(apiErr, 90*60, 0)
+	assert.Contains(t, err.Error(), "session duration of 1h30m exceeds the maximum")
+	assert.NotContains(t, err.Error(), "--clamp-duration")
+}
+
+func TestAssumedRoleName(t *testing.T) {
+	name, err := assumedRoleName("arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_Admin_1234/user@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "AWSReservedSSO_Admin_1234", name)
+
+	name, err = assumedRoleName("arn:aws-us-gov:sts::123456789012:assumed-role/ReadOnly/session")
+	assert.NoError(t, err)
+	assert.Equal(t, "ReadOnly", name)
+
+	_, err = assumedRoleName("arn:aws:iam::123456789012:user/bob")
+	assert.Error(t, err)
+	_, err = assumedRoleName("invalid")
+	assert.Error(t, err)
+}
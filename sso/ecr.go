@@ -0,0 +1,102 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// ECRAuthorization is the username & password for `docker login` to the
+// ECR registry at Endpoint
+type ECRAuthorization struct {
+	Username  string
+	Password  string // ECR token, not an AWS secret key
+	Endpoint  string // ex: https://123456789012.dkr.ecr.us-east-1.amazonaws.com
+	ExpiresAt int64  // Unix epoch seconds
+}
+
+// ECRAPI is the subset of the AWS ECR API we use
+type ECRAPI interface {
+	GetAuthorizationToken(context.Context, *ecr.GetAuthorizationTokenInput, ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+}
+
+// NewECRClient returns an AWS ECR client using the given role credentials
+func NewECRClient(ctx context.Context, creds *storage.RoleCredentials, region string, httpClient *http.Client) (*ecr.Client, error) {
+	cfgCreds := credentials.NewStaticCredentialsProvider(
+		creds.AccessKeyId,
+		creds.SecretAccessKey,
+		creds.SessionToken,
+	)
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+		config.WithCredentialsProvider(cfgCreds),
+		config.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ecr.NewFromConfig(cfg), nil
+}
+
+// GetECRAuthorization calls ECR GetAuthorizationToken for the `docker login`
+// username & password of the registry
+func GetECRAuthorization(ctx context.Context, api ECRAPI) (*ECRAuthorization, error) {
+	output, err := api.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get ECR authorization token: %s", err.Error())
+	}
+	if len(output.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("ECR did not return an authorization token")
+	}
+	return parseECRAuthorization(output.AuthorizationData[0])
+}
+
+// parseECRAuthorization decodes the base64 encoded `username:password` token
+func parseECRAuthorization(auth ecrtypes.AuthorizationData) (*ECRAuthorization, error) {
+	token, err := base64.StdEncoding.DecodeString(aws.ToString(auth.AuthorizationToken))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode ECR authorization token: %s", err.Error())
+	}
+	s := strings.SplitN(string(token), ":", 2)
+	if len(s) != 2 {
+		return nil, fmt.Errorf("Invalid ECR authorization token")
+	}
+
+	ret := &ECRAuthorization{
+		Username: s[0],
+		Password: s[1],
+		Endpoint: aws.ToString(auth.ProxyEndpoint),
+	}
+	if auth.ExpiresAt != nil {
+		ret.ExpiresAt = auth.ExpiresAt.Unix()
+	}
+	return ret, nil
+}
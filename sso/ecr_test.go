@@ -0,0 +1,84 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockECRApi struct {
+	output *ecr.GetAuthorizationTokenOutput
+	err    error
+}
+
+func (m *mockECRApi) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	return m.output, m.err
+}
+
+func TestGetECRAuthorization(t *testing.T) {
+	token := base64.StdEncoding.EncodeToString([]byte("AWS:ecr-password"))
+	api := &mockECRApi{
+		output: &ecr.GetAuthorizationTokenOutput{
+			AuthorizationData: []ecrtypes.AuthorizationData{
+				{
+					AuthorizationToken: aws.String(token),
+					ExpiresAt:          aws.Time(time.Unix(1665043200, 0)),
+					ProxyEndpoint:      aws.String("https://123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+				},
+			},
+		},
+	}
+
+	auth, err := GetECRAuthorization(context.Background(), api)
+	assert.NoError(t, err)
+	assert.Equal(t, &ECRAuthorization{
+		Username:  "AWS",
+		Password:  "ecr-password",
+		Endpoint:  "https://123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		ExpiresAt: 1665043200,
+	}, auth)
+
+	api.output = &ecr.GetAuthorizationTokenOutput{}
+	_, err = GetECRAuthorization(context.Background(), api)
+	assert.Error(t, err)
+
+	api.err = fmt.Errorf("AccessDeniedException: not allowed")
+	_, err = GetECRAuthorization(context.Background(), api)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "AccessDeniedException: not allowed")
+}
+
+func TestParseECRAuthorization(t *testing.T) {
+	_, err := parseECRAuthorization(ecrtypes.AuthorizationData{AuthorizationToken: aws.String("not base64!")})
+	assert.Error(t, err)
+
+	_, err = parseECRAuthorization(ecrtypes.AuthorizationData{
+		AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("no-colon"))),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// ECSCredentials is the JSON returned by the ECS container credentials endpoint
+// which the AWS SDKs use via $AWS_CONTAINER_CREDENTIALS_FULL_URI
+type ECSCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+	RoleArn         string `json:"RoleArn"`
+}
+
+// ECSCredentialsFunc returns the current credentials for the role, refreshing
+// them as necessary
+type ECSCredentialsFunc func() (*storage.RoleCredentials, error)
+
+// ECSServer is a local ECS container credentials endpoint for a single role,
+// compatible with `aws-vault exec --ecs-server`
+type ECSServer struct {
+	listener  net.Listener
+	server    *http.Server
+	authToken string
+	creds     ECSCredentialsFunc
+	lock      sync.Mutex // only refresh the credentials once at a time
+}
+
+// NewECSServer listens on a random localhost port.  Requests must provide the
+// AuthToken in the Authorization header.
+func NewECSServer(creds ECSCredentialsFunc) (*ECSServer, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("Unable to generate authorization token: %s", err.Error())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start ECS server: %s", err.Error())
+	}
+
+	e := &ECSServer{
+		listener:  listener,
+		authToken: hex.EncodeToString(token),
+		creds:     creds,
+	}
+	e.server = &http.Server{Handler: e}
+	return e, nil
+}
+
+// Url returns the value for $AWS_CONTAINER_CREDENTIALS_FULL_URI
+func (e *ECSServer) Url() string {
+	return fmt.Sprintf("http://%s/", e.listener.Addr().String())
+}
+
+// AuthToken returns the value for $AWS_CONTAINER_AUTHORIZATION_TOKEN
+func (e *ECSServer) AuthToken() string {
+	return e.authToken
+}
+
+// Serve handles requests until Close is called
+func (e *ECSServer) Serve() error {
+	if err := e.server.Serve(e.listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Close stops the server
+func (e *ECSServer) Close() error {
+	return e.server.Close()
+}
+
+func (e *ECSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(e.authToken)) != 1 {
+		http.Error(w, "invalid Authorization token", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	e.lock.Lock()
+	creds, err := e.creds()
+	e.lock.Unlock()
+	if err != nil {
+		log.WithError(err).Errorf("Unable to get credentials for ECS server")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(ECSCredentials{
+		AccessKeyId:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+		Expiration:      creds.ExpireISO8601(),
+		RoleArn:         creds.RoleArn(),
+	})
+	if err != nil {
+		log.WithError(err).Errorf("Unable to write ECS server response")
+	}
+}
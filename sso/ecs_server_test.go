@@ -0,0 +1,82 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+func TestECSServer(t *testing.T) {
+	fail := false
+	e, err := NewECSServer(func() (*storage.RoleCredentials, error) {
+		if fail {
+			return nil, fmt.Errorf("no credentials")
+		}
+		return &storage.RoleCredentials{
+			RoleName:        "Admin",
+			AccountId:       123456789012,
+			AccessKeyId:     "access-key",
+			SecretAccessKey: "secret-key",
+			SessionToken:    "session-token",
+			Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+		}, nil
+	})
+	assert.NoError(t, err)
+	go func() {
+		assert.NoError(t, e.Serve())
+	}()
+	defer e.Close()
+
+	get := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, e.Url(), nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", token)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp := get("wrong")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = get(e.AuthToken())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	creds := ECSCredentials{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&creds))
+	resp.Body.Close()
+	assert.Equal(t, "access-key", creds.AccessKeyId)
+	assert.Equal(t, "secret-key", creds.SecretAccessKey)
+	assert.Equal(t, "session-token", creds.Token)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/Admin", creds.RoleArn)
+	_, err = time.Parse(time.RFC3339, creds.Expiration)
+	assert.NoError(t, err)
+
+	fail = true
+	resp = get(e.AuthToken())
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
@@ -0,0 +1,112 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Environment is a named group of settings which override the top level
+// settings in the config file when selected via --env or $AWS_SSO_ENV
+type Environment struct {
+	SSO               map[string]*SSOConfig `koanf:"SSOConfig" yaml:"SSOConfig,omitempty"`
+	DefaultSSO        string                `koanf:"DefaultSSO" yaml:"DefaultSSO,omitempty"`
+	DefaultRegion     string                `koanf:"DefaultRegion" yaml:"DefaultRegion,omitempty"`
+	UrlAction         string                `koanf:"UrlAction" yaml:"UrlAction,omitempty"`
+	Browser           string                `koanf:"Browser" yaml:"Browser,omitempty"`
+	ProfileFormat     string                `koanf:"ProfileFormat" yaml:"ProfileFormat,omitempty"`
+	ListFields        []string              `koanf:"ListFields" yaml:"ListFields,omitempty"`
+	AccountsAllowlist []string              `koanf:"AccountsAllowlist" yaml:"AccountsAllowlist,omitempty"`
+}
+
+// EnvironmentNames returns the sorted list of configured Environments
+func (s *Settings) EnvironmentNames() []string {
+	names := []string{}
+	for name := range s.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyEnvironment overrides our settings with those of the named Environment.
+// An empty name selects the DefaultEnv, if any.
+func (s *Settings) applyEnvironment(name string) error {
+	if name == "" {
+		name = s.DefaultEnv
+	}
+	if name == "" {
+		return nil
+	}
+
+	env, ok := s.Environments[name]
+	if !ok {
+		if len(s.Environments) == 0 {
+			return fmt.Errorf("Invalid environment '%s'. No Environments are configured", name)
+		}
+		return fmt.Errorf("Invalid environment '%s'. Valid options: %s", name,
+			strings.Join(s.EnvironmentNames(), ", "))
+	}
+
+	// AWS SSO instances defined in the environment are only available when it
+	// is selected and are the default if it has exactly one
+	for ssoName, c := range env.SSO {
+		if _, ok := s.SSO[ssoName]; ok {
+			return fmt.Errorf("Environment '%s' SSOConfig '%s' is already defined in the top level SSOConfig", name, ssoName)
+		}
+		if s.SSO == nil {
+			s.SSO = map[string]*SSOConfig{}
+		}
+		s.SSO[ssoName] = c
+		if len(env.SSO) == 1 {
+			s.DefaultSSO = ssoName
+		}
+	}
+
+	if env.DefaultSSO != "" {
+		s.DefaultSSO = env.DefaultSSO
+	}
+	if env.DefaultRegion != "" {
+		s.DefaultRegion = env.DefaultRegion
+	}
+	if env.UrlAction != "" {
+		s.UrlAction = env.UrlAction
+	}
+	if env.Browser != "" {
+		s.Browser = env.Browser
+	}
+	if env.ProfileFormat != "" {
+		s.ProfileFormat = env.ProfileFormat
+	}
+	if len(env.ListFields) > 0 {
+		s.ListFields = env.ListFields
+	}
+	if len(env.AccountsAllowlist) > 0 {
+		s.AccountsAllowlist = env.AccountsAllowlist
+	}
+	s.env = name
+	return nil
+}
+
+// GetEnvironment returns the name of the selected Environment or an empty string
+func (s *Settings) GetEnvironment() string {
+	return s.env
+}
@@ -0,0 +1,182 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// ErrOffline is returned for every request made by the NewOfflineHTTPClient
+var ErrOffline = errors.New("Network access is disabled in --offline mode")
+
+// TLS versions which may be used for TLSMinVersion.  Anything older is insecure.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewHTTPClient returns an http.Client for talking to AWS which honors the
+// standard $HTTPS_PROXY, $HTTP_PROXY & $NO_PROXY environment variables unless
+// proxyUrl is set and uses the provided TLS config
+func NewHTTPClient(proxyUrl string, tlsConfig *tls.Config) (*http.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConfig
+
+	if proxyUrl != "" {
+		u, err := url.Parse(proxyUrl)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy URL: %s", proxyUrl)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+
+	return &http.Client{
+		Transport: &tlsHintTransport{transport: tr},
+	}, nil
+}
+
+// NewOfflineHTTPClient returns an http.Client which immediately fails every
+// request with ErrOffline instead of touching the network
+func NewOfflineHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &offlineTransport{},
+	}
+}
+
+// NewTLSConfig returns the tls.Config for talking to AWS which enforces the
+// minVersion (default 1.2), optional cipher suite allowlist and trusts the
+// certificates in the optional caBundle
+func NewTLSConfig(minVersion string, cipherSuites []string, caBundle string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if minVersion != "" {
+		v, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("Invalid TLSMinVersion %s: must be one of 1.2 or 1.3", minVersion)
+		}
+		config.MinVersion = v
+	}
+
+	if len(cipherSuites) > 0 {
+		if config.MinVersion == tls.VersionTLS13 {
+			return nil, fmt.Errorf("TLSCipherSuites can not be used with TLSMinVersion 1.3")
+		}
+		ids, err := tlsCipherSuites(cipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = ids
+	}
+
+	if caBundle != "" {
+		pem, err := ioutil.ReadFile(utils.GetHomePath(caBundle))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA bundle: %s", err.Error())
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No valid PEM certificates found in CA bundle: %s", caBundle)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// tlsCipherSuites converts the list of TLS 1.2 cipher suite names into their IDs.
+// Insecure suites are rejected and TLS 1.3 suites are not configurable in Go.
+func tlsCipherSuites(names []string) ([]uint16, error) {
+	secure := map[string]*tls.CipherSuite{}
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite
+	}
+	insecure := map[string]bool{}
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := []uint16{}
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if insecure[name] {
+			return ids, fmt.Errorf("Refusing to use insecure TLS cipher suite: %s", name)
+		}
+		suite, ok := secure[name]
+		if !ok {
+			return ids, fmt.Errorf("Unknown TLS cipher suite: %s", name)
+		}
+		tls12 := false
+		for _, v := range suite.SupportedVersions {
+			if v == tls.VersionTLS12 {
+				tls12 = true
+			}
+		}
+		if !tls12 {
+			return ids, fmt.Errorf("TLS 1.3 cipher suites are not configurable: %s", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// tlsHintTransport adds a hint about the CABundle option to TLS verification errors
+type tlsHintTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *tlsHintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil && isTLSVerifyError(err) {
+		err = fmt.Errorf("%w.  If you are behind a TLS intercepting proxy, please set `CABundle` in the config file or use --ca-bundle", err)
+	}
+	return resp, err
+}
+
+// isTLSVerifyError returns true if the error was due to an untrusted certificate
+func isTLSVerifyError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var certInvalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+
+	return errors.As(err, &unknownAuthority) ||
+		errors.As(err, &certInvalid) ||
+		errors.As(err, &hostname)
+}
+
+// offlineTransport refuses to make any network calls
+type offlineTransport struct{}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, ErrOffline
+}
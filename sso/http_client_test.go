@@ -0,0 +1,131 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// untrusted certificate includes a hint about the CA bundle
+	config, err := NewTLSConfig("", []string{}, "")
+	assert.NoError(t, err)
+	c, err := NewHTTPClient("", config)
+	assert.NoError(t, err)
+	_, err = c.Get(ts.URL)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CABundle")
+
+	// trust the test server via the CA bundle
+	f, err := os.CreateTemp("", "*.pem")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	err = pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	assert.NoError(t, err)
+	f.Close()
+
+	config, err = NewTLSConfig("", []string{}, f.Name())
+	assert.NoError(t, err)
+	c, err = NewHTTPClient("", config)
+	assert.NoError(t, err)
+	resp, err := c.Get(ts.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// invalid bundles & proxies
+	_, err = NewTLSConfig("", []string{}, "./testdata/does-not-exist.pem")
+	assert.Error(t, err)
+
+	_, err = NewTLSConfig("", []string{}, TEST_SETTINGS_FILE)
+	assert.Error(t, err)
+
+	_, err = NewHTTPClient("proxy.example.com", nil)
+	assert.Error(t, err)
+
+	c, err = NewHTTPClient("http://proxy.example.com:3128", nil)
+	assert.NoError(t, err)
+	tr := c.Transport.(*tlsHintTransport).transport.(*http.Transport)
+	req, _ := http.NewRequest("GET", "https://portal.sso.us-east-1.amazonaws.com", nil)
+	u, err := tr.Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.example.com:3128", u.Host)
+}
+
+func TestNewOfflineHTTPClient(t *testing.T) {
+	called := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer ts.Close()
+
+	c := NewOfflineHTTPClient()
+	_, err := c.Get(ts.URL)
+	assert.ErrorIs(t, err, ErrOffline)
+	assert.False(t, called)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	config, err := NewTLSConfig("", []string{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Nil(t, config.CipherSuites)
+
+	config, err = NewTLSConfig("1.3", []string{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+	// no downgrades
+	_, err = NewTLSConfig("1.1", []string{}, "")
+	assert.Error(t, err)
+	_, err = NewTLSConfig("1.0", []string{}, "")
+	assert.Error(t, err)
+
+	config, err = NewTLSConfig("1.2", []string{
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"tls_ecdhe_ecdsa_with_aes_256_gcm_sha384",
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}, config.CipherSuites)
+
+	_, err = NewTLSConfig("1.2", []string{"TLS_RSA_WITH_RC4_128_SHA"}, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insecure")
+
+	_, err = NewTLSConfig("1.2", []string{"TLS_AES_128_GCM_SHA256"}, "")
+	assert.Error(t, err)
+
+	_, err = NewTLSConfig("1.2", []string{"TLS_BOGUS"}, "")
+	assert.Error(t, err)
+
+	_, err = NewTLSConfig("1.3", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, "")
+	assert.Error(t, err)
+}
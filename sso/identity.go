@@ -0,0 +1,98 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// CallerIdentity is who AWS thinks we are according to sts:GetCallerIdentity
+type CallerIdentity struct {
+	Account string `json:"Account"`
+	Arn     string `json:"Arn"`
+	UserId  string `json:"UserId"`
+}
+
+// GetCallerIdentity uses the given credentials to call sts:GetCallerIdentity
+func GetCallerIdentity(ctx context.Context, creds *storage.RoleCredentials, region string, httpClient *http.Client) (CallerIdentity, error) {
+	cfg, err := credentialsConfig(ctx, creds, region, httpClient)
+	if err != nil {
+		return CallerIdentity{}, err
+	}
+
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return CallerIdentity{}, err
+	}
+	return CallerIdentity{
+		Account: aws.ToString(output.Account),
+		Arn:     aws.ToString(output.Arn),
+		UserId:  aws.ToString(output.UserId),
+	}, nil
+}
+
+// credentialsConfig returns an AWS config which uses the given static credentials
+func credentialsConfig(ctx context.Context, creds *storage.RoleCredentials, region string, httpClient *http.Client) (aws.Config, error) {
+	cfgCreds := credentials.NewStaticCredentialsProvider(
+		creds.AccessKeyId,
+		creds.SecretAccessKey,
+		creds.SessionToken,
+	)
+
+	return config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+		config.WithCredentialsProvider(cfgCreds),
+		config.WithHTTPClient(httpClient),
+	)
+}
+
+// RoleErrorReason returns a short description of why AWS refused to
+// give us credentials for a role
+func RoleErrorReason(err error) string {
+	if IsDurationTooLargeError(err) {
+		return "session duration exceeds the role MaxSessionDuration"
+	}
+	if IsThrottlingError(err) {
+		return "throttled by AWS"
+	}
+	var ae smithy.APIError
+	if !errors.As(err, &ae) {
+		return "error"
+	}
+	switch ae.ErrorCode() {
+	case "AccessDenied", "AccessDeniedException", "ForbiddenException":
+		return "access denied"
+	case "UnauthorizedException", "ExpiredToken", "ExpiredTokenException", "InvalidClientTokenId":
+		return "invalid or expired credentials"
+	case "RegionDisabledException":
+		return "STS is disabled in this region"
+	case "ValidationError", "ValidationException", "InvalidRequestException":
+		return "invalid request"
+	}
+	return ae.ErrorCode()
+}
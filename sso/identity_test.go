@@ -0,0 +1,44 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleErrorReason(t *testing.T) {
+	assert.Equal(t, "access denied", RoleErrorReason(&smithy.GenericAPIError{
+		Code:    "AccessDenied",
+		Message: "User is not authorized to perform: sts:AssumeRole",
+	}))
+	assert.Equal(t, "access denied", RoleErrorReason(fmt.Errorf("wrapped: %w", &smithy.GenericAPIError{Code: "ForbiddenException"})))
+	assert.Equal(t, "invalid or expired credentials", RoleErrorReason(&types.UnauthorizedException{}))
+	assert.Equal(t, "throttled by AWS", RoleErrorReason(&types.TooManyRequestsException{}))
+	assert.Equal(t, "session duration exceeds the role MaxSessionDuration", RoleErrorReason(&smithy.GenericAPIError{
+		Code:    "ValidationError",
+		Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role.",
+	}))
+	assert.Equal(t, "SomethingElse", RoleErrorReason(&smithy.GenericAPIError{Code: "SomethingElse"}))
+	assert.Equal(t, "error", RoleErrorReason(fmt.Errorf("some error")))
+}
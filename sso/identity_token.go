@@ -0,0 +1,149 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const IDENTITY_TOKEN_ISSUER = "aws-sso-cli"
+
+// IdentityTokenClaims are the JWT claims of the token generated by `identity-token`
+type IdentityTokenClaims struct {
+	Issuer         string `json:"iss"`
+	Subject        string `json:"sub"` // caller ARN according to sts:GetCallerIdentity
+	Audience       string `json:"aud,omitempty"`
+	IssuedAt       int64  `json:"iat"`
+	NotBefore      int64  `json:"nbf"`
+	Expires        int64  `json:"exp"`
+	Id             string `json:"jti"`
+	AccountId      string `json:"aws_account_id"`
+	RoleName       string `json:"aws_role_name"`
+	RoleArn        string `json:"aws_role_arn"`
+	UserId         string `json:"aws_user_id"`
+	SSO            string `json:"aws_sso"`
+	CredsExpiresAt int64  `json:"aws_credentials_exp"`
+}
+
+// SigningKey signs identity tokens with either HMAC-SHA256 (HS256) using a shared
+// secret or Ed25519 (EdDSA) using a private key
+type SigningKey struct {
+	alg     string
+	secret  []byte
+	private ed25519.PrivateKey
+}
+
+// LoadSigningKey reads the key from the file.  A PKCS#8 PEM encoded Ed25519
+// private key is used for EdDSA, anything else is the HS256 shared secret.
+// The key is sensitive, so we warn if other users can read the file.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read signing key: %s", err.Error())
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		log.Warnf("Signing key %s is accessible by other users, please chmod 600 it", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read signing key: %s", err.Error())
+	}
+	return ParseSigningKey(data)
+}
+
+// ParseSigningKey returns the SigningKey for the contents of a key file
+func ParseSigningKey(data []byte) (*SigningKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse signing key: %s", err.Error())
+		}
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("Unsupported signing key type %T, only Ed25519 is supported", key)
+		}
+		return &SigningKey{alg: "EdDSA", private: private}, nil
+	}
+
+	secret := []byte(strings.TrimSpace(string(data)))
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("HS256 signing key must be at least 32 bytes")
+	}
+	return &SigningKey{alg: "HS256", secret: secret}, nil
+}
+
+// Algorithm returns the JWT alg of the key
+func (k *SigningKey) Algorithm() string {
+	return k.alg
+}
+
+func (k *SigningKey) sign(data []byte) ([]byte, error) {
+	switch k.alg {
+	case "EdDSA":
+		return k.private.Sign(rand.Reader, data, crypto.Hash(0))
+	case "HS256":
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	}
+	return nil, fmt.Errorf("Unknown signing algorithm: %s", k.alg)
+}
+
+// NewIdentityToken returns the signed JWT for the claims.  If the claims have
+// no Id, a random one is generated.
+func NewIdentityToken(claims IdentityTokenClaims, key *SigningKey) (string, error) {
+	if claims.Id == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		claims.Id = hex.EncodeToString(id)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": key.alg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sig, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,115 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeIdentityToken(t *testing.T, token string) (map[string]string, IdentityTokenClaims, []byte) {
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+
+	header := map[string]string{}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &header))
+
+	claims := IdentityTokenClaims{}
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &claims))
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	return header, claims, sig
+}
+
+func TestIdentityTokenHS256(t *testing.T) {
+	_, err := ParseSigningKey([]byte("too short"))
+	assert.Error(t, err)
+
+	secret := "0123456789abcdef0123456789abcdef"
+	key, err := ParseSigningKey([]byte(secret + "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "HS256", key.Algorithm())
+
+	claims := IdentityTokenClaims{
+		Issuer:    IDENTITY_TOKEN_ISSUER,
+		Subject:   "arn:aws:sts::123456789012:assumed-role/Admin/user@example.com",
+		IssuedAt:  1000,
+		NotBefore: 1000,
+		Expires:   1300,
+		AccountId: "123456789012",
+		RoleName:  "Admin",
+	}
+	token, err := NewIdentityToken(claims, key)
+	assert.NoError(t, err)
+
+	header, decoded, sig := decodeIdentityToken(t, token)
+	assert.Equal(t, map[string]string{"alg": "HS256", "typ": "JWT"}, header)
+	assert.NotEmpty(t, decoded.Id)
+	claims.Id = decoded.Id
+	assert.Equal(t, claims, decoded)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(token[:strings.LastIndex(token, ".")]))
+	assert.True(t, hmac.Equal(mac.Sum(nil), sig))
+}
+
+func TestIdentityTokenEdDSA(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	key, err := LoadSigningKey(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "EdDSA", key.Algorithm())
+
+	token, err := NewIdentityToken(IdentityTokenClaims{Id: "my-id", Subject: "arn"}, key)
+	assert.NoError(t, err)
+	header, claims, sig := decodeIdentityToken(t, token)
+	assert.Equal(t, "EdDSA", header["alg"])
+	assert.Equal(t, "my-id", claims.Id)
+	assert.True(t, ed25519.Verify(public, []byte(token[:strings.LastIndex(token, ".")]), sig))
+
+	_, err = LoadSigningKey(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	_, err = ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// How long to wait for another process to complete the AWS SSO login when
+// there is no LoginTimeout
+const LOGIN_LOCK_TIMEOUT = 5 * time.Minute
+
+// how often to check if the other process has completed the login.
+// variable to make testing easier
+var loginLockPoll = 500 * time.Millisecond
+
+// loginOnce coordinates the AWS SSO login between concurrent aws-sso processes
+// via a lock file so that only one of them starts the device authorization and
+// opens the browser.  The others wait for the lock to be released and reuse
+// the new token.  If the other process takes too long, we login ourselves.
+func (as *AWSSSO) loginOnce() error {
+	path := as.loginLockFile()
+	if path == "" {
+		return as.reauthenticate()
+	}
+
+	timeout := as.LoginTimeout
+	if timeout == 0 {
+		timeout = LOGIN_LOCK_TIMEOUT
+	}
+	deadline := time.Now().Add(timeout)
+	ctx := as.getContext()
+	waited := false
+
+	for {
+		locked, err := acquireLoginLock(path, timeout)
+		if err != nil {
+			log.WithError(err).Warnf("Unable to create login lock")
+			return as.reauthenticate()
+		}
+		if locked {
+			defer os.Remove(path)
+			// the other process may have finished right before we got the lock
+			if waited && as.reloadToken() {
+				return nil
+			}
+			return as.reauthenticate()
+		}
+
+		if !waited {
+			log.Infof("Waiting for another aws-sso process to complete the AWS SSO login...")
+			waited = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(loginLockPoll):
+		}
+
+		if as.reloadToken() {
+			log.Infof("Using AWS SSO token from another aws-sso process")
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			log.Warnf("Timed out waiting for another aws-sso process to login.  Reauthenticating...")
+			return as.reauthenticate()
+		}
+	}
+}
+
+// loginLockFile returns the path of the lock file for this AWS SSO instance,
+// which is kept next to our cache file.  Returns an empty string if there
+// is no cache file.
+func (as *AWSSSO) loginLockFile() string {
+	if as.SSOConfig == nil || as.SSOConfig.settings == nil || as.SSOConfig.settings.cacheFile == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(as.StoreKey()))
+	dir := filepath.Dir(as.SSOConfig.settings.cacheFile)
+	return filepath.Join(dir, fmt.Sprintf("login-%x.lock", sum[:8]))
+}
+
+// acquireLoginLock creates the lock file and returns true if we now hold
+// the lock.  Lock files older than stale were left behind by a process which
+// died and are removed.
+func acquireLoginLock(path string, stale time.Duration) (bool, error) {
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			return true, f.Close()
+		} else if !errors.Is(err, os.ErrExist) {
+			return false, err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) <= stale {
+			return false, nil
+		}
+		log.Debugf("Removing stale login lock %s", path)
+		if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// reloadToken returns true if another process has saved a valid AWS SSO
+// token in the SecureStore and updates our token
+func (as *AWSSSO) reloadToken() bool {
+	if r, ok := as.store.(storage.Reloader); ok {
+		if err := r.Reload(); err != nil {
+			log.WithError(err).Debugf("Unable to reload SecureStore")
+		}
+	}
+
+	token := storage.CreateTokenResponse{}
+	if err := as.store.GetCreateTokenResponse(as.StoreKey(), &token); err != nil || as.tokenExpired(&token) {
+		return false
+	}
+	as.Token = token
+	return true
+}
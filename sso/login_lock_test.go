@@ -0,0 +1,93 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+func TestAcquireLoginLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "login.lock")
+
+	locked, err := acquireLoginLock(path, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	locked, err = acquireLoginLock(path, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, locked)
+
+	// stale locks are removed
+	old := time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, os.Chtimes(path, old, old))
+	locked, err = acquireLoginLock(path, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	_, err = acquireLoginLock(filepath.Join(path, "missing-dir", "login.lock"), time.Minute)
+	assert.Error(t, err)
+}
+
+func TestLoginOnceWaits(t *testing.T) {
+	defer func(poll time.Duration) { loginLockPoll = poll }(loginLockPoll)
+	loginLockPoll = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	storeFile := filepath.Join(dir, "store.json")
+	assert.NoError(t, os.WriteFile(storeFile, []byte("{}"), 0600))
+	store, err := storage.OpenJsonStore(storeFile)
+	assert.NoError(t, err)
+
+	var secureStore storage.SecureStorage = store
+	as := &AWSSSO{
+		SsoRegion: "us-west-1",
+		StartUrl:  "https://testing.awsapps.com/start",
+		store:     secureStore,
+		SSOConfig: &SSOConfig{
+			settings: &Settings{cacheFile: filepath.Join(dir, "cache.json")},
+		},
+		LoginTimeout: 5 * time.Second,
+	}
+	lockFile := as.loginLockFile()
+	assert.Equal(t, dir, filepath.Dir(lockFile))
+
+	// another process is logging in
+	locked, err := acquireLoginLock(lockFile, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		other, _ := storage.OpenJsonStore(storeFile)
+		_ = other.SaveCreateTokenResponse(as.StoreKey(), storage.CreateTokenResponse{
+			AccessToken: "new-token",
+			ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+		})
+		os.Remove(lockFile)
+	}()
+
+	assert.NoError(t, as.loginOnce())
+	assert.Equal(t, "new-token", as.Token.AccessToken)
+}
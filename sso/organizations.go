@@ -0,0 +1,199 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/storage"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// AWS Organizations is a global service homed in us-east-1
+const ORGANIZATIONS_REGION = "us-east-1"
+
+// Tag key used for the OU path of imported accounts
+const OU_TAG = "OU"
+
+// OrganizationsAPI is the subset of the AWS Organizations API we use
+type OrganizationsAPI interface {
+	ListAccounts(context.Context, *organizations.ListAccountsInput, ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error)
+	ListParents(context.Context, *organizations.ListParentsInput, ...func(*organizations.Options)) (*organizations.ListParentsOutput, error)
+	DescribeOrganizationalUnit(context.Context, *organizations.DescribeOrganizationalUnitInput, ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error)
+}
+
+// OrgAccount is an account in the AWS Organization
+type OrgAccount struct {
+	Id     int64
+	Name   string
+	OUPath string // ex: /Engineering/Production
+}
+
+// NewOrganizationsClient returns an AWS Organizations client using the given role credentials
+func NewOrganizationsClient(ctx context.Context, creds *storage.RoleCredentials, httpClient *http.Client) (*organizations.Client, error) {
+	cfgCreds := credentials.NewStaticCredentialsProvider(
+		creds.AccessKeyId,
+		creds.SecretAccessKey,
+		creds.SessionToken,
+	)
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(ORGANIZATIONS_REGION),
+		config.WithCredentialsProvider(cfgCreds),
+		config.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return organizations.NewFromConfig(cfg), nil
+}
+
+// IsOrganizationsAccessDenied returns true if the error means we can't query AWS Organizations
+func IsOrganizationsAccessDenied(err error) bool {
+	var ade *orgtypes.AccessDeniedException
+	var niu *orgtypes.AWSOrganizationsNotInUseException
+	return errors.As(err, &ade) || errors.As(err, &niu)
+}
+
+// ListOrgAccounts returns every active account in the AWS Organization along with its OU path
+func ListOrgAccounts(ctx context.Context, api OrganizationsAPI) ([]OrgAccount, error) {
+	accounts := []OrgAccount{}
+	ouPaths := map[string]string{} // cache of parent Id => OU path
+
+	input := organizations.ListAccountsInput{}
+	for {
+		resp, err := api.ListAccounts(ctx, &input)
+		if err != nil {
+			return accounts, err
+		}
+
+		for _, a := range resp.Accounts {
+			if a.Status != orgtypes.AccountStatusActive {
+				log.Debugf("Skipping %s account %s", a.Status, aws.ToString(a.Id))
+				continue
+			}
+
+			id, err := utils.AccountIdToInt64(aws.ToString(a.Id))
+			if err != nil {
+				return accounts, err
+			}
+
+			path, err := getOUPath(ctx, api, aws.ToString(a.Id), ouPaths)
+			if err != nil {
+				return accounts, err
+			}
+
+			accounts = append(accounts, OrgAccount{
+				Id:     id,
+				Name:   aws.ToString(a.Name),
+				OUPath: path,
+			})
+		}
+
+		if aws.ToString(resp.NextToken) == "" {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Id < accounts[j].Id })
+	return accounts, nil
+}
+
+// getOUPath walks up the tree from the given account or OU to the root and
+// returns the OU path.  Accounts directly under the root return "/"
+func getOUPath(ctx context.Context, api OrganizationsAPI, childId string, cache map[string]string) (string, error) {
+	resp, err := api.ListParents(ctx, &organizations.ListParentsInput{
+		ChildId: aws.String(childId),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Parents) == 0 {
+		return "", fmt.Errorf("Unable to find parent of %s", childId)
+	}
+
+	parent := resp.Parents[0]
+	parentId := aws.ToString(parent.Id)
+	if parent.Type == orgtypes.ParentTypeRoot {
+		return "/", nil
+	}
+
+	if path, ok := cache[parentId]; ok {
+		return path, nil
+	}
+
+	ou, err := api.DescribeOrganizationalUnit(ctx, &organizations.DescribeOrganizationalUnitInput{
+		OrganizationalUnitId: aws.String(parentId),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	path, err := getOUPath(ctx, api, parentId, cache)
+	if err != nil {
+		return "", err
+	}
+
+	path = strings.TrimSuffix(path, "/") + "/" + aws.ToString(ou.OrganizationalUnit.Name)
+	cache[parentId] = path
+	return path, nil
+}
+
+// ImportAccounts builds the Accounts config for every account we have AWS SSO roles in,
+// using the AWS Organizations account name and OU path when available
+func ImportAccounts(roles *Roles, orgAccounts []OrgAccount) map[string]*SSOAccount {
+	orgById := map[int64]OrgAccount{}
+	for _, a := range orgAccounts {
+		orgById[a.Id] = a
+	}
+
+	accounts := map[string]*SSOAccount{}
+	for id, account := range roles.Accounts {
+		accountId, _ := utils.AccountIdToString(id)
+		a := SSOAccount{
+			Name: account.Alias,
+		}
+
+		if org, ok := orgById[id]; ok {
+			a.Name = org.Name
+			a.Tags = map[string]string{
+				OU_TAG: org.OUPath,
+			}
+			delete(orgById, id)
+		}
+		accounts[accountId] = &a
+	}
+
+	for id := range orgById {
+		log.Infof("Skipping account %d: no AWS SSO roles", id)
+	}
+	return accounts
+}
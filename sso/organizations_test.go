@@ -0,0 +1,144 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// mock organizations
+type mockOrganizationsApi struct {
+	Accounts    []orgtypes.Account
+	Parents     map[string]orgtypes.Parent // childId => parent
+	OUNames     map[string]string          // ouId => name
+	Error       error
+	DescribeOUs int
+}
+
+func (m *mockOrganizationsApi) ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	// return one account per page to exercise pagination
+	i := 0
+	if params.NextToken != nil {
+		fmt.Sscanf(*params.NextToken, "%d", &i)
+	}
+	out := organizations.ListAccountsOutput{
+		Accounts: m.Accounts[i : i+1],
+	}
+	if i+1 < len(m.Accounts) {
+		out.NextToken = aws.String(fmt.Sprintf("%d", i+1))
+	}
+	return &out, nil
+}
+
+func (m *mockOrganizationsApi) ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error) {
+	return &organizations.ListParentsOutput{
+		Parents: []orgtypes.Parent{m.Parents[*params.ChildId]},
+	}, nil
+}
+
+func (m *mockOrganizationsApi) DescribeOrganizationalUnit(ctx context.Context, params *organizations.DescribeOrganizationalUnitInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationalUnitOutput, error) {
+	m.DescribeOUs++
+	return &organizations.DescribeOrganizationalUnitOutput{
+		OrganizationalUnit: &orgtypes.OrganizationalUnit{
+			Id:   params.OrganizationalUnitId,
+			Name: aws.String(m.OUNames[*params.OrganizationalUnitId]),
+		},
+	}, nil
+}
+
+func newMockOrganizationsApi() *mockOrganizationsApi {
+	return &mockOrganizationsApi{
+		Accounts: []orgtypes.Account{
+			{Id: aws.String("000000000002"), Name: aws.String("Prod"), Status: orgtypes.AccountStatusActive},
+			{Id: aws.String("000000000001"), Name: aws.String("Management"), Status: orgtypes.AccountStatusActive},
+			{Id: aws.String("000000000003"), Name: aws.String("Staging"), Status: orgtypes.AccountStatusActive},
+			{Id: aws.String("000000000004"), Name: aws.String("Closed"), Status: orgtypes.AccountStatusSuspended},
+		},
+		Parents: map[string]orgtypes.Parent{
+			"000000000001": {Id: aws.String("r-root"), Type: orgtypes.ParentTypeRoot},
+			"000000000002": {Id: aws.String("ou-prod"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"000000000003": {Id: aws.String("ou-stage"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"ou-eng":       {Id: aws.String("r-root"), Type: orgtypes.ParentTypeRoot},
+			"ou-prod":      {Id: aws.String("ou-eng"), Type: orgtypes.ParentTypeOrganizationalUnit},
+			"ou-stage":     {Id: aws.String("ou-eng"), Type: orgtypes.ParentTypeOrganizationalUnit},
+		},
+		OUNames: map[string]string{
+			"ou-eng":   "Engineering",
+			"ou-prod":  "Production",
+			"ou-stage": "Staging",
+		},
+	}
+}
+
+func TestListOrgAccounts(t *testing.T) {
+	api := newMockOrganizationsApi()
+	accounts, err := ListOrgAccounts(context.TODO(), api)
+	assert.NoError(t, err)
+	assert.Equal(t, []OrgAccount{
+		{Id: 1, Name: "Management", OUPath: "/"},
+		{Id: 2, Name: "Prod", OUPath: "/Engineering/Production"},
+		{Id: 3, Name: "Staging", OUPath: "/Engineering/Staging"},
+	}, accounts)
+	// ou-eng is only looked up once
+	assert.Equal(t, 3, api.DescribeOUs)
+
+	api.Error = &orgtypes.AccessDeniedException{}
+	_, err = ListOrgAccounts(context.TODO(), api)
+	assert.Error(t, err)
+	assert.True(t, IsOrganizationsAccessDenied(err))
+	assert.False(t, IsOrganizationsAccessDenied(fmt.Errorf("some error")))
+}
+
+func TestImportAccounts(t *testing.T) {
+	roles := &Roles{
+		Accounts: map[int64]*AWSAccount{
+			1: {Alias: "mgmt-alias"},
+			2: {Alias: "prod-alias"},
+			5: {Alias: "other-alias"},
+		},
+	}
+	orgAccounts := []OrgAccount{
+		{Id: 1, Name: "Management", OUPath: "/"},
+		{Id: 2, Name: "Prod", OUPath: "/Engineering/Production"},
+		{Id: 3, Name: "Staging", OUPath: "/Engineering/Staging"},
+	}
+
+	accounts := ImportAccounts(roles, orgAccounts)
+	assert.Len(t, accounts, 3)
+	assert.Equal(t, "Management", accounts["000000000001"].Name)
+	assert.Equal(t, map[string]string{"OU": "/"}, accounts["000000000001"].Tags)
+	assert.Equal(t, "Prod", accounts["000000000002"].Name)
+	assert.Equal(t, map[string]string{"OU": "/Engineering/Production"}, accounts["000000000002"].Tags)
+	assert.Equal(t, "other-alias", accounts["000000000005"].Name)
+	assert.Empty(t, accounts["000000000005"].Tags)
+
+	// no Organizations access
+	accounts = ImportAccounts(roles, []OrgAccount{})
+	assert.Equal(t, "prod-alias", accounts["000000000002"].Name)
+}
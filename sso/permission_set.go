@@ -0,0 +1,94 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/synfinatic/aws-sso-cli/storage"
+)
+
+// PermissionSetAPI is the subset of the AWS SSO Admin API we use
+type PermissionSetAPI interface {
+	DescribePermissionSet(context.Context, *ssoadmin.DescribePermissionSetInput, ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error)
+}
+
+// NewSSOAdminClient returns an AWS SSO Admin client using the given role credentials
+func NewSSOAdminClient(ctx context.Context, creds *storage.RoleCredentials, region string, httpClient *http.Client) (*ssoadmin.Client, error) {
+	cfgCreds := credentials.NewStaticCredentialsProvider(
+		creds.AccessKeyId,
+		creds.SecretAccessKey,
+		creds.SessionToken,
+	)
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+		config.WithCredentialsProvider(cfgCreds),
+		config.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ssoadmin.NewFromConfig(cfg), nil
+}
+
+// ParsePermissionSetARN returns the AWS SSO instance ARN of the given permission set ARN:
+// arn:aws:sso:::permissionSet/ssoins-<id>/ps-<id>
+func ParsePermissionSetARN(arn string) (string, error) {
+	s := strings.Split(arn, ":")
+	if len(s) != 6 || s[0] != "arn" || s[2] != "sso" {
+		return "", fmt.Errorf("Invalid permission set ARN: %s", arn)
+	}
+	parts := strings.Split(s[5], "/")
+	if len(parts) != 3 || parts[0] != "permissionSet" ||
+		!strings.HasPrefix(parts[1], "ssoins-") || !strings.HasPrefix(parts[2], "ps-") {
+		return "", fmt.Errorf("Invalid permission set ARN: %s", arn)
+	}
+	return fmt.Sprintf("arn:%s:sso:::instance/%s", s[1], parts[1]), nil
+}
+
+// ResolvePermissionSet returns the name of the role created by the permission
+// set in the given account.  AWS SSO names the role after the permission set.
+func ResolvePermissionSet(ctx context.Context, api PermissionSetAPI, roles *Roles, accountId int64, arn string) (string, error) {
+	instanceArn, err := ParsePermissionSetARN(arn)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := api.DescribePermissionSet(ctx, &ssoadmin.DescribePermissionSetInput{
+		InstanceArn:      aws.String(instanceArn),
+		PermissionSetArn: aws.String(arn),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Unable to describe permission set %s: %s", arn, err.Error())
+	}
+	name := aws.ToString(output.PermissionSet.Name)
+
+	if _, err := roles.GetRole(accountId, name); err != nil {
+		return "", fmt.Errorf("Permission set %s (%s) is not assigned to you in account %d", name, arn, accountId)
+	}
+	return name, nil
+}
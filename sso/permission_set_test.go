@@ -0,0 +1,113 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_PERMISSION_SET_ARN = "arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-abcdef1234567890"
+
+type mockPermissionSetApi struct {
+	names map[string]string // ARN => Name
+}
+
+func (m *mockPermissionSetApi) DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
+	if aws.ToString(params.InstanceArn) != "arn:aws:sso:::instance/ssoins-1234567890abcdef" {
+		return nil, fmt.Errorf("Invalid InstanceArn")
+	}
+	name, ok := m.names[aws.ToString(params.PermissionSetArn)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	return &ssoadmin.DescribePermissionSetOutput{
+		PermissionSet: &types.PermissionSet{
+			Name:             aws.String(name),
+			PermissionSetArn: params.PermissionSetArn,
+		},
+	}, nil
+}
+
+func TestParsePermissionSetARN(t *testing.T) {
+	instance, err := ParsePermissionSetARN(TEST_PERMISSION_SET_ARN)
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:sso:::instance/ssoins-1234567890abcdef", instance)
+
+	instance, err = ParsePermissionSetARN("arn:aws-us-gov:sso:::permissionSet/ssoins-1234/ps-5678")
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws-us-gov:sso:::instance/ssoins-1234", instance)
+
+	for _, arn := range []string{
+		"arn:aws:iam::123456789012:role/ReadOnly",
+		"arn:aws:sso:::instance/ssoins-1234",
+		"arn:aws:sso:::permissionSet/ps-5678",
+		"invalid",
+	} {
+		_, err = ParsePermissionSetARN(arn)
+		assert.Error(t, err, arn)
+	}
+}
+
+func TestResolvePermissionSet(t *testing.T) {
+	api := &mockPermissionSetApi{
+		names: map[string]string{
+			TEST_PERMISSION_SET_ARN: "ReadOnly",
+			"arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-0000000000000000": "Admin",
+		},
+	}
+	roles := &Roles{
+		Accounts: map[int64]*AWSAccount{
+			123456789012: {
+				Roles: map[string]*AWSRole{
+					"ReadOnly": {Arn: "arn:aws:iam::123456789012:role/ReadOnly"},
+				},
+			},
+		},
+	}
+
+	role, err := ResolvePermissionSet(context.TODO(), api, roles, 123456789012, TEST_PERMISSION_SET_ARN)
+	assert.NoError(t, err)
+	assert.Equal(t, "ReadOnly", role)
+
+	// not assigned in the account
+	_, err = ResolvePermissionSet(context.TODO(), api, roles, 123456789012,
+		"arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-0000000000000000")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not assigned")
+
+	// unknown account
+	_, err = ResolvePermissionSet(context.TODO(), api, roles, 999999999999, TEST_PERMISSION_SET_ARN)
+	assert.Error(t, err)
+
+	// unknown permission set
+	_, err = ResolvePermissionSet(context.TODO(), api, roles, 123456789012,
+		"arn:aws:sso:::permissionSet/ssoins-1234567890abcdef/ps-1111111111111111")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to describe")
+
+	_, err = ResolvePermissionSet(context.TODO(), api, roles, 123456789012, "invalid")
+	assert.Error(t, err)
+}
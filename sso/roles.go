@@ -22,6 +22,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -58,10 +59,14 @@ type AWSAccount struct {
 type AWSRole struct {
 	Arn           string            `json:"Arn"`
 	DefaultRegion string            `json:"DefaultRegion,omitempty"`
-	Expires       int64             `json:"Expires,omitempty"` // Seconds since Unix Epoch
+	Expires       int64             `json:"Expires,omitempty"`   // Seconds since Unix Epoch
+	LastUsed      int64             `json:"LastUsed,omitempty"`  // Seconds since Unix Epoch
+	Refreshed     int64             `json:"Refreshed,omitempty"` // Seconds since Unix Epoch
 	Profile       string            `json:"Profile,omitempty"`
 	Tags          map[string]string `json:"Tags,omitempty"`
 	Via           string            `json:"Via,omitempty"`
+	Description   string            `json:"Description,omitempty"`
+	Disabled      bool              `json:"Disabled,omitempty"` // hidden unless --show-disabled
 }
 
 // AccountIds returns all the configured AWS SSO AccountIds
@@ -73,6 +78,95 @@ func (r *Roles) AccountIds() []int64 {
 	return ret
 }
 
+// maximum number of edits for CheckAccountAccess and CheckRoleAccess to suggest
+// an AccountId or role name
+const ACCOUNT_SUGGEST_DISTANCE = 3
+
+// CheckAccountAccess returns an error if we have no roles in the given
+// AccountId, suggesting the closest AccountId we do have access to.  Always
+// succeeds if we have no accounts to check against.
+func (r *Roles) CheckAccountAccess(accountId int64) error {
+	if len(r.Accounts) == 0 {
+		return nil
+	}
+	if _, ok := r.Accounts[accountId]; ok {
+		return nil
+	}
+
+	want, err := utils.AccountIdToString(accountId)
+	if err != nil {
+		return err
+	}
+
+	ids := r.AccountIds()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var best int64
+	bestDistance := ACCOUNT_SUGGEST_DISTANCE + 1
+	for _, id := range ids {
+		s, _ := utils.AccountIdToString(id)
+		if d := utils.EditDistance(want, s); d < bestDistance {
+			best, bestDistance = id, d
+		}
+	}
+
+	if best == 0 {
+		return fmt.Errorf("You don't have access to account %s", want)
+	}
+	return fmt.Errorf("You don't have access to account %s (did you mean %s?)", want, r.accountLabel(best))
+}
+
+// accountLabel returns the AccountId along with the config Name and/or AWS Alias
+func (r *Roles) accountLabel(accountId int64) string {
+	label, _ := utils.AccountIdToString(accountId)
+	account := r.Accounts[accountId]
+	names := []string{}
+	if account.Name != "" {
+		names = append(names, account.Name)
+	}
+	if account.Alias != "" && account.Alias != account.Name {
+		names = append(names, account.Alias)
+	}
+	if len(names) > 0 {
+		label = fmt.Sprintf("%s \"%s\"", label, strings.Join(names, "\" / \""))
+	}
+	return label
+}
+
+// CheckRoleAccess returns an error if we do not have the given role in the
+// AccountId.  Always succeeds if we have no accounts to check against.
+func (r *Roles) CheckRoleAccess(accountId int64, roleName string) error {
+	if err := r.CheckAccountAccess(accountId); err != nil {
+		return err
+	}
+	if len(r.Accounts) == 0 {
+		return nil
+	}
+	if _, ok := r.Accounts[accountId].Roles[roleName]; ok {
+		return nil
+	}
+
+	names := []string{}
+	for name := range r.Accounts[accountId].Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best := ""
+	bestDistance := ACCOUNT_SUGGEST_DISTANCE + 1
+	for _, name := range names {
+		if d := utils.EditDistance(strings.ToLower(roleName), strings.ToLower(name)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+
+	arn := utils.MakeRoleARN(accountId, roleName)
+	if best == "" {
+		return fmt.Errorf("You don't have access to %s in account %s", arn, r.accountLabel(accountId))
+	}
+	return fmt.Errorf("You don't have access to %s (did you mean %s in account %s?)", arn, best, r.accountLabel(accountId))
+}
+
 // AllRoles returns all the Roles as a flat list
 func (r *Roles) GetAllRoles() []*AWSRoleFlat {
 	ret := []*AWSRoleFlat{}
@@ -124,6 +218,59 @@ func (r *Roles) GetRoleTags() *RoleTags {
 	return &ret
 }
 
+// TagCount is the number of roles which have the given tag key or value
+type TagCount struct {
+	Name  string `json:"name" header:"Name"`
+	Count int    `json:"count" header:"Count"`
+}
+
+func (tc TagCount) GetHeader(fieldName string) (string, error) {
+	v := reflect.ValueOf(tc)
+	return gotable.GetHeaderTag(v, fieldName)
+}
+
+// GetTagKeyCounts returns every tag key and the number of roles which have it
+// sorted by count descending
+func (r *Roles) GetTagKeyCounts() []TagCount {
+	counts := map[string]int{}
+	for _, role := range r.GetAllRoles() {
+		for k := range role.Tags {
+			counts[k]++
+		}
+	}
+	return sortTagCounts(counts)
+}
+
+// GetTagValueCounts returns every value for the given tag key and the number
+// of roles which have it sorted by count descending
+func (r *Roles) GetTagValueCounts(key string) []TagCount {
+	counts := map[string]int{}
+	for _, role := range r.GetAllRoles() {
+		if v, ok := role.Tags[key]; ok {
+			counts[v]++
+		}
+	}
+	return sortTagCounts(counts)
+}
+
+// sortTagCounts sorts by count descending and then by name
+func sortTagCounts(counts map[string]int) []TagCount {
+	ret := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		ret = append(ret, TagCount{
+			Name:  name,
+			Count: count,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
 // Role returns the specified role as an AWSRoleFlat
 func (r *Roles) GetRole(accountId int64, roleName string) (*AWSRoleFlat, error) {
 	account, ok := r.Accounts[accountId]
@@ -138,6 +285,8 @@ func (r *Roles) GetRole(accountId int64, roleName string) (*AWSRoleFlat, error)
 				AccountAlias:  account.Alias,
 				EmailAddress:  account.EmailAddress,
 				Expires:       role.Expires,
+				LastUsed:      role.LastUsed,
+				Refreshed:     role.Refreshed,
 				Arn:           role.Arn,
 				RoleName:      roleName,
 				Profile:       role.Profile,
@@ -147,6 +296,8 @@ func (r *Roles) GetRole(accountId int64, roleName string) (*AWSRoleFlat, error)
 				StartUrl:      r.StartUrl,
 				Tags:          map[string]string{},
 				Via:           role.Via,
+				Description:   role.Description,
+				Disabled:      role.Disabled,
 			}
 
 			// copy over account tags
@@ -239,26 +390,23 @@ func (r *Roles) GetRoleChain(accountId int64, roleName string) []*AWSRoleFlat {
 func (r *Roles) MatchingRoles(tags map[string]string) []*AWSRoleFlat {
 	ret := []*AWSRoleFlat{}
 	for _, role := range r.GetAllRoles() {
-		matches := true
-		for k, v := range tags {
-			if roleVal, ok := role.Tags[k]; ok {
-				if roleVal != v {
-					matches = false
-				}
-			} else {
-				matches = false
-			}
-			if !matches {
-				break
-			}
-		}
-		if matches {
+		if role.MatchesTags(tags) {
 			ret = append(ret, role)
 		}
 	}
 	return ret
 }
 
+// MatchesTags returns true if the role has all of the given tag key/values
+func (r *AWSRoleFlat) MatchesTags(tags map[string]string) bool {
+	for k, v := range tags {
+		if roleVal, ok := r.Tags[k]; !ok || roleVal != v {
+			return false
+		}
+	}
+	return true
+}
+
 // MatchingRolesWithTagKey returns the roles that have the tag key
 func (r *Roles) MatchingRolesWithTagKey(key string) []*AWSRoleFlat {
 	ret := []*AWSRoleFlat{}
@@ -275,13 +423,18 @@ func (r *Roles) MatchingRolesWithTagKey(key string) []*AWSRoleFlat {
 
 // This is what we always return for a role definition
 type AWSRoleFlat struct {
-	Id            int               `header:"Id"`
+	Id            int               `json:"Id" header:"Id"`
 	AccountId     int64             `json:"AccountId" header:"AccountId"`
+	AccountIdStr  string            `json:"-" header:"AccountId"`
 	AccountName   string            `json:"AccountName" header:"AccountName"`
 	AccountAlias  string            `json:"AccountAlias" header:"AccountAlias"`
 	EmailAddress  string            `json:"EmailAddress" header:"EmailAddress"`
 	Expires       int64             `json:"Expires" header:"ExpiresEpoch"`
 	ExpiresStr    string            `json:"-" header:"Expires"`
+	LastUsed      int64             `json:"LastUsed" header:"LastUsedEpoch"`
+	LastUsedStr   string            `json:"-" header:"LastUsed"`
+	Refreshed     int64             `json:"Refreshed" header:"RefreshedEpoch"`
+	RefreshedStr  string            `json:"-" header:"Refreshed"`
 	Arn           string            `json:"Arn" header:"ARN"`
 	RoleName      string            `json:"RoleName" header:"Role"`
 	Profile       string            `json:"Profile" header:"Profile"`
@@ -291,6 +444,8 @@ type AWSRoleFlat struct {
 	StartUrl      string            `json:"StartUrl" header:"StartUrl"`
 	Tags          map[string]string `json:"Tags"` // not supported by GenerateTable
 	Via           string            `json:"Via,omitempty" header:"Via"`
+	Description   string            `json:"Description,omitempty" header:"Description"`
+	Disabled      bool              `json:"Disabled,omitempty" header:"Disabled"`
 	// SelectTags    map[string]string // tags without spaces
 }
 
@@ -304,8 +459,22 @@ func (r *AWSRoleFlat) IsExpired() bool {
 	if r.Expires == 0 {
 		return true
 	}
-	d := time.Until(time.Unix(r.Expires, 0))
-	return d <= 0
+	return utils.Remaining(r.Expires) <= 0
+}
+
+// IsValid returns if this role has creds available which will not expire
+// within MinValidRemainingMinutes
+func (r *AWSRoleFlat) IsValid() bool {
+	return r.Expires != 0 && utils.ValidUntil(r.Expires)
+}
+
+// ExpiresWithin returns if this role has no creds available or they
+// expire within the given duration
+func (r *AWSRoleFlat) ExpiresWithin(d time.Duration) bool {
+	if r.Expires == 0 {
+		return true
+	}
+	return utils.Remaining(r.Expires) <= d
 }
 
 // ExpiresIn returns how long until this role expires as a string
@@ -330,6 +499,7 @@ func (r *AWSRoleFlat) ProfileName(s *Settings) (string, error) {
 		"AccountIdStr":  accountIdToStr,
 		"EmptyString":   emptyString,
 		"FirstItem":     firstItem,
+		"NormalizeName": utils.NormalizeName,
 		"StringsJoin":   stringsJoin,
 		"StringReplace": stringReplace,
 	}
@@ -345,10 +515,17 @@ func (r *AWSRoleFlat) ProfileName(s *Settings) (string, error) {
 		return "", err
 	}
 
+	// normalize a copy so the original names are still used for display
+	role := *r
+	if s.NormalizeAccountNames {
+		role.AccountName = utils.NormalizeName(role.AccountName)
+		role.AccountAlias = utils.NormalizeName(role.AccountAlias)
+	}
+
 	buf := new(bytes.Buffer)
-	log.Tracef("RoleInfo: %s", spew.Sdump(r))
+	log.Tracef("RoleInfo: %s", spew.Sdump(role))
 	log.Tracef("Template: %s", spew.Sdump(templ))
-	if err := templ.Execute(buf, r); err != nil {
+	if err := templ.Execute(buf, role); err != nil {
 		log.WithError(err).Errorf("Unable to generate AWS_SSO_PROFILE")
 	}
 
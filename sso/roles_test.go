@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -107,6 +108,58 @@ func (suite *CacheRolesTestSuite) TestAccountIds() {
 	assert.NotContains(t, roles.AccountIds(), int64(2582346))
 }
 
+func (suite *CacheRolesTestSuite) TestCheckAccountAccess() {
+	t := suite.T()
+	roles := suite.cache.SSO[suite.cache.ssoName].Roles
+
+	assert.NoError(t, roles.CheckAccountAccess(258234615182))
+
+	// transposed digits
+	err := roles.CheckAccountAccess(258234615128)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "258234615128")
+	assert.Contains(t, err.Error(), "did you mean 258234615182 \"OurCompany Control Tower Playground\"")
+
+	err = roles.CheckAccountAccess(111111111111)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "did you mean")
+
+	// nothing to validate against
+	empty := &Roles{Accounts: map[int64]*AWSAccount{}}
+	assert.NoError(t, empty.CheckAccountAccess(111111111111))
+
+	// suggestions include both the config Name and the AWS Alias
+	named := &Roles{Accounts: map[int64]*AWSAccount{
+		111111111112: {Name: "Production", Alias: "acme-prod"},
+	}}
+	err = named.CheckAccountAccess(111111111111)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "did you mean 111111111112 \"Production\" / \"acme-prod\"")
+}
+
+func (suite *CacheRolesTestSuite) TestCheckRoleAccess() {
+	t := suite.T()
+	roles := suite.cache.SSO[suite.cache.ssoName].Roles
+
+	assert.NoError(t, roles.CheckRoleAccess(258234615182, "AWSAdministratorAccess"))
+
+	err := roles.CheckRoleAccess(258234615182, "NoSuchRole")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "arn:aws:iam::258234615182:role/NoSuchRole")
+	assert.NotContains(t, err.Error(), "did you mean")
+
+	// typo in the role name
+	err = roles.CheckRoleAccess(258234615182, "AWSAdministratorAcess")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "did you mean AWSAdministratorAccess")
+
+	assert.Error(t, roles.CheckRoleAccess(111111111111, "AWSAdministratorAccess"))
+
+	// nothing to validate against
+	empty := &Roles{Accounts: map[int64]*AWSAccount{}}
+	assert.NoError(t, empty.CheckRoleAccess(111111111111, "AWSAdministratorAccess"))
+}
+
 func (suite *CacheRolesTestSuite) TestGetAllRoles() {
 	t := suite.T()
 
@@ -149,6 +202,35 @@ func (suite *CacheRolesTestSuite) TestGetRoleTags() {
 	assert.NotContains(t, tags[arn]["Email"], "foobar@ourcompany.com")
 }
 
+func (suite *CacheRolesTestSuite) TestGetTagKeyCounts() {
+	t := suite.T()
+	roles := suite.cache.SSO[suite.cache.ssoName].Roles
+
+	counts := roles.GetTagKeyCounts()
+	assert.NotEmpty(t, counts)
+	for i := 1; i < len(counts); i++ {
+		assert.GreaterOrEqual(t, counts[i-1].Count, counts[i].Count)
+	}
+	assert.Contains(t, counts, TagCount{Name: "Foo", Count: 1})
+	assert.Contains(t, counts, TagCount{Name: "Role", Count: len(roles.GetAllRoles())})
+}
+
+func (suite *CacheRolesTestSuite) TestGetTagValueCounts() {
+	t := suite.T()
+	roles := suite.cache.SSO[suite.cache.ssoName].Roles
+
+	counts := roles.GetTagValueCounts("Foo")
+	assert.Equal(t, []TagCount{{Name: "Bar", Count: 1}}, counts)
+
+	counts = roles.GetTagValueCounts("AccountID")
+	assert.NotEmpty(t, counts)
+	for i := 1; i < len(counts); i++ {
+		assert.GreaterOrEqual(t, counts[i-1].Count, counts[i].Count)
+	}
+
+	assert.Empty(t, roles.GetTagValueCounts("NoSuchKey"))
+}
+
 func (suite *CacheRolesTestSuite) TestGetRole() {
 	t := suite.T()
 	roles := suite.cache.SSO[suite.cache.ssoName].Roles
@@ -183,6 +265,19 @@ func (suite *CacheRolesTestSuite) TestProfileName() {
 	p, err = r.ProfileName(settings)
 	assert.NoError(t, err)
 	assert.Equal(t, "ourcompany_control_tower_playground:AWSADMINISTRATORACCESS", p)
+
+	settings.ProfileFormat = `{{ .AccountAlias | NormalizeName }}:{{ .RoleName }}`
+	p, err = r.ProfileName(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "ourcompany-control-tower-playground:AWSAdministratorAccess", p)
+
+	settings.NormalizeAccountNames = true
+	settings.ProfileFormat = `{{ FirstItem .AccountName .AccountAlias }}:{{ .RoleName }}`
+	p, err = r.ProfileName(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "ourcompany-control-tower-playground:AWSAdministratorAccess", p)
+	assert.Equal(t, "OurCompany Control Tower Playground", r.AccountAlias)
+	settings.NormalizeAccountNames = false
 }
 
 func (suite *CacheRolesTestSuite) TestGetRoleByProfile() {
@@ -222,3 +317,16 @@ func (suite *CacheRolesTestSuite) TestGetEnvVarTags() {
 	}
 	assert.Equal(t, x, flat.GetEnvVarTags(&settings))
 }
+
+func TestExpiresWithin(t *testing.T) {
+	r := AWSRoleFlat{}
+	assert.True(t, r.ExpiresWithin(0))
+
+	r.Expires = time.Now().Add(10 * time.Minute).Unix()
+	assert.False(t, r.ExpiresWithin(0))
+	assert.False(t, r.ExpiresWithin(5*time.Minute))
+	assert.True(t, r.ExpiresWithin(15*time.Minute))
+
+	r.Expires = time.Now().Add(-1 * time.Minute).Unix()
+	assert.True(t, r.ExpiresWithin(0))
+}
@@ -0,0 +1,107 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// AWS limits the number of managed session policies
+const MAX_SESSION_POLICY_ARNS = 10
+
+var policyArnRegexp = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(aws|\d{12}):policy/.+$`)
+
+// SessionPolicy scopes down the permissions of the credentials returned
+// by sts:AssumeRole
+type SessionPolicy struct {
+	PolicyArns []string
+	Policy     string // inline JSON policy document
+}
+
+// LoadSessionPolicy validates the policy ARNs and reads the inline policy
+// document from the (optional) file
+func LoadSessionPolicy(arns []string, file string) (SessionPolicy, error) {
+	p := SessionPolicy{
+		PolicyArns: []string{},
+	}
+
+	if len(arns) > MAX_SESSION_POLICY_ARNS {
+		return p, fmt.Errorf("Too many policy ARNs: %d > %d", len(arns), MAX_SESSION_POLICY_ARNS)
+	}
+
+	for _, arn := range arns {
+		if !policyArnRegexp.MatchString(arn) {
+			return p, fmt.Errorf("Invalid IAM policy ARN: %s", arn)
+		}
+		p.PolicyArns = append(p.PolicyArns, arn)
+	}
+	sort.Strings(p.PolicyArns)
+
+	if file != "" {
+		policy, err := ioutil.ReadFile(utils.GetHomePath(file))
+		if err != nil {
+			return p, fmt.Errorf("Unable to read policy file: %s", err.Error())
+		}
+		if !json.Valid(policy) {
+			return p, fmt.Errorf("Policy file %s is not valid JSON", file)
+		}
+		p.Policy = strings.TrimSpace(string(policy))
+	}
+	return p, nil
+}
+
+// IsEmpty returns if there are no policies to apply
+func (p SessionPolicy) IsEmpty() bool {
+	return len(p.PolicyArns) == 0 && p.Policy == ""
+}
+
+// Hash returns a unique value for the policy or an empty string if IsEmpty()
+func (p SessionPolicy) Hash() string {
+	if p.IsEmpty() {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(strings.Join(p.PolicyArns, ",")))
+	h.Write([]byte{0})
+	h.Write([]byte(p.Policy))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// apply adds the session policies to the AssumeRoleInput
+func (p SessionPolicy) apply(input *sts.AssumeRoleInput) {
+	if p.Policy != "" {
+		input.Policy = aws.String(p.Policy)
+	}
+	for _, arn := range p.PolicyArns {
+		input.PolicyArns = append(input.PolicyArns, types.PolicyDescriptorType{
+			Arn: aws.String(arn),
+		})
+	}
+}
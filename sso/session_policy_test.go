@@ -0,0 +1,89 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSessionPolicy(t *testing.T) {
+	p, err := LoadSessionPolicy([]string{}, "")
+	assert.NoError(t, err)
+	assert.True(t, p.IsEmpty())
+	assert.Equal(t, "", p.Hash())
+
+	p, err = LoadSessionPolicy([]string{
+		"arn:aws:iam::aws:policy/ReadOnlyAccess",
+		"arn:aws:iam::123456789012:policy/MyPolicy",
+	}, "")
+	assert.NoError(t, err)
+	assert.False(t, p.IsEmpty())
+	assert.NotEmpty(t, p.Hash())
+
+	// order of ARNs does not matter
+	p2, err := LoadSessionPolicy([]string{
+		"arn:aws:iam::123456789012:policy/MyPolicy",
+		"arn:aws:iam::aws:policy/ReadOnlyAccess",
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, p.Hash(), p2.Hash())
+
+	_, err = LoadSessionPolicy([]string{"arn:aws:iam::123456789012:role/MyRole"}, "")
+	assert.Error(t, err)
+
+	tooMany := []string{}
+	for i := 0; i <= MAX_SESSION_POLICY_ARNS; i++ {
+		tooMany = append(tooMany, "arn:aws:iam::aws:policy/ReadOnlyAccess")
+	}
+	_, err = LoadSessionPolicy(tooMany, "")
+	assert.Error(t, err)
+
+	_, err = LoadSessionPolicy([]string{}, "./testdata/does-not-exist.json")
+	assert.Error(t, err)
+}
+
+func TestLoadSessionPolicyFile(t *testing.T) {
+	f, err := os.CreateTemp("", "*.json")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`)
+	assert.NoError(t, err)
+	f.Close()
+
+	p, err := LoadSessionPolicy([]string{}, f.Name())
+	assert.NoError(t, err)
+	assert.False(t, p.IsEmpty())
+	assert.NotEmpty(t, p.Hash())
+
+	input := sts.AssumeRoleInput{}
+	p.apply(&input)
+	assert.Contains(t, aws.ToString(input.Policy), "s3:GetObject")
+	assert.Empty(t, input.PolicyArns)
+
+	err = os.WriteFile(f.Name(), []byte(`{"Version": `), 0600)
+	assert.NoError(t, err)
+	_, err = LoadSessionPolicy([]string{}, f.Name())
+	assert.Error(t, err)
+}
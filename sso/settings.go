@@ -19,12 +19,17 @@ package sso
  */
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	// "github.com/davecgh/go-spew/spew"
 	goyaml "github.com/goccy/go-yaml"
@@ -32,6 +37,7 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
 	log "github.com/sirupsen/logrus"
 	"github.com/synfinatic/aws-sso-cli/utils"
 	// goyaml "gopkg.in/yaml.v3"
@@ -40,30 +46,94 @@ import (
 const (
 	AWS_SSO_SESSION_EXPIRATION_FORMAT = "2006-01-02 15:04:05 -0700 MST"
 	CACHE_TTL                         = 60 * 60 * 24 // 1 day in seconds
+	REDACTED                          = "**REDACTED**"
+	STDIN_CONFIG                      = "-" // read the config from stdin
 )
 
+// where to read the config from when using STDIN_CONFIG
+var configStdin io.Reader = os.Stdin
+
 type Settings struct {
-	configFile        string                 // name of this file
-	cacheFile         string                 // name of cache file; always passed in via CLI args
-	Cache             *Cache                 `yaml:"-"` // our cache data
-	SSO               map[string]*SSOConfig  `koanf:"SSOConfig" yaml:"SSOConfig,omitempty"`
-	DefaultSSO        string                 `koanf:"DefaultSSO" yaml:"DefaultSSO,omitempty"`   // specify default SSO by key
-	SecureStore       string                 `koanf:"SecureStore" yaml:"SecureStore,omitempty"` // json or keyring
-	DefaultRegion     string                 `koanf:"DefaultRegion" yaml:"DefaultRegion,omitempty"`
-	ConsoleDuration   int32                  `koanf:"ConsoleDuration" yaml:"ConsoleDuration,omitempty"`
-	JsonStore         string                 `koanf:"JsonStore" yaml:"JsonStore,omitempty"`
-	UrlAction         string                 `koanf:"UrlAction" yaml:"UrlAction,omitempty"`
-	Browser           string                 `koanf:"Browser" yaml:"Browser,omitempty"`
-	ProfileFormat     string                 `koanf:"ProfileFormat" yaml:"ProfileFormat,omitempty"`
-	AccountPrimaryTag []string               `koanf:"AccountPrimaryTag" yaml:"AccountPrimaryTag,omitempty"`
-	PromptColors      PromptColors           `koanf:"PromptColors" yaml:"PromptColors,omitempty"` // go-prompt colors
-	LogLevel          string                 `koanf:"LogLevel" yaml:"LogLevel,omitempty"`
-	LogLines          bool                   `koanf:"LogLines" yaml:"LogLines,omitempty"`
-	HistoryLimit      int64                  `koanf:"HistoryLimit" yaml:"HistoryLimit,omitempty"`
-	HistoryMinutes    int64                  `koanf:"HistoryMinutes" yaml:"HistoryMinutes,omitempty"`
-	ListFields        []string               `koanf:"ListFields" yaml:"ListFields,omitempty"`
-	ConfigVariables   map[string]interface{} `koanf:"ConfigVariables" yaml:"ConfigVariables,omitempty"`
-	EnvVarTags        []string               `koanf:"EnvVarTags" yaml:"EnvVarTags,omitempty"`
+	configFile               string                  // name of this file
+	stdinConfig              []byte                  // config read from stdin
+	cacheFile                string                  // name of cache file; always passed in via CLI args
+	allAccounts              bool                    // ignore AccountsAllowlist
+	showDisabled             bool                    // include roles disabled in the config
+	showHiddenTags           bool                    // ignore HiddenTags
+	offline                  bool                    // never make network calls
+	browserOverride          string                  // --browser flag
+	httpClient               *http.Client            // for talking to AWS
+	tlsConfig                *tls.Config             // used by httpClient
+	accountNames             map[int64]string        // AccountNames + AccountNamesFile
+	env                      string                  // selected Environment
+	Cache                    *Cache                  `yaml:"-"` // our cache data
+	SSO                      map[string]*SSOConfig   `koanf:"SSOConfig" yaml:"SSOConfig,omitempty"`
+	DefaultSSO               string                  `koanf:"DefaultSSO" yaml:"DefaultSSO,omitempty"`   // specify default SSO by key
+	SecureStore              string                  `koanf:"SecureStore" yaml:"SecureStore,omitempty"` // json or keyring
+	DefaultRegion            string                  `koanf:"DefaultRegion" yaml:"DefaultRegion,omitempty"`
+	ConsoleDuration          int32                   `koanf:"ConsoleDuration" yaml:"ConsoleDuration,omitempty"`
+	JsonStore                string                  `koanf:"JsonStore" yaml:"JsonStore,omitempty"`
+	UrlAction                string                  `koanf:"UrlAction" yaml:"UrlAction,omitempty"`
+	UrlActions               map[string]string       `koanf:"UrlActions" yaml:"UrlActions,omitempty"`
+	RemoteOpenCommand        []string                `koanf:"RemoteOpenCommand" yaml:"RemoteOpenCommand,omitempty"`
+	Browser                  string                  `koanf:"Browser" yaml:"Browser,omitempty"`
+	MaxOpenUrls              int                     `koanf:"MaxOpenUrls" yaml:"MaxOpenUrls,omitempty"`
+	OpenUrlDelayMilliseconds int64                   `koanf:"OpenUrlDelayMilliseconds" yaml:"OpenUrlDelayMilliseconds,omitempty"`
+	OpenUrlRetries           int                     `koanf:"OpenUrlRetries" yaml:"OpenUrlRetries,omitempty"`
+	OpenUrlRetryMilliseconds int64                   `koanf:"OpenUrlRetryMilliseconds" yaml:"OpenUrlRetryMilliseconds,omitempty"`
+	ProfileFormat            string                  `koanf:"ProfileFormat" yaml:"ProfileFormat,omitempty"`
+	AccountPrimaryTag        []string                `koanf:"AccountPrimaryTag" yaml:"AccountPrimaryTag,omitempty"`
+	PromptColors             PromptColors            `koanf:"PromptColors" yaml:"PromptColors,omitempty"` // go-prompt colors
+	LogLevel                 string                  `koanf:"LogLevel" yaml:"LogLevel,omitempty"`
+	LogLines                 bool                    `koanf:"LogLines" yaml:"LogLines,omitempty"`
+	HistoryLimit             int64                   `koanf:"HistoryLimit" yaml:"HistoryLimit,omitempty"`
+	HistoryMinutes           int64                   `koanf:"HistoryMinutes" yaml:"HistoryMinutes,omitempty"`
+	ListFields               []string                `koanf:"ListFields" yaml:"ListFields,omitempty"`
+	TimeFormat               *utils.TimeRemainFormat `koanf:"TimeFormat" yaml:"TimeFormat,omitempty"`
+	MaskAccounts             bool                    `koanf:"MaskAccounts" yaml:"MaskAccounts,omitempty"`
+	NormalizeAccountNames    bool                    `koanf:"NormalizeAccountNames" yaml:"NormalizeAccountNames,omitempty"`
+	ConfigVariables          map[string]interface{}  `koanf:"ConfigVariables" yaml:"ConfigVariables,omitempty"`
+	ProfileOutput            string                  `koanf:"ProfileOutput" yaml:"ProfileOutput,omitempty"`
+	EnvVarTags               []string                `koanf:"EnvVarTags" yaml:"EnvVarTags,omitempty"`
+	DefaultExec              []string                `koanf:"DefaultExec" yaml:"DefaultExec,omitempty"`
+	PostExecHook             []string                `koanf:"PostExecHook" yaml:"PostExecHook,omitempty"`
+	NotifyAction             string                  `koanf:"NotifyAction" yaml:"NotifyAction,omitempty"`
+	NotifyWebhook            string                  `koanf:"NotifyWebhook" yaml:"NotifyWebhook,omitempty"`
+	NotifyMinutes            int64                   `koanf:"NotifyMinutes" yaml:"NotifyMinutes,omitempty"`
+	AccountsAllowlist        []string                `koanf:"AccountsAllowlist" yaml:"AccountsAllowlist,omitempty"`
+	AccountNames             map[string]string       `koanf:"AccountNames" yaml:"AccountNames,omitempty"`
+	AccountNamesFile         string                  `koanf:"AccountNamesFile" yaml:"AccountNamesFile,omitempty"`
+	ProxyUrl                 string                  `koanf:"ProxyUrl" yaml:"ProxyUrl,omitempty"`
+	CABundle                 string                  `koanf:"CABundle" yaml:"CABundle,omitempty"`
+	TLSMinVersion            string                  `koanf:"TLSMinVersion" yaml:"TLSMinVersion,omitempty"`
+	TLSCipherSuites          []string                `koanf:"TLSCipherSuites" yaml:"TLSCipherSuites,omitempty"`
+	LoginTimeout             int64                   `koanf:"LoginTimeout" yaml:"LoginTimeout,omitempty"`
+	TokenExpiryBufferMinutes int64                   `koanf:"TokenExpiryBufferMinutes" yaml:"TokenExpiryBufferMinutes,omitempty"`
+	MaxConcurrency           int                     `koanf:"MaxConcurrency" yaml:"MaxConcurrency,omitempty"`
+	Environments             map[string]*Environment `koanf:"Environments" yaml:"Environments,omitempty"`
+	DefaultEnv               string                  `koanf:"DefaultEnv" yaml:"DefaultEnv,omitempty"`
+	PostLoginHook            []string                `koanf:"PostLoginHook" yaml:"PostLoginHook,omitempty"`
+	PrefetchOnLogin          bool                    `koanf:"PrefetchOnLogin" yaml:"PrefetchOnLogin,omitempty"`
+	PrefetchTags             map[string]string       `koanf:"PrefetchTags" yaml:"PrefetchTags,omitempty"`
+	ExpiryWarnMinutes        int64                   `koanf:"ExpiryWarnMinutes" yaml:"ExpiryWarnMinutes,omitempty"`
+	ExpiryCriticalMinutes    int64                   `koanf:"ExpiryCriticalMinutes" yaml:"ExpiryCriticalMinutes,omitempty"`
+	RefreshIfExpiringMinutes int64                   `koanf:"RefreshIfExpiringMinutes" yaml:"RefreshIfExpiringMinutes,omitempty"`
+	MinValidRemainingMinutes int64                   `koanf:"MinValidRemainingMinutes" yaml:"MinValidRemainingMinutes,omitempty"`
+	Aliases                  map[string]string       `koanf:"Aliases" yaml:"Aliases,omitempty"`
+	UseServerTime            bool                    `koanf:"UseServerTime" yaml:"UseServerTime,omitempty"`
+	IgnoreClockSkew          bool                    `koanf:"IgnoreClockSkew" yaml:"IgnoreClockSkew,omitempty"`
+	UseAwsCliToken           bool                    `koanf:"UseAwsCliToken" yaml:"UseAwsCliToken,omitempty"`
+	TagsFile                 string                  `koanf:"TagsFile" yaml:"TagsFile,omitempty"`
+	HiddenTags               []string                `koanf:"HiddenTags" yaml:"HiddenTags,omitempty"`
+	ConfirmTags              []ConfirmTag            `koanf:"ConfirmTags" yaml:"ConfirmTags,omitempty"`
+	IdentityTokenKeyFile     string                  `koanf:"IdentityTokenKeyFile" yaml:"IdentityTokenKeyFile,omitempty"`
+}
+
+// ConfirmTag is a list of Key=Value tags and the prompt to confirm before using
+// a role which matches all of them
+type ConfirmTag struct {
+	Filter []string `koanf:"Filter" yaml:"Filter"`
+	Prompt string   `koanf:"Prompt" yaml:"Prompt"`
 }
 
 type SSOConfig struct {
@@ -72,6 +142,10 @@ type SSOConfig struct {
 	StartUrl      string                 `koanf:"StartUrl" yaml:"StartUrl"`
 	Accounts      map[string]*SSOAccount `koanf:"Accounts" yaml:"Accounts,omitempty"` // key must be a string to avoid parse errors!
 	DefaultRegion string                 `koanf:"DefaultRegion" yaml:"DefaultRegion,omitempty"`
+	// Role ARN allowed to call sso:DescribePermissionSet
+	PermissionSetRole string `koanf:"PermissionSetRole" yaml:"PermissionSetRole,omitempty"`
+	// Console federation endpoint instead of the default for the partition
+	FederationUrl string `koanf:"FederationUrl" yaml:"FederationUrl,omitempty"`
 }
 
 type SSOAccount struct {
@@ -80,6 +154,7 @@ type SSOAccount struct {
 	Tags          map[string]string   `koanf:"Tags" yaml:"Tags,omitempty" `
 	Roles         map[string]*SSORole `koanf:"Roles" yaml:"Roles,omitempty"`
 	DefaultRegion string              `koanf:"DefaultRegion" yaml:"DefaultRegion,omitempty"`
+	Enabled       *bool               `koanf:"Enabled" yaml:"Enabled,omitempty"` // nil is enabled
 }
 
 type SSORole struct {
@@ -91,6 +166,10 @@ type SSORole struct {
 	Via            string            `koanf:"Via" yaml:"Via,omitempty"`
 	ExternalId     string            `koanf:"ExternalId" yaml:"ExternalId,omitempty"`
 	SourceIdentity string            `koanf:"SourceIdentity" yaml:"SourceIdentity,omitempty"`
+	Browser        string            `koanf:"Browser" yaml:"Browser,omitempty"`
+	PostExecHook   []string          `koanf:"PostExecHook" yaml:"PostExecHook,omitempty"`
+	Description    string            `koanf:"Description" yaml:"Description,omitempty"`
+	Enabled        *bool             `koanf:"Enabled" yaml:"Enabled,omitempty"` // nil inherits from the account
 }
 
 // GetDefaultRegion scans the config settings file to pick the most local DefaultRegion from the tree
@@ -100,11 +179,6 @@ func (s *Settings) GetDefaultRegion(id int64, roleName string, noRegion bool) st
 		return ""
 	}
 
-	accountId, err := utils.AccountIdToString(id)
-	if err != nil {
-		log.WithError(err).Fatalf("Unable to GetDefaultRegion()")
-	}
-
 	currentRegion := os.Getenv("AWS_DEFAULT_REGION")
 	ssoManagedRegion := os.Getenv("AWS_SSO_DEFAULT_REGION")
 
@@ -113,9 +187,20 @@ func (s *Settings) GetDefaultRegion(id int64, roleName string, noRegion bool) st
 		return ""
 	}
 
+	return s.GetRoleRegion(s.DefaultSSO, id, roleName)
+}
+
+// GetRoleRegion returns the most specific DefaultRegion in the config for the
+// given role of the AWS SSO instance, ignoring the environment
+func (s *Settings) GetRoleRegion(ssoName string, id int64, roleName string) string {
+	accountId, err := utils.AccountIdToString(id)
+	if err != nil {
+		log.WithError(err).Fatalf("Unable to GetRoleRegion()")
+	}
+
 	role := s.DefaultRegion
 
-	if c, ok := s.SSO[s.DefaultSSO]; ok {
+	if c, ok := s.SSO[ssoName]; ok {
 		if c.DefaultRegion != "" {
 			role = c.DefaultRegion
 		}
@@ -133,6 +218,46 @@ func (s *Settings) GetDefaultRegion(id int64, roleName string, noRegion bool) st
 	return role
 }
 
+// GetBrowser returns the browser to open URLs for the given role in order of:
+// --browser flag, role Browser, global Browser or the system default browser ("")
+func (s *Settings) GetBrowser(id int64, roleName string) string {
+	if s.browserOverride != "" {
+		return s.browserOverride
+	}
+
+	accountId, err := utils.AccountIdToString(id)
+	if err != nil {
+		log.WithError(err).Fatalf("Unable to GetBrowser()")
+	}
+
+	if c, ok := s.SSO[s.DefaultSSO]; ok {
+		if a, ok := c.Accounts[accountId]; ok {
+			if r, ok := a.Roles[roleName]; ok && r.Browser != "" {
+				return r.Browser
+			}
+		}
+	}
+	return s.Browser
+}
+
+// GetPostExecHook returns the command & args to run after `exec` for the given
+// role in order of: role PostExecHook or global PostExecHook
+func (s *Settings) GetPostExecHook(id int64, roleName string) []string {
+	accountId, err := utils.AccountIdToString(id)
+	if err != nil {
+		log.WithError(err).Fatalf("Unable to GetPostExecHook()")
+	}
+
+	if c, ok := s.SSO[s.DefaultSSO]; ok {
+		if a, ok := c.Accounts[accountId]; ok {
+			if r, ok := a.Roles[roleName]; ok && len(r.PostExecHook) > 0 {
+				return r.PostExecHook
+			}
+		}
+	}
+	return s.PostExecHook
+}
+
 var DEFAULT_ACCOUNT_PRIMARY_TAGS []string = []string{
 	"AccountName",
 	"AccountAlias",
@@ -140,11 +265,22 @@ var DEFAULT_ACCOUNT_PRIMARY_TAGS []string = []string{
 }
 
 type OverrideSettings struct {
-	Browser    string
-	DefaultSSO string
-	LogLevel   string
-	LogLines   bool
-	UrlAction  string
+	AllAccounts     bool
+	Browser         string
+	CABundle        string
+	DefaultSSO      string
+	Env             string
+	IgnoreClockSkew bool
+	LogLevel        string
+	LogLines        bool
+	LoginTimeout    int64
+	Offline         bool
+	ProxyUrl        string
+	ReloadTags      bool
+	ShowDisabled    bool
+	ShowHiddenTags  bool
+	SSORegion       string
+	UrlAction       string
 }
 
 // Loads our settings from config, cache and CLI args
@@ -161,7 +297,16 @@ func LoadSettings(configFile, cacheFile string, defaults map[string]interface{},
 		return s, fmt.Errorf("Unable to load default settings: %s", err.Error())
 	}
 
-	if err := konf.Load(file.Provider(configFile), yaml.Parser()); err != nil {
+	if configFile == STDIN_CONFIG {
+		data, err := ioutil.ReadAll(configStdin)
+		if err != nil {
+			return s, fmt.Errorf("Unable to read config from stdin: %s", err.Error())
+		}
+		s.stdinConfig = data
+		if err := konf.Load(rawbytes.Provider(data), yaml.Parser()); err != nil {
+			return s, fmt.Errorf("Unable to parse config from stdin: %s", err.Error())
+		}
+	} else if err := konf.Load(file.Provider(configFile), yaml.Parser()); err != nil {
 		return s, fmt.Errorf("Unable to open config file %s: %s", configFile, err.Error())
 	}
 
@@ -173,8 +318,93 @@ func LoadSettings(configFile, cacheFile string, defaults map[string]interface{},
 		s.AccountPrimaryTag = append(s.AccountPrimaryTag, DEFAULT_ACCOUNT_PRIMARY_TAGS...)
 	}
 
+	if err := s.applyEnvironment(override.Env); err != nil {
+		return s, err
+	}
+
 	s.setOverrides(override)
 
+	if err := s.validateExpiryThresholds(); err != nil {
+		return s, err
+	}
+
+	if err := s.validateAliases(configFile); err != nil {
+		return s, err
+	}
+
+	if err := s.loadAccountNames(); err != nil {
+		return s, err
+	}
+
+	if err := s.validateUrlActions(); err != nil {
+		return s, err
+	}
+
+	if s.RefreshIfExpiringMinutes < 0 {
+		return s, fmt.Errorf("RefreshIfExpiringMinutes must not be negative")
+	}
+
+	if s.MinValidRemainingMinutes < 0 {
+		return s, fmt.Errorf("MinValidRemainingMinutes must not be negative")
+	}
+
+	if s.TokenExpiryBufferMinutes < 0 {
+		return s, fmt.Errorf("TokenExpiryBufferMinutes must not be negative")
+	}
+
+	for _, pattern := range s.HiddenTags {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return s, fmt.Errorf("Invalid HiddenTags pattern %s: %s", pattern, err.Error())
+		}
+	}
+
+	if s.OpenUrlDelayMilliseconds < 0 {
+		return s, fmt.Errorf("OpenUrlDelayMilliseconds must not be negative")
+	}
+
+	if len(s.DefaultExec) > 0 && s.DefaultExec[0] == "" {
+		return s, fmt.Errorf("DefaultExec must start with a command")
+	}
+
+	if len(s.PostExecHook) > 0 && s.PostExecHook[0] == "" {
+		return s, fmt.Errorf("PostExecHook must start with a command")
+	}
+
+	if s.OpenUrlRetries < 0 {
+		return s, fmt.Errorf("OpenUrlRetries must not be negative")
+	}
+
+	if s.OpenUrlRetryMilliseconds < 0 {
+		return s, fmt.Errorf("OpenUrlRetryMilliseconds must not be negative")
+	}
+
+	switch s.ProfileOutput {
+	case "", "json", "yaml", "yaml-stream", "text", "table":
+	default:
+		return s, fmt.Errorf("Invalid ProfileOutput '%s'. Valid options: json, yaml, yaml-stream, text, table",
+			s.ProfileOutput)
+	}
+
+	var err error
+	if s.tlsConfig, err = NewTLSConfig(s.TLSMinVersion, s.TLSCipherSuites, s.CABundle); err != nil {
+		return s, err
+	}
+	if s.httpClient, err = NewHTTPClient(s.ProxyUrl, s.tlsConfig); err != nil {
+		return s, err
+	}
+	if !s.IgnoreClockSkew {
+		s.httpClient = WithClockSkewDetection(s.httpClient, s.UseServerTime)
+	}
+	if s.offline {
+		s.httpClient = NewOfflineHTTPClient()
+	}
+
+	for name, c := range s.SSO {
+		if err := validateFederationUrl(name, c.FederationUrl); err != nil {
+			return s, err
+		}
+	}
+
 	if _, ok := s.SSO[s.DefaultSSO]; !ok {
 		// Select our SSO Provider
 		if len(s.SSO) == 0 {
@@ -200,17 +430,45 @@ func LoadSettings(configFile, cacheFile string, defaults map[string]interface{},
 		}
 	}
 
+	if override.SSORegion != "" {
+		s.SSO[s.DefaultSSO].SSORegion = override.SSORegion
+	}
 	s.SSO[s.DefaultSSO].Refresh(s)
 
 	// load the cache
-	var err error
-	if s.Cache, err = OpenCache(s.cacheFile, s); err != nil {
+	if s.Cache, err = OpenCache(s.cacheFile, s); errors.Is(err, ErrCacheTooNew) {
+		return s, err
+	} else if err != nil {
 		log.Infof("%s", err.Error())
 	}
 
+	// merge any changes to our external tags file into the cache
+	if updated, err := s.Cache.ApplyTagsFile(override.ReloadTags); err != nil {
+		return s, err
+	} else if updated && s.cacheFile != "" {
+		if err = s.Cache.Save(false); err != nil {
+			log.WithError(err).Warnf("Unable to save cache")
+		}
+	}
+
 	return s, nil
 }
 
+// validateExpiryThresholds ensures the list coloring thresholds make sense
+func (s *Settings) validateExpiryThresholds() error {
+	if s.ExpiryWarnMinutes < 0 || s.ExpiryCriticalMinutes < 0 {
+		return fmt.Errorf("ExpiryWarnMinutes and ExpiryCriticalMinutes must not be negative")
+	}
+	if s.ExpiryWarnMinutes == 0 && s.ExpiryCriticalMinutes == 0 {
+		return nil // coloring disabled
+	}
+	if s.ExpiryWarnMinutes <= s.ExpiryCriticalMinutes {
+		return fmt.Errorf("ExpiryWarnMinutes (%d) must be greater than ExpiryCriticalMinutes (%d)",
+			s.ExpiryWarnMinutes, s.ExpiryCriticalMinutes)
+	}
+	return nil
+}
+
 // Save overwrites the current config file with our settings (not recommended)
 func (s *Settings) Save(configFile string, overwrite bool) error {
 	if _, err := os.Stat(configFile); !errors.Is(err, os.ErrNotExist) && !overwrite {
@@ -232,6 +490,48 @@ func (s *Settings) Save(configFile string, overwrite bool) error {
 	return ioutil.WriteFile(configFile, data, 0600)
 }
 
+// Redacted returns a deep copy of our settings with any secrets masked
+// so it is safe to print for debugging purposes
+func (s *Settings) Redacted() (*Settings, error) {
+	data, err := goyaml.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Settings{}
+	if err = goyaml.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+
+	r.ProxyUrl = redactUrl(r.ProxyUrl)
+	if r.NotifyWebhook != "" {
+		r.NotifyWebhook = REDACTED
+	}
+
+	for _, sso := range r.SSO {
+		for _, account := range sso.Accounts {
+			for _, role := range account.Roles {
+				if role != nil && role.ExternalId != "" {
+					role.ExternalId = REDACTED
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// redactUrl masks any password embedded in the given URL
+func redactUrl(u string) string {
+	if u == "" {
+		return u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return REDACTED
+	}
+	return parsed.Redacted()
+}
+
 // configure our settings using the overrides
 func (s *Settings) setOverrides(override OverrideSettings) {
 	// Setup Logging
@@ -262,6 +562,7 @@ func (s *Settings) setOverrides(override OverrideSettings) {
 	// Other overrides from CLI
 	if override.Browser != "" {
 		s.Browser = override.Browser
+		s.browserOverride = override.Browser
 	}
 	if override.DefaultSSO != "" {
 		s.DefaultSSO = override.DefaultSSO
@@ -270,13 +571,127 @@ func (s *Settings) setOverrides(override OverrideSettings) {
 	if override.UrlAction != "" {
 		s.UrlAction = override.UrlAction
 	}
+
+	if override.ProxyUrl != "" {
+		s.ProxyUrl = override.ProxyUrl
+	}
+
+	if override.CABundle != "" {
+		s.CABundle = override.CABundle
+	}
+
+	if override.LoginTimeout > 0 {
+		s.LoginTimeout = override.LoginTimeout
+	}
+
+	if override.IgnoreClockSkew {
+		s.IgnoreClockSkew = true
+	}
+
+	s.allAccounts = override.AllAccounts
+	s.showDisabled = override.ShowDisabled
+	s.showHiddenTags = override.ShowHiddenTags
+	s.offline = override.Offline
+}
+
+// ShowDisabled returns if roles disabled in the config should be displayed
+func (s *Settings) ShowDisabled() bool {
+	return s.showDisabled
+}
+
+// TagHidden returns if the tag key matches one of the HiddenTags patterns
+// and should be left out of our output
+func (s *Settings) TagHidden(key string) bool {
+	if s.showHiddenTags {
+		return false
+	}
+	for _, pattern := range s.HiddenTags {
+		if match, _ := filepath.Match(pattern, key); match {
+			return true
+		}
+	}
+	return false
+}
+
+// VisibleTags returns the tags without any which are hidden.  The tags
+// are never modified so it is safe to pass the tags of a cached role.
+func (s *Settings) VisibleTags(tags map[string]string) map[string]string {
+	if s.showHiddenTags || len(s.HiddenTags) == 0 {
+		return tags
+	}
+	visible := map[string]string{}
+	for k, v := range tags {
+		if !s.TagHidden(k) {
+			visible[k] = v
+		}
+	}
+	return visible
+}
+
+// Offline returns if we must only use cached data and never talk to AWS
+func (s *Settings) Offline() bool {
+	return s.offline
+}
+
+// HTTPClient returns the http.Client to use for talking to AWS
+func (s *Settings) HTTPClient() *http.Client {
+	if s.httpClient == nil {
+		return http.DefaultClient
+	}
+	return s.httpClient
+}
+
+// TLSConfig returns a copy of the tls.Config used for talking to AWS
+func (s *Settings) TLSConfig() *tls.Config {
+	if s.tlsConfig == nil {
+		return &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return s.tlsConfig.Clone()
+}
+
+// AccountAllowed returns if the given AWS Account should be queried for roles
+// based on the AccountsAllowlist which may contain AccountIDs or glob patterns
+// matching the account name.  An empty allowlist allows all accounts.
+func (s *Settings) AccountAllowed(accountId int64, accountName string) bool {
+	if s.allAccounts || len(s.AccountsAllowlist) == 0 {
+		return true
+	}
+
+	for _, allow := range s.AccountsAllowlist {
+		if id, err := utils.AccountIdToInt64(allow); err == nil && id == accountId {
+			return true
+		}
+		if match, err := filepath.Match(allow, accountName); err != nil {
+			log.WithError(err).Warnf("Invalid AccountsAllowlist pattern: %s", allow)
+		} else if match {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Settings) ConfigFile() string {
 	return s.configFile
 }
 
+func (s *Settings) CacheFile() string {
+	return s.cacheFile
+}
+
+// ReadConfig returns the contents of our config file, even if it was read from stdin
+func (s *Settings) ReadConfig() ([]byte, error) {
+	if s.configFile == STDIN_CONFIG {
+		return s.stdinConfig, nil
+	}
+	return ioutil.ReadFile(s.configFile)
+}
+
 func (s *Settings) CreatedAt() int64 {
+	if s.configFile == STDIN_CONFIG {
+		// no file to check, so only changes to the account names count
+		return s.accountNamesModified()
+	}
+
 	f, err := os.Open(s.configFile)
 	if err != nil {
 		log.WithError(err).Fatalf("Unable to open %s", s.configFile)
@@ -287,6 +702,11 @@ func (s *Settings) CreatedAt() int64 {
 	if err != nil {
 		log.WithError(err).Fatalf("Unable to Stat() %s", s.configFile)
 	}
+
+	// account names are part of our config, even when in another file
+	if modified := s.accountNamesModified(); modified > info.ModTime().Unix() {
+		return modified
+	}
 	return info.ModTime().Unix()
 }
 
@@ -349,6 +769,93 @@ func (c *SSOConfig) CreatedAt() int64 {
 	return c.settings.CreatedAt()
 }
 
+// HTTPClient returns the http.Client to use for talking to AWS
+func (c *SSOConfig) HTTPClient() *http.Client {
+	if c.settings == nil {
+		return http.DefaultClient
+	}
+	return c.settings.HTTPClient()
+}
+
+// Partition returns the AWS partition (aws, aws-us-gov or aws-cn) of this AWS SSO instance
+func (c *SSOConfig) Partition() string {
+	return utils.DetectPartition(c.StartUrl, c.SSORegion)
+}
+
+// GetFederationUrl returns the console federation endpoint for this AWS SSO
+// instance: either the FederationUrl or the default for our partition
+func (c *SSOConfig) GetFederationUrl() string {
+	if c.FederationUrl != "" {
+		return c.FederationUrl
+	}
+	return utils.FederationUrl(c.Partition())
+}
+
+// validateFederationUrl verifies the optional FederationUrl is a well formed https URL
+func validateFederationUrl(ssoName, federationUrl string) error {
+	if federationUrl == "" {
+		return nil
+	}
+	u, err := url.Parse(federationUrl)
+	if err != nil {
+		return fmt.Errorf("Invalid FederationUrl for %s: %s", ssoName, err.Error())
+	}
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("Invalid FederationUrl for %s: %s must be an https URL", ssoName, federationUrl)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("Invalid FederationUrl for %s: %s must not have a query or fragment", ssoName, federationUrl)
+	}
+	return nil
+}
+
+// LoginTimeout returns how long to wait for the user to complete the AWS SSO login
+func (c *SSOConfig) LoginTimeout() time.Duration {
+	if c.settings == nil {
+		return 0
+	}
+	return time.Duration(c.settings.LoginTimeout) * time.Minute
+}
+
+// TokenExpiryBuffer returns how long before its real expiry the AWS SSO token
+// is treated as expired
+func (c *SSOConfig) TokenExpiryBuffer() time.Duration {
+	if c.settings == nil {
+		return 0
+	}
+	return time.Duration(c.settings.TokenExpiryBufferMinutes) * time.Minute
+}
+
+// Name returns the name of this AWS SSO instance in the config file
+func (c *SSOConfig) Name() string {
+	if c.settings == nil {
+		return ""
+	}
+	for name, sso := range c.settings.SSO {
+		if sso == c {
+			return name
+		}
+	}
+	return ""
+}
+
+// PostLoginHook returns the command & args to run after logging into AWS SSO
+func (c *SSOConfig) PostLoginHook() []string {
+	if c.settings == nil {
+		return []string{}
+	}
+	return c.settings.PostLoginHook
+}
+
+// UseAwsCliToken returns true if we should fall back to the AWS SSO token
+// cached by `aws sso login`
+func (c *SSOConfig) UseAwsCliToken() bool {
+	if c.settings == nil {
+		return false
+	}
+	return c.settings.UseAwsCliToken
+}
+
 // GetRoles returns a list of all the roles for this SSOConfig
 func (s *SSOConfig) GetRoles() []*SSORole {
 	roles := []*SSORole{}
@@ -415,6 +922,20 @@ func (s *SSOConfig) GetRole(accountId int64, role string) (*SSORole, error) {
 	return &SSORole{}, fmt.Errorf("Unable to find %s:%s", id, role)
 }
 
+// RoleSessionName returns the session name used to sts:AssumeRole the role
+// via another role, or an empty string if AWS SSO provides the credentials directly
+func (s *SSOConfig) RoleSessionName(accountId int64, role string) string {
+	configRole, err := s.GetRole(accountId, role)
+	if err != nil || configRole.Via == "" {
+		return ""
+	}
+	viaAccountId, viaRole, err := utils.ParseRoleARN(configRole.Via)
+	if err != nil {
+		return ""
+	}
+	return roleSessionName(viaAccountId, viaRole)
+}
+
 // HasRole returns true/false if the given Account has the provided arn
 func (a *SSOAccount) HasRole(arn string) bool {
 	hasRole := false
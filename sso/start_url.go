@@ -0,0 +1,164 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// https://docs.aws.amazon.com/general/latest/gr/sso.html
+var SSORegions []string = []string{
+	"us-east-1",
+	"us-east-2",
+	"us-west-2",
+	"ap-south-1",
+	"ap-northeast-2",
+	"ap-southeast-1",
+	"ap-southeast-2",
+	"ap-northeast-1",
+	"ca-central-1",
+	"eu-central-1",
+	"eu-west-1",
+	"eu-west-2",
+	"eu-west-3",
+	"eu-north-1",
+	"sa-east-1",
+	"us-gov-west-1",
+}
+
+// ValidateStartUrl verifies the AWS SSO start URL is well formed and returns
+// it without any trailing slash
+func ValidateStartUrl(startUrl string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(startUrl))
+	if err != nil {
+		return "", fmt.Errorf("Invalid AWS SSO start URL %s: %s", startUrl, err.Error())
+	}
+
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("Invalid AWS SSO start URL %s: must use https", startUrl)
+	}
+
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("Invalid AWS SSO start URL %s: missing hostname", startUrl)
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// these types & variables make our code easier to unit test
+type startUrlGetterFunc func(context.Context, *http.Client, string) (*http.Response, error)
+
+var startUrlGetter startUrlGetterFunc = getStartUrl
+
+// max bytes of the AWS access portal page we look at for the region
+const startUrlMaxBody = 1024 * 1024
+
+// hostnames of the regional AWS SSO endpoints referenced by the AWS access portal
+var ssoRegionRegexp = regexp.MustCompile(`(?:portal\.sso|oidc)\.([a-z0-9-]+)\.amazonaws\.com|([a-z0-9-]+)\.signin\.aws`)
+
+// getStartUrl does an HTTP GET of the start URL
+func getStartUrl(ctx context.Context, client *http.Client, startUrl string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, startUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// DiscoverSSORegion verifies the start URL is an AWS SSO portal and returns
+// which of the provided regions it is hosted in.  The region is read from the
+// regional AWS SSO endpoints referenced by the AWS access portal page, so no
+// OIDC client is registered.
+func DiscoverSSORegion(ctx context.Context, client *http.Client, startUrl string, regions []string) (string, error) {
+	resp, err := startUrlGetter(ctx, client, startUrl)
+	if err != nil {
+		return "", fmt.Errorf("Unable to connect to %s: %s", startUrl, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s is not a valid AWS SSO portal: %s", startUrl, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, startUrlMaxBody))
+	if err != nil {
+		return "", fmt.Errorf("Unable to read %s: %s", startUrl, err.Error())
+	}
+
+	for _, match := range ssoRegionRegexp.FindAllStringSubmatch(string(body), -1) {
+		region := match[1] + match[2] // only one group matches
+		log.Debugf("Found AWS SSO endpoint %s", match[0])
+		for _, r := range regions {
+			if r == region {
+				return region, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s is not a valid AWS SSO portal: unable to determine the AWS SSO region", startUrl)
+}
+
+// isRegionMismatchCandidate returns true if AWS SSO OIDC rejected the start URL
+// in a way which happens when it is hosted in a different region
+func isRegionMismatchCandidate(err error) bool {
+	var ae smithy.APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	switch ae.ErrorCode() {
+	case "InvalidRequestException", "InvalidClientException", "UnauthorizedClientException", "AccessDeniedException":
+		return true
+	}
+	return false
+}
+
+// RegionMismatchError returns an error explaining which region the start URL
+// is actually hosted in when it was rejected by AWS SSO in ssoRegion.
+// Otherwise the original error is returned.
+func RegionMismatchError(ctx context.Context, client *http.Client, err error, startUrl, ssoRegion string) error {
+	if !isRegionMismatchCandidate(err) {
+		return err
+	}
+
+	regions := []string{}
+	for _, region := range SSORegions {
+		if region != ssoRegion {
+			regions = append(regions, region)
+		}
+	}
+	region, derr := DiscoverSSORegion(ctx, client, startUrl, regions)
+	if derr != nil {
+		log.Debugf("Unable to find another AWS SSO region for %s: %s", startUrl, derr.Error())
+		return err
+	}
+	return fmt.Errorf("%s is hosted in %s, not the configured SSORegion %s.  "+
+		"Please set SSORegion to %s in the config file or use --sso-region %s",
+		startUrl, region, ssoRegion, region, region)
+}
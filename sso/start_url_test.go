@@ -0,0 +1,122 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStartUrl(t *testing.T) {
+	u, err := ValidateStartUrl("https://d-754545454.awsapps.com/start")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://d-754545454.awsapps.com/start", u)
+
+	u, err = ValidateStartUrl(" https://d-754545454.awsapps.com/start/#/ ")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://d-754545454.awsapps.com/start", u)
+
+	_, err = ValidateStartUrl("http://d-754545454.awsapps.com/start")
+	assert.Error(t, err)
+
+	_, err = ValidateStartUrl("d-754545454.awsapps.com")
+	assert.Error(t, err)
+
+	_, err = ValidateStartUrl("https:///start")
+	assert.Error(t, err)
+}
+
+func mockStartUrlGetter(status int, body string) startUrlGetterFunc {
+	return func(ctx context.Context, client *http.Client, u string) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d", status),
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+}
+
+const testPortalPage = `<html><head>
+<script>window.config = {"api": "https://portal.sso.us-west-2.amazonaws.com"};</script>
+</head></html>`
+
+func TestDiscoverSSORegion(t *testing.T) {
+	defer func() {
+		startUrlGetter = getStartUrl
+	}()
+
+	regions := []string{"us-east-1", "us-west-2", "eu-west-1"}
+
+	startUrlGetter = mockStartUrlGetter(200, testPortalPage)
+	r, err := DiscoverSSORegion(context.TODO(), http.DefaultClient, "https://foo.awsapps.com/start", regions)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", r)
+
+	startUrlGetter = mockStartUrlGetter(200, `<a href="https://eu-west-1.signin.aws/platform/login">`)
+	r, err = DiscoverSSORegion(context.TODO(), http.DefaultClient, "https://foo.awsapps.com/start", regions)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", r)
+
+	// region we were not asked about
+	startUrlGetter = mockStartUrlGetter(200, testPortalPage)
+	_, err = DiscoverSSORegion(context.TODO(), http.DefaultClient, "https://foo.awsapps.com/start", []string{"eu-west-1"})
+	assert.Error(t, err)
+
+	startUrlGetter = mockStartUrlGetter(200, "<html>not an AWS access portal</html>")
+	_, err = DiscoverSSORegion(context.TODO(), http.DefaultClient, "https://foo.awsapps.com/start", regions)
+	assert.Error(t, err)
+
+	startUrlGetter = mockStartUrlGetter(404, testPortalPage)
+	_, err = DiscoverSSORegion(context.TODO(), http.DefaultClient, "https://foo.awsapps.com/start", regions)
+	assert.Error(t, err)
+
+	startUrlGetter = func(ctx context.Context, client *http.Client, u string) (*http.Response, error) {
+		return nil, fmt.Errorf("no such host")
+	}
+	_, err = DiscoverSSORegion(context.TODO(), http.DefaultClient, "https://foo.awsapps.com/start", regions)
+	assert.Error(t, err)
+}
+
+func TestRegionMismatchError(t *testing.T) {
+	defer func() {
+		startUrlGetter = getStartUrl
+	}()
+
+	startUrlGetter = mockStartUrlGetter(200, "https://portal.sso.eu-west-1.amazonaws.com")
+
+	invalid := &smithy.GenericAPIError{Code: "InvalidRequestException", Message: "invalid request"}
+	err := RegionMismatchError(context.TODO(), http.DefaultClient, invalid, "https://foo.awsapps.com/start", "us-east-1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is hosted in eu-west-1, not the configured SSORegion us-east-1")
+	assert.Contains(t, err.Error(), "--sso-region eu-west-1")
+
+	// unrelated errors are never probed
+	other := fmt.Errorf("no such host")
+	assert.Equal(t, other, RegionMismatchError(context.TODO(), http.DefaultClient, other, "https://foo.awsapps.com/start", "us-east-1"))
+
+	// configured region is correct, but something else is wrong
+	assert.Equal(t, invalid, RegionMismatchError(context.TODO(), http.DefaultClient, invalid, "https://foo.awsapps.com/start", "eu-west-1"))
+}
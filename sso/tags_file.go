@@ -0,0 +1,104 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	goyaml "github.com/goccy/go-yaml"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// LoadTagsFile reads an external YAML file of role ARN => TagKey => Value
+// and verifies every ARN can be parsed
+func LoadTagsFile(fileName string) (*RoleTags, error) {
+	tags := RoleTags{}
+
+	data, err := ioutil.ReadFile(utils.GetHomePath(fileName))
+	if err != nil {
+		return &tags, fmt.Errorf("Unable to read TagsFile: %s", err.Error())
+	}
+
+	if err = goyaml.Unmarshal(data, &tags); err != nil {
+		return &tags, fmt.Errorf("Unable to parse TagsFile %s: %s", fileName, err.Error())
+	}
+
+	for arn := range tags {
+		if _, _, err := utils.ParseRoleARN(arn); err != nil {
+			return &tags, fmt.Errorf("Invalid role in TagsFile %s: %s", fileName, err.Error())
+		}
+	}
+	return &tags, nil
+}
+
+// ApplyTagsFile merges the tags from our TagsFile into the cached roles.
+// The file is only re-read if it has been modified since it was last applied
+// unless force is true.  Returns true if the cache was modified.
+func (c *Cache) ApplyTagsFile(force bool) (bool, error) {
+	if c.settings == nil || c.settings.TagsFile == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(utils.GetHomePath(c.settings.TagsFile))
+	if err != nil {
+		return false, fmt.Errorf("Unable to read TagsFile: %s", err.Error())
+	}
+
+	cache := c.GetSSO()
+	if cache.Roles == nil {
+		return false, nil
+	}
+	modified := info.ModTime().Unix()
+	if !force && modified <= cache.TagsFileUpdate {
+		return false, nil
+	}
+
+	tags, err := LoadTagsFile(c.settings.TagsFile)
+	if err != nil {
+		return false, err
+	}
+
+	cache.Roles.applyTags(tags)
+	cache.TagsFileUpdate = modified
+	return true, nil
+}
+
+// applyTags merges the given tags into any matching roles.  Roles which
+// are not known to us are skipped.
+func (r *Roles) applyTags(tags *RoleTags) {
+	for arn, roleTags := range *tags {
+		accountId, roleName, _ := utils.ParseRoleARN(arn)
+		account, ok := r.Accounts[accountId]
+		if !ok {
+			continue
+		}
+		role, ok := account.Roles[roleName]
+		if !ok {
+			continue
+		}
+		if role.Tags == nil {
+			role.Tags = map[string]string{}
+		}
+		for k, v := range roleTags {
+			role.Tags[k] = v
+		}
+	}
+}
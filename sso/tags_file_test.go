@@ -0,0 +1,101 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_TAGS_FILE = "./testdata/tags_file.yaml"
+
+func TestLoadTagsFile(t *testing.T) {
+	tags, err := LoadTagsFile(TEST_TAGS_FILE)
+	assert.NoError(t, err)
+	assert.Equal(t, "platform", tags.GetRoleTags("arn:aws:iam::258234615182:role/AWSAdministratorAccess")["Team"])
+
+	_, err = LoadTagsFile("./testdata/missing.yaml")
+	assert.Error(t, err)
+
+	f, err := os.CreateTemp("", "*.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("not-an-arn:\n  Team: platform\n")
+	assert.NoError(t, err)
+	f.Close()
+
+	_, err = LoadTagsFile(f.Name())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid role in TagsFile")
+}
+
+func TestApplyTagsFile(t *testing.T) {
+	f, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	input, err := ioutil.ReadFile(TEST_CACHE_FILE)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(f.Name(), input, 0600))
+
+	settings := &Settings{
+		DefaultSSO: "Default",
+		cacheFile:  f.Name(),
+	}
+	c, err := OpenCache(f.Name(), settings)
+	assert.NoError(t, err)
+
+	// no TagsFile is a no-op
+	updated, err := c.ApplyTagsFile(false)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	settings.TagsFile = TEST_TAGS_FILE
+	updated, err = c.ApplyTagsFile(false)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	role, err := c.GetRole("arn:aws:iam::258234615182:role/AWSAdministratorAccess")
+	assert.NoError(t, err)
+	assert.Equal(t, "platform", role.Tags["Team"])
+	assert.Equal(t, "AWSAdministratorAccess", role.Tags["Role"])
+
+	role, err = c.GetRole("arn:aws:iam::258234615182:role/AWSReadOnlyAccess")
+	assert.NoError(t, err)
+	assert.Equal(t, "audit", role.Tags["Team"])
+
+	// unchanged file is skipped unless forced
+	updated, err = c.ApplyTagsFile(false)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	updated, err = c.ApplyTagsFile(true)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	// a file newer than the last applied copy is picked up automatically
+	c.GetSSO().TagsFileUpdate = 0
+	updated, err = c.ApplyTagsFile(false)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+}
@@ -0,0 +1,55 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os/exec"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// validateUrlActions ensures every custom UrlActions handler exists and
+// resolves it to an absolute path.  Actions which conflict with a built-in
+// action are ignored.
+func (s *Settings) validateUrlActions() error {
+	for name, handler := range s.UrlActions {
+		if utils.IsBuiltinUrlAction(name) {
+			log.Warnf("Ignoring UrlActions.%s: conflicts with the built-in action", name)
+			delete(s.UrlActions, name)
+			continue
+		}
+		if handler == "" {
+			return fmt.Errorf("Invalid UrlActions.%s: missing handler", name)
+		}
+		path, err := exec.LookPath(utils.GetHomePath(handler))
+		if err != nil {
+			return fmt.Errorf("Invalid UrlActions.%s: %s", name, err.Error())
+		}
+		s.UrlActions[name] = path
+	}
+
+	if len(s.RemoteOpenCommand) > 0 {
+		if _, err := utils.RemoteOpenArgs(s.RemoteOpenCommand, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package sso
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUrlActions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "url-actions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	handler := filepath.Join(dir, "post-url")
+	assert.NoError(t, ioutil.WriteFile(handler, []byte("#!/bin/sh\n"), 0755))
+
+	s := &Settings{
+		UrlActions: map[string]string{
+			"post": handler,
+			"clip": handler, // built-in actions are ignored
+		},
+	}
+	assert.NoError(t, s.validateUrlActions())
+	assert.Equal(t, map[string]string{"post": handler}, s.UrlActions)
+
+	s.UrlActions["missing"] = filepath.Join(dir, "missing")
+	err = s.validateUrlActions()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid UrlActions.missing")
+	delete(s.UrlActions, "missing")
+
+	s.UrlActions["empty"] = ""
+	assert.Error(t, s.validateUrlActions())
+	delete(s.UrlActions, "empty")
+
+	s.RemoteOpenCommand = []string{"ssh", "laptop", "open", "{{ .Url }}"}
+	assert.NoError(t, s.validateUrlActions())
+	s.RemoteOpenCommand = []string{"ssh", "laptop", "open", "{{ .Url"}
+	assert.Error(t, s.validateUrlActions())
+}
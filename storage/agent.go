@@ -0,0 +1,444 @@
+package storage
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synfinatic/aws-sso-cli/utils"
+)
+
+// Environment variable with the path of the agent socket
+const AGENT_SOCKET_ENV = "AWS_SSO_AGENT_SOCK"
+
+// how long a client waits to connect to the agent
+const AGENT_DIAL_TIMEOUT = 5 * time.Second
+
+// how often the agent checks for expired secrets
+const AGENT_EXPIRE_INTERVAL = 15 * time.Second
+
+// agentRequest is a single request from the client to the agent
+type agentRequest struct {
+	Op   string          `json:"op"`   // ping, get, save, delete or list
+	Type string          `json:"type"` // RegisterClientData, CreateTokenResponse or RoleCredentials
+	Key  string          `json:"key"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// agentResponse is the agent's reply to an agentRequest
+type agentResponse struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Agent serves a MemoryStore to other aws-sso processes via a unix socket
+type Agent struct {
+	store       *MemoryStore
+	secureStore SecureStorage // optional copy of our secrets to reload on a miss
+	listener    net.Listener
+	path        string
+	wg          sync.WaitGroup
+	done        chan struct{}
+}
+
+// NewAgent creates the unix socket at the given path.  Only the current
+// user is allowed to connect.
+func NewAgent(path string) (*Agent, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, AGENT_DIAL_TIMEOUT); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("Agent is already running on %s", path)
+		}
+		// stale socket from a previous agent
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := utils.EnsureDirExists(path); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to listen on %s: %s", path, err.Error())
+	}
+
+	if err = os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &Agent{
+		store:    NewMemoryStore(),
+		listener: listener,
+		path:     path,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// SetExpiry evicts secrets which have not been requested within the idle
+// timeout or were saved more than the max lifetime ago.  Evicted secrets
+// are a cache miss for the client.  Zero disables either limit.
+func (a *Agent) SetExpiry(idleTimeout, maxLifetime time.Duration) {
+	a.store.SetExpiry(idleTimeout, maxLifetime)
+}
+
+// SetSecureStore writes every secret through to the given SecureStore and
+// transparently reloads secrets from it which are not (or no longer) held
+// in memory
+func (a *Agent) SetSecureStore(store SecureStorage) {
+	a.secureStore = store
+}
+
+// expire periodically evicts expired secrets so they do not linger in
+// memory until the next request
+func (a *Agent) expire() {
+	ticker := time.NewTicker(AGENT_EXPIRE_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			if evicted := a.store.Expire(now); evicted > 0 {
+				log.Debugf("Evicted %d expired secrets", evicted)
+			}
+		}
+	}
+}
+
+// Serve handles client connections until Close() is called
+func (a *Agent) Serve() error {
+	go a.expire()
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		a.wg.Add(1)
+		go a.handle(conn)
+	}
+}
+
+// Close stops the agent, zeros all of the secrets and removes the socket
+func (a *Agent) Close() error {
+	err := a.listener.Close()
+	close(a.done)
+	a.wg.Wait()
+	a.store.Zero()
+	os.Remove(a.path)
+	return err
+}
+
+// handle processes requests on the connection until the client hangs up
+func (a *Agent) handle(conn net.Conn) {
+	defer a.wg.Done()
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		req := agentRequest{}
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		resp := a.dispatch(req)
+		zero(req.Data)
+		err := enc.Encode(resp)
+		zero(resp.Data)
+		if err != nil {
+			log.WithError(err).Debugf("Unable to reply to agent client")
+			return
+		}
+	}
+}
+
+// dispatch runs a single request against our MemoryStore
+func (a *Agent) dispatch(req agentRequest) agentResponse {
+	resp := agentResponse{}
+
+	switch req.Type {
+	case REGISTER_CLIENT_DATA, CREATE_TOKEN_RESPONSE, ROLE_CREDENTIALS:
+	default:
+		if req.Op != "ping" {
+			resp.Error = fmt.Sprintf("Unknown type: %s", req.Type)
+			return resp
+		}
+	}
+
+	switch req.Op {
+	case "ping":
+	case "get":
+		data, err := a.store.getRaw(req.Type, req.Key)
+		if err != nil && a.secureStore != nil {
+			data, err = a.reload(req.Type, req.Key)
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Data = data
+		}
+	case "save":
+		a.store.saveRaw(req.Type, req.Key, req.Data)
+		if a.secureStore != nil {
+			if err := saveSecureStore(a.secureStore, req.Type, req.Key, req.Data); err != nil {
+				resp.Error = err.Error()
+			}
+		}
+	case "delete":
+		a.store.deleteRaw(req.Type, req.Key)
+		if a.secureStore != nil {
+			if err := deleteSecureStore(a.secureStore, req.Type, req.Key); err != nil {
+				resp.Error = err.Error()
+			}
+		}
+	case "list":
+		keys := a.store.listRaw(req.Type)
+		if a.secureStore != nil {
+			stored, err := a.secureStore.ListRoleCredentials()
+			if err != nil {
+				resp.Error = err.Error()
+				return resp
+			}
+			keys = mergeKeys(keys, stored)
+		}
+		data, err := json.Marshal(keys)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Data = data
+		}
+	default:
+		resp.Error = fmt.Sprintf("Unknown operation: %s", req.Op)
+	}
+	return resp
+}
+
+// reload reads a secret which is not in memory from our SecureStore and
+// keeps it in memory for the next request
+func (a *Agent) reload(kind, key string) ([]byte, error) {
+	if r, ok := a.secureStore.(Reloader); ok {
+		if err := r.Reload(); err != nil {
+			return []byte{}, err
+		}
+	}
+
+	var v interface{}
+	var err error
+	switch kind {
+	case REGISTER_CLIENT_DATA:
+		client := RegisterClientData{}
+		err = a.secureStore.GetRegisterClientData(key, &client)
+		v = client
+	case CREATE_TOKEN_RESPONSE:
+		token := CreateTokenResponse{}
+		err = a.secureStore.GetCreateTokenResponse(key, &token)
+		v = token
+	case ROLE_CREDENTIALS:
+		creds := RoleCredentials{}
+		err = a.secureStore.GetRoleCredentials(key, &creds)
+		v = creds
+	}
+	if err != nil {
+		return []byte{}, fmt.Errorf("No %s for %s", kind, key)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return []byte{}, err
+	}
+	a.store.saveRaw(kind, key, data)
+	log.Debugf("Reloaded %s for %s from the SecureStore", kind, key)
+	return data, nil
+}
+
+// saveSecureStore saves the JSON encoded secret in the SecureStore
+func saveSecureStore(store SecureStorage, kind, key string, data []byte) error {
+	switch kind {
+	case REGISTER_CLIENT_DATA:
+		client := RegisterClientData{}
+		if err := json.Unmarshal(data, &client); err != nil {
+			return err
+		}
+		return store.SaveRegisterClientData(key, client)
+	case CREATE_TOKEN_RESPONSE:
+		token := CreateTokenResponse{}
+		if err := json.Unmarshal(data, &token); err != nil {
+			return err
+		}
+		return store.SaveCreateTokenResponse(key, token)
+	default:
+		creds := RoleCredentials{}
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return err
+		}
+		return store.SaveRoleCredentials(key, creds)
+	}
+}
+
+// deleteSecureStore deletes the secret from the SecureStore.  Secrets which
+// were never saved there are not an error.
+func deleteSecureStore(store SecureStorage, kind, key string) error {
+	var err error
+	switch kind {
+	case REGISTER_CLIENT_DATA:
+		err = store.DeleteRegisterClientData(key)
+	case CREATE_TOKEN_RESPONSE:
+		err = store.DeleteCreateTokenResponse(key)
+	default:
+		err = store.DeleteRoleCredentials(key)
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "Missing ") {
+		return nil
+	}
+	return err
+}
+
+// mergeKeys returns the keys in either list without duplicates
+func mergeKeys(a, b []string) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, key := range append(a, b...) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// AgentStore implements SecureStorage by talking to a running Agent
+type AgentStore struct {
+	path string
+}
+
+// OpenAgentStore connects to the agent listening on the given socket
+func OpenAgentStore(path string) (*AgentStore, error) {
+	as := &AgentStore{
+		path: path,
+	}
+	if err := as.call("ping", "", "", nil, nil); err != nil {
+		return nil, fmt.Errorf("Unable to connect to agent %s: %s", path, err.Error())
+	}
+	return as, nil
+}
+
+// call sends a single request to the agent and decodes the response into out
+func (as *AgentStore) call(op, kind, key string, in interface{}, out interface{}) error {
+	conn, err := net.DialTimeout("unix", as.path, AGENT_DIAL_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	req := agentRequest{
+		Op:   op,
+		Type: kind,
+		Key:  key,
+	}
+	if in != nil {
+		if req.Data, err = json.Marshal(in); err != nil {
+			return err
+		}
+		defer zero(req.Data)
+	}
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+
+	resp := agentResponse{}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	defer zero(resp.Data)
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(resp.Data, out)
+	}
+	return nil
+}
+
+// SaveRegisterClientData saves the RegisterClientData in the agent
+func (as *AgentStore) SaveRegisterClientData(key string, client RegisterClientData) error {
+	return as.call("save", REGISTER_CLIENT_DATA, key, client, nil)
+}
+
+// GetRegisterClientData retrieves the RegisterClientData from the agent
+func (as *AgentStore) GetRegisterClientData(key string, client *RegisterClientData) error {
+	return as.call("get", REGISTER_CLIENT_DATA, key, nil, client)
+}
+
+// DeleteRegisterClientData deletes the RegisterClientData from the agent
+func (as *AgentStore) DeleteRegisterClientData(key string) error {
+	return as.call("delete", REGISTER_CLIENT_DATA, key, nil, nil)
+}
+
+// SaveCreateTokenResponse stores the token in the agent
+func (as *AgentStore) SaveCreateTokenResponse(key string, token CreateTokenResponse) error {
+	return as.call("save", CREATE_TOKEN_RESPONSE, key, token, nil)
+}
+
+// GetCreateTokenResponse retrieves the CreateTokenResponse from the agent
+func (as *AgentStore) GetCreateTokenResponse(key string, token *CreateTokenResponse) error {
+	return as.call("get", CREATE_TOKEN_RESPONSE, key, nil, token)
+}
+
+// DeleteCreateTokenResponse deletes the token from the agent
+func (as *AgentStore) DeleteCreateTokenResponse(key string) error {
+	return as.call("delete", CREATE_TOKEN_RESPONSE, key, nil, nil)
+}
+
+// SaveRoleCredentials stores the role credentials in the agent
+func (as *AgentStore) SaveRoleCredentials(arn string, token RoleCredentials) error {
+	return as.call("save", ROLE_CREDENTIALS, arn, token, nil)
+}
+
+// GetRoleCredentials retrieves the role credentials from the agent
+func (as *AgentStore) GetRoleCredentials(arn string, token *RoleCredentials) error {
+	return as.call("get", ROLE_CREDENTIALS, arn, nil, token)
+}
+
+// DeleteRoleCredentials deletes the role credentials from the agent
+func (as *AgentStore) DeleteRoleCredentials(arn string) error {
+	return as.call("delete", ROLE_CREDENTIALS, arn, nil, nil)
+}
+
+// ListRoleCredentials returns the keys of all the role credentials in the agent
+func (as *AgentStore) ListRoleCredentials() ([]string, error) {
+	keys := []string{}
+	err := as.call("list", ROLE_CREDENTIALS, "", nil, &keys)
+	return keys, err
+}
@@ -0,0 +1,237 @@
+package storage
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exercises a SecureStorage implementation
+func testSecureStorage(t *testing.T, store SecureStorage) {
+	key := "us-east-1|https://d-xxxxxxx.awsapps.com/start"
+
+	rcd := RegisterClientData{}
+	assert.Error(t, store.GetRegisterClientData(key, &rcd))
+	rcdTest := RegisterClientData{
+		ClientId:              "not a real client id",
+		ClientIdIssuedAt:      1629947379,
+		ClientSecret:          "not a real secret",
+		ClientSecretExpiresAt: 1637723379,
+	}
+	assert.NoError(t, store.SaveRegisterClientData(key, rcdTest))
+	assert.NoError(t, store.GetRegisterClientData(key, &rcd))
+	assert.Equal(t, rcdTest, rcd)
+	assert.NoError(t, store.DeleteRegisterClientData(key))
+	assert.Error(t, store.GetRegisterClientData(key, &rcd))
+
+	token := CreateTokenResponse{}
+	assert.Error(t, store.GetCreateTokenResponse(key, &token))
+	tokenTest := CreateTokenResponse{
+		AccessToken: "not a real token",
+		ExpiresAt:   1637723379,
+	}
+	assert.NoError(t, store.SaveCreateTokenResponse(key, tokenTest))
+	assert.NoError(t, store.GetCreateTokenResponse(key, &token))
+	assert.Equal(t, tokenTest, token)
+	assert.NoError(t, store.DeleteCreateTokenResponse(key))
+	assert.Error(t, store.GetCreateTokenResponse(key, &token))
+
+	arn := "arn:aws:iam::012344553243:role/AWSAdministratorAccess"
+	rc := RoleCredentials{}
+	assert.Error(t, store.GetRoleCredentials(arn, &rc))
+	rcTest := RoleCredentials{
+		RoleName:        "AWSAdministratorAccess",
+		AccountId:       12344553243,
+		AccessKeyId:     "not a real access key id",
+		SecretAccessKey: "not a real acess key",
+		SessionToken:    "not a real session token",
+		Expiration:      1637444478000,
+	}
+	assert.NoError(t, store.SaveRoleCredentials(arn, rcTest))
+	assert.NoError(t, store.GetRoleCredentials(arn, &rc))
+	assert.Equal(t, rcTest, rc)
+
+	// overwrite
+	rcTest.SessionToken = "another fake session token"
+	assert.NoError(t, store.SaveRoleCredentials(arn, rcTest))
+	assert.NoError(t, store.GetRoleCredentials(arn, &rc))
+	assert.Equal(t, rcTest, rc)
+
+	keys, err := store.ListRoleCredentials()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{arn}, keys)
+
+	assert.NoError(t, store.DeleteRoleCredentials(arn))
+	assert.Error(t, store.GetRoleCredentials(arn, &rc))
+	keys, err = store.ListRoleCredentials()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestMemoryStore(t *testing.T) {
+	ms := NewMemoryStore()
+	testSecureStorage(t, ms)
+
+	rc := RoleCredentials{
+		RoleName:        "AWSAdministratorAccess",
+		AccountId:       12344553243,
+		SecretAccessKey: "not a real acess key",
+	}
+	assert.NoError(t, ms.SaveRoleCredentials("arn", rc))
+	data := ms.items[memoryKey(ROLE_CREDENTIALS, "arn")].data
+	assert.NotEmpty(t, data)
+
+	ms.Zero()
+	assert.Empty(t, ms.items)
+	for _, b := range data {
+		assert.Equal(t, byte(0), b)
+	}
+	assert.Error(t, ms.GetRoleCredentials("arn", &rc))
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ms := NewMemoryStore()
+	ms.SetExpiry(time.Minute, time.Hour)
+
+	rc := RoleCredentials{SecretAccessKey: "not a real acess key"}
+	assert.NoError(t, ms.SaveRoleCredentials("arn", rc))
+	assert.NoError(t, ms.SaveRoleCredentials("idle", rc))
+	item := ms.items[memoryKey(ROLE_CREDENTIALS, "arn")]
+	idle := ms.items[memoryKey(ROLE_CREDENTIALS, "idle")]
+	data := idle.data
+
+	// access resets the idle timeout
+	item.accessed = time.Now().Add(-50 * time.Second)
+	idle.accessed = time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, ms.GetRoleCredentials("arn", &rc))
+	assert.Equal(t, 1, ms.Expire(time.Now().Add(30*time.Second)))
+	assert.Len(t, ms.items, 1)
+	for _, b := range data {
+		assert.Equal(t, byte(0), b)
+	}
+
+	// expired items are a miss even before Expire() runs
+	item.accessed = time.Now().Add(-2 * time.Minute)
+	assert.Error(t, ms.GetRoleCredentials("arn", &rc))
+	assert.Empty(t, ms.items)
+
+	// max lifetime applies even when frequently accessed
+	assert.NoError(t, ms.SaveRoleCredentials("arn", rc))
+	item = ms.items[memoryKey(ROLE_CREDENTIALS, "arn")]
+	item.created = time.Now().Add(-2 * time.Hour)
+	assert.Error(t, ms.GetRoleCredentials("arn", &rc))
+
+	// disabled
+	ms.SetExpiry(0, 0)
+	assert.NoError(t, ms.SaveRoleCredentials("arn", rc))
+	assert.Equal(t, 0, ms.Expire(time.Now().Add(24*time.Hour)))
+}
+
+func TestAgent(t *testing.T) {
+	dir, err := os.MkdirTemp("", "agent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "agent.sock")
+
+	_, err = OpenAgentStore(sock)
+	assert.Error(t, err)
+
+	agent, err := NewAgent(sock)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- agent.Serve() }()
+
+	info, err := os.Stat(sock)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// only one agent at a time
+	_, err = NewAgent(sock)
+	assert.Error(t, err)
+
+	store, err := OpenAgentStore(sock)
+	assert.NoError(t, err)
+	testSecureStorage(t, store)
+
+	assert.Error(t, store.call("get", "Invalid", "key", nil, nil))
+	assert.Error(t, store.call("invalid", ROLE_CREDENTIALS, "key", nil, nil))
+
+	// secrets are gone after shutdown
+	assert.NoError(t, store.SaveRoleCredentials("arn", RoleCredentials{SecretAccessKey: "secret"}))
+	assert.NoError(t, agent.Close())
+	assert.NoError(t, <-done)
+	assert.Empty(t, agent.store.items)
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, store.GetRoleCredentials("arn", &RoleCredentials{}))
+}
+
+func TestAgentSecureStore(t *testing.T) {
+	dir, err := os.MkdirTemp("", "agent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "agent.sock")
+
+	agent, err := NewAgent(sock)
+	assert.NoError(t, err)
+	backing := NewMemoryStore()
+	agent.SetSecureStore(backing)
+	done := make(chan error)
+	go func() { done <- agent.Serve() }()
+
+	store, err := OpenAgentStore(sock)
+	assert.NoError(t, err)
+	testSecureStorage(t, store)
+
+	// saves are written through
+	rc := RoleCredentials{SecretAccessKey: "secret"}
+	assert.NoError(t, store.SaveRoleCredentials("arn", rc))
+	assert.NoError(t, backing.GetRoleCredentials("arn", &RoleCredentials{}))
+
+	// evicted secrets are reloaded from the SecureStore
+	agent.store.Zero()
+	rc2 := RoleCredentials{}
+	assert.NoError(t, store.GetRoleCredentials("arn", &rc2))
+	assert.Equal(t, rc, rc2)
+	assert.Len(t, agent.store.items, 1)
+
+	// secrets only in the SecureStore are listed
+	assert.NoError(t, backing.SaveRoleCredentials("other", rc))
+	keys, err := store.ListRoleCredentials()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"arn", "other"}, keys)
+
+	// deletes are written through
+	assert.NoError(t, store.DeleteRoleCredentials("arn"))
+	assert.Error(t, backing.GetRoleCredentials("arn", &RoleCredentials{}))
+	assert.Error(t, store.GetRoleCredentials("arn", &RoleCredentials{}))
+
+	assert.NoError(t, agent.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestMergeKeys(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, mergeKeys([]string{"a", "b"}, []string{"b", "c"}))
+	assert.Equal(t, []string{}, mergeKeys([]string{}, []string{}))
+}
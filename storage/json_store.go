@@ -57,6 +57,19 @@ func OpenJsonStore(fileName string) (*JsonStore, error) {
 	return &cache, err
 }
 
+// Reload re-reads the JSON store file to pick up changes made by other processes
+func (jc *JsonStore) Reload() error {
+	cacheBytes, err := ioutil.ReadFile(jc.filename)
+	if err != nil || len(cacheBytes) == 0 {
+		return err
+	}
+	jc.RegisterClient = map[string]RegisterClientData{}
+	jc.StartDeviceAuth = map[string]StartDeviceAuthData{}
+	jc.CreateTokenResponse = map[string]CreateTokenResponse{}
+	jc.RoleCredentials = map[string]RoleCredentials{}
+	return json.Unmarshal(cacheBytes, jc)
+}
+
 // save writes the JSON store file, creating the directory if necessary
 func (jc *JsonStore) save() error {
 	log.Debugf("Saving JSON Cache")
@@ -138,3 +151,12 @@ func (jc *JsonStore) DeleteRoleCredentials(arn string) error {
 	delete(jc.RoleCredentials, arn)
 	return jc.save()
 }
+
+// ListRoleCredentials returns the keys of all the RoleCredentials in the json file
+func (jc *JsonStore) ListRoleCredentials() ([]string, error) {
+	keys := []string{}
+	for k := range jc.RoleCredentials {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
@@ -156,3 +156,19 @@ func (s *JsonStoreTestSuite) TestCreateTokenResponse() {
 	err = s.json.GetCreateTokenResponse(key, &tr)
 	assert.NotNil(t, err)
 }
+
+func (s *JsonStoreTestSuite) TestReload() {
+	t := s.T()
+
+	other, err := OpenJsonStore(s.jsonFile)
+	assert.Nil(t, err)
+	err = other.SaveCreateTokenResponse("reload-key", CreateTokenResponse{AccessToken: "from another process"})
+	assert.Nil(t, err)
+
+	tr := CreateTokenResponse{}
+	assert.NotNil(t, s.json.GetCreateTokenResponse("reload-key", &tr))
+
+	assert.Nil(t, s.json.Reload())
+	assert.Nil(t, s.json.GetCreateTokenResponse("reload-key", &tr))
+	assert.Equal(t, "from another process", tr.AccessToken)
+}
@@ -63,7 +63,7 @@ type KeyringApi interface {
 }
 
 func NewKeyringConfig(name, configDir string) (*keyring.Config, error) {
-	securePath := path.Join(configDir, "secure")
+	securePath := keyringFileDir(configDir)
 
 	c := keyring.Config{
 		ServiceName: KEYRING_ID, // generic backend provider
@@ -330,7 +330,30 @@ func (kr *KeyringStore) DeleteRoleCredentials(arn string) error {
 	}
 
 	delete(storage.RoleCredentials, arn)
-	return nil
+	return kr.saveStorageData(storage)
+}
+
+// ListRoleCredentials returns the keys of all the RoleCredentials in the Keyring
+func (kr *KeyringStore) ListRoleCredentials() ([]string, error) {
+	storage := StorageData{}
+	if err := kr.getStorageData(&storage); err != nil {
+		return []string{}, err
+	}
+
+	keys := []string{}
+	for k := range storage.RoleCredentials {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// KeyringFileDir returns the directory used by the "file" keyring backend
+func KeyringFileDir(configDir string) string {
+	return getHomePath(keyringFileDir(configDir))
+}
+
+func keyringFileDir(configDir string) string {
+	return path.Join(configDir, "secure")
 }
 
 func getHomePath(path string) string {
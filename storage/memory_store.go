@@ -0,0 +1,243 @@
+package storage
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The types of data we store.  Used for keys & the agent protocol
+const (
+	REGISTER_CLIENT_DATA  = "RegisterClientData"
+	CREATE_TOKEN_RESPONSE = "CreateTokenResponse"
+	ROLE_CREDENTIALS      = "RoleCredentials"
+)
+
+// MemoryStore implements SecureStorage by only ever keeping our data in memory.
+// Everything is stored as JSON encoded bytes so it can be zeroed when no longer needed.
+type MemoryStore struct {
+	lock        sync.Mutex
+	items       map[string]*memoryItem
+	idleTimeout time.Duration // evict items not accessed for this long, 0 = never
+	maxLifetime time.Duration // evict items saved this long ago, 0 = never
+}
+
+type memoryItem struct {
+	data     []byte
+	created  time.Time
+	accessed time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: map[string]*memoryItem{},
+	}
+}
+
+// SetExpiry evicts items which have not been accessed within the idle timeout
+// or were saved more than the max lifetime ago.  Zero disables either limit.
+func (ms *MemoryStore) SetExpiry(idleTimeout, maxLifetime time.Duration) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.idleTimeout = idleTimeout
+	ms.maxLifetime = maxLifetime
+}
+
+// expired returns if the item should be evicted.  Caller must hold the lock.
+func (ms *MemoryStore) expired(item *memoryItem, now time.Time) bool {
+	if ms.idleTimeout > 0 && now.Sub(item.accessed) >= ms.idleTimeout {
+		return true
+	}
+	return ms.maxLifetime > 0 && now.Sub(item.created) >= ms.maxLifetime
+}
+
+// Expire zeros & removes every expired item and returns how many were evicted
+func (ms *MemoryStore) Expire(now time.Time) int {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	evicted := 0
+	for k, item := range ms.items {
+		if ms.expired(item, now) {
+			zero(item.data)
+			delete(ms.items, k)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+func memoryKey(kind, key string) string {
+	return fmt.Sprintf("%s|%s", kind, key)
+}
+
+// zero overwrites the given bytes
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// saveRaw stores a copy of the JSON encoded data
+func (ms *MemoryStore) saveRaw(kind, key string, data []byte) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	k := memoryKey(kind, key)
+	if old, ok := ms.items[k]; ok {
+		zero(old.data)
+	}
+	now := time.Now()
+	ms.items[k] = &memoryItem{
+		data:     append([]byte{}, data...),
+		created:  now,
+		accessed: now,
+	}
+}
+
+// getRaw returns a copy of the JSON encoded data
+func (ms *MemoryStore) getRaw(kind, key string) ([]byte, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	k := memoryKey(kind, key)
+	item, ok := ms.items[k]
+	now := time.Now()
+	if ok && ms.expired(item, now) {
+		zero(item.data)
+		delete(ms.items, k)
+		ok = false
+	}
+	if !ok {
+		return []byte{}, fmt.Errorf("No %s for %s", kind, key)
+	}
+	item.accessed = now
+	return append([]byte{}, item.data...), nil
+}
+
+// listRaw returns the keys of all the unexpired items of the given kind
+func (ms *MemoryStore) listRaw(kind string) []string {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	prefix := memoryKey(kind, "")
+	now := time.Now()
+	keys := []string{}
+	for k, item := range ms.items {
+		if !strings.HasPrefix(k, prefix) || ms.expired(item, now) {
+			continue
+		}
+		keys = append(keys, strings.TrimPrefix(k, prefix))
+	}
+	return keys
+}
+
+// deleteRaw removes & zeros the data
+func (ms *MemoryStore) deleteRaw(kind, key string) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	k := memoryKey(kind, key)
+	if old, ok := ms.items[k]; ok {
+		zero(old.data)
+		delete(ms.items, k)
+	}
+}
+
+func (ms *MemoryStore) save(kind, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ms.saveRaw(kind, key, data)
+	zero(data)
+	return nil
+}
+
+func (ms *MemoryStore) get(kind, key string, v interface{}) error {
+	data, err := ms.getRaw(kind, key)
+	if err != nil {
+		return err
+	}
+	defer zero(data)
+	return json.Unmarshal(data, v)
+}
+
+// Zero overwrites & removes everything we have stored
+func (ms *MemoryStore) Zero() {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	for k, v := range ms.items {
+		zero(v.data)
+		delete(ms.items, k)
+	}
+}
+
+// SaveRegisterClientData saves the RegisterClientData in memory
+func (ms *MemoryStore) SaveRegisterClientData(key string, client RegisterClientData) error {
+	return ms.save(REGISTER_CLIENT_DATA, key, client)
+}
+
+// GetRegisterClientData retrieves the RegisterClientData from memory
+func (ms *MemoryStore) GetRegisterClientData(key string, client *RegisterClientData) error {
+	return ms.get(REGISTER_CLIENT_DATA, key, client)
+}
+
+// DeleteRegisterClientData deletes the RegisterClientData from memory
+func (ms *MemoryStore) DeleteRegisterClientData(key string) error {
+	ms.deleteRaw(REGISTER_CLIENT_DATA, key)
+	return nil
+}
+
+// SaveCreateTokenResponse stores the token in memory
+func (ms *MemoryStore) SaveCreateTokenResponse(key string, token CreateTokenResponse) error {
+	return ms.save(CREATE_TOKEN_RESPONSE, key, token)
+}
+
+// GetCreateTokenResponse retrieves the CreateTokenResponse from memory
+func (ms *MemoryStore) GetCreateTokenResponse(key string, token *CreateTokenResponse) error {
+	return ms.get(CREATE_TOKEN_RESPONSE, key, token)
+}
+
+// DeleteCreateTokenResponse deletes the token from memory
+func (ms *MemoryStore) DeleteCreateTokenResponse(key string) error {
+	ms.deleteRaw(CREATE_TOKEN_RESPONSE, key)
+	return nil
+}
+
+// SaveRoleCredentials stores the role credentials in memory
+func (ms *MemoryStore) SaveRoleCredentials(arn string, token RoleCredentials) error {
+	return ms.save(ROLE_CREDENTIALS, arn, token)
+}
+
+// GetRoleCredentials retrieves the role credentials from memory
+func (ms *MemoryStore) GetRoleCredentials(arn string, token *RoleCredentials) error {
+	return ms.get(ROLE_CREDENTIALS, arn, token)
+}
+
+// DeleteRoleCredentials deletes the role credentials from memory
+func (ms *MemoryStore) DeleteRoleCredentials(arn string) error {
+	ms.deleteRaw(ROLE_CREDENTIALS, arn)
+	return nil
+}
+
+// ListRoleCredentials returns the keys of all the role credentials in memory
+func (ms *MemoryStore) ListRoleCredentials() ([]string, error) {
+	return ms.listRaw(ROLE_CREDENTIALS), nil
+}
@@ -31,4 +31,11 @@ type SecureStorage interface {
 	SaveRoleCredentials(string, RoleCredentials) error
 	GetRoleCredentials(string, *RoleCredentials) error
 	DeleteRoleCredentials(string) error
+	ListRoleCredentials() ([]string, error)
+}
+
+// Reloader is implemented by SecureStorage backends which cache their contents
+// in memory and need to re-read them to see changes made by other processes
+type Reloader interface {
+	Reload() error
 }
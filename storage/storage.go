@@ -19,6 +19,8 @@ package storage
  */
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -62,7 +64,12 @@ type CreateTokenResponse struct {
 // Expired returns true if it has expired or will in the next minute
 func (t *CreateTokenResponse) Expired() bool {
 	// XXX: I think an minute buffer here is fine?
-	return t.ExpiresAt <= time.Now().Add(time.Minute).Unix()
+	return t.ExpiresAt <= utils.Now().Add(time.Minute).Unix()
+}
+
+// ExpiresWithin returns true if it has expired or will within the buffer
+func (t *CreateTokenResponse) ExpiresWithin(buffer time.Duration) bool {
+	return t.ExpiresAt <= utils.Now().Add(buffer).Unix()
 }
 
 type RoleCredentials struct { // Cache
@@ -72,6 +79,57 @@ type RoleCredentials struct { // Cache
 	SecretAccessKey string `json:"secretAccessKey"`
 	SessionToken    string `json:"sessionToken"`
 	Expiration      int64  `json:"expiration"` // not in seconds, but millisec
+	CacheKey        string `json:"cacheKey,omitempty"`
+}
+
+// RoleCredentialsKey identifies the request which generated a set of
+// RoleCredentials so they are only re-used for an identical request
+type RoleCredentialsKey struct {
+	SSO         string // name of the AWS SSO instance
+	Arn         string
+	Region      string
+	Duration    int32 // seconds, 0 is the AWS default
+	SessionName string
+	Policy      string // hash of any session policy
+}
+
+func (k RoleCredentialsKey) String() string {
+	return fmt.Sprintf("%s|%s|%s|%d|%s|%s", k.SSO, k.Arn, k.Region, k.Duration, k.SessionName, k.Policy)
+}
+
+// GetCachedRoleCredentials loads the RoleCredentials for the key from the store
+// and returns an error if they are missing, expired or for a different request
+func GetCachedRoleCredentials(store SecureStorage, key RoleCredentialsKey, creds *RoleCredentials) error {
+	if err := store.GetRoleCredentials(key.String(), creds); err != nil {
+		return err
+	}
+	if creds.CacheKey != key.String() {
+		return fmt.Errorf("Cached role credentials for %s do not match %s", key.Arn, key.String())
+	}
+	if creds.Expired() {
+		return fmt.Errorf("Cached role credentials for %s have expired", key.Arn)
+	}
+	return nil
+}
+
+// DeleteRoleCredentialsForArn deletes every set of RoleCredentials cached for
+// the role, regardless of the request which generated them
+func DeleteRoleCredentialsForArn(store SecureStorage, arn string) error {
+	keys, err := store.ListRoleCredentials()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		parts := strings.Split(k, "|")
+		// older versions stored the RoleCredentials by the ARN alone
+		if k != arn && (len(parts) < 2 || parts[1] != arn) {
+			continue
+		}
+		if err := store.DeleteRoleCredentials(k); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // RoleArn returns the ARN for the role
@@ -89,9 +147,10 @@ func (r *RoleCredentials) ExpireString() string {
 	return time.UnixMilli(r.Expiration).String() // yes, millisec
 }
 
-// Expired returns if these role creds have expired or will expire in the next minute
+// Expired returns if these role creds have expired or will expire within
+// utils.MinValidRemaining() (one minute by default)
 func (r *RoleCredentials) Expired() bool {
-	now := time.Now().Add(time.Minute).UnixMilli() // yes, millisec
+	now := utils.Now().Add(utils.MinValidRemaining()).UnixMilli() // yes, millisec
 	return r.Expiration <= now
 }
 
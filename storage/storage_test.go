@@ -19,6 +19,7 @@ package storage
  */
 
 import (
+	"os"
 	"testing"
 	"time"
 
@@ -42,6 +43,18 @@ func TestCreateTokenResponseExpired(t *testing.T) {
 	assert.False(t, tr.Expired())
 }
 
+func TestCreateTokenResponseExpiresWithin(t *testing.T) {
+	tr := &CreateTokenResponse{
+		ExpiresAt: time.Now().Unix() + 90,
+	}
+	assert.False(t, tr.ExpiresWithin(0))
+	assert.False(t, tr.ExpiresWithin(time.Minute))
+	assert.True(t, tr.ExpiresWithin(2*time.Minute))
+
+	tr.ExpiresAt = time.Now().Unix()
+	assert.True(t, tr.ExpiresWithin(0))
+}
+
 func TestRegisterClientDataExpired(t *testing.T) {
 	tr := &RegisterClientData{
 		ClientSecretExpiresAt: 0,
@@ -114,3 +127,94 @@ func TestExpireISO8601(t *testing.T) {
 	x.Expiration = time.Now().Unix()
 	assert.Equal(t, time.UnixMilli(x.Expiration).Format(time.RFC3339), x.ExpireISO8601())
 }
+
+func TestGetCachedRoleCredentials(t *testing.T) {
+	f, err := os.CreateTemp("", "*")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	store, err := OpenJsonStore(f.Name())
+	assert.NoError(t, err)
+
+	key := RoleCredentialsKey{
+		Arn:    "arn:aws:iam::012344553243:role/foobar",
+		Region: "us-east-1",
+	}
+	creds := RoleCredentials{}
+	assert.Error(t, GetCachedRoleCredentials(store, key, &creds))
+
+	err = store.SaveRoleCredentials(key.String(), RoleCredentials{
+		AccountId:  12344553243,
+		RoleName:   "foobar",
+		Expiration: time.Now().Add(time.Hour).UnixMilli(),
+		CacheKey:   key.String(),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, GetCachedRoleCredentials(store, key, &creds))
+	assert.Equal(t, "foobar", creds.RoleName)
+
+	// changing the region is a cache miss
+	key2 := key
+	key2.Region = "us-west-2"
+	assert.Error(t, GetCachedRoleCredentials(store, key2, &creds))
+
+	// so is the duration or session name
+	key2 = key
+	key2.Duration = 900
+	assert.Error(t, GetCachedRoleCredentials(store, key2, &creds))
+
+	key2 = key
+	key2.SessionName = "session"
+	assert.Error(t, GetCachedRoleCredentials(store, key2, &creds))
+
+	// creds are namespaced by AWS SSO instance
+	key2 = key
+	key2.SSO = "Secondary"
+	assert.Error(t, GetCachedRoleCredentials(store, key2, &creds))
+
+	// scoped and unscoped sessions don't collide
+	key2 = key
+	key2.Policy = "7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730"
+	assert.Error(t, GetCachedRoleCredentials(store, key2, &creds))
+
+	// and both can be cached at the same time
+	err = store.SaveRoleCredentials(key2.String(), RoleCredentials{
+		RoleName:   "scoped",
+		Expiration: time.Now().Add(time.Hour).UnixMilli(),
+		CacheKey:   key2.String(),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, GetCachedRoleCredentials(store, key2, &creds))
+	assert.Equal(t, "scoped", creds.RoleName)
+	assert.NoError(t, GetCachedRoleCredentials(store, key, &creds))
+	assert.Equal(t, "foobar", creds.RoleName)
+
+	// expired creds are a miss
+	err = store.SaveRoleCredentials(key.String(), RoleCredentials{
+		Expiration: time.Now().UnixMilli(),
+		CacheKey:   key.String(),
+	})
+	assert.NoError(t, err)
+	assert.Error(t, GetCachedRoleCredentials(store, key, &creds))
+}
+
+func TestDeleteRoleCredentialsForArn(t *testing.T) {
+	store := NewMemoryStore()
+	arn := "arn:aws:iam::012344553243:role/foobar"
+	other := "arn:aws:iam::012344553243:role/other"
+
+	key := RoleCredentialsKey{Arn: arn, Region: "us-east-1"}
+	key2 := key
+	key2.Duration = 900
+	otherKey := RoleCredentialsKey{Arn: other, Region: "us-east-1"}
+
+	for _, k := range []string{key.String(), key2.String(), otherKey.String(), arn} {
+		assert.NoError(t, store.SaveRoleCredentials(k, RoleCredentials{}))
+	}
+
+	assert.NoError(t, DeleteRoleCredentialsForArn(store, arn))
+	keys, err := store.ListRoleCredentials()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{otherKey.String()}, keys)
+}
@@ -0,0 +1,79 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// privateBrowserFlags maps the browser name to the flag which opens a private window
+var privateBrowserFlags = map[string]string{
+	"brave":    "--incognito",
+	"chrome":   "--incognito",
+	"chromium": "--incognito",
+	"edge":     "--inprivate",
+	"firefox":  "--private-window",
+	"opera":    "--private",
+	"vivaldi":  "--incognito",
+}
+
+// PrivateBrowserFlag returns the command line flag to open a private/incognito
+// window for the given browser path or application name
+func PrivateBrowserFlag(browser string) (string, error) {
+	if browser == "" {
+		return "", fmt.Errorf("Unable to open a private window with the default browser.  Please specify the browser to use")
+	}
+	name := strings.ToLower(filepath.Base(browser))
+
+	keys := make([]string, 0, len(privateBrowserFlags))
+	for k := range privateBrowserFlags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strings.Contains(name, k) {
+			return privateBrowserFlags[k], nil
+		}
+	}
+	return "", fmt.Errorf("Unable to open a private window with unknown browser: '%s'", browser)
+}
+
+type urlOpenerArgsFunc func(string, string, ...string) error
+
+var urlOpenerArgs urlOpenerArgsFunc = openWithArgs
+
+// openWithArgs opens the url with the browser passing it the extra args
+func openWithArgs(url, browser string, args ...string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// need -n to open a new instance so the args are passed
+		cmdArgs := append([]string{"-na", browser, "--args"}, args...)
+		cmd = exec.Command("open", append(cmdArgs, url)...) // #nosec
+	default:
+		cmd = exec.Command(browser, append(args, url)...) // #nosec
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,73 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateBrowserFlag(t *testing.T) {
+	f, err := PrivateBrowserFlag("/usr/bin/google-chrome")
+	assert.NoError(t, err)
+	assert.Equal(t, "--incognito", f)
+
+	f, err = PrivateBrowserFlag("Google Chrome")
+	assert.NoError(t, err)
+	assert.Equal(t, "--incognito", f)
+
+	f, err = PrivateBrowserFlag("/usr/bin/firefox")
+	assert.NoError(t, err)
+	assert.Equal(t, "--private-window", f)
+
+	f, err = PrivateBrowserFlag(`C:\Program Files\Microsoft\Edge\msedge.exe`)
+	assert.NoError(t, err)
+	assert.Equal(t, "--inprivate", f)
+
+	_, err = PrivateBrowserFlag("/usr/bin/lynx")
+	assert.Error(t, err)
+
+	_, err = PrivateBrowserFlag("")
+	assert.Error(t, err)
+}
+
+func TestHandleUrlPrivate(t *testing.T) {
+	var checkArgs []string
+	urlOpenerArgs = func(url, browser string, args ...string) error {
+		checkValue = url
+		checkBrowser = browser
+		checkArgs = args
+		return nil
+	}
+	urlOpenerWith = testUrlOpenerWith
+	defer func() { urlOpenerArgs = openWithArgs }()
+
+	assert.NoError(t, HandleUrlPrivate("open", "/usr/bin/firefox", "url", "pre", "post"))
+	assert.Equal(t, "url", checkValue)
+	assert.Equal(t, "/usr/bin/firefox", checkBrowser)
+	assert.Equal(t, []string{"--private-window"}, checkArgs)
+
+	// unknown browsers open normally
+	checkArgs = []string{}
+	assert.NoError(t, HandleUrlPrivate("open", "lynx", "other-url", "pre", "post"))
+	assert.Equal(t, "other-url", checkValue)
+	assert.Equal(t, "lynx", checkBrowser)
+	assert.Empty(t, checkArgs)
+}
@@ -0,0 +1,72 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"sync"
+	"time"
+)
+
+var clockSkew time.Duration
+var clockSkewLock sync.RWMutex
+
+// SetClockSkew sets how far ahead (positive) or behind (negative) the
+// AWS servers are compared to the local clock.  Used by Now()
+func SetClockSkew(skew time.Duration) {
+	clockSkewLock.Lock()
+	defer clockSkewLock.Unlock()
+	clockSkew = skew
+}
+
+// ClockSkew returns the difference between AWS time and the local clock
+func ClockSkew() time.Duration {
+	clockSkewLock.RLock()
+	defer clockSkewLock.RUnlock()
+	return clockSkew
+}
+
+// Now returns the current time, corrected for any clock skew.  Should be used
+// whenever comparing against an expiration time issued by AWS
+func Now() time.Time {
+	return time.Now().Add(ClockSkew())
+}
+
+// how much time must remain for cached credentials to be considered valid
+var minValidRemaining = time.Minute
+
+// SetMinValidRemaining sets how much time must remain before cached credentials
+// expire for them to still be considered valid.  Used by ValidUntil()
+func SetMinValidRemaining(d time.Duration) {
+	clockSkewLock.Lock()
+	defer clockSkewLock.Unlock()
+	minValidRemaining = d
+}
+
+// MinValidRemaining returns how much time must remain for cached credentials to be valid
+func MinValidRemaining() time.Duration {
+	clockSkewLock.RLock()
+	defer clockSkewLock.RUnlock()
+	return minValidRemaining
+}
+
+// ValidUntil returns true if credentials which expire at the given Unix epoch
+// time are still valid, taking MinValidRemaining into account
+func ValidUntil(expires int64) bool {
+	return Remaining(expires) > MinValidRemaining()
+}
@@ -0,0 +1,58 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockSkew(t *testing.T) {
+	defer SetClockSkew(0)
+
+	assert.Equal(t, time.Duration(0), ClockSkew())
+	assert.WithinDuration(t, time.Now(), Now(), time.Second)
+
+	SetClockSkew(time.Hour)
+	assert.Equal(t, time.Hour, ClockSkew())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), Now(), time.Second)
+
+	// our local clock is an hour slow, so these creds have already expired
+	x, err := TimeRemain(time.Now().Add(30*time.Minute).Unix(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Expired", x)
+}
+
+func TestMinValidRemaining(t *testing.T) {
+	defer SetMinValidRemaining(time.Minute)
+
+	assert.Equal(t, time.Minute, MinValidRemaining())
+	assert.False(t, ValidUntil(0))
+	assert.False(t, ValidUntil(time.Now().Add(30*time.Second).Unix()))
+	assert.True(t, ValidUntil(time.Now().Add(2*time.Minute).Unix()))
+
+	SetMinValidRemaining(15 * time.Minute)
+	assert.False(t, ValidUntil(time.Now().Add(10*time.Minute).Unix()))
+	assert.True(t, ValidUntil(time.Now().Add(20*time.Minute).Unix()))
+
+	SetMinValidRemaining(0)
+	assert.True(t, ValidUntil(time.Now().Add(30*time.Second).Unix()))
+}
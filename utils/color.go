@@ -0,0 +1,64 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"regexp"
+	"time"
+)
+
+// ANSI color codes
+const (
+	COLOR_RED    = "\x1b[31m"
+	COLOR_YELLOW = "\x1b[33m"
+	COLOR_RESET  = "\x1b[0m"
+)
+
+var ansiRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// ExpiryColor returns the color to display a role which expires at the given
+// Unix epoch or an empty string if no color should be used
+func ExpiryColor(expires int64, warn, critical time.Duration) string {
+	if expires == 0 {
+		return ""
+	}
+	remain := time.Unix(expires, 0).Sub(Now())
+	switch {
+	case remain <= 0:
+		return ""
+	case remain < critical:
+		return COLOR_RED
+	case remain < warn:
+		return COLOR_YELLOW
+	}
+	return ""
+}
+
+// Colorize wraps the string in the given color
+func Colorize(s, color string) string {
+	if color == "" || s == "" {
+		return s
+	}
+	return color + s + COLOR_RESET
+}
+
+// VisibleLen returns the length of the string without any ANSI color codes
+func VisibleLen(s string) int {
+	return len(ansiRe.ReplaceAllString(s, ""))
+}
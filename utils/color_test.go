@@ -0,0 +1,48 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryColor(t *testing.T) {
+	warn := 15 * time.Minute
+	critical := 5 * time.Minute
+	now := time.Now()
+
+	assert.Equal(t, "", ExpiryColor(0, warn, critical))
+	assert.Equal(t, "", ExpiryColor(now.Add(-time.Minute).Unix(), warn, critical))
+	assert.Equal(t, COLOR_RED, ExpiryColor(now.Add(2*time.Minute).Unix(), warn, critical))
+	assert.Equal(t, COLOR_YELLOW, ExpiryColor(now.Add(10*time.Minute).Unix(), warn, critical))
+	assert.Equal(t, "", ExpiryColor(now.Add(time.Hour).Unix(), warn, critical))
+}
+
+func TestColorize(t *testing.T) {
+	assert.Equal(t, "foo", Colorize("foo", ""))
+	assert.Equal(t, "", Colorize("", COLOR_RED))
+	assert.Equal(t, "\x1b[31mfoo\x1b[0m", Colorize("foo", COLOR_RED))
+
+	assert.Equal(t, 3, VisibleLen(Colorize("foo", COLOR_RED)))
+	assert.Equal(t, 3, VisibleLen("foo"))
+	assert.Equal(t, 0, VisibleLen(""))
+}
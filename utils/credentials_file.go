@@ -0,0 +1,120 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// comment we put in each profile we write so users know when it goes stale
+const CREDENTIALS_EXPIRES_COMMENT = "# aws-sso-cli credentials expire at"
+
+// keys in a profile which we always replace
+var credentialsFileKeys = []string{"aws_access_key_id", "aws_secret_access_key", "aws_session_token"}
+
+// CredentialsProfile is a single [profile] section in ~/.aws/credentials
+type CredentialsProfile struct {
+	Name            string
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+}
+
+func (p CredentialsProfile) lines() []string {
+	return []string{
+		fmt.Sprintf("%s %s", CREDENTIALS_EXPIRES_COMMENT, p.Expires.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("aws_access_key_id = %s", p.AccessKeyId),
+		fmt.Sprintf("aws_secret_access_key = %s", p.SecretAccessKey),
+		fmt.Sprintf("aws_session_token = %s", p.SessionToken),
+	}
+}
+
+// UpdateCredentialsFile returns the contents of an AWS shared credentials file with
+// the credentials of the given profiles replaced or appended.  Other sections, keys
+// and comments are left untouched.
+func UpdateCredentialsFile(data string, profiles []CredentialsProfile) string {
+	byName := map[string]CredentialsProfile{}
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+
+	written := map[string]bool{}
+	out := []string{}
+	inProfile := false
+	lines := strings.Split(data, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			name := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			p, ok := byName[name]
+			inProfile = ok && !written[name]
+			out = append(out, line)
+			if inProfile {
+				out = append(out, p.lines()...)
+				written[name] = true
+			}
+			continue
+		}
+		if inProfile && isCredentialsFileLine(trimmed) {
+			continue // replaced above
+		}
+		out = append(out, line)
+	}
+
+	for _, p := range profiles {
+		if written[p.Name] {
+			continue
+		}
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		out = append(out, fmt.Sprintf("[%s]", p.Name))
+		out = append(out, p.lines()...)
+		written[p.Name] = true
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// isCredentialsFileLine returns true if the line is one we generate
+func isCredentialsFileLine(line string) bool {
+	if strings.HasPrefix(line, CREDENTIALS_EXPIRES_COMMENT) {
+		return true
+	}
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return false
+	}
+	key := strings.TrimSpace(kv[0])
+	for _, k := range credentialsFileKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateCredentialsFile(t *testing.T) {
+	expires := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	profiles := []CredentialsProfile{
+		{
+			Name:            "Data:Admin",
+			AccessKeyId:     "AKID1",
+			SecretAccessKey: "secret1",
+			SessionToken:    "token1",
+			Expires:         expires,
+		},
+		{
+			Name:            "Data:ReadOnly",
+			AccessKeyId:     "AKID2",
+			SecretAccessKey: "secret2",
+			SessionToken:    "token2",
+			Expires:         expires,
+		},
+	}
+
+	existing := `# my static keys
+[default]
+aws_access_key_id = STATIC
+aws_secret_access_key = static
+
+[Data:Admin]
+# keep this comment
+# aws-sso-cli credentials expire at 2022-02-01T00:00:00Z
+aws_access_key_id = OLD
+aws_secret_access_key = old
+aws_session_token = old
+region = us-west-2
+`
+	assert.Equal(t, `# my static keys
+[default]
+aws_access_key_id = STATIC
+aws_secret_access_key = static
+
+[Data:Admin]
+# aws-sso-cli credentials expire at 2022-03-01T12:00:00Z
+aws_access_key_id = AKID1
+aws_secret_access_key = secret1
+aws_session_token = token1
+# keep this comment
+region = us-west-2
+
+[Data:ReadOnly]
+# aws-sso-cli credentials expire at 2022-03-01T12:00:00Z
+aws_access_key_id = AKID2
+aws_secret_access_key = secret2
+aws_session_token = token2
+`, UpdateCredentialsFile(existing, profiles))
+
+	// empty file
+	assert.Equal(t, `[Data:ReadOnly]
+# aws-sso-cli credentials expire at 2022-03-01T12:00:00Z
+aws_access_key_id = AKID2
+aws_secret_access_key = secret2
+aws_session_token = token2
+`, UpdateCredentialsFile("", profiles[1:]))
+
+	// nothing to do
+	assert.Equal(t, existing, UpdateCredentialsFile(existing, []CredentialsProfile{}))
+}
@@ -0,0 +1,114 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// header identifying data encrypted by EncryptWithPassphrase
+var encryptMagic = []byte("aws-sso-enc-v1\n")
+
+const (
+	encryptSaltLen = 16
+	encryptKeyLen  = 32 // AES-256
+
+	// scrypt parameters recommended for interactive use as of 2017
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// passphraseKey derives the AES key from the passphrase via scrypt
+func passphraseKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, encryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptWithPassphrase encrypts the data using AES-256-GCM with a key
+// derived from the passphrase.  The result contains everything except the
+// passphrase needed to decrypt it.
+func EncryptWithPassphrase(data []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return []byte{}, fmt.Errorf("Passphrase must not be empty")
+	}
+
+	salt := make([]byte, encryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return []byte{}, err
+	}
+	gcm, err := passphraseKey(passphrase, salt)
+	if err != nil {
+		return []byte{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return []byte{}, err
+	}
+
+	header := append([]byte{}, encryptMagic...)
+	header = append(header, salt...)
+	header = append(header, nonce...)
+	// authenticate the header too.  Seal into a copy so the additional data
+	// doesn't overlap with the destination.
+	out := append([]byte{}, header...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+// DecryptWithPassphrase decrypts data created by EncryptWithPassphrase
+func DecryptWithPassphrase(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptMagic) {
+		return []byte{}, fmt.Errorf("Unsupported file format")
+	}
+	offset := len(encryptMagic)
+	if len(data) < offset+encryptSaltLen {
+		return []byte{}, fmt.Errorf("Truncated data")
+	}
+	salt := data[offset : offset+encryptSaltLen]
+	offset += encryptSaltLen
+
+	gcm, err := passphraseKey(passphrase, salt)
+	if err != nil {
+		return []byte{}, err
+	}
+	if len(data) < offset+gcm.NonceSize() {
+		return []byte{}, fmt.Errorf("Truncated data")
+	}
+	nonce := data[offset : offset+gcm.NonceSize()]
+	offset += gcm.NonceSize()
+
+	plain, err := gcm.Open(nil, nonce, data[offset:], data[:offset])
+	if err != nil {
+		return []byte{}, fmt.Errorf("Unable to decrypt: invalid passphrase or corrupted data")
+	}
+	return plain, nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptWithPassphrase(t *testing.T) {
+	data := []byte("SSOConfig:\n  Default:\n    SSORegion: us-east-1\n")
+
+	enc, err := EncryptWithPassphrase(data, "correct horse")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(enc), "SSORegion")
+
+	plain, err := DecryptWithPassphrase(enc, "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, data, plain)
+
+	_, err = DecryptWithPassphrase(enc, "battery staple")
+	assert.Error(t, err)
+
+	// tampering with the header or ciphertext is detected
+	bad := append([]byte{}, enc...)
+	bad[len(encryptMagic)] ^= 0xff
+	_, err = DecryptWithPassphrase(bad, "correct horse")
+	assert.Error(t, err)
+
+	bad = append([]byte{}, enc...)
+	bad[len(bad)-1] ^= 0xff
+	_, err = DecryptWithPassphrase(bad, "correct horse")
+	assert.Error(t, err)
+
+	_, err = DecryptWithPassphrase(enc[:10], "correct horse")
+	assert.Error(t, err)
+	_, err = DecryptWithPassphrase([]byte("not encrypted"), "correct horse")
+	assert.Error(t, err)
+
+	_, err = EncryptWithPassphrase(data, "")
+	assert.Error(t, err)
+}
+
+func TestEncryptWithPassphraseHeader(t *testing.T) {
+	data := []byte("SSOConfig:\n  Default:\n    SSORegion: us-east-1\n")
+
+	enc, err := EncryptWithPassphrase(data, "correct horse")
+	assert.NoError(t, err)
+
+	offset := len(encryptMagic)
+	salt := enc[offset : offset+encryptSaltLen]
+	gcm, err := passphraseKey("correct horse", salt)
+	assert.NoError(t, err)
+	offset += encryptSaltLen
+	nonce := enc[offset : offset+gcm.NonceSize()]
+	offset += gcm.NonceSize()
+	header, ciphertext := enc[:offset], enc[offset:]
+
+	// the whole header is the additional data
+	plain, err := gcm.Open(nil, nonce, ciphertext, header)
+	assert.NoError(t, err)
+	assert.Equal(t, data, plain)
+
+	// so the same key & nonce fail to open it with a tampered header
+	bad := append([]byte{}, header...)
+	bad[0] ^= 0xff
+	_, err = gcm.Open(nil, nonce, ciphertext, bad)
+	assert.Error(t, err)
+}
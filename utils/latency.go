@@ -0,0 +1,70 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// LatencyStats are the min/avg/max times to open a TLS connection
+type LatencyStats struct {
+	Samples int
+	Min     time.Duration
+	Avg     time.Duration
+	Max     time.Duration
+}
+
+// MeasureLatency times the TCP connect and TLS handshake to the host:port the
+// given number of times.  No data is sent after the handshake.
+func MeasureLatency(ctx context.Context, addr string, samples int, timeout time.Duration, config *tls.Config) (LatencyStats, error) {
+	stats := LatencyStats{}
+	if samples < 1 {
+		return stats, fmt.Errorf("Invalid number of samples: %d", samples)
+	}
+
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		dialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: timeout},
+			Config:    config,
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return stats, err
+		}
+		d := time.Since(start)
+		conn.Close()
+
+		if stats.Samples == 0 || d < stats.Min {
+			stats.Min = d
+		}
+		if d > stats.Max {
+			stats.Max = d
+		}
+		total += d
+		stats.Samples++
+	}
+	stats.Avg = total / time.Duration(stats.Samples)
+	return stats, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasureLatency(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	config := &tls.Config{RootCAs: pool, ServerName: "example.com", MinVersion: tls.VersionTLS12}
+	addr := strings.TrimPrefix(ts.URL, "https://")
+
+	stats, err := MeasureLatency(context.TODO(), addr, 3, time.Second, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Samples)
+	assert.True(t, stats.Min > 0)
+	assert.True(t, stats.Min <= stats.Avg)
+	assert.True(t, stats.Avg <= stats.Max)
+
+	_, err = MeasureLatency(context.TODO(), addr, 0, time.Second, config)
+	assert.Error(t, err)
+
+	// untrusted certificate
+	_, err = MeasureLatency(context.TODO(), addr, 1, time.Second, &tls.Config{MinVersion: tls.VersionTLS12})
+	assert.Error(t, err)
+}
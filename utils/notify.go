@@ -0,0 +1,92 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+)
+
+// these types & variables make our code easier to unit test
+type commandRunnerFunc func(string, ...string) error
+type webhookPosterFunc func(string, string, io.Reader) (*http.Response, error)
+
+var commandRunner commandRunnerFunc = runCommand
+var webhookPoster webhookPosterFunc = http.Post
+
+// SetNotifyHTTPClient sets the client used to call webhooks so they honor
+// our ProxyUrl and TLS settings
+func SetNotifyHTTPClient(client *http.Client) {
+	webhookPoster = client.Post
+}
+
+func runCommand(name string, args ...string) error {
+	return exec.Command(name, args...).Run() // #nosec
+}
+
+// NotifyMessage is the JSON body sent to a webhook
+type NotifyMessage struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// Notify sends the title & message using the given action
+func Notify(action, webhook, title, message string) error {
+	switch action {
+	case "print":
+		fmt.Fprintf(printWriter, "%s: %s\n", title, message)
+	case "notify-send":
+		if err := commandRunner("notify-send", title, message); err != nil {
+			return fmt.Errorf("Unable to run notify-send: %s", err.Error())
+		}
+	case "osascript":
+		script := fmt.Sprintf("display notification %s with title %s",
+			strconv.Quote(message), strconv.Quote(title))
+		if err := commandRunner("osascript", "-e", script); err != nil {
+			return fmt.Errorf("Unable to run osascript: %s", err.Error())
+		}
+	case "webhook":
+		if webhook == "" {
+			return fmt.Errorf("No webhook URL specified")
+		}
+		body, err := json.Marshal(NotifyMessage{
+			Title:   title,
+			Message: message,
+		})
+		if err != nil {
+			return err
+		}
+		resp, err := webhookPoster(webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("Unable to call webhook: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("Webhook returned %s", resp.Status)
+		}
+	default:
+		return fmt.Errorf("Unknown notify action: '%s'", action)
+	}
+	return nil
+}
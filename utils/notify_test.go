@@ -0,0 +1,102 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	var cmdName string
+	var cmdArgs []string
+	commandRunner = func(name string, args ...string) error {
+		cmdName = name
+		cmdArgs = args
+		return nil
+	}
+	defer func() { commandRunner = runCommand }()
+
+	assert.NoError(t, Notify("notify-send", "", "title", "message"))
+	assert.Equal(t, "notify-send", cmdName)
+	assert.Equal(t, []string{"title", "message"}, cmdArgs)
+
+	assert.NoError(t, Notify("osascript", "", "title", "message"))
+	assert.Equal(t, "osascript", cmdName)
+	assert.Equal(t, []string{"-e", `display notification "message" with title "title"`}, cmdArgs)
+
+	commandRunner = func(name string, args ...string) error {
+		return fmt.Errorf("failed")
+	}
+	assert.Error(t, Notify("notify-send", "", "title", "message"))
+	assert.Error(t, Notify("osascript", "", "title", "message"))
+
+	origPrint := printWriter
+	defer func() { printWriter = origPrint }()
+	printWriter = new(bytes.Buffer)
+	assert.NoError(t, Notify("print", "", "title", "message"))
+	assert.Equal(t, "title: message\n", printWriter.(*bytes.Buffer).String())
+
+	assert.Error(t, Notify("foo", "", "title", "message"))
+	assert.Error(t, Notify("webhook", "", "title", "message"))
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	msg := NotifyMessage{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &msg)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	assert.NoError(t, Notify("webhook", ts.URL, "title", "message"))
+	assert.Equal(t, "title", msg.Title)
+	assert.Equal(t, "message", msg.Message)
+
+	assert.Error(t, Notify("webhook", ts.URL+"/fail", "title", "message"))
+
+	// uses the client we were given
+	origPoster := webhookPoster
+	defer func() { webhookPoster = origPoster }()
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, fmt.Errorf("blocked by test transport")
+		}),
+	}
+	SetNotifyHTTPClient(client)
+	err := Notify("webhook", ts.URL, "title", "message")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "blocked by test transport")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,145 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	PARTITION_AWS      = "aws"
+	PARTITION_GOVCLOUD = "aws-us-gov"
+	PARTITION_CHINA    = "aws-cn"
+)
+
+type partitionHosts struct {
+	Signin    string
+	Console   string
+	DNSSuffix string // for regional service endpoints
+	Region    string // default region for global services like STS
+}
+
+// hostnames for federated console access in each partition
+var partitions = map[string]partitionHosts{
+	PARTITION_AWS: {
+		Signin:    "signin.aws.amazon.com",
+		Console:   "console.aws.amazon.com",
+		DNSSuffix: "amazonaws.com",
+		Region:    "us-east-1",
+	},
+	PARTITION_GOVCLOUD: {
+		Signin:    "signin.amazonaws-us-gov.com",
+		Console:   "console.amazonaws-us-gov.com",
+		DNSSuffix: "amazonaws.com",
+		Region:    "us-gov-west-1",
+	},
+	PARTITION_CHINA: {
+		Signin:    "signin.amazonaws.cn",
+		Console:   "console.amazonaws.cn",
+		DNSSuffix: "amazonaws.com.cn",
+		Region:    "cn-north-1",
+	},
+}
+
+// RegionPartition returns the AWS partition the region belongs to
+func RegionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return PARTITION_GOVCLOUD
+	case strings.HasPrefix(region, "cn-"):
+		return PARTITION_CHINA
+	default:
+		return PARTITION_AWS
+	}
+}
+
+// DetectPartition returns the AWS partition for the AWS SSO instance based
+// on the start URL, falling back to the AWS SSO region
+func DetectPartition(startUrl, region string) string {
+	if u, err := url.Parse(startUrl); err == nil {
+		host := strings.ToLower(u.Hostname())
+		switch {
+		case strings.HasSuffix(host, ".awsapps.cn"):
+			return PARTITION_CHINA
+		case strings.Contains(host, "us-gov"):
+			return PARTITION_GOVCLOUD
+		}
+	}
+	return RegionPartition(region)
+}
+
+// getPartitionHosts returns the hosts for the partition, defaulting to the commercial partition
+func getPartitionHosts(partition string) partitionHosts {
+	if p, ok := partitions[partition]; ok {
+		return p
+	}
+	return partitions[PARTITION_AWS]
+}
+
+// FederationUrl returns the console federation endpoint for the partition
+func FederationUrl(partition string) string {
+	return fmt.Sprintf("https://%s/federation", getPartitionHosts(partition).Signin)
+}
+
+// PartitionRegion returns the default region of the partition, for use when
+// we need to call AWS but have no region configured
+func PartitionRegion(partition string) string {
+	return getPartitionHosts(partition).Region
+}
+
+// ServiceHost returns the hostname of the regional AWS service endpoint
+// (ex: sts, oidc or portal.sso) in the partition
+func ServiceHost(partition, service, region string) string {
+	return fmt.Sprintf("%s.%s.%s", service, region, getPartitionHosts(partition).DNSSuffix)
+}
+
+// ConsoleUrl returns the AWS Console URL for the partition and region
+func ConsoleUrl(partition, region string) string {
+	return ConsoleServiceUrl(partition, region, "")
+}
+
+// ConsoleServiceUrl returns the AWS Console URL for a service in the partition
+// and region.  The service is either the name of the service (ex: ec2) or the
+// path to a specific page (ex: ec2/v2/home#Instances:)
+func ConsoleServiceUrl(partition, region, service string) string {
+	path := strings.TrimPrefix(service, "/")
+	if path == "" {
+		path = "console"
+	}
+
+	fragment := ""
+	if i := strings.Index(path, "#"); i >= 0 {
+		path, fragment = path[:i], path[i:]
+	}
+	if !strings.Contains(path, "/") {
+		path = fmt.Sprintf("%s/home", path)
+	}
+
+	return fmt.Sprintf("https://%s/%s?region=%s%s", getPartitionHosts(partition).Console, path, region, fragment)
+}
+
+// MakeRoleARNPartition creates an IAM Role ARN in the given partition
+func MakeRoleARNPartition(partition string, account int64, name string) string {
+	if partition == "" {
+		partition = PARTITION_AWS
+	}
+	return strings.Replace(MakeRoleARN(account, name), "arn:aws:", fmt.Sprintf("arn:%s:", partition), 1)
+}
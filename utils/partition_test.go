@@ -0,0 +1,83 @@
+package utils
+
+/*
+ * AWS SSO CLI
+ * Copyright (c) 2021-2022 Aaron Turner  <synfinatic at gmail dot com>
+ *
+ * This program is free software: you can redistribute it
+ * and/or modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or with the authors permission any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionPartition(t *testing.T) {
+	assert.Equal(t, PARTITION_AWS, RegionPartition("us-east-1"))
+	assert.Equal(t, PARTITION_AWS, RegionPartition(""))
+	assert.Equal(t, PARTITION_GOVCLOUD, RegionPartition("us-gov-west-1"))
+	assert.Equal(t, PARTITION_CHINA, RegionPartition("cn-north-1"))
+}
+
+func TestPartitionRegion(t *testing.T) {
+	assert.Equal(t, "us-east-1", PartitionRegion(PARTITION_AWS))
+	assert.Equal(t, "us-gov-west-1", PartitionRegion(PARTITION_GOVCLOUD))
+	assert.Equal(t, "cn-north-1", PartitionRegion(PARTITION_CHINA))
+	assert.Equal(t, "us-east-1", PartitionRegion(""))
+}
+
+func TestDetectPartition(t *testing.T) {
+	assert.Equal(t, PARTITION_AWS, DetectPartition("https://d-754545454.awsapps.com/start", "us-east-1"))
+	assert.Equal(t, PARTITION_GOVCLOUD,
+		DetectPartition("https://start.us-gov-home.awsapps.com/directory/d-906704c5a6", "us-east-1"))
+	assert.Equal(t, PARTITION_GOVCLOUD, DetectPartition("https://d-754545454.awsapps.com/start", "us-gov-west-1"))
+	assert.Equal(t, PARTITION_CHINA, DetectPartition("https://d-754545454.awsapps.cn/start", "us-east-1"))
+	assert.Equal(t, PARTITION_CHINA, DetectPartition("", "cn-northwest-1"))
+}
+
+func TestPartitionUrls(t *testing.T) {
+	assert.Equal(t, "https://signin.aws.amazon.com/federation", FederationUrl(PARTITION_AWS))
+	assert.Equal(t, "https://signin.aws.amazon.com/federation", FederationUrl(""))
+	assert.Equal(t, "https://signin.amazonaws-us-gov.com/federation", FederationUrl(PARTITION_GOVCLOUD))
+	assert.Equal(t, "https://signin.amazonaws.cn/federation", FederationUrl(PARTITION_CHINA))
+
+	assert.Equal(t, "https://console.aws.amazon.com/console/home?region=us-east-1",
+		ConsoleUrl(PARTITION_AWS, "us-east-1"))
+	assert.Equal(t, "https://console.amazonaws-us-gov.com/console/home?region=us-gov-west-1",
+		ConsoleUrl(PARTITION_GOVCLOUD, "us-gov-west-1"))
+	assert.Equal(t, "https://console.amazonaws.cn/console/home?region=cn-north-1",
+		ConsoleUrl(PARTITION_CHINA, "cn-north-1"))
+
+	// service deep links use the same region
+	assert.Equal(t, "https://console.aws.amazon.com/ec2/home?region=us-west-2",
+		ConsoleServiceUrl(PARTITION_AWS, "us-west-2", "ec2"))
+	assert.Equal(t, "https://console.aws.amazon.com/ec2/v2/home?region=us-west-2#Instances:",
+		ConsoleServiceUrl(PARTITION_AWS, "us-west-2", "/ec2/v2/home#Instances:"))
+	assert.Equal(t, "https://console.amazonaws-us-gov.com/cloudwatch/home?region=us-gov-east-1#logsV2:",
+		ConsoleServiceUrl(PARTITION_GOVCLOUD, "us-gov-east-1", "cloudwatch#logsV2:"))
+	assert.Equal(t, ConsoleUrl(PARTITION_AWS, "us-east-1"), ConsoleServiceUrl(PARTITION_AWS, "us-east-1", ""))
+}
+
+func TestServiceHost(t *testing.T) {
+	assert.Equal(t, "sts.us-east-1.amazonaws.com", ServiceHost(PARTITION_AWS, "sts", "us-east-1"))
+	assert.Equal(t, "portal.sso.us-gov-west-1.amazonaws.com", ServiceHost(PARTITION_GOVCLOUD, "portal.sso", "us-gov-west-1"))
+	assert.Equal(t, "oidc.cn-north-1.amazonaws.com.cn", ServiceHost(PARTITION_CHINA, "oidc", "cn-north-1"))
+}
+
+func TestMakeRoleARNPartition(t *testing.T) {
+	assert.Equal(t, "arn:aws:iam::000001111111:role/Foo", MakeRoleARNPartition("", 1111111, "Foo"))
+	assert.Equal(t, "arn:aws-us-gov:iam::000001111111:role/Foo", MakeRoleARNPartition(PARTITION_GOVCLOUD, 1111111, "Foo"))
+	assert.Equal(t, "arn:aws-cn:iam::000001111111:role/Foo", MakeRoleARNPartition(PARTITION_CHINA, 1111111, "Foo"))
+}
@@ -19,13 +19,17 @@ package utils
  */
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/atotto/clipboard"
 	log "github.com/sirupsen/logrus"
@@ -57,12 +61,203 @@ type urlOpenerFunc func(string) error
 type urlOpenerWithFunc func(string, string) error
 type clipboardWriterFunc func(string) error
 
+// limit on how many URLs we open in the browser before asking for confirmation
+var maxOpenUrls int = 0 // 0 = unlimited
+var openedUrls int = 0
+var openUrlConfirm func(int) error
+
+// time to wait between opening URLs in the browser so it doesn't drop any
+var openUrlDelay time.Duration = 0
+var openUrlSleep func(time.Duration) = time.Sleep
+
+// how many times to retry opening a URL in the browser and whether to print
+// the URL if it still fails
+var openUrlRetries int = 0
+var openUrlRetryDelay time.Duration = 0
+var openUrlFallback bool = false
+
+// SetOpenUrlLimit sets how many URLs may be opened in the browser before
+// confirm is called with the number of the URL about to be opened.  If
+// confirm returns an error, that URL is not opened.  0 disables the limit.
+func SetOpenUrlLimit(max int, confirm func(int) error) {
+	maxOpenUrls = max
+	openUrlConfirm = confirm
+}
+
+// SetOpenUrlDelay sets how long to wait before opening each URL in the
+// browser after the first.  0 disables the delay.
+func SetOpenUrlDelay(delay time.Duration) {
+	openUrlDelay = delay
+}
+
+// SetOpenUrlRetries sets how many times to retry opening a URL in the browser,
+// waiting delay between each attempt.  If fallback is true, the URL is printed
+// instead of returning an error when the last attempt fails.
+func SetOpenUrlRetries(retries int, delay time.Duration, fallback bool) {
+	openUrlRetries = retries
+	openUrlRetryDelay = delay
+	openUrlFallback = fallback
+}
+
+// checkOpenUrlLimit is called before opening each URL in the browser and
+// waits for the openUrlDelay if we have already opened one
+func checkOpenUrlLimit() error {
+	if maxOpenUrls > 0 && openedUrls+1 > maxOpenUrls {
+		if openUrlConfirm == nil {
+			return fmt.Errorf("Refusing to open more than %d URLs in the browser", maxOpenUrls)
+		}
+		if err := openUrlConfirm(openedUrls + 1); err != nil {
+			return err
+		}
+		maxOpenUrls = 0 // only ask once
+	}
+	if openedUrls > 0 && openUrlDelay > 0 {
+		openUrlSleep(openUrlDelay)
+	}
+	openedUrls++
+	return nil
+}
+
 var urlOpener urlOpenerFunc = open.Run
 var urlOpenerWith urlOpenerWithFunc = open.RunWith
 var clipboardWriter clipboardWriterFunc = clipboard.WriteAll
+var urlActionRunner func(string, string) error = runUrlActionHandler
+var remoteOpenRunner func([]string) error = runRemoteOpen
+
+// command & args for the remote-open action
+var remoteOpenCommand = []string{}
+
+// SetRemoteOpenCommand sets the command used by the remote-open URL action
+// to open URLs in a browser on another host.  See RemoteOpenArgs.
+func SetRemoteOpenCommand(command []string) {
+	remoteOpenCommand = command
+}
+
+// RemoteOpenArgs returns the command & args to run for the remote-open URL
+// action.  Each is a Go template with the URL available as {{ .Url }}
+func RemoteOpenArgs(command []string, url string) ([]string, error) {
+	if len(command) == 0 {
+		return []string{}, fmt.Errorf("The remote-open URL action requires RemoteOpenCommand to be set in the config")
+	}
+
+	args := []string{}
+	for _, arg := range command {
+		templ, err := template.New("arg").Parse(arg)
+		if err != nil {
+			return []string{}, fmt.Errorf("Invalid RemoteOpenCommand argument '%s': %s", arg, err.Error())
+		}
+		buf := new(bytes.Buffer)
+		if err = templ.Execute(buf, map[string]string{"Url": url}); err != nil {
+			return []string{}, fmt.Errorf("Invalid RemoteOpenCommand argument '%s': %s", arg, err.Error())
+		}
+		args = append(args, buf.String())
+	}
+	return args, nil
+}
+
+// user defined URL actions: name => path to handler
+var customUrlActions = map[string]string{}
+
+// SetUrlActions registers user defined URL actions which run the given
+// executable with the URL.  Built-in actions take precedence.
+func SetUrlActions(actions map[string]string) {
+	customUrlActions = map[string]string{}
+	for name, handler := range actions {
+		if !IsBuiltinUrlAction(name) {
+			customUrlActions[name] = handler
+		}
+	}
+}
+
+// IsBuiltinUrlAction returns true if the URL action is provided by aws-sso
+func IsBuiltinUrlAction(action string) bool {
+	switch action {
+	case "clip", "open", "print", "remote-open":
+		return true
+	}
+	return false
+}
+
+// ParseUrlAction splits a comma separated list of URL actions (ex: clip,print)
+// and verifies each one is valid
+func ParseUrlAction(action string) ([]string, error) {
+	actions := []string{}
+	for _, a := range strings.Split(action, ",") {
+		a = strings.TrimSpace(a)
+		if _, ok := customUrlActions[a]; ok || IsBuiltinUrlAction(a) {
+			actions = append(actions, a)
+		} else {
+			return []string{}, fmt.Errorf("Unknown --url-action option: '%s'", a)
+		}
+	}
+	return actions, nil
+}
 
-// Prints, opens or copies to clipboard the given URL
+// UrlActionIncludes returns true if the URL action (which may be a list)
+// includes the given action
+func UrlActionIncludes(action, want string) bool {
+	for _, a := range strings.Split(action, ",") {
+		if strings.TrimSpace(a) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// UrlActionOverride returns the URL action selected by the --open, --print or
+// --clip flags of a command or the given action if none of them were set
+func UrlActionOverride(open, print, clip bool, action string) string {
+	switch {
+	case open:
+		return "open"
+	case print:
+		return "print"
+	case clip:
+		return "clip"
+	}
+	return action
+}
+
+// Prints, opens or copies to clipboard the given URL.  The action may be a
+// comma separated list of actions which are run in order until one fails.
 func HandleUrl(action, browser, url, pre, post string) error {
+	return handleUrl(action, browser, url, pre, post, false)
+}
+
+// HandleUrlPrivate is the same as HandleUrl, but opens the URL in a private
+// or incognito browser window if possible
+func HandleUrlPrivate(action, browser, url, pre, post string) error {
+	return handleUrl(action, browser, url, pre, post, true)
+}
+
+func handleUrl(action, browser, url, pre, post string, private bool) error {
+	actions, err := ParseUrlAction(action)
+	if err != nil {
+		return err
+	}
+	// no need to fall back to printing the URL if we are going to anyways
+	printsUrl := false
+	for _, a := range actions {
+		printsUrl = printsUrl || a == "print"
+	}
+	for _, a := range actions {
+		err = handleUrlAction(a, browser, url, pre, post, private, printsUrl)
+		if err != nil && a == "open" && printsUrl && openUrlFallback {
+			// our print action shows the URL instead
+			log.Warnf("%s", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleUrlAction runs a single URL action.  If printsUrl is true, the
+// print action is also being run, so a failed open does not print the URL
+// and returns the error.
+func handleUrlAction(action, browser, url, pre, post string, private, printsUrl bool) error {
 	var err error
 	switch action {
 	case "clip":
@@ -75,25 +270,86 @@ func HandleUrl(action, browser, url, pre, post string) error {
 	case "print":
 		fmt.Fprintf(printWriter, "%s%s%s", pre, url, post)
 	case "open":
-		switch browser {
-		case "":
-			err = urlOpener(url)
-			browser = "default browser"
-		default:
-			err = urlOpenerWith(url, browser)
+		if err = checkOpenUrlLimit(); err != nil {
+			return err
+		}
+		flag := ""
+		if private {
+			if flag, err = PrivateBrowserFlag(browser); err != nil {
+				log.Warnf("%s", err.Error())
+			}
+		}
+		name := browser
+		if name == "" {
+			name = "default browser"
+		}
+		for attempt := 0; ; attempt++ {
+			switch {
+			case browser == "":
+				err = urlOpener(url)
+			case flag != "":
+				err = urlOpenerArgs(url, browser, flag)
+			default:
+				err = urlOpenerWith(url, browser)
+			}
+			if err == nil || attempt >= openUrlRetries {
+				break
+			}
+			log.Debugf("Unable to open URL with %s, retrying: %s", name, err.Error())
+			openUrlSleep(openUrlRetryDelay)
 		}
 		if err != nil {
-			err = fmt.Errorf("Unable to open URL with %s: %s", browser, err.Error())
+			err = fmt.Errorf("Unable to open URL with %s: %s", name, err.Error())
+			if openUrlFallback && !printsUrl {
+				log.Warnf("%s", err.Error())
+				fmt.Fprintf(printWriter, "%s%s%s", pre, url, post)
+				err = nil
+			}
 		} else {
-			log.Infof("Opening URL in %s.\n", browser)
+			log.Infof("Opening URL in %s.\n", name)
+		}
+	case "remote-open":
+		if err = checkOpenUrlLimit(); err != nil {
+			return err
+		}
+		args, err := RemoteOpenArgs(remoteOpenCommand, url)
+		if err != nil {
+			return err
+		}
+		if err = remoteOpenRunner(args); err != nil {
+			return fmt.Errorf("Unable to open URL via %s: %s", args[0], err.Error())
 		}
+		log.Infof("Sent URL to %s.\n", args[0])
 	default:
-		err = fmt.Errorf("Unknown --url-action option: '%s'", action)
+		handler := customUrlActions[action]
+		if err = urlActionRunner(handler, url); err != nil {
+			err = fmt.Errorf("Unable to handle URL with %s action: %s", action, err.Error())
+		} else {
+			log.Infof("Sent URL to %s.\n", handler)
+		}
 	}
 
 	return err
 }
 
+// runUrlActionHandler runs the handler with the URL as the only argument
+// and also on stdin
+func runUrlActionHandler(handler, url string) error {
+	cmd := exec.Command(handler, url)
+	cmd.Stdin = strings.NewReader(url + "\n")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runRemoteOpen runs the remote-open command with any output sent to stderr
+func runRemoteOpen(args []string) error {
+	cmd := exec.Command(args[0], args[1:]...) // #nosec
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 // ParseRoleARN parses an ARN representing a role in long or short format
 func ParseRoleARN(arn string) (int64, string, error) {
 	s := strings.Split(arn, ":")
@@ -176,24 +432,197 @@ func ParseTimeString(t string) (int64, error) {
 	return i.Unix(), nil
 }
 
+// Remaining returns how much time remains until the given Unix epoch time
+func Remaining(expires int64) time.Duration {
+	return time.Unix(expires, 0).Sub(Now())
+}
+
+// TimeRemainFormat controls how TimeRemain & TimeSince format a duration.
+// Labels are appended directly to the number, so long form labels should
+// include a leading space.  The plural labels default to the singular ones.
+type TimeRemainFormat struct {
+	Hour      string `koanf:"Hour" yaml:"Hour,omitempty"`           // label after the number of hours
+	Hours     string `koanf:"Hours" yaml:"Hours,omitempty"`         // plural label after the number of hours
+	Minute    string `koanf:"Minute" yaml:"Minute,omitempty"`       // label after the number of minutes
+	Minutes   string `koanf:"Minutes" yaml:"Minutes,omitempty"`     // plural label after the number of minutes
+	Separator string `koanf:"Separator" yaml:"Separator,omitempty"` // between the hours and minutes
+	Pad       string `koanf:"Pad" yaml:"Pad,omitempty"`             // prefix for durations without hours to align columns
+	Expired   string `koanf:"Expired" yaml:"Expired,omitempty"`     // returned by TimeRemainWithFormat when no time remains
+	Long      bool   `koanf:"Long" yaml:"Long,omitempty"`           // omit units which are zero
+}
+
+// user provided TimeFormat which overrides the defaults used by TimeRemain & TimeSince
+var timeRemainFormat TimeRemainFormat
+
+// SetTimeRemainFormat sets the format used by TimeRemain & TimeSince.  Empty
+// fields keep the value of the short format, or the long format if Long is set.
+func SetTimeRemainFormat(f TimeRemainFormat) {
+	timeRemainFormat = f
+}
+
+// timeFormat returns the format to use for TimeRemain & TimeSince
+func timeFormat(space bool) TimeRemainFormat {
+	f := DefaultTimeRemainFormat(space)
+	if timeRemainFormat.Long {
+		f = LongTimeRemainFormat()
+	}
+
+	f.Hour = firstNonEmpty(timeRemainFormat.Hour, f.Hour)
+	f.Hours = firstNonEmpty(timeRemainFormat.Hours, f.Hours)
+	f.Minute = firstNonEmpty(timeRemainFormat.Minute, f.Minute)
+	f.Minutes = firstNonEmpty(timeRemainFormat.Minutes, f.Minutes)
+	f.Separator = firstNonEmpty(timeRemainFormat.Separator, f.Separator)
+	f.Expired = firstNonEmpty(timeRemainFormat.Expired, f.Expired)
+	if space {
+		f.Pad = firstNonEmpty(timeRemainFormat.Pad, f.Pad)
+	}
+	return f
+}
+
+// firstNonEmpty returns the first of the strings which is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// DefaultTimeRemainFormat returns the MMm or HHhMMm format.  If space is true,
+// then the hours and minutes are separated and durations without hours are
+// padded so they align when printed in a column.
+func DefaultTimeRemainFormat(space bool) TimeRemainFormat {
+	f := TimeRemainFormat{
+		Hour:    "h",
+		Minute:  "m",
+		Expired: "Expired",
+	}
+	if space {
+		f.Separator = " "
+		f.Pad = "   "
+	}
+	return f
+}
+
+// LongTimeRemainFormat returns the English long form, ex: 5 hours 5 minutes
+func LongTimeRemainFormat() TimeRemainFormat {
+	return TimeRemainFormat{
+		Hour:      " hour",
+		Hours:     " hours",
+		Minute:    " minute",
+		Minutes:   " minutes",
+		Separator: " ",
+		Expired:   "Expired",
+		Long:      true,
+	}
+}
+
+// Format returns the duration rounded to the minute
+func (f TimeRemainFormat) Format(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int64(d / time.Hour)
+	minutes := int64((d % time.Hour) / time.Minute)
+
+	hour := f.label(hours, f.Hour, f.Hours)
+	minute := f.label(minutes, f.Minute, f.Minutes)
+
+	if f.Long {
+		switch {
+		case hours > 0 && minutes > 0:
+			return fmt.Sprintf("%d%s%s%d%s", hours, hour, f.Separator, minutes, minute)
+		case hours > 0:
+			return fmt.Sprintf("%d%s", hours, hour)
+		default:
+			return fmt.Sprintf("%d%s", minutes, minute)
+		}
+	}
+
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%d%s%s%d%s", hours, hour, f.Separator, minutes, minute)
+	case minutes > 0:
+		return fmt.Sprintf("%s%d%s", f.Pad, minutes, minute)
+	default:
+		return f.Pad
+	}
+}
+
+// label returns the singular or plural label for the value
+func (f TimeRemainFormat) label(value int64, singular, plural string) string {
+	if value != 1 && plural != "" {
+		return plural
+	}
+	return singular
+}
+
 // Returns the MMm or HHhMMm or 'Expired' if no time remains
 func TimeRemain(expires int64, space bool) (string, error) {
-	d := time.Until(time.Unix(expires, 0))
+	return TimeRemainWithFormat(expires, timeFormat(space)), nil
+}
+
+// TimeRemainWithFormat returns the time remaining using the given format
+func TimeRemainWithFormat(expires int64, f TimeRemainFormat) string {
+	d := Remaining(expires)
 	if d <= 0 {
-		return "Expired", nil
+		return f.Expired
 	}
+	return f.Format(d)
+}
 
-	s := strings.Replace(d.Round(time.Minute).String(), "0s", "", 1)
-	if space {
-		if strings.Contains(s, "h") {
-			s = strings.Replace(s, "h", "h ", 1)
-		} else {
-			s = fmt.Sprintf("   %s", s)
+// Returns the MMm or HHhMMm since the given time or an empty string if the time is zero
+func TimeSince(t int64, space bool) (string, error) {
+	if t == 0 {
+		return "", nil
+	}
+
+	d := time.Since(time.Unix(t, 0))
+	if d < 0 {
+		return "", fmt.Errorf("Time %d is in the future", t)
+	}
+	return formatDuration(d, space), nil
+}
+
+// ParseSince parses either a Go duration (ex: 24h) or number of days (ex: 7d)
+// which is relative to now or an absolute RFC3339 time and returns the Unix epoch
+func ParseSince(since string, now time.Time) (int64, error) {
+	if strings.HasSuffix(since, "d") {
+		if days, err := strconv.ParseInt(strings.TrimSuffix(since, "d"), 10, 64); err == nil {
+			if days < 0 {
+				return 0, fmt.Errorf("Invalid duration %s: must be positive", since)
+			}
+			return now.AddDate(0, 0, -int(days)).Unix(), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(since); err == nil {
+		if d < 0 {
+			return 0, fmt.Errorf("Invalid duration %s: must be positive", since)
 		}
+		return now.Add(-d).Unix(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time %s: must be a duration (ex: 24h, 7d) or RFC3339", since)
 	}
+	return t.Unix(), nil
+}
 
-	// Just return the number of MMm or HHhMMm
-	return s, nil
+// Truncate shortens the string to at most max characters, ending with an
+// ellipsis if it was truncated
+func Truncate(s string, max int) string {
+	r := []rune(s)
+	if max < 1 || len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
+
+// formatDuration returns the duration rounded to the minute as MMm or HHhMMm
+// unless overridden via SetTimeRemainFormat
+func formatDuration(d time.Duration, space bool) string {
+	return timeFormat(space).Format(d)
 }
 
 // AccountIdToString returns a string version of AWS AccountID
@@ -204,6 +633,25 @@ func AccountIdToString(a int64) (string, error) {
 	return fmt.Sprintf("%012d", a), nil
 }
 
+// AccountIdToMaskedString returns a string version of AWS AccountID with
+// all but the last 4 digits replaced with `x`
+func AccountIdToMaskedString(a int64) (string, error) {
+	s, err := AccountIdToString(a)
+	if err != nil {
+		return "", err
+	}
+	return strings.Repeat("x", len(s)-4) + s[len(s)-4:], nil
+}
+
+// MakeMaskedRoleARN is like MakeRoleARN, but masks the AccountID
+func MakeMaskedRoleARN(account int64, name string) string {
+	a, err := AccountIdToMaskedString(account)
+	if err != nil {
+		log.WithError(err).Panicf("Unable to MakeMaskedRoleARN")
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s", a, name)
+}
+
 // AccountIdToInt64 returns an int64 version of AWS AccountID in base10
 func AccountIdToInt64(a string) (int64, error) {
 	x, err := strconv.ParseInt(a, 10, 64)
@@ -215,3 +663,67 @@ func AccountIdToInt64(a string) (int64, error) {
 	}
 	return x, nil
 }
+
+// EditDistance returns the Levenshtein distance between the two strings
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// NormalizeName converts a name into a form which is safe to use in shells,
+// profile names, etc: lowercase with runs of whitespace replaced by a single
+// `-` and all other characters which are not a-z, 0-9 or `-` removed
+func NormalizeName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case r == '-' || unicode.IsSpace(r):
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// NormalizeIdentifier converts a name into a valid Terraform/HCL identifier:
+// the NormalizeName with `_` instead of `-` which never starts with a digit
+func NormalizeIdentifier(name string) string {
+	id := strings.ReplaceAll(NormalizeName(name), "-", "_")
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		id = "_" + id
+	}
+	return id
+}
@@ -147,6 +147,23 @@ func (suite *UtilsTestSuite) TestAccountToString() {
 	assert.Error(t, err)
 }
 
+func (suite *UtilsTestSuite) TestAccountToMaskedString() {
+	t := suite.T()
+
+	a, err := AccountIdToMaskedString(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "xxxxxxxx0000", a)
+
+	a, err = AccountIdToMaskedString(258234615182)
+	assert.NoError(t, err)
+	assert.Equal(t, "xxxxxxxx5182", a)
+
+	_, err = AccountIdToMaskedString(-1)
+	assert.Error(t, err)
+
+	assert.Equal(t, "arn:aws:iam::xxxxxxxx1111:role/Foo", MakeMaskedRoleARN(11111, "Foo"))
+}
+
 func (suite *UtilsTestSuite) TestAccountToInt64() {
 	t := suite.T()
 
@@ -232,6 +249,315 @@ func (suite *UtilsTestSuite) TestHandleUrl() {
 
 	clipboardWriter = testUrlOpenerError
 	assert.Error(t, HandleUrl("clip", "", "url", "pre", "post"))
+
+	// multiple actions run in order and stop at the first error
+	clipboardWriter = testClipboardWriter
+	printWriter = new(bytes.Buffer)
+	checkValue = ""
+	assert.NoError(t, HandleUrl("clip,print", "", "multi-url", "", ""))
+	assert.Equal(t, "multi-url", checkValue)
+	assert.Equal(t, "multi-url", printWriter.(*bytes.Buffer).String())
+
+	clipboardWriter = testUrlOpenerError
+	printWriter = new(bytes.Buffer)
+	assert.Error(t, HandleUrl("clip,print", "", "multi-url", "", ""))
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+
+	// invalid actions are caught before doing anything
+	printWriter = new(bytes.Buffer)
+	assert.Error(t, HandleUrl("print,foo", "", "multi-url", "", ""))
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+}
+
+func (suite *UtilsTestSuite) TestUrlActionOverride() {
+	t := suite.T()
+	origOpener, origClip, origPrint := urlOpener, clipboardWriter, printWriter
+	defer func() {
+		urlOpener, clipboardWriter, printWriter = origOpener, origClip, origPrint
+	}()
+
+	assert.Equal(t, "remote-open", UrlActionOverride(false, false, false, "remote-open"))
+	assert.Equal(t, "", UrlActionOverride(false, false, false, ""))
+
+	urlOpener = testUrlOpener
+	clipboardWriter = testClipboardWriter
+
+	// each flag dispatches to its handler instead of the default action
+	printWriter = new(bytes.Buffer)
+	checkValue = ""
+	assert.NoError(t, HandleUrl(UrlActionOverride(true, false, false, "print"), "", "open-url", "pre", "post"))
+	assert.Equal(t, "open-url", checkValue)
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+
+	checkValue = ""
+	assert.NoError(t, HandleUrl(UrlActionOverride(false, true, false, "open"), "", "print-url", "pre", "post"))
+	assert.Equal(t, "", checkValue)
+	assert.Equal(t, "preprint-urlpost", printWriter.(*bytes.Buffer).String())
+
+	printWriter = new(bytes.Buffer)
+	assert.NoError(t, HandleUrl(UrlActionOverride(false, false, true, "open"), "", "clip-url", "pre", "post"))
+	assert.Equal(t, "clip-url", checkValue)
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+}
+
+func (suite *UtilsTestSuite) TestOpenUrlLimit() {
+	t := suite.T()
+	origOpener := urlOpener
+	defer func() {
+		urlOpener = origOpener
+		SetOpenUrlLimit(0, nil)
+		openedUrls = 0
+	}()
+	urlOpener = testUrlOpener
+	openedUrls = 0
+
+	// refuse past the limit
+	asked := 0
+	SetOpenUrlLimit(2, func(count int) error {
+		asked++
+		assert.Equal(t, 3, count)
+		return fmt.Errorf("Aborted")
+	})
+	assert.NoError(t, HandleUrl("open", "", "url1", "", ""))
+	assert.NoError(t, HandleUrl("open", "", "url2", "", ""))
+	assert.Error(t, HandleUrl("open", "", "url3", "", ""))
+	assert.Equal(t, 1, asked)
+	assert.Equal(t, "url2", checkValue)
+
+	// other actions are not limited
+	printWriter = new(bytes.Buffer)
+	assert.NoError(t, HandleUrl("print", "", "url4", "", ""))
+
+	// only ask once
+	SetOpenUrlLimit(2, func(count int) error {
+		asked++
+		return nil
+	})
+	assert.NoError(t, HandleUrl("open", "", "url3", "", ""))
+	assert.NoError(t, HandleUrl("open", "", "url4", "", ""))
+	assert.Equal(t, 2, asked)
+
+	// no confirm function
+	openedUrls = 0
+	SetOpenUrlLimit(1, nil)
+	assert.NoError(t, HandleUrl("open", "", "url1", "", ""))
+	assert.Error(t, HandleUrl("open", "", "url2", "", ""))
+}
+
+func (suite *UtilsTestSuite) TestOpenUrlDelay() {
+	t := suite.T()
+	origOpener := urlOpener
+	origSleep := openUrlSleep
+	defer func() {
+		urlOpener = origOpener
+		openUrlSleep = origSleep
+		SetOpenUrlDelay(0)
+		openedUrls = 0
+	}()
+	urlOpener = testUrlOpener
+	slept := []time.Duration{}
+	openUrlSleep = func(d time.Duration) {
+		slept = append(slept, d)
+	}
+
+	// no delay by default
+	openedUrls = 0
+	assert.NoError(t, HandleUrl("open", "", "url1", "", ""))
+	assert.NoError(t, HandleUrl("open", "", "url2", "", ""))
+	assert.Empty(t, slept)
+
+	// only wait before the second and later URLs
+	openedUrls = 0
+	SetOpenUrlDelay(500 * time.Millisecond)
+	assert.NoError(t, HandleUrl("open", "", "url1", "", ""))
+	assert.Empty(t, slept)
+	assert.NoError(t, HandleUrl("open", "", "url2", "", ""))
+	assert.NoError(t, HandleUrl("open", "", "url3", "", ""))
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}, slept)
+
+	// printing URLs is never delayed
+	printWriter = new(bytes.Buffer)
+	assert.NoError(t, HandleUrl("print", "", "url4", "", ""))
+	assert.Len(t, slept, 2)
+}
+
+func (suite *UtilsTestSuite) TestOpenUrlRetries() {
+	t := suite.T()
+	origOpener := urlOpener
+	origSleep := openUrlSleep
+	origPrint := printWriter
+	defer func() {
+		urlOpener = origOpener
+		openUrlSleep = origSleep
+		printWriter = origPrint
+		SetOpenUrlRetries(0, 0, false)
+		openedUrls = 0
+	}()
+	slept := []time.Duration{}
+	openUrlSleep = func(d time.Duration) {
+		slept = append(slept, d)
+	}
+
+	// succeed on the last retry
+	attempts := 0
+	urlOpener = func(url string) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("browser is starting")
+		}
+		return nil
+	}
+	SetOpenUrlRetries(2, 250*time.Millisecond, true)
+	printWriter = new(bytes.Buffer)
+	assert.NoError(t, HandleUrl("open", "", "url1", "pre", "post"))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, slept)
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+
+	// fall back to printing the URL
+	attempts = 0
+	urlOpener = testUrlOpenerError
+	assert.NoError(t, HandleUrl("open", "", "url2", "pre", "post"))
+	assert.Equal(t, "preurl2post", printWriter.(*bytes.Buffer).String())
+
+	// don't print the URL twice
+	printWriter = new(bytes.Buffer)
+	assert.NoError(t, HandleUrl("open,print", "", "url3", "", ""))
+	assert.Equal(t, "url3", printWriter.(*bytes.Buffer).String())
+
+	// only a printed URL makes a failed open a success
+	printWriter = new(bytes.Buffer)
+	assert.Error(t, handleUrlAction("open", "", "url3", "", "", false, true))
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+
+	// --no-fallback
+	SetOpenUrlRetries(1, 0, false)
+	printWriter = new(bytes.Buffer)
+	assert.Error(t, HandleUrl("open", "", "url4", "", ""))
+	assert.Equal(t, "", printWriter.(*bytes.Buffer).String())
+}
+
+func (suite *UtilsTestSuite) TestCustomUrlActions() {
+	t := suite.T()
+	origRunner := urlActionRunner
+	origPrint := printWriter
+	defer func() {
+		urlActionRunner = origRunner
+		printWriter = origPrint
+		SetUrlActions(map[string]string{})
+	}()
+
+	var handled, handledUrl string
+	urlActionRunner = func(handler, url string) error {
+		handled, handledUrl = handler, url
+		if handler == "/bin/fail" {
+			return fmt.Errorf("exit status 1")
+		}
+		return nil
+	}
+
+	assert.Error(t, HandleUrl("post", "", "url", "", ""))
+
+	SetUrlActions(map[string]string{
+		"post":  "/usr/local/bin/post-url",
+		"fail":  "/bin/fail",
+		"print": "/bin/false", // built-in actions take precedence
+	})
+	assert.NoError(t, HandleUrl("post", "", "some-url", "", ""))
+	assert.Equal(t, "/usr/local/bin/post-url", handled)
+	assert.Equal(t, "some-url", handledUrl)
+
+	printWriter = new(bytes.Buffer)
+	handled = ""
+	assert.NoError(t, HandleUrl("print", "", "print-url", "", ""))
+	assert.Equal(t, "", handled)
+	assert.Equal(t, "print-url", printWriter.(*bytes.Buffer).String())
+
+	assert.Error(t, HandleUrl("fail,print", "", "url", "", ""))
+}
+
+func (suite *UtilsTestSuite) TestRemoteOpen() {
+	t := suite.T()
+	origRunner := remoteOpenRunner
+	defer func() {
+		remoteOpenRunner = origRunner
+		SetRemoteOpenCommand([]string{})
+	}()
+
+	var ran []string
+	remoteOpenRunner = func(args []string) error {
+		ran = args
+		return nil
+	}
+
+	// must be configured
+	err := HandleUrl("remote-open", "", "url", "", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "RemoteOpenCommand")
+	assert.Nil(t, ran)
+
+	SetRemoteOpenCommand([]string{"ssh", "laptop", "open '{{ .Url }}'"})
+	assert.NoError(t, HandleUrl("remote-open", "", "https://example.com/?a=b", "", ""))
+	assert.Equal(t, []string{"ssh", "laptop", "open 'https://example.com/?a=b'"}, ran)
+
+	remoteOpenRunner = func(args []string) error {
+		return fmt.Errorf("exit status 255")
+	}
+	assert.Error(t, HandleUrl("remote-open", "", "url", "", ""))
+
+	_, err = RemoteOpenArgs([]string{"{{ .Url"}, "url")
+	assert.Error(t, err)
+}
+
+func (suite *UtilsTestSuite) TestParseUrlAction() {
+	t := suite.T()
+
+	a, err := ParseUrlAction("open")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"open"}, a)
+
+	a, err = ParseUrlAction("clip, print")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"clip", "print"}, a)
+
+	_, err = ParseUrlAction("clip+print")
+	assert.Error(t, err)
+	_, err = ParseUrlAction("")
+	assert.Error(t, err)
+
+	assert.True(t, UrlActionIncludes("clip,print", "print"))
+	assert.True(t, UrlActionIncludes("open", "open"))
+	assert.False(t, UrlActionIncludes("clip,print", "open"))
+}
+
+func (suite *UtilsTestSuite) TestEditDistance() {
+	t := suite.T()
+
+	assert.Equal(t, 0, EditDistance("123456789012", "123456789012"))
+	assert.Equal(t, 1, EditDistance("123456789012", "123456789013"))
+	assert.Equal(t, 2, EditDistance("123456789012", "123456789021"))
+	assert.Equal(t, 3, EditDistance("kitten", "sitting"))
+	assert.Equal(t, 5, EditDistance("", "hello"))
+	assert.Equal(t, 5, EditDistance("hello", ""))
+}
+
+func (suite *UtilsTestSuite) TestNormalizeName() {
+	t := suite.T()
+
+	assert.Equal(t, "ourcompany-control-tower-playground", NormalizeName("OurCompany Control Tower Playground"))
+	assert.Equal(t, "prod-us", NormalizeName("  Prod 🚀 -- US  "))
+	assert.Equal(t, "dev-team", NormalizeName("Dev-Team"))
+	assert.Equal(t, "devteama", NormalizeName("dev.team_a"))
+	assert.Equal(t, "", NormalizeName("🚀"))
+}
+
+func (suite *UtilsTestSuite) TestNormalizeIdentifier() {
+	t := suite.T()
+
+	assert.Equal(t, "ourcompany_control_tower_playground", NormalizeIdentifier("OurCompany Control Tower Playground"))
+	assert.Equal(t, "prod_us", NormalizeIdentifier("  Prod 🚀 -- US  "))
+	assert.Equal(t, "_123_sandbox", NormalizeIdentifier("123 Sandbox"))
+	assert.Equal(t, "_", NormalizeIdentifier("🚀"))
 }
 
 func (suite *UtilsTestSuite) TestParseTimeString() {
@@ -269,3 +595,114 @@ func (suite *UtilsTestSuite) TestTimeRemain() {
 	assert.NoError(t, e)
 	assert.Equal(t, "5h5m", x)
 }
+
+func (suite *UtilsTestSuite) TestTimeRemainFormat() {
+	t := suite.T()
+
+	short := DefaultTimeRemainFormat(false)
+	assert.Equal(t, "5h0m", short.Format(5*time.Hour))
+	assert.Equal(t, "26h3m", short.Format(26*time.Hour+3*time.Minute))
+	assert.Equal(t, "1m", short.Format(40*time.Second))
+	assert.Equal(t, "", short.Format(20*time.Second))
+
+	long := LongTimeRemainFormat()
+	assert.Equal(t, "5 hours 5 minutes", long.Format(5*time.Hour+5*time.Minute))
+	assert.Equal(t, "1 hour", long.Format(time.Hour))
+	assert.Equal(t, "1 hour 1 minute", long.Format(61*time.Minute))
+	assert.Equal(t, "0 minutes", long.Format(0))
+
+	custom := TimeRemainFormat{
+		Hour:      " Std.",
+		Minute:    " Min.",
+		Separator: ", ",
+		Expired:   "Abgelaufen",
+	}
+	assert.Equal(t, "2 Std., 30 Min.", custom.Format(150*time.Minute))
+	assert.Equal(t, "Abgelaufen", TimeRemainWithFormat(0, custom))
+
+	x := TimeRemainWithFormat(time.Now().Add(5*time.Hour+5*time.Minute).Unix(), long)
+	assert.Equal(t, "5 hours 5 minutes", x)
+
+	// TimeRemain & TimeSince use the configured format
+	defer SetTimeRemainFormat(TimeRemainFormat{})
+	SetTimeRemainFormat(TimeRemainFormat{Long: true})
+	x, err := TimeRemain(time.Now().Add(5*time.Hour+5*time.Minute).Unix(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "5 hours 5 minutes", x)
+
+	SetTimeRemainFormat(TimeRemainFormat{Hour: " Std.", Minute: " Min.", Expired: "Abgelaufen"})
+	x, err = TimeRemain(time.Now().Add(150*time.Minute).Unix(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "2 Std.30 Min.", x)
+	x, err = TimeRemain(time.Now().Add(150*time.Minute).Unix(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "2 Std. 30 Min.", x)
+	x, err = TimeRemain(0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Abgelaufen", x)
+	x, err = TimeSince(time.Now().Add(-10*time.Minute).Unix(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "10 Min.", x)
+}
+
+func (suite *UtilsTestSuite) TestTimeSince() {
+	t := suite.T()
+
+	x, e := TimeSince(0, false)
+	assert.NoError(t, e)
+	assert.Equal(t, "", x)
+
+	d, _ := time.ParseDuration("5m")
+	past := time.Now().Add(-d)
+	x, e = TimeSince(past.Unix(), true)
+	assert.NoError(t, e)
+	assert.Equal(t, "   5m", x)
+
+	d, _ = time.ParseDuration("5h5m")
+	past = time.Now().Add(-d)
+	x, e = TimeSince(past.Unix(), false)
+	assert.NoError(t, e)
+	assert.Equal(t, "5h5m", x)
+
+	_, e = TimeSince(time.Now().Add(d).Unix(), false)
+	assert.Error(t, e)
+}
+
+func (suite *UtilsTestSuite) TestTruncate() {
+	t := suite.T()
+
+	assert.Equal(t, "hello", Truncate("hello", 5))
+	assert.Equal(t, "hell…", Truncate("hello world", 5))
+	assert.Equal(t, "hello world", Truncate("hello world", 0))
+	assert.Equal(t, "", Truncate("", 5))
+}
+
+func (suite *UtilsTestSuite) TestParseSince() {
+	t := suite.T()
+	now := time.Date(2022, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	x, e := ParseSince("24h", now)
+	assert.NoError(t, e)
+	assert.Equal(t, now.Add(-24*time.Hour).Unix(), x)
+
+	x, e = ParseSince("90m", now)
+	assert.NoError(t, e)
+	assert.Equal(t, now.Add(-90*time.Minute).Unix(), x)
+
+	x, e = ParseSince("7d", now)
+	assert.NoError(t, e)
+	assert.Equal(t, time.Date(2022, 1, 25, 12, 0, 0, 0, time.UTC).Unix(), x)
+
+	_, e = ParseSince("-7d", now)
+	assert.Error(t, e)
+
+	x, e = ParseSince("2022-01-31T08:00:00Z", now)
+	assert.NoError(t, e)
+	assert.Equal(t, time.Date(2022, 1, 31, 8, 0, 0, 0, time.UTC).Unix(), x)
+
+	_, e = ParseSince("-1h", now)
+	assert.Error(t, e)
+
+	_, e = ParseSince("yesterday", now)
+	assert.Error(t, e)
+}